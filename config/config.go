@@ -24,6 +24,16 @@ type Config struct {
 	Auth         AuthConfig
 	Redis        RedisConfig
 	RateLimiter  RateLimiterConfig
+	Events       EventsConfig
+	Ballot       BallotConfig
+	Invite       InviteConfig
+	Tags         TagsConfig
+	Trending     TrendingConfig
+	Storage      StorageConfig
+	Uploads      UploadsConfig
+	Mail         MailConfig
+	Verification VerificationConfig
+	Reset        ResetConfig
 }
 
 // All configuration structs now use exported fields
@@ -40,10 +50,13 @@ type AuthConfig struct {
 	Token TokenConfig
 }
 
+// TokenConfig signs access tokens, which expire after Exp. Refresh tokens
+// renew them and expire after RefreshExp.
 type TokenConfig struct {
-	Secret string
-	Exp    time.Duration
-	Iss    string
+	Secret     string
+	Exp        time.Duration
+	Iss        string
+	RefreshExp time.Duration
 }
 
 type BasicConfig struct {
@@ -62,6 +75,97 @@ type DbConfig struct {
 	Host         string
 }
 
+// EventsConfig selects how live poll results are fanned out: "postgres"
+// (LISTEN/NOTIFY, shared by every replica) or "local" (in-process only).
+type EventsConfig struct {
+	Backend string
+}
+
+// BallotConfig holds the secret keying the voter hashes of anonymous polls.
+// Changing it lets users vote again on anonymous polls that are still open.
+type BallotConfig struct {
+	Secret string
+}
+
+// InviteConfig holds the secret signing invite links to invite-only polls
+// and how long a link stays valid. Changing the secret revokes every link.
+type InviteConfig struct {
+	Secret string
+	TTL    time.Duration
+}
+
+// TagsConfig caps the number of tags a poll can carry.
+type TagsConfig struct {
+	MaxPerPoll int
+}
+
+// TrendingConfig tunes the trending polls ranking. Votes older than Window
+// are ignored and the rest decay with their age in hours raised to Gravity,
+// as on Hacker News. The Size best polls are recomputed every Refresh.
+type TrendingConfig struct {
+	Window  time.Duration
+	Gravity float64
+	Refresh time.Duration
+	Size    int
+}
+
+// StorageConfig selects where uploaded files are kept: "local" (a directory
+// on disk) or "s3" (a bucket of any S3-compatible object store).
+type StorageConfig struct {
+	Driver   string
+	LocalDir string
+	S3       S3Config
+}
+
+// S3Config locates and authenticates an S3-compatible bucket. Endpoint is
+// the store's base URL, for example http://localhost:9000 for MinIO.
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// UploadsConfig limits image uploads to MaxImageSize bytes and sets the
+// longest side of generated thumbnails.
+type UploadsConfig struct {
+	MaxImageSize  int
+	ThumbnailSize int
+}
+
+// MailConfig selects how emails are sent: "smtp" (through SMTP), "file"
+// (appended to File) or "log" (written to standard output). From is the
+// sender address of every email.
+type MailConfig struct {
+	Driver string
+	From   string
+	File   string
+	SMTP   SMTPConfig
+}
+
+// SMTPConfig locates and authenticates an SMTP server. Username may be
+// empty for servers that accept mail without auth.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// VerificationConfig holds the secret signing email verification links, how
+// long a link stays valid and how often a user can ask for a new one.
+type VerificationConfig struct {
+	Secret         string
+	TTL            time.Duration
+	ResendInterval time.Duration
+}
+
+// ResetConfig sets how long a password reset link stays valid.
+type ResetConfig struct {
+	TTL time.Duration
+}
+
 type RateLimiterConfig struct {
 	RequestsPerTimeFrame int
 	TimeFrame            time.Duration
@@ -94,8 +198,9 @@ func LoadConfig() (Config, error) {
 	if config.TokenConfig.Secret == "" {
 		return Config{}, fmt.Errorf("JWT_SECRET environment variable must be set")
 	}
-	config.TokenConfig.Exp = parseDuration(envOrDefault("TOKEN_EXP", "24h"))
+	config.TokenConfig.Exp = parseDuration(envOrDefault("TOKEN_EXP", "15m"))
 	config.TokenConfig.Iss = envOrDefault("TOKEN_ISS", "JonoMot")
+	config.TokenConfig.RefreshExp = parseDuration(envOrDefault("REFRESH_TOKEN_EXP", "720h"))
 
 	// Database config
 	config.Db.Username = envOrDefault("DB_USERNAME", "admin")
@@ -119,6 +224,64 @@ func LoadConfig() (Config, error) {
 	config.RateLimiter.TimeFrame = parseDuration(envOrDefault("RATELIMITER_TIMEFRAME", "5s"))
 	config.RateLimiter.Enabled = parseBool(envOrDefault("RATELIMITER_ENABLED", "true"))
 
+	// Live events config
+	config.Events.Backend = envOrDefault("EVENTS_BACKEND", "postgres")
+
+	// Anonymous ballots config
+	config.Ballot.Secret = envOrDefault("BALLOT_SECRET", "")
+	if config.Ballot.Secret == "" {
+		return Config{}, fmt.Errorf("BALLOT_SECRET environment variable must be set")
+	}
+
+	// Invite links config
+	config.Invite.Secret = envOrDefault("INVITE_SECRET", "")
+	if config.Invite.Secret == "" {
+		return Config{}, fmt.Errorf("INVITE_SECRET environment variable must be set")
+	}
+	config.Invite.TTL = parseDuration(envOrDefault("INVITE_TTL", "168h"))
+
+	// Poll tags config
+	config.Tags.MaxPerPoll = parseInt(envOrDefault("POLL_MAX_TAGS", "5"))
+
+	// Trending polls config
+	config.Trending.Window = parseDuration(envOrDefault("TRENDING_WINDOW", "48h"))
+	config.Trending.Gravity = parseFloat(envOrDefault("TRENDING_GRAVITY", "1.8"))
+	config.Trending.Refresh = parseDuration(envOrDefault("TRENDING_REFRESH", "1m"))
+	config.Trending.Size = parseInt(envOrDefault("TRENDING_SIZE", "100"))
+
+	// File storage config
+	config.Storage.Driver = envOrDefault("STORAGE_DRIVER", "local")
+	config.Storage.LocalDir = envOrDefault("STORAGE_LOCAL_DIR", "./uploads")
+	config.Storage.S3.Endpoint = envOrDefault("S3_ENDPOINT", "https://s3.amazonaws.com")
+	config.Storage.S3.Region = envOrDefault("S3_REGION", "us-east-1")
+	config.Storage.S3.Bucket = envOrDefault("S3_BUCKET", "")
+	config.Storage.S3.AccessKey = envOrDefault("S3_ACCESS_KEY", "")
+	config.Storage.S3.SecretKey = envOrDefault("S3_SECRET_KEY", "")
+
+	// Image uploads config
+	config.Uploads.MaxImageSize = parseInt(envOrDefault("UPLOAD_MAX_IMAGE_SIZE", "5242880"))
+	config.Uploads.ThumbnailSize = parseInt(envOrDefault("UPLOAD_THUMBNAIL_SIZE", "256"))
+
+	// Mail config
+	config.Mail.Driver = envOrDefault("MAIL_DRIVER", "log")
+	config.Mail.From = envOrDefault("MAIL_FROM", "no-reply@localhost")
+	config.Mail.File = envOrDefault("MAIL_FILE", "./mail.log")
+	config.Mail.SMTP.Host = envOrDefault("SMTP_HOST", "localhost")
+	config.Mail.SMTP.Port = parseInt(envOrDefault("SMTP_PORT", "587"))
+	config.Mail.SMTP.Username = envOrDefault("SMTP_USERNAME", "")
+	config.Mail.SMTP.Password = envOrDefault("SMTP_PASSWORD", "")
+
+	// Email verification config
+	config.Verification.Secret = envOrDefault("VERIFY_SECRET", "")
+	if config.Verification.Secret == "" {
+		return Config{}, fmt.Errorf("VERIFY_SECRET environment variable must be set")
+	}
+	config.Verification.TTL = parseDuration(envOrDefault("VERIFY_TTL", "24h"))
+	config.Verification.ResendInterval = parseDuration(envOrDefault("VERIFY_RESEND_INTERVAL", "1m"))
+
+	// Password reset config
+	config.Reset.TTL = parseDuration(envOrDefault("PASSWORD_RESET_TTL", "1h"))
+
 	return config, nil
 }
 
@@ -153,6 +316,16 @@ func parseInt(value string) int {
 	return intVal
 }
 
+// parseFloat parses a floating-point number with a fallback to 0
+func parseFloat(value string) float64 {
+	floatVal, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		fmt.Printf("Warning: invalid number '%s', using 0\n", value)
+		return 0
+	}
+	return floatVal
+}
+
 // parseBool parses a boolean with a fallback
 func parseBool(value string) bool {
 	boolVal, err := strconv.ParseBool(value)
@@ -36,6 +36,14 @@ func TestHelperFunctions(t *testing.T) {
 		assert.Equal(t, 0, parseInt("invalid"))
 	})
 
+	t.Run("ParseFloat", func(t *testing.T) {
+		// Valid float
+		assert.Equal(t, 1.8, parseFloat("1.8"))
+
+		// Invalid float
+		assert.Equal(t, 0.0, parseFloat("invalid"))
+	})
+
 	t.Run("ParseBool", func(t *testing.T) {
 		// Valid bools
 		assert.Equal(t, true, parseBool("true"))
@@ -51,3 +59,31 @@ func TestHelperFunctions(t *testing.T) {
 		assert.True(t, hasPrefix("test", "t"))
 	})
 }
+
+func TestLoadConfig_RequiresSecrets(t *testing.T) {
+	secrets := []string{"JWT_SECRET", "BALLOT_SECRET", "INVITE_SECRET", "VERIFY_SECRET"}
+
+	for _, missing := range secrets {
+		t.Run(missing, func(t *testing.T) {
+			for _, key := range secrets {
+				t.Setenv(key, "secret-"+key)
+			}
+			t.Setenv(missing, "")
+
+			_, err := LoadConfig()
+			assert.EqualError(t, err, missing+" environment variable must be set")
+		})
+	}
+
+	t.Run("All set", func(t *testing.T) {
+		for _, key := range secrets {
+			t.Setenv(key, "secret-"+key)
+		}
+
+		cfg, err := LoadConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, "secret-BALLOT_SECRET", cfg.Ballot.Secret)
+		assert.Equal(t, "secret-INVITE_SECRET", cfg.Invite.Secret)
+		assert.Equal(t, "secret-VERIFY_SECRET", cfg.Verification.Secret)
+	})
+}
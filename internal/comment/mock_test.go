@@ -0,0 +1,129 @@
+package comment
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepository implements comment.Repository interface for testing
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) GetPoll(ctx context.Context, pollID int64) (*PollInfo, error) {
+	args := m.Called(ctx, pollID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*PollInfo), args.Error(1)
+}
+
+func (m *MockRepository) SetCommentsEnabled(ctx context.Context, pollID int64, enabled bool) error {
+	args := m.Called(ctx, pollID, enabled)
+	return args.Error(0)
+}
+
+func (m *MockRepository) Create(ctx context.Context, cm *Comment) error {
+	args := m.Called(ctx, cm)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetByID(ctx context.Context, pollID, id int64) (*Comment, error) {
+	args := m.Called(ctx, pollID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Comment), args.Error(1)
+}
+
+func (m *MockRepository) List(ctx context.Context, f ListFilter) ([]Comment, error) {
+	args := m.Called(ctx, f)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Comment), args.Error(1)
+}
+
+func (m *MockRepository) ListPinned(ctx context.Context, pollID int64) ([]Comment, error) {
+	args := m.Called(ctx, pollID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Comment), args.Error(1)
+}
+
+func (m *MockRepository) UpdateBody(ctx context.Context, id int64, body string) error {
+	args := m.Called(ctx, id, body)
+	return args.Error(0)
+}
+
+func (m *MockRepository) Moderate(ctx context.Context, id int64, pinned, hidden bool) error {
+	args := m.Called(ctx, id, pinned, hidden)
+	return args.Error(0)
+}
+
+func (m *MockRepository) SoftDelete(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// MockDBService implements database.Service interface for testing
+type MockDBService struct {
+	mock.Mock
+}
+
+func (m *MockDBService) Health() map[string]string {
+	args := m.Called()
+	return args.Get(0).(map[string]string)
+}
+
+func (m *MockDBService) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockDBService) DB() *sql.DB {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil
+	}
+	return args.Get(0).(*sql.DB)
+}
+
+// MockCommentService implements comment.CommentService for testing
+type MockCommentService struct {
+	mock.Mock
+}
+
+func (m *MockCommentService) CreateComment(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockCommentService) ListComments(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockCommentService) EditComment(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockCommentService) DeleteComment(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockCommentService) ModerateComment(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockCommentService) UpdateSettings(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
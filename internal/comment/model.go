@@ -0,0 +1,84 @@
+package comment
+
+import "time"
+
+// Comment is a comment on a poll. Top-level comments have no ParentID;
+// replies point at the comment they answer, so discussions form threads.
+type Comment struct {
+	ID       int64  `json:"id"`
+	PollID   int64  `json:"poll_id"`
+	ParentID *int64 `json:"parent_id,omitempty"`
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Body     string `json:"body"`
+	// Pinned top-level comments are listed first. Hidden comments keep their
+	// place in the thread but their body is only shown to their author and
+	// the poll's moderators.
+	Pinned bool `json:"pinned"`
+	Hidden bool `json:"hidden"`
+	// Deleted comments keep their place so their replies stay attached, but
+	// lose their body.
+	Deleted    bool       `json:"deleted"`
+	ReplyCount int64      `json:"reply_count"`
+	CreatedAt  time.Time  `json:"created_at"`
+	EditedAt   *time.Time `json:"edited_at,omitempty"`
+}
+
+// PollInfo is what comments need to know about the poll they belong to.
+type PollInfo struct {
+	ID              int64
+	UserID          int64
+	CommentsEnabled bool
+}
+
+// ListFilter selects a page of comments. Without a ParentID the top-level
+// comments are listed newest first, leaving out pinned ones; with one, the
+// replies to that comment are listed oldest first. After is the ID of the
+// last comment of the previous page.
+type ListFilter struct {
+	PollID   int64
+	ParentID *int64
+	After    int64
+	Limit    int
+}
+
+// CreateCommentRequest represents the request payload for commenting on a
+// poll. ParentID makes the comment a reply.
+type CreateCommentRequest struct {
+	Body     string `json:"body" example:"Surprised Go won this one."`
+	ParentID *int64 `json:"parent_id,omitempty" example:"12"`
+}
+
+// EditCommentRequest represents the request payload for editing a comment.
+type EditCommentRequest struct {
+	Body string `json:"body" example:"Surprised Go won this one, to be honest."`
+}
+
+// ModerateCommentRequest pins or hides a comment. Omitted fields are left
+// unchanged.
+type ModerateCommentRequest struct {
+	Pinned *bool `json:"pinned,omitempty" example:"true"`
+	Hidden *bool `json:"hidden,omitempty" example:"false"`
+}
+
+// CommentSettingsRequest turns comments on a poll on or off.
+type CommentSettingsRequest struct {
+	Enabled bool `json:"enabled" example:"false"`
+}
+
+// CommentSettingsResponse reports whether a poll accepts comments.
+type CommentSettingsResponse struct {
+	PollID  int64 `json:"poll_id"`
+	Enabled bool  `json:"enabled"`
+}
+
+// CommentListResponse is one page of comments. Pinned comments are only
+// included on the first page of top-level comments. NextCursor is empty on
+// the last page.
+type CommentListResponse struct {
+	PollID          int64     `json:"poll_id"`
+	CommentsEnabled bool      `json:"comments_enabled"`
+	Pinned          []Comment `json:"pinned,omitempty"`
+	Comments        []Comment `json:"comments"`
+	NextCursor      string    `json:"next_cursor,omitempty" example:"MTI"`
+}
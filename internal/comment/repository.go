@@ -0,0 +1,212 @@
+package comment
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/phsaurav/echo_prod_blueprint/internal/database"
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+)
+
+// Repo is the concrete implementation of the comment repository.
+type Repo struct {
+	DB *sql.DB
+}
+
+// NewRepo creates a new comment repository instance.
+func NewRepo(db database.Service) *Repo {
+	return &Repo{DB: db.DB()}
+}
+
+var _ Repository = (*Repo)(nil)
+
+// commentColumns lists the columns read by scanComment, in scan order.
+const commentColumns = `c.id, c.poll_id, c.parent_id, c.user_id, u.username, c.body, c.pinned, c.hidden,
+	c.deleted_at IS NOT NULL, c.created_at, c.edited_at,
+	(SELECT COUNT(*) FROM poll_comments r WHERE r.parent_id = c.id AND r.deleted_at IS NULL)`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanComment scans the commentColumns of a row into cm.
+func scanComment(row rowScanner, cm *Comment) error {
+	var parentID sql.NullInt64
+	var editedAt sql.NullTime
+	err := row.Scan(&cm.ID, &cm.PollID, &parentID, &cm.UserID, &cm.Username, &cm.Body, &cm.Pinned, &cm.Hidden,
+		&cm.Deleted, &cm.CreatedAt, &editedAt, &cm.ReplyCount)
+	if err != nil {
+		return err
+	}
+	if parentID.Valid {
+		cm.ParentID = &parentID.Int64
+	}
+	if editedAt.Valid {
+		cm.EditedAt = &editedAt.Time
+	}
+	return nil
+}
+
+// GetPoll fetches the owner and comment setting of a poll.
+func (r *Repo) GetPoll(ctx context.Context, pollID int64) (*PollInfo, error) {
+	p := &PollInfo{ID: pollID}
+	query := `SELECT user_id, comments_enabled FROM polls WHERE id = $1 AND deleted_at IS NULL`
+	if err := r.DB.QueryRowContext(ctx, query, pollID).Scan(&p.UserID, &p.CommentsEnabled); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errs.NotFound(err)
+		}
+		return nil, errs.InternalServerError(err)
+	}
+	return p, nil
+}
+
+// SetCommentsEnabled turns comments on a poll on or off.
+func (r *Repo) SetCommentsEnabled(ctx context.Context, pollID int64, enabled bool) error {
+	query := `UPDATE polls SET comments_enabled = $2 WHERE id = $1 AND deleted_at IS NULL`
+	res, err := r.DB.ExecContext(ctx, query, pollID, enabled)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errs.NotFound(sql.ErrNoRows)
+	}
+	return nil
+}
+
+// Create inserts a comment, filling in its ID, author name and creation time.
+func (r *Repo) Create(ctx context.Context, cm *Comment) error {
+	query := `
+		WITH c AS (
+			INSERT INTO poll_comments (poll_id, parent_id, user_id, body, created_at)
+			VALUES ($1, $2, $3, $4, NOW())
+			RETURNING id, user_id, created_at
+		)
+		SELECT c.id, u.username, c.created_at FROM c JOIN users u ON u.id = c.user_id
+	`
+	err := r.DB.QueryRowContext(ctx, query, cm.PollID, cm.ParentID, cm.UserID, cm.Body).
+		Scan(&cm.ID, &cm.Username, &cm.CreatedAt)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// GetByID fetches a comment of a poll, including a deleted one.
+func (r *Repo) GetByID(ctx context.Context, pollID, id int64) (*Comment, error) {
+	query := `SELECT ` + commentColumns + `
+		FROM poll_comments c JOIN users u ON u.id = c.user_id
+		WHERE c.id = $1 AND c.poll_id = $2`
+	cm := new(Comment)
+	if err := scanComment(r.DB.QueryRowContext(ctx, query, id, pollID), cm); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errs.NotFound(err)
+		}
+		return nil, errs.InternalServerError(err)
+	}
+	return cm, nil
+}
+
+// List fetches a page of comments matching the filter. Pinned top-level
+// comments are left out; ListPinned returns them.
+func (r *Repo) List(ctx context.Context, f ListFilter) ([]Comment, error) {
+	args := []interface{}{f.PollID}
+	var where, orderBy string
+	if f.ParentID == nil {
+		where = "c.parent_id IS NULL AND NOT c.pinned"
+		orderBy = "c.id DESC"
+		if f.After != 0 {
+			args = append(args, f.After)
+			where += fmt.Sprintf(" AND c.id < $%d", len(args))
+		}
+	} else {
+		args = append(args, *f.ParentID)
+		where = fmt.Sprintf("c.parent_id = $%d", len(args))
+		orderBy = "c.id"
+		if f.After != 0 {
+			args = append(args, f.After)
+			where += fmt.Sprintf(" AND c.id > $%d", len(args))
+		}
+	}
+	args = append(args, f.Limit)
+
+	query := fmt.Sprintf(`SELECT %s
+		FROM poll_comments c JOIN users u ON u.id = c.user_id
+		WHERE c.poll_id = $1 AND %s
+		ORDER BY %s
+		LIMIT $%d`, commentColumns, where, orderBy, len(args))
+	return r.query(ctx, query, args...)
+}
+
+// ListPinned fetches the pinned top-level comments of a poll, newest first.
+func (r *Repo) ListPinned(ctx context.Context, pollID int64) ([]Comment, error) {
+	query := `SELECT ` + commentColumns + `
+		FROM poll_comments c JOIN users u ON u.id = c.user_id
+		WHERE c.poll_id = $1 AND c.parent_id IS NULL AND c.pinned
+		ORDER BY c.id DESC`
+	return r.query(ctx, query, pollID)
+}
+
+// query runs a comment query and scans every row.
+func (r *Repo) query(ctx context.Context, query string, args ...interface{}) ([]Comment, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var cm Comment
+		if err := scanComment(rows, &cm); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		comments = append(comments, cm)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	return comments, nil
+}
+
+// UpdateBody replaces the body of a comment and marks it edited.
+func (r *Repo) UpdateBody(ctx context.Context, id int64, body string) error {
+	query := `UPDATE poll_comments SET body = $2, edited_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
+	res, err := r.DB.ExecContext(ctx, query, id, body)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errs.NotFound(sql.ErrNoRows)
+	}
+	return nil
+}
+
+// Moderate sets whether a comment is pinned and hidden.
+func (r *Repo) Moderate(ctx context.Context, id int64, pinned, hidden bool) error {
+	query := `UPDATE poll_comments SET pinned = $2, hidden = $3 WHERE id = $1 AND deleted_at IS NULL`
+	res, err := r.DB.ExecContext(ctx, query, id, pinned, hidden)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errs.NotFound(sql.ErrNoRows)
+	}
+	return nil
+}
+
+// SoftDelete removes a comment from its thread while keeping its replies.
+// Deleted comments are also unpinned.
+func (r *Repo) SoftDelete(ctx context.Context, id int64) error {
+	query := `UPDATE poll_comments SET deleted_at = NOW(), pinned = FALSE WHERE id = $1 AND deleted_at IS NULL`
+	res, err := r.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errs.NotFound(sql.ErrNoRows)
+	}
+	return nil
+}
@@ -0,0 +1,411 @@
+package comment
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var commentRowColumns = []string{"id", "poll_id", "parent_id", "user_id", "username", "body", "pinned", "hidden",
+	"deleted", "created_at", "edited_at", "reply_count"}
+
+func TestNewRepo(t *testing.T) {
+	// Create mock DB
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create mock database service
+	mockDB := new(MockDBService)
+	mockDB.On("DB").Return(db)
+
+	// Create repository
+	repo := NewRepo(mockDB)
+
+	// Assert
+	assert.NotNil(t, repo)
+	assert.Equal(t, db, repo.DB)
+	mockDB.AssertExpectations(t)
+}
+
+func TestRepo_GetPoll(t *testing.T) {
+	tests := []struct {
+		name          string
+		mockSetup     func(sqlmock.Sqlmock)
+		expectedPoll  *PollInfo
+		expectedError bool
+	}{
+		{
+			name: "Existing poll",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT user_id, comments_enabled FROM polls").
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "comments_enabled"}).AddRow(5, false))
+			},
+			expectedPoll: &PollInfo{ID: 1, UserID: 5, CommentsEnabled: false},
+		},
+		{
+			name: "Missing poll",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT user_id, comments_enabled FROM polls").
+					WithArgs(1).
+					WillReturnError(sql.ErrNoRows)
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mock DB
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			// Create repository
+			repo := &Repo{DB: db}
+
+			// Setup expectations
+			tt.mockSetup(mock)
+
+			// Call function under test
+			poll, err := repo.GetPoll(context.Background(), 1)
+
+			// Assert
+			if tt.expectedError {
+				assert.Error(t, err)
+				assert.Nil(t, poll)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedPoll, poll)
+			}
+
+			// Verify all expectations were met
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepo_SetCommentsEnabled(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectExec("UPDATE polls SET comments_enabled = \\$2").
+		WithArgs(1, false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Call function under test
+	err = repo.SetCommentsEnabled(context.Background(), 1, false)
+
+	// Assert
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_Create(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	parentID := int64(3)
+	cm := &Comment{PollID: 1, ParentID: &parentID, UserID: 5, Body: "Agreed"}
+
+	// Setup expectations
+	now := time.Now()
+	mock.ExpectQuery("INSERT INTO poll_comments").
+		WithArgs(1, &parentID, 5, "Agreed").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "created_at"}).AddRow(10, "alice", now))
+
+	// Call function under test
+	err = repo.Create(context.Background(), cm)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), cm.ID)
+	assert.Equal(t, "alice", cm.Username)
+	assert.Equal(t, now, cm.CreatedAt)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetByID(t *testing.T) {
+	tests := []struct {
+		name          string
+		mockSetup     func(sqlmock.Sqlmock)
+		expectedError bool
+	}{
+		{
+			name: "Existing reply",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("FROM poll_comments c JOIN users u ON u.id = c.user_id WHERE c.id = \\$1 AND c.poll_id = \\$2").
+					WithArgs(10, 1).
+					WillReturnRows(sqlmock.NewRows(commentRowColumns).
+						AddRow(10, 1, 3, 5, "alice", "Agreed", false, false, false, time.Now(), nil, 0))
+			},
+		},
+		{
+			name: "Comment of another poll",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("FROM poll_comments").
+					WithArgs(10, 1).
+					WillReturnError(sql.ErrNoRows)
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mock DB
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			// Create repository
+			repo := &Repo{DB: db}
+
+			// Setup expectations
+			tt.mockSetup(mock)
+
+			// Call function under test
+			cm, err := repo.GetByID(context.Background(), 1, 10)
+
+			// Assert
+			if tt.expectedError {
+				assert.Error(t, err)
+				assert.Nil(t, cm)
+			} else {
+				assert.NoError(t, err)
+				require.NotNil(t, cm.ParentID)
+				assert.Equal(t, int64(3), *cm.ParentID)
+				assert.Nil(t, cm.EditedAt)
+			}
+
+			// Verify all expectations were met
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepo_List(t *testing.T) {
+	parentID := int64(3)
+	tests := []struct {
+		name      string
+		filter    ListFilter
+		mockSetup func(sqlmock.Sqlmock)
+	}{
+		{
+			name:   "First page of top-level comments",
+			filter: ListFilter{PollID: 1, Limit: 21},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("WHERE c.poll_id = \\$1 AND c.parent_id IS NULL AND NOT c.pinned ORDER BY c.id DESC LIMIT \\$2").
+					WithArgs(1, 21).
+					WillReturnRows(sqlmock.NewRows(commentRowColumns).
+						AddRow(12, 1, nil, 5, "alice", "Second", false, false, false, time.Now(), nil, 0).
+						AddRow(11, 1, nil, 6, "bob", "First", false, false, false, time.Now(), nil, 2))
+			},
+		},
+		{
+			name:   "Next page of top-level comments",
+			filter: ListFilter{PollID: 1, After: 11, Limit: 21},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("NOT c.pinned AND c.id < \\$2 ORDER BY c.id DESC LIMIT \\$3").
+					WithArgs(1, 11, 21).
+					WillReturnRows(sqlmock.NewRows(commentRowColumns))
+			},
+		},
+		{
+			name:   "Replies after a cursor",
+			filter: ListFilter{PollID: 1, ParentID: &parentID, After: 7, Limit: 11},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("c.parent_id = \\$2 AND c.id > \\$3 ORDER BY c.id LIMIT \\$4").
+					WithArgs(1, 3, 7, 11).
+					WillReturnRows(sqlmock.NewRows(commentRowColumns))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mock DB
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			// Create repository
+			repo := &Repo{DB: db}
+
+			// Setup expectations
+			tt.mockSetup(mock)
+
+			// Call function under test
+			comments, err := repo.List(context.Background(), tt.filter)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.NotNil(t, comments)
+
+			// Verify all expectations were met
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepo_ListPinned(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectQuery("c.parent_id IS NULL AND c.pinned ORDER BY c.id DESC").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(commentRowColumns).
+			AddRow(4, 1, nil, 5, "alice", "Read the rules", true, false, false, time.Now(), time.Now(), 0))
+
+	// Call function under test
+	comments, err := repo.ListPinned(context.Background(), 1)
+
+	// Assert
+	assert.NoError(t, err)
+	require.Len(t, comments, 1)
+	assert.True(t, comments[0].Pinned)
+	assert.NotNil(t, comments[0].EditedAt)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_UpdateBody(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectExec("UPDATE poll_comments SET body = \\$2, edited_at = NOW\\(\\)").
+		WithArgs(10, "Edited").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Call function under test
+	err = repo.UpdateBody(context.Background(), 10, "Edited")
+
+	// Assert
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_Moderate(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectExec("UPDATE poll_comments SET pinned = \\$2, hidden = \\$3").
+		WithArgs(10, true, false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Call function under test
+	err = repo.Moderate(context.Background(), 10, true, false)
+
+	// Assert
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_SoftDelete(t *testing.T) {
+	tests := []struct {
+		name          string
+		mockSetup     func(sqlmock.Sqlmock)
+		expectedError bool
+	}{
+		{
+			name: "Live comment",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("UPDATE poll_comments SET deleted_at = NOW\\(\\), pinned = FALSE").
+					WithArgs(10).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "Already deleted",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("UPDATE poll_comments SET deleted_at").
+					WithArgs(10).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectedError: true,
+		},
+		{
+			name: "Database error",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("UPDATE poll_comments SET deleted_at").
+					WithArgs(10).
+					WillReturnError(errors.New("database error"))
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mock DB
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			// Create repository
+			repo := &Repo{DB: db}
+
+			// Setup expectations
+			tt.mockSetup(mock)
+
+			// Call function under test
+			err = repo.SoftDelete(context.Background(), 10)
+
+			// Assert
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			// Verify all expectations were met
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package comment
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/phsaurav/echo_prod_blueprint/internal/database"
+)
+
+type CommentService interface {
+	CreateComment(c echo.Context) error
+	ListComments(c echo.Context) error
+	EditComment(c echo.Context) error
+	DeleteComment(c echo.Context) error
+	ModerateComment(c echo.Context) error
+	UpdateSettings(c echo.Context) error
+}
+
+// Register wires the comment feature onto the poll group. pollAccess is the
+// poll feature's ResolvePoll middleware, so comments are only reachable on
+// polls the caller may see.
+func Register(g *echo.Group, db database.Service, authMiddleware, optionalAuthMiddleware, pollAccess echo.MiddlewareFunc) {
+	repo := NewRepo(db)
+	service := NewService(repo)
+	RegisterRoutes(g, service, authMiddleware, optionalAuthMiddleware, pollAccess)
+}
+
+func RegisterRoutes(g *echo.Group, service CommentService, authMiddleware, optionalAuthMiddleware, pollAccess echo.MiddlewareFunc) {
+	g.GET("/:id/comments", service.ListComments, optionalAuthMiddleware, pollAccess)
+	g.POST("/:id/comments", service.CreateComment, authMiddleware, pollAccess)
+	g.PUT("/:id/comments/settings", service.UpdateSettings, authMiddleware, pollAccess)
+	g.PATCH("/:id/comments/:comment_id", service.EditComment, authMiddleware, pollAccess)
+	g.DELETE("/:id/comments/:comment_id", service.DeleteComment, authMiddleware, pollAccess)
+	g.PUT("/:id/comments/:comment_id/moderation", service.ModerateComment, authMiddleware, pollAccess)
+}
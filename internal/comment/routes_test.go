@@ -0,0 +1,88 @@
+package comment
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/phsaurav/echo_prod_blueprint/testutils"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestRegisterRoutes tests that routes are registered correctly
+func TestRegisterRoutes(t *testing.T) {
+	// Setup
+	e := echo.New()
+	g := e.Group("/api/v1/poll")
+
+	mockService := new(MockCommentService)
+
+	// Mock auth and poll access middleware
+	authMiddleware := testutils.CreateAuthMiddleware()
+
+	// Register routes
+	RegisterRoutes(g, mockService, authMiddleware, authMiddleware, authMiddleware)
+
+	// Test GET /api/v1/poll/:id/comments
+	mockService.On("ListComments", mock.Anything).Return(nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/poll/1/comments", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test POST /api/v1/poll/:id/comments
+	mockService.On("CreateComment", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/poll/1/comments", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test PUT /api/v1/poll/:id/comments/settings
+	mockService.On("UpdateSettings", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/poll/1/comments/settings", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test PATCH /api/v1/poll/:id/comments/:comment_id
+	mockService.On("EditComment", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPatch, "/api/v1/poll/1/comments/2", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test DELETE /api/v1/poll/:id/comments/:comment_id
+	mockService.On("DeleteComment", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/poll/1/comments/2", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test PUT /api/v1/poll/:id/comments/:comment_id/moderation
+	mockService.On("ModerateComment", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/poll/1/comments/2/moderation", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Verify all expected methods were called
+	mockService.AssertExpectations(t)
+}
+
+// TestRegister tests the Register function
+func TestRegister(t *testing.T) {
+	// Setup
+	e := echo.New()
+	g := e.Group("/api/v1/poll")
+
+	// Create mock database service
+	mockDB := new(MockDBService)
+	mockDB.On("DB").Return(nil)
+
+	// Mock auth middleware
+	authMiddleware := testutils.CreateAuthMiddleware()
+
+	assert.NotPanics(t, func() {
+		Register(g, mockDB, authMiddleware, authMiddleware, authMiddleware)
+	})
+
+	// Verify mock was called
+	mockDB.AssertExpectations(t)
+}
@@ -0,0 +1,469 @@
+package comment
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/phsaurav/echo_prod_blueprint/pkg/auth"
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/response"
+)
+
+type Repository interface {
+	GetPoll(ctx context.Context, pollID int64) (*PollInfo, error)
+	SetCommentsEnabled(ctx context.Context, pollID int64, enabled bool) error
+	Create(ctx context.Context, cm *Comment) error
+	GetByID(ctx context.Context, pollID, id int64) (*Comment, error)
+	List(ctx context.Context, f ListFilter) ([]Comment, error)
+	ListPinned(ctx context.Context, pollID int64) ([]Comment, error)
+	UpdateBody(ctx context.Context, id int64, body string) error
+	Moderate(ctx context.Context, id int64, pinned, hidden bool) error
+	SoftDelete(ctx context.Context, id int64) error
+}
+
+const (
+	// maxBodyLength caps the length of a comment, in characters.
+	maxBodyLength = 2000
+	// defaultPageSize and maxPageSize bound the comments listed per page.
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// Service implements the consumer-side CommentService interface.
+type Service struct {
+	Repo Repository
+}
+
+// NewService creates a new comment service instance.
+func NewService(repo Repository) *Service {
+	return &Service{Repo: repo}
+}
+
+// CreateComment comments on a poll or replies to a comment
+// @Summary Comment on a poll
+// @Description Post a comment on a poll, or a reply to one of its comments with parent_id. Not allowed once the poll owner has disabled comments.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Param request body CreateCommentRequest true "Comment body and optional parent"
+// @Success 200 {object} Comment "Created comment"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid input"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - comments are disabled"
+// @Failure 404 {object} response.FailedResponse "Not found - poll or parent comment doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/comments [post]
+func (s *Service) CreateComment(c echo.Context) error {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	var req CreateCommentRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+	body, err := checkBody(req.Body)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	poll, err := s.Repo.GetPoll(ctx, pollID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if !poll.CommentsEnabled {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("comments are disabled on this poll"))).Send(c)
+	}
+	if req.ParentID != nil {
+		parent, err := s.Repo.GetByID(ctx, pollID, *req.ParentID)
+		if err != nil {
+			return response.ErrorBuilder(err).Send(c)
+		}
+		if parent.Deleted {
+			return response.ErrorBuilder(errs.BaseErr("cannot reply to a deleted comment")).Send(c)
+		}
+	}
+
+	cm := &Comment{PollID: pollID, ParentID: req.ParentID, UserID: auth.UserID(c), Body: body}
+	if err := s.Repo.Create(ctx, cm); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(cm).Send(c)
+}
+
+// ListComments lists a page of a poll's comments
+// @Summary List poll comments
+// @Description List the top-level comments of a poll newest first, with pinned comments on the first page, or with parent_id the replies to a comment oldest first. Pages are chained with next_cursor. Deleted comments and comments hidden by a moderator keep their place without their body.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Param parent_id query int false "List the replies to this comment"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param limit query int false "Comments per page (max 100)" default(20)
+// @Success 200 {object} CommentListResponse "Page of comments"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid parameter"
+// @Failure 404 {object} response.FailedResponse "Not found - poll or parent comment doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Router /api/v1/poll/{id}/comments [get]
+func (s *Service) ListComments(c echo.Context) error {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	f := ListFilter{PollID: pollID, Limit: defaultPageSize}
+	if v := c.QueryParam("parent_id"); v != "" {
+		parentID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return response.ErrorBuilder(errs.BadRequest(errors.New("parent_id must be an integer"))).Send(c)
+		}
+		f.ParentID = &parentID
+	}
+	if v := c.QueryParam("cursor"); v != "" {
+		f.After, err = decodeCursor(v)
+		if err != nil {
+			return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+		}
+	}
+	if v := c.QueryParam("limit"); v != "" {
+		f.Limit, err = strconv.Atoi(v)
+		if err != nil || f.Limit < 1 {
+			return response.ErrorBuilder(errs.BadRequest(errors.New("limit must be a positive integer"))).Send(c)
+		}
+		if f.Limit > maxPageSize {
+			f.Limit = maxPageSize
+		}
+	}
+
+	ctx := c.Request().Context()
+	poll, err := s.Repo.GetPoll(ctx, pollID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if f.ParentID != nil {
+		if _, err := s.Repo.GetByID(ctx, pollID, *f.ParentID); err != nil {
+			return response.ErrorBuilder(err).Send(c)
+		}
+	}
+
+	// Fetch one extra comment to learn whether another page follows
+	limit := f.Limit
+	f.Limit++
+	comments, err := s.Repo.List(ctx, f)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	resp := CommentListResponse{PollID: pollID, CommentsEnabled: poll.CommentsEnabled}
+	if len(comments) > limit {
+		comments = comments[:limit]
+		resp.NextCursor = encodeCursor(comments[limit-1].ID)
+	}
+	resp.Comments = redact(c, poll, comments)
+
+	if f.ParentID == nil && f.After == 0 {
+		pinned, err := s.Repo.ListPinned(ctx, pollID)
+		if err != nil {
+			return response.ErrorBuilder(err).Send(c)
+		}
+		resp.Pinned = redact(c, poll, pinned)
+	}
+
+	return response.SuccessBuilder(resp).Send(c)
+}
+
+// EditComment edits the caller's own comment
+// @Summary Edit a comment
+// @Description Replace the body of one of the caller's comments and mark it edited. Not allowed once the poll owner has disabled comments.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Param comment_id path int true "Comment ID"
+// @Param request body EditCommentRequest true "New comment body"
+// @Success 200 {object} Comment "Edited comment"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid input"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the author, or comments are disabled"
+// @Failure 404 {object} response.FailedResponse "Not found - comment doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/comments/{comment_id} [patch]
+func (s *Service) EditComment(c echo.Context) error {
+	pollID, commentID, err := commentParams(c)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	var req EditCommentRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+	body, err := checkBody(req.Body)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	poll, err := s.Repo.GetPoll(ctx, pollID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if !poll.CommentsEnabled {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("comments are disabled on this poll"))).Send(c)
+	}
+	cm, err := s.liveComment(ctx, pollID, commentID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if cm.UserID != auth.UserID(c) {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("only the author can edit a comment"))).Send(c)
+	}
+
+	if err := s.Repo.UpdateBody(ctx, commentID, body); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	now := time.Now().UTC()
+	cm.Body = body
+	cm.EditedAt = &now
+
+	return response.SuccessBuilder(cm).Send(c)
+}
+
+// DeleteComment soft-deletes a comment
+// @Summary Delete a comment
+// @Description Remove a comment from its thread. Its replies stay in place. The author, the poll owner or an admin can delete a comment.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Param comment_id path int true "Comment ID"
+// @Success 200 {object} map[string]string "Comment deleted"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid poll or comment ID"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the author or a moderator"
+// @Failure 404 {object} response.FailedResponse "Not found - comment doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/comments/{comment_id} [delete]
+func (s *Service) DeleteComment(c echo.Context) error {
+	pollID, commentID, err := commentParams(c)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	poll, err := s.Repo.GetPoll(ctx, pollID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	cm, err := s.liveComment(ctx, pollID, commentID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if cm.UserID != auth.UserID(c) && !canModerate(c, poll) {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("only the author or a moderator can delete a comment"))).Send(c)
+	}
+
+	if err := s.Repo.SoftDelete(ctx, commentID); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(map[string]string{"message": "Comment deleted successfully"}).Send(c)
+}
+
+// ModerateComment pins or hides a comment
+// @Summary Moderate a comment
+// @Description Pin a top-level comment to the top of the discussion, or hide a comment's body from everyone but its author and the moderators. Only the poll owner or an admin can moderate.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Param comment_id path int true "Comment ID"
+// @Param request body ModerateCommentRequest true "Pinned and hidden flags"
+// @Success 200 {object} Comment "Moderated comment"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid input, or pinning a reply"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the poll owner or an admin"
+// @Failure 404 {object} response.FailedResponse "Not found - comment doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/comments/{comment_id}/moderation [put]
+func (s *Service) ModerateComment(c echo.Context) error {
+	pollID, commentID, err := commentParams(c)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	var req ModerateCommentRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	poll, err := s.Repo.GetPoll(ctx, pollID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if !canModerate(c, poll) {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("only the poll owner or an admin can moderate comments"))).Send(c)
+	}
+	cm, err := s.liveComment(ctx, pollID, commentID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	if req.Pinned != nil {
+		if *req.Pinned && cm.ParentID != nil {
+			return response.ErrorBuilder(errs.BaseErr("only top-level comments can be pinned")).Send(c)
+		}
+		cm.Pinned = *req.Pinned
+	}
+	if req.Hidden != nil {
+		cm.Hidden = *req.Hidden
+	}
+	if err := s.Repo.Moderate(ctx, commentID, cm.Pinned, cm.Hidden); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(cm).Send(c)
+}
+
+// UpdateSettings turns comments on a poll on or off
+// @Summary Enable or disable comments
+// @Description Turn comments on a poll on or off. Existing comments stay listed while comments are disabled, but nobody can post or edit. Only the poll owner or an admin can change it.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Param request body CommentSettingsRequest true "Whether the poll accepts comments"
+// @Success 200 {object} CommentSettingsResponse "Updated comment setting"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid input"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the poll owner or an admin"
+// @Failure 404 {object} response.FailedResponse "Not found - poll doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/comments/settings [put]
+func (s *Service) UpdateSettings(c echo.Context) error {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	var req CommentSettingsRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	poll, err := s.Repo.GetPoll(ctx, pollID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if !canModerate(c, poll) {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("only the poll owner or an admin can change comment settings"))).Send(c)
+	}
+
+	if err := s.Repo.SetCommentsEnabled(ctx, pollID, req.Enabled); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(CommentSettingsResponse{PollID: pollID, Enabled: req.Enabled}).Send(c)
+}
+
+// liveComment fetches a comment of a poll that has not been deleted.
+func (s *Service) liveComment(ctx context.Context, pollID, commentID int64) (*Comment, error) {
+	cm, err := s.Repo.GetByID(ctx, pollID, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if cm.Deleted {
+		return nil, errs.NotFound(errors.New("comment not found"))
+	}
+	return cm, nil
+}
+
+// redact removes the bodies the caller may not read: those of deleted
+// comments, and those of hidden comments unless the caller wrote them or
+// moderates the poll.
+func redact(c echo.Context, poll *PollInfo, comments []Comment) []Comment {
+	moderator := canModerate(c, poll)
+	viewer, signedIn := auth.ViewerID(c)
+	for i := range comments {
+		cm := &comments[i]
+		switch {
+		case cm.Deleted:
+			cm.Body = ""
+		case cm.Hidden && !moderator && !(signedIn && cm.UserID == viewer):
+			cm.Body = ""
+		}
+	}
+	return comments
+}
+
+// checkBody trims a comment body and checks its length.
+func checkBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", errs.BaseErr("body is required")
+	}
+	if utf8.RuneCountInString(body) > maxBodyLength {
+		return "", errs.BaseErr(fmt.Sprintf("comments can be at most %d characters", maxBodyLength))
+	}
+	return body, nil
+}
+
+// commentParams parses the poll and comment IDs of a comment route.
+func commentParams(c echo.Context) (pollID, commentID int64, err error) {
+	pollID, err = strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0, 0, errs.BadRequest(err)
+	}
+	commentID, err = strconv.ParseInt(c.Param("comment_id"), 10, 64)
+	if err != nil {
+		return 0, 0, errs.BadRequest(err)
+	}
+	return pollID, commentID, nil
+}
+
+// encodeCursor turns the ID of the last comment of a page into an opaque cursor.
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// decodeCursor returns the comment ID a cursor was made from.
+func decodeCursor(cursor string) (int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+	id, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid cursor")
+	}
+	return id, nil
+}
+
+// canModerate reports whether the authenticated user owns the poll or is an
+// admin. Anonymous callers moderate nothing.
+func canModerate(c echo.Context, poll *PollInfo) bool {
+	userID, ok := auth.ViewerID(c)
+	if !ok {
+		return false
+	}
+	return poll.UserID == userID || auth.HasPermission(c, auth.PermissionModerateComments)
+}
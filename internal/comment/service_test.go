@@ -0,0 +1,599 @@
+package comment
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/phsaurav/echo_prod_blueprint/pkg/auth"
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+	"github.com/phsaurav/echo_prod_blueprint/testutils"
+)
+
+func TestNewService(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo)
+
+	assert.NotNil(t, service)
+	assert.Equal(t, mockRepo, service.Repo)
+}
+
+// addAdminToken adds a token granting an admin's comment moderation
+// permission to the context
+func addAdminToken(c echo.Context, userID int64) {
+	token := jwt.New(jwt.SigningMethodHS256)
+	claims := token.Claims.(jwt.MapClaims)
+	claims["user_id"] = float64(userID)
+	claims["roles"] = []interface{}{auth.RoleAdmin}
+	claims["perms"] = []interface{}{auth.PermissionModerateComments}
+	c.Set("user", token)
+}
+
+// setParams sets the poll and, when given, comment path parameters
+func setParams(c echo.Context, pollID, commentID string) {
+	if commentID == "" {
+		c.SetParamNames("id")
+		c.SetParamValues(pollID)
+		return
+	}
+	c.SetParamNames("id", "comment_id")
+	c.SetParamValues(pollID, commentID)
+}
+
+// openPoll is a poll owned by user 5 that accepts comments
+func openPoll() *PollInfo {
+	return &PollInfo{ID: 1, UserID: 5, CommentsEnabled: true}
+}
+
+func TestService_CreateComment(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Top-level comment",
+			requestBody: `{"body": "  Surprised Go won.  "}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+				repo.On("Create", mock.Anything, mock.MatchedBy(func(cm *Comment) bool {
+					return cm.PollID == 1 && cm.UserID == 7 && cm.ParentID == nil && cm.Body == "Surprised Go won."
+				})).Return(nil).Run(func(args mock.Arguments) {
+					args.Get(1).(*Comment).ID = 10
+				})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"id":10,"poll_id":1,"user_id":7`,
+		},
+		{
+			name:        "Reply",
+			requestBody: `{"body": "Me too", "parent_id": 3}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+				repo.On("GetByID", mock.Anything, int64(1), int64(3)).Return(&Comment{ID: 3, PollID: 1}, nil)
+				repo.On("Create", mock.Anything, mock.MatchedBy(func(cm *Comment) bool {
+					return cm.ParentID != nil && *cm.ParentID == 3
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"parent_id":3`,
+		},
+		{
+			name:        "Reply to a deleted comment",
+			requestBody: `{"body": "Me too", "parent_id": 3}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+				repo.On("GetByID", mock.Anything, int64(1), int64(3)).Return(&Comment{ID: 3, PollID: 1, Deleted: true}, nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"cannot reply to a deleted comment"`,
+		},
+		{
+			name:        "Parent on another poll",
+			requestBody: `{"body": "Me too", "parent_id": 3}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+				repo.On("GetByID", mock.Anything, int64(1), int64(3)).Return(nil, errs.NotFound(sql.ErrNoRows))
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:        "Comments disabled",
+			requestBody: `{"body": "Hello"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(&PollInfo{ID: 1, UserID: 5}, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"comments are disabled on this poll"`,
+		},
+		{
+			name:           "Blank body",
+			requestBody:    `{"body": "   "}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"body is required"`,
+		},
+		{
+			name:        "Repository error",
+			requestBody: `{"body": "Hello"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+				repo.On("Create", mock.Anything, mock.Anything).Return(errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"database error"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.CreateContext(http.MethodPost, "/", tt.requestBody)
+			setParams(c, "1", "")
+			testutils.AddUserToken(c, 7)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.CreateComment(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_ListComments(t *testing.T) {
+	parentID := int64(3)
+	now := time.Now()
+	tests := []struct {
+		name           string
+		url            string
+		viewer         int64
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   []string
+		unexpectedBody []string
+	}{
+		{
+			name: "First page with pinned comments and a next page",
+			url:  "/?limit=2",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+				repo.On("List", mock.Anything, ListFilter{PollID: 1, Limit: 3}).Return([]Comment{
+					{ID: 12, PollID: 1, Body: "Third", CreatedAt: now},
+					{ID: 11, PollID: 1, Body: "Second", CreatedAt: now},
+					{ID: 10, PollID: 1, Body: "First", CreatedAt: now},
+				}, nil)
+				repo.On("ListPinned", mock.Anything, int64(1)).Return([]Comment{{ID: 4, PollID: 1, Body: "Rules", Pinned: true}}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   []string{`"pinned":[{"id":4`, `"body":"Second"`, `"next_cursor":"` + encodeCursor(11) + `"`},
+			unexpectedBody: []string{`"body":"First"`},
+		},
+		{
+			name: "Later page skips pinned comments",
+			url:  "/?cursor=" + encodeCursor(11),
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+				repo.On("List", mock.Anything, ListFilter{PollID: 1, After: 11, Limit: 21}).Return([]Comment{
+					{ID: 10, PollID: 1, Body: "First"},
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   []string{`"body":"First"`},
+			unexpectedBody: []string{`"pinned":[`, `"next_cursor"`},
+		},
+		{
+			name: "Replies redact deleted and hidden comments",
+			url:  "/?parent_id=3",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+				repo.On("GetByID", mock.Anything, int64(1), int64(3)).Return(&Comment{ID: 3, PollID: 1}, nil)
+				repo.On("List", mock.Anything, ListFilter{PollID: 1, ParentID: &parentID, Limit: 21}).Return([]Comment{
+					{ID: 20, PollID: 1, ParentID: &parentID, UserID: 8, Body: "Gone", Deleted: true},
+					{ID: 21, PollID: 1, ParentID: &parentID, UserID: 8, Body: "Rude", Hidden: true},
+					{ID: 22, PollID: 1, ParentID: &parentID, UserID: 7, Body: "Mine", Hidden: true},
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   []string{`"id":20,"poll_id":1,"parent_id":3,"user_id":8,"username":"","body":""`, `"body":"Mine"`},
+			unexpectedBody: []string{"Gone", "Rude"},
+		},
+		{
+			name:   "Owner sees hidden comments",
+			url:    "/",
+			viewer: 5,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+				repo.On("List", mock.Anything, ListFilter{PollID: 1, Limit: 21}).Return([]Comment{
+					{ID: 21, PollID: 1, UserID: 8, Body: "Rude", Hidden: true},
+				}, nil)
+				repo.On("ListPinned", mock.Anything, int64(1)).Return([]Comment{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   []string{`"body":"Rude"`},
+		},
+		{
+			name:           "Invalid cursor",
+			url:            "/?cursor=!!",
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   []string{`"error":"invalid cursor"`},
+		},
+		{
+			name:           "Invalid limit",
+			url:            "/?limit=0",
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   []string{`"error":"limit must be a positive integer"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.CreateContext(http.MethodGet, tt.url, "")
+			setParams(c, "1", "")
+			if tt.viewer != 0 {
+				testutils.AddUserToken(c, tt.viewer)
+			} else {
+				testutils.AddUserToken(c, 7)
+			}
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.ListComments(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			for _, body := range tt.expectedBody {
+				assert.Contains(t, rec.Body.String(), body)
+			}
+			for _, body := range tt.unexpectedBody {
+				assert.NotContains(t, rec.Body.String(), body)
+			}
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_ListComments_Anonymous(t *testing.T) {
+	// Setup
+	c, rec := testutils.CreateContext(http.MethodGet, "/", "")
+	setParams(c, "1", "")
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+	mockRepo.On("List", mock.Anything, ListFilter{PollID: 1, Limit: 21}).Return([]Comment{
+		{ID: 21, PollID: 1, UserID: 8, Body: "Rude", Hidden: true},
+	}, nil)
+	mockRepo.On("ListPinned", mock.Anything, int64(1)).Return([]Comment{}, nil)
+
+	service := NewService(mockRepo)
+
+	// Execute
+	err := service.ListComments(c)
+
+	// Assert - hidden bodies are redacted for anonymous readers
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "Rude")
+
+	// Verify mocks
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_EditComment(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Author edits",
+			requestBody: `{"body": "Edited"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+				repo.On("GetByID", mock.Anything, int64(1), int64(10)).Return(&Comment{ID: 10, PollID: 1, UserID: 7, Body: "Original"}, nil)
+				repo.On("UpdateBody", mock.Anything, int64(10), "Edited").Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"body":"Edited"`,
+		},
+		{
+			name:        "Someone else's comment",
+			requestBody: `{"body": "Edited"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+				repo.On("GetByID", mock.Anything, int64(1), int64(10)).Return(&Comment{ID: 10, PollID: 1, UserID: 8}, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"only the author can edit a comment"`,
+		},
+		{
+			name:        "Deleted comment",
+			requestBody: `{"body": "Edited"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+				repo.On("GetByID", mock.Anything, int64(1), int64(10)).Return(&Comment{ID: 10, PollID: 1, UserID: 7, Deleted: true}, nil)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"error":"comment not found"`,
+		},
+		{
+			name:        "Comments disabled",
+			requestBody: `{"body": "Edited"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(&PollInfo{ID: 1, UserID: 5}, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"comments are disabled on this poll"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.CreateContext(http.MethodPatch, "/", tt.requestBody)
+			setParams(c, "1", "10")
+			testutils.AddUserToken(c, 7)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.EditComment(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_DeleteComment(t *testing.T) {
+	tests := []struct {
+		name           string
+		addToken       func(echo.Context)
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:     "Author deletes",
+			addToken: func(c echo.Context) { testutils.AddUserToken(c, 7) },
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+				repo.On("GetByID", mock.Anything, int64(1), int64(10)).Return(&Comment{ID: 10, PollID: 1, UserID: 7}, nil)
+				repo.On("SoftDelete", mock.Anything, int64(10)).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"Comment deleted successfully"`,
+		},
+		{
+			name:     "Poll owner deletes",
+			addToken: func(c echo.Context) { testutils.AddUserToken(c, 5) },
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+				repo.On("GetByID", mock.Anything, int64(1), int64(10)).Return(&Comment{ID: 10, PollID: 1, UserID: 7}, nil)
+				repo.On("SoftDelete", mock.Anything, int64(10)).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:     "Admin deletes",
+			addToken: func(c echo.Context) { addAdminToken(c, 99) },
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+				repo.On("GetByID", mock.Anything, int64(1), int64(10)).Return(&Comment{ID: 10, PollID: 1, UserID: 7}, nil)
+				repo.On("SoftDelete", mock.Anything, int64(10)).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:     "Another user",
+			addToken: func(c echo.Context) { testutils.AddUserToken(c, 8) },
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+				repo.On("GetByID", mock.Anything, int64(1), int64(10)).Return(&Comment{ID: 10, PollID: 1, UserID: 7}, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"only the author or a moderator can delete a comment"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.CreateContext(http.MethodDelete, "/", "")
+			setParams(c, "1", "10")
+			tt.addToken(c)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.DeleteComment(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_ModerateComment(t *testing.T) {
+	parentID := int64(3)
+	tests := []struct {
+		name           string
+		userID         int64
+		requestBody    string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Owner pins a top-level comment",
+			userID:      5,
+			requestBody: `{"pinned": true}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+				repo.On("GetByID", mock.Anything, int64(1), int64(10)).Return(&Comment{ID: 10, PollID: 1, UserID: 7, Hidden: true}, nil)
+				repo.On("Moderate", mock.Anything, int64(10), true, true).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"pinned":true,"hidden":true`,
+		},
+		{
+			name:        "Pinning a reply",
+			userID:      5,
+			requestBody: `{"pinned": true}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+				repo.On("GetByID", mock.Anything, int64(1), int64(10)).Return(&Comment{ID: 10, PollID: 1, ParentID: &parentID}, nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"only top-level comments can be pinned"`,
+		},
+		{
+			name:        "Not the owner",
+			userID:      7,
+			requestBody: `{"hidden": true}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"only the poll owner or an admin can moderate comments"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.CreateContext(http.MethodPut, "/", tt.requestBody)
+			setParams(c, "1", "10")
+			testutils.AddUserToken(c, tt.userID)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.ModerateComment(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_UpdateSettings(t *testing.T) {
+	tests := []struct {
+		name           string
+		userID         int64
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Owner disables comments",
+			userID: 5,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+				repo.On("SetCommentsEnabled", mock.Anything, int64(1), false).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"poll_id":1,"enabled":false`,
+		},
+		{
+			name:   "Not the owner",
+			userID: 7,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPoll", mock.Anything, int64(1)).Return(openPoll(), nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"only the poll owner or an admin can change comment settings"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.CreateContext(http.MethodPut, "/", `{"enabled": false}`)
+			setParams(c, "1", "")
+			testutils.AddUserToken(c, tt.userID)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.UpdateSettings(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCursor(t *testing.T) {
+	id, err := decodeCursor(encodeCursor(42))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), id)
+
+	_, err = decodeCursor("not a cursor")
+	assert.Error(t, err)
+	_, err = decodeCursor(encodeCursor(0))
+	assert.Error(t, err)
+}
@@ -0,0 +1,46 @@
+package poll
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+)
+
+// receiptBytes is the amount of randomness in a ballot receipt token.
+const receiptBytes = 16
+
+// voterFor identifies a user as a voter on a poll. On anonymous polls the
+// user is replaced by an HMAC-SHA256 of the poll and user IDs keyed with the
+// ballot secret: a user always maps to the same hash, so duplicate votes are
+// still rejected, but the hash cannot be traced back to the user without the
+// secret, which never leaves the API.
+func (s *Service) voterFor(p *Poll, userID int64) Voter {
+	if !p.Anonymous {
+		return Voter{UserID: userID}
+	}
+	mac := hmac.New(sha256.New, s.BallotSecret)
+	mac.Write([]byte(strconv.FormatInt(p.ID, 10) + ":" + strconv.FormatInt(userID, 10)))
+	return Voter{Hash: hex.EncodeToString(mac.Sum(nil))}
+}
+
+// newReceipt generates a receipt token for an anonymous ballot along with
+// the hash stored in its place.
+func newReceipt() (token, hash string, err error) {
+	b := make([]byte, receiptBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", errs.InternalServerError(err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashReceipt(token), nil
+}
+
+// hashReceipt returns the stored form of a receipt token.
+func hashReceipt(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
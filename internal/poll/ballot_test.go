@@ -0,0 +1,42 @@
+package poll
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVoterFor(t *testing.T) {
+	s := NewService(new(MockRepository))
+	s.BallotSecret = []byte("secret")
+
+	regular := &Poll{ID: 1}
+	anonymous := &Poll{ID: 1, Anonymous: true}
+	otherAnonymous := &Poll{ID: 2, Anonymous: true}
+
+	assert.Equal(t, Voter{UserID: 7}, s.voterFor(regular, 7))
+
+	voter := s.voterFor(anonymous, 7)
+	assert.Zero(t, voter.UserID)
+	assert.Len(t, voter.Hash, 64)
+	assert.Equal(t, voter, s.voterFor(anonymous, 7), "a user always gets the same hash on a poll")
+	assert.NotEqual(t, voter, s.voterFor(anonymous, 8))
+	assert.NotEqual(t, voter, s.voterFor(otherAnonymous, 7), "hashes differ between polls")
+
+	s.BallotSecret = []byte("rotated")
+	assert.NotEqual(t, voter, s.voterFor(anonymous, 7))
+}
+
+func TestNewReceipt(t *testing.T) {
+	token, hash, err := newReceipt()
+	require.NoError(t, err)
+
+	assert.Len(t, token, 22)
+	assert.Equal(t, hashReceipt(token), hash)
+	assert.NotEqual(t, token, hash)
+
+	other, _, err := newReceipt()
+	require.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
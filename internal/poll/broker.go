@@ -0,0 +1,239 @@
+package poll
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/logger"
+)
+
+var logging = logger.NewLogger()
+
+// resultsChannel is the Postgres NOTIFY channel carrying the IDs of polls
+// whose results changed.
+const resultsChannel = "poll_results"
+
+// listenRetryDelay is how long the Postgres listener waits before reconnecting.
+const listenRetryDelay = 2 * time.Second
+
+// Broker fans out "results changed" signals for polls to live subscribers.
+// Signals carry no payload: subscribers re-read the results they need.
+type Broker interface {
+	// Publish signals every subscriber of the poll that its results changed.
+	Publish(ctx context.Context, pollID int64) error
+	// Subscribe returns a channel that receives a signal whenever the poll's
+	// results change, and a function that ends the subscription. Signals are
+	// coalesced, so a slow subscriber sees at most one pending signal. The
+	// channel is closed when the broker shuts down.
+	Subscribe(pollID int64) (<-chan struct{}, func())
+	// Close ends every subscription.
+	Close() error
+}
+
+var (
+	_ Broker = (*LocalBroker)(nil)
+	_ Broker = (*PGBroker)(nil)
+)
+
+// LocalBroker is an in-process Broker for a single API instance.
+type LocalBroker struct {
+	mu     sync.Mutex
+	subs   map[int64]map[chan struct{}]struct{}
+	closed bool
+}
+
+// NewLocalBroker creates an in-process broker.
+func NewLocalBroker() *LocalBroker {
+	return &LocalBroker{subs: make(map[int64]map[chan struct{}]struct{})}
+}
+
+func (b *LocalBroker) Publish(_ context.Context, pollID int64) error {
+	b.notify(pollID)
+	return nil
+}
+
+// notify signals the local subscribers of a poll without blocking on any of them.
+func (b *LocalBroker) notify(pollID int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs[pollID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// A signal is already pending; the subscriber will read fresh results anyway
+		}
+	}
+}
+
+// notifyAll signals every local subscriber, e.g. after signals may have been missed.
+func (b *LocalBroker) notifyAll() {
+	b.mu.Lock()
+	ids := make([]int64, 0, len(b.subs))
+	for id := range b.subs {
+		ids = append(ids, id)
+	}
+	b.mu.Unlock()
+
+	for _, id := range ids {
+		b.notify(id)
+	}
+}
+
+func (b *LocalBroker) Subscribe(pollID int64) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if b.subs[pollID] == nil {
+		b.subs[pollID] = make(map[chan struct{}]struct{})
+	}
+	b.subs[pollID][ch] = struct{}{}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+
+			if _, ok := b.subs[pollID][ch]; !ok {
+				// Already closed by Close
+				return
+			}
+			delete(b.subs[pollID], ch)
+			if len(b.subs[pollID]) == 0 {
+				delete(b.subs, pollID)
+			}
+			close(ch)
+		})
+	}
+}
+
+// Subscribers returns the number of live subscriptions to a poll.
+func (b *LocalBroker) Subscribers(pollID int64) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs[pollID])
+}
+
+func (b *LocalBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	for _, chans := range b.subs {
+		for ch := range chans {
+			close(ch)
+		}
+	}
+	b.subs = make(map[int64]map[chan struct{}]struct{})
+	return nil
+}
+
+// PGBroker is a Broker shared by every API instance on the same database.
+// Publish sends a Postgres NOTIFY; each instance LISTENs on a dedicated
+// connection and relays the signals to its local subscribers.
+type PGBroker struct {
+	*LocalBroker
+	DB *sql.DB
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewPGBroker creates a Postgres-backed broker and starts its listener.
+func NewPGBroker(db *sql.DB) *PGBroker {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &PGBroker{
+		LocalBroker: NewLocalBroker(),
+		DB:          db,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+	go b.listen(ctx)
+	return b
+}
+
+func (b *PGBroker) Publish(ctx context.Context, pollID int64) error {
+	_, err := b.DB.ExecContext(ctx, `SELECT pg_notify($1, $2)`, resultsChannel, strconv.FormatInt(pollID, 10))
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// Close stops the listener and ends every local subscription.
+func (b *PGBroker) Close() error {
+	if b.cancel != nil {
+		b.cancel()
+		<-b.done
+	}
+	return b.LocalBroker.Close()
+}
+
+// listen keeps a LISTEN connection open until the broker is closed,
+// reconnecting after failures.
+func (b *PGBroker) listen(ctx context.Context) {
+	defer close(b.done)
+
+	for {
+		err := b.listenOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		logging.Errorf("Poll results listener disconnected: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+func (b *PGBroker) listenOnce(ctx context.Context) error {
+	conn, err := b.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "LISTEN "+resultsChannel); err != nil {
+		return err
+	}
+	// Signals sent while we were disconnected are lost, so refresh everyone
+	b.notifyAll()
+
+	return conn.Raw(func(driverConn any) error {
+		pgConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("unsupported driver connection %T", driverConn)
+		}
+		for {
+			n, err := pgConn.Conn().WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			pollID, err := strconv.ParseInt(n.Payload, 10, 64)
+			if err != nil {
+				logging.Errorf("Ignoring malformed poll results notification %q", n.Payload)
+				continue
+			}
+			b.notify(pollID)
+		}
+	})
+}
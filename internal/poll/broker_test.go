@@ -0,0 +1,94 @@
+package poll
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalBroker(t *testing.T) {
+	t.Run("Fans out to every subscriber of the poll", func(t *testing.T) {
+		b := NewLocalBroker()
+		first, unsubFirst := b.Subscribe(1)
+		second, unsubSecond := b.Subscribe(1)
+		other, unsubOther := b.Subscribe(2)
+		defer unsubFirst()
+		defer unsubSecond()
+		defer unsubOther()
+
+		assert.NoError(t, b.Publish(context.Background(), 1))
+
+		assert.Len(t, first, 1)
+		assert.Len(t, second, 1)
+		assert.Len(t, other, 0)
+	})
+
+	t.Run("Coalesces signals for slow subscribers", func(t *testing.T) {
+		b := NewLocalBroker()
+		ch, unsubscribe := b.Subscribe(1)
+		defer unsubscribe()
+
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, b.Publish(context.Background(), 1))
+		}
+
+		assert.Len(t, ch, 1)
+	})
+
+	t.Run("Unsubscribe removes the subscriber", func(t *testing.T) {
+		b := NewLocalBroker()
+		ch, unsubscribe := b.Subscribe(1)
+		assert.Equal(t, 1, b.Subscribers(1))
+
+		unsubscribe()
+		unsubscribe()
+
+		assert.Equal(t, 0, b.Subscribers(1))
+		_, ok := <-ch
+		assert.False(t, ok)
+	})
+
+	t.Run("Close ends every subscription", func(t *testing.T) {
+		b := NewLocalBroker()
+		ch, unsubscribe := b.Subscribe(1)
+
+		assert.NoError(t, b.Close())
+		unsubscribe()
+
+		_, ok := <-ch
+		assert.False(t, ok)
+
+		// Subscribing after close yields a closed channel
+		late, _ := b.Subscribe(1)
+		_, ok = <-late
+		assert.False(t, ok)
+	})
+}
+
+func TestPGBroker_Publish(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create broker without starting its listener
+	b := &PGBroker{LocalBroker: NewLocalBroker(), DB: db}
+
+	// Setup expectations
+	mock.ExpectExec("SELECT pg_notify\\(\\$1, \\$2\\)").
+		WithArgs(resultsChannel, "42").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Call function under test
+	err = b.Publish(context.Background(), 42)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, b.Close())
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,167 @@
+package poll
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+)
+
+// nextPoll returns the follow-up poll of the option a ballot ranked first.
+func nextPoll(p *Poll, firstChoice int64) *int64 {
+	for _, opt := range p.Options {
+		if opt.ID == firstChoice {
+			return opt.NextPollID
+		}
+	}
+	return nil
+}
+
+// checkFollowUps validates the rules that are to replace the follow-ups of
+// p: every option must belong to p and have at most one rule, every target
+// must be managed by the caller, and the rules must not create a cycle.
+func (s *Service) checkFollowUps(c echo.Context, p *Poll, rules []FollowUpRule) error {
+	ctx := c.Request().Context()
+
+	var targets []int64
+	seenOptions := make(map[int64]bool, len(rules))
+	seenTargets := make(map[int64]bool, len(rules))
+	for _, rule := range rules {
+		if !hasOption(p, rule.OptionID) {
+			return errs.BaseErr(fmt.Sprintf("option %d does not belong to this poll", rule.OptionID))
+		}
+		if seenOptions[rule.OptionID] {
+			return errs.BaseErr(fmt.Sprintf("option %d has more than one follow-up", rule.OptionID))
+		}
+		seenOptions[rule.OptionID] = true
+
+		if seenTargets[rule.NextPollID] {
+			continue
+		}
+		seenTargets[rule.NextPollID] = true
+		targets = append(targets, rule.NextPollID)
+
+		if rule.NextPollID == p.ID {
+			continue
+		}
+		next, err := s.Repo.GetByID(ctx, rule.NextPollID)
+		if err != nil {
+			return err
+		}
+		if !canManage(c, next) {
+			return errs.Forbidden(fmt.Errorf("you do not manage poll %d", next.ID))
+		}
+	}
+
+	cycle, err := s.findCycle(ctx, p.ID, targets)
+	if err != nil {
+		return err
+	}
+	if cycle != nil {
+		return errs.BaseErr("follow-up rules would create a cycle: " + joinPollIDs(cycle))
+	}
+	return nil
+}
+
+// findCycle walks the existing follow-ups from targets and returns the chain
+// of polls leading back to pollID, starting and ending with it, or nil when
+// pointing pollID at targets keeps the follow-up graph acyclic. The graph is
+// acyclic before the change, so any new cycle has to pass through pollID.
+func (s *Service) findCycle(ctx context.Context, pollID int64, targets []int64) ([]int64, error) {
+	parent := make(map[int64]int64, len(targets))
+	queue := make([]int64, 0, len(targets))
+	for _, id := range targets {
+		parent[id] = pollID
+		queue = append(queue, id)
+	}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == pollID {
+			path := []int64{pollID}
+			for at := parent[pollID]; at != pollID; at = parent[at] {
+				path = append(path, at)
+			}
+			path = append(path, pollID)
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+			return path, nil
+		}
+
+		rules, err := s.Repo.GetFollowUps(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range rules {
+			if _, seen := parent[rule.NextPollID]; !seen {
+				parent[rule.NextPollID] = id
+				queue = append(queue, rule.NextPollID)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// joinPollIDs formats a chain of polls as "12 -> 15 -> 12".
+func joinPollIDs(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// funnel walks the follow-up chain from p breadth-first and counts, for each
+// rule, how many voters entered it and how many went on to vote in the next
+// poll. A poll reached by several rules is only expanded once.
+func (s *Service) funnel(ctx context.Context, p *Poll) (*FunnelResponse, error) {
+	voters, err := s.Repo.CountVoters(ctx, p.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &FunnelResponse{PollID: p.ID, Voters: voters, Steps: []FunnelStep{}}
+	depth := map[int64]int{p.ID: 0}
+	queue := []int64{p.ID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		rules, err := s.Repo.GetFollowUps(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range rules {
+			entered, continued, err := s.Repo.GetFunnelStep(ctx, id, rule.OptionID, rule.NextPollID)
+			if err != nil {
+				return nil, err
+			}
+			step := FunnelStep{
+				FromPollID: id,
+				OptionID:   rule.OptionID,
+				NextPollID: rule.NextPollID,
+				Depth:      depth[id] + 1,
+				Entered:    entered,
+				Continued:  continued,
+				DropOff:    entered - continued,
+			}
+			if entered > 0 {
+				step.DropOffRate = math.Round(float64(step.DropOff)*1000/float64(entered)) / 10
+			}
+			resp.Steps = append(resp.Steps, step)
+
+			if _, seen := depth[rule.NextPollID]; !seen {
+				depth[rule.NextPollID] = depth[id] + 1
+				queue = append(queue, rule.NextPollID)
+			}
+		}
+	}
+	return resp, nil
+}
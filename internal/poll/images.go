@@ -0,0 +1,171 @@
+package poll
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+
+	// Register the GIF decoder; JPEG and PNG are registered by the
+	// encoders imported above.
+	_ "image/gif"
+
+	"github.com/labstack/echo/v4"
+
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/imaging"
+)
+
+// imagesPath is where the images endpoint serves stored images.
+const imagesPath = "/api/v1/poll/images/"
+
+// defaultMaxImageSize is the largest image upload accepted, in bytes, when
+// the service is not configured otherwise.
+const defaultMaxImageSize = 5 << 20
+
+// multipartOverhead is the room left in an upload body, beyond the image
+// itself, for the multipart boundaries and part headers.
+const multipartOverhead = 64 << 10
+
+// defaultThumbnailSize is the longest side of generated thumbnails when the
+// service is not configured otherwise.
+const defaultThumbnailSize = 256
+
+// maxImagePixels bounds the decoded size of an upload, so a small file
+// cannot expand into an enormous bitmap.
+const maxImagePixels = 25_000_000
+
+// thumbnailQuality is the JPEG quality of thumbnails of JPEG images.
+const thumbnailQuality = 85
+
+// imageExtensions maps the accepted image content types to the extension
+// they are stored with.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// newImage describes the image stored under key with its thumbnail under
+// thumbnailKey, or returns nil when there is no image.
+func newImage(key, thumbnailKey string) *Image {
+	if key == "" {
+		return nil
+	}
+	return &Image{
+		Key:          key,
+		ThumbnailKey: thumbnailKey,
+		URL:          imagesPath + key,
+		ThumbnailURL: imagesPath + thumbnailKey,
+	}
+}
+
+// uploadImage stores the image uploaded in the image field of a multipart
+// form.
+func (s *Service) uploadImage(c echo.Context) (*Image, error) {
+	if s.Storage == nil {
+		return nil, errs.BaseErr("image uploads are not configured")
+	}
+	tooLarge := errs.BaseErr(fmt.Sprintf("images can be at most %d bytes", s.MaxImageSize))
+	// Bound the body before the form is parsed, so an oversized upload is
+	// refused without being read to the end
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, s.MaxImageSize+multipartOverhead)
+	fh, err := c.FormFile("image")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, tooLarge
+		}
+		return nil, errs.BadRequest(errors.New("an image file is required in the image field"))
+	}
+	if fh.Size > s.MaxImageSize {
+		return nil, tooLarge
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, s.MaxImageSize+1))
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	if int64(len(data)) > s.MaxImageSize {
+		return nil, tooLarge
+	}
+	return s.saveImage(c.Request().Context(), data)
+}
+
+// saveImage checks that data is a JPEG, PNG or GIF image judging by its
+// content rather than its name, generates its thumbnail and stores both
+// under a fresh random key. Thumbnails of JPEG images are JPEG; the others
+// are PNG, to keep transparency.
+func (s *Service) saveImage(ctx context.Context, data []byte) (*Image, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		return nil, errs.BaseErr("images must be JPEG, PNG or GIF")
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errs.BaseErr("image could not be decoded")
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, errs.BaseErr(fmt.Sprintf("images can be at most %d megapixels", maxImagePixels/1_000_000))
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errs.BaseErr("image could not be decoded")
+	}
+
+	thumb := imaging.Thumbnail(src, s.ThumbnailSize)
+	var buf bytes.Buffer
+	thumbExt, thumbType := ".png", "image/png"
+	if contentType == "image/jpeg" {
+		thumbExt, thumbType = ".jpg", "image/jpeg"
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: thumbnailQuality})
+	} else {
+		err = png.Encode(&buf, thumb)
+	}
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+
+	id, err := newSlug()
+	if err != nil {
+		return nil, err
+	}
+	img := newImage(id+ext, id+"_thumb"+thumbExt)
+	if err := s.Storage.Put(ctx, img.Key, bytes.NewReader(data), contentType); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	if err := s.Storage.Put(ctx, img.ThumbnailKey, &buf, thumbType); err != nil {
+		s.deleteImage(ctx, &Image{Key: img.Key})
+		return nil, errs.InternalServerError(err)
+	}
+	return img, nil
+}
+
+// deleteImage removes an image and its thumbnail from storage. It runs once
+// the image is no longer referenced, so failures are logged rather than
+// returned.
+func (s *Service) deleteImage(ctx context.Context, img *Image) {
+	if img == nil || s.Storage == nil {
+		return
+	}
+	for _, key := range []string{img.Key, img.ThumbnailKey} {
+		if key == "" {
+			continue
+		}
+		if err := s.Storage.Delete(ctx, key); err != nil {
+			logging.Errorf("Deleting image %s: %v", key, err)
+		}
+	}
+}
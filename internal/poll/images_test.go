@@ -0,0 +1,415 @@
+package poll
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/storage"
+)
+
+// encodeImage returns a w by h image encoded as PNG or JPEG.
+func encodeImage(t *testing.T, format string, w, h int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		require.NoError(t, jpeg.Encode(&buf, img, nil))
+	} else {
+		require.NoError(t, png.Encode(&buf, img))
+	}
+	return buf.Bytes()
+}
+
+// readBlob returns the blob stored under key, decoded as an image.
+func readBlob(t *testing.T, store storage.Storage, key string) (image.Image, string) {
+	obj, err := store.Get(context.Background(), key)
+	require.NoError(t, err)
+	defer obj.Body.Close()
+	img, format, err := image.Decode(obj.Body)
+	require.NoError(t, err)
+	return img, format
+}
+
+// multipartContext builds a request uploading data as the image field.
+func multipartContext(t *testing.T, data []byte) (echo.Context, *httptest.ResponseRecorder) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("image", "upload.bin")
+	require.NoError(t, err)
+	_, err = part.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPut, "/", &body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+func TestService_SaveImage(t *testing.T) {
+	store, err := storage.NewLocal(t.TempDir())
+	require.NoError(t, err)
+	service := NewService(new(MockRepository))
+	service.Storage = store
+	ctx := context.Background()
+
+	t.Run("PNG gets a PNG thumbnail", func(t *testing.T) {
+		img, err := service.saveImage(ctx, encodeImage(t, "png", 600, 300))
+		require.NoError(t, err)
+		assert.True(t, strings.HasSuffix(img.Key, ".png"))
+		assert.Equal(t, strings.TrimSuffix(img.Key, ".png")+"_thumb.png", img.ThumbnailKey)
+		assert.Equal(t, imagesPath+img.Key, img.URL)
+
+		original, _ := readBlob(t, store, img.Key)
+		assert.Equal(t, image.Rect(0, 0, 600, 300), original.Bounds())
+		thumb, format := readBlob(t, store, img.ThumbnailKey)
+		assert.Equal(t, "png", format)
+		assert.Equal(t, image.Rect(0, 0, 256, 128), thumb.Bounds())
+	})
+
+	t.Run("JPEG gets a JPEG thumbnail", func(t *testing.T) {
+		img, err := service.saveImage(ctx, encodeImage(t, "jpeg", 100, 400))
+		require.NoError(t, err)
+		assert.True(t, strings.HasSuffix(img.Key, ".jpg"))
+		thumb, format := readBlob(t, store, img.ThumbnailKey)
+		assert.Equal(t, "jpeg", format)
+		assert.Equal(t, image.Rect(0, 0, 64, 256), thumb.Bounds())
+	})
+
+	t.Run("Content is checked, not the name", func(t *testing.T) {
+		_, err := service.saveImage(ctx, []byte("<svg xmlns='http://www.w3.org/2000/svg'></svg>"))
+		assert.ErrorContains(t, err, "images must be JPEG, PNG or GIF")
+	})
+
+	t.Run("Corrupt image", func(t *testing.T) {
+		data := encodeImage(t, "png", 10, 10)
+		_, err := service.saveImage(ctx, data[:40])
+		assert.ErrorContains(t, err, "image could not be decoded")
+	})
+}
+
+func TestService_UploadCoverImage(t *testing.T) {
+	tests := []struct {
+		name           string
+		userID         int64
+		noStorage      bool
+		maxSize        int64
+		data           []byte
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Owner replaces the cover image",
+			userID: 3,
+			data:   encodeImage(t, "png", 40, 20),
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).
+					Return(&Poll{ID: 1, UserID: 3, CoverImage: newImage("old.png", "old_thumb.png")}, nil)
+				repo.On("SetCoverImage", mock.Anything, int64(1), mock.MatchedBy(func(img *Image) bool {
+					return strings.HasSuffix(img.Key, ".png") && strings.HasSuffix(img.ThumbnailKey, "_thumb.png")
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"thumbnail_url":"/api/v1/poll/images/`,
+		},
+		{
+			name:   "Non-owner is forbidden",
+			userID: 4,
+			data:   encodeImage(t, "png", 40, 20),
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(&Poll{ID: 1, UserID: 3}, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"only the poll owner or an admin can change its images"`,
+		},
+		{
+			name:    "Too large",
+			userID:  3,
+			maxSize: 100,
+			data:    encodeImage(t, "png", 200, 200),
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(&Poll{ID: 1, UserID: 3}, nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"images can be at most 100 bytes"`,
+		},
+		{
+			name:    "Body over the limit is refused before parsing",
+			userID:  3,
+			maxSize: 100,
+			data:    make([]byte, multipartOverhead+1<<10),
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(&Poll{ID: 1, UserID: 3}, nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"images can be at most 100 bytes"`,
+		},
+		{
+			name:      "Uploads not configured",
+			userID:    3,
+			noStorage: true,
+			data:      encodeImage(t, "png", 40, 20),
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(&Poll{ID: 1, UserID: 3}, nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"image uploads are not configured"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := multipartContext(t, tt.data)
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			addUserToken(c, tt.userID)
+
+			store, err := storage.NewLocal(t.TempDir())
+			require.NoError(t, err)
+			require.NoError(t, store.Put(context.Background(), "old.png", strings.NewReader("old"), "image/png"))
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+			if !tt.noStorage {
+				service.Storage = store
+			}
+			if tt.maxSize > 0 {
+				service.MaxImageSize = tt.maxSize
+			}
+
+			// Execute
+			err = service.UploadCoverImage(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+			if tt.expectedStatus == http.StatusOK {
+				// The replaced image is removed from storage
+				_, err := store.Get(context.Background(), "old.png")
+				assert.ErrorIs(t, err, storage.ErrNotFound)
+			}
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_DeleteOptionImage(t *testing.T) {
+	poll := &Poll{ID: 1, UserID: 3, Options: []Option{{ID: 2, PollID: 1, Text: "Go", Image: newImage("go.png", "go_thumb.png")}}}
+
+	tests := []struct {
+		name           string
+		optionID       string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:     "Owner removes the option image",
+			optionID: "2",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(poll, nil)
+				repo.On("SetOptionImage", mock.Anything, int64(1), int64(2), (*Image)(nil)).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"Option image removed successfully"`,
+		},
+		{
+			name:     "Option of another poll",
+			optionID: "9",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(poll, nil)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"error":"option not found"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodDelete, "/", "")
+			c.SetParamNames("id", "option_id")
+			c.SetParamValues("1", tt.optionID)
+			addUserToken(c, 3)
+
+			store, err := storage.NewLocal(t.TempDir())
+			require.NoError(t, err)
+			require.NoError(t, store.Put(context.Background(), "go.png", strings.NewReader("go"), "image/png"))
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+			service.Storage = store
+
+			// Execute
+			err = service.DeleteOptionImage(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+			_, err = store.Get(context.Background(), "go.png")
+			if tt.expectedStatus == http.StatusOK {
+				assert.ErrorIs(t, err, storage.ErrNotFound)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_ImagesOfRemovedOptionsAndPolls(t *testing.T) {
+	newPoll := func() *Poll {
+		return &Poll{ID: 1, UserID: 3, Type: TypeSingle, Status: StatusOpen,
+			CoverImage: newImage("cover.png", "cover_thumb.png"),
+			Options: []Option{
+				{ID: 1, PollID: 1, Text: "Go", Image: newImage("go.png", "go_thumb.png")},
+				{ID: 2, PollID: 1, Text: "Rust"},
+			}}
+	}
+	setup := func(t *testing.T, repo *MockRepository) (*Service, storage.Storage) {
+		store, err := storage.NewLocal(t.TempDir())
+		require.NoError(t, err)
+		for _, key := range []string{"cover.png", "cover_thumb.png", "go.png", "go_thumb.png"} {
+			require.NoError(t, store.Put(context.Background(), key, strings.NewReader(key), "image/png"))
+		}
+		service := NewService(repo)
+		service.Storage = store
+		return service, store
+	}
+	stored := func(store storage.Storage, key string) bool {
+		_, err := store.Get(context.Background(), key)
+		return err == nil
+	}
+
+	t.Run("Replacing the options removes their images", func(t *testing.T) {
+		repo := new(MockRepository)
+		repo.On("GetByID", mock.Anything, int64(1)).Return(newPoll(), nil)
+		repo.On("Update", mock.Anything, mock.AnythingOfType("*poll.Poll"), true, true, false).Return(nil)
+		service, store := setup(t, repo)
+
+		c, rec := setupEchoContext(http.MethodPatch, "/", `{"options":["X","Y"]}`)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		addUserToken(c, 3)
+
+		assert.NoError(t, service.UpdatePoll(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.False(t, stored(store, "go.png"))
+		assert.False(t, stored(store, "go_thumb.png"))
+		assert.True(t, stored(store, "cover.png"))
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("A failed edit keeps the images", func(t *testing.T) {
+		repo := new(MockRepository)
+		repo.On("GetByID", mock.Anything, int64(1)).Return(newPoll(), nil)
+		repo.On("Update", mock.Anything, mock.AnythingOfType("*poll.Poll"), true, true, false).
+			Return(errs.Conflict(errors.New("question and options cannot be edited once voting has started")))
+		service, store := setup(t, repo)
+
+		c, rec := setupEchoContext(http.MethodPatch, "/", `{"options":["X","Y"]}`)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		addUserToken(c, 3)
+
+		assert.NoError(t, service.UpdatePoll(c))
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.True(t, stored(store, "go.png"))
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Deleting the poll removes all its images", func(t *testing.T) {
+		repo := new(MockRepository)
+		repo.On("GetByID", mock.Anything, int64(1)).Return(newPoll(), nil)
+		repo.On("SoftDelete", mock.Anything, int64(1)).Return(nil)
+		service, store := setup(t, repo)
+
+		c, rec := setupEchoContext(http.MethodDelete, "/", "")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		addUserToken(c, 3)
+
+		assert.NoError(t, service.DeletePoll(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		for _, key := range []string{"cover.png", "cover_thumb.png", "go.png", "go_thumb.png"} {
+			assert.False(t, stored(store, key), key)
+		}
+		repo.AssertExpectations(t)
+	})
+}
+
+func TestService_GetImage(t *testing.T) {
+	store, err := storage.NewLocal(t.TempDir())
+	require.NoError(t, err)
+	data := encodeImage(t, "png", 4, 4)
+	require.NoError(t, store.Put(context.Background(), "abc.png", bytes.NewReader(data), "image/png"))
+
+	service := NewService(new(MockRepository))
+	service.Storage = store
+
+	tests := []struct {
+		name           string
+		key            string
+		expectedStatus int
+	}{
+		{name: "Stored image", key: "abc.png", expectedStatus: http.StatusOK},
+		{name: "Missing image", key: "missing.png", expectedStatus: http.StatusNotFound},
+		{name: "Invalid key", key: "..", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodGet, "/", "")
+			c.SetParamNames("key")
+			c.SetParamValues(tt.key)
+
+			// Execute
+			err := service.GetImage(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, "image/png", rec.Header().Get(echo.HeaderContentType))
+				assert.Contains(t, rec.Header().Get("Cache-Control"), "immutable")
+				body, _ := io.ReadAll(rec.Body)
+				assert.Equal(t, data, body)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package poll
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/mock"
@@ -26,21 +27,222 @@ func (m *MockRepository) GetByID(ctx context.Context, id int64) (*Poll, error) {
 	return args.Get(0).(*Poll), args.Error(1)
 }
 
-func (m *MockRepository) Vote(ctx context.Context, pollID, optionID, userID int64) error {
-	args := m.Called(ctx, pollID, optionID, userID)
+func (m *MockRepository) Vote(ctx context.Context, pollID int64, voter Voter, optionIDs []int64, receiptHash string) error {
+	args := m.Called(ctx, pollID, voter, optionIDs, receiptHash)
 	return args.Error(0)
 }
 
+func (m *MockRepository) GetBallots(ctx context.Context, pollID int64) ([]Ballot, error) {
+	args := m.Called(ctx, pollID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Ballot), args.Error(1)
+}
+
 func (m *MockRepository) GetResults(ctx context.Context, pollID int64) ([]Option, error) {
 	args := m.Called(ctx, pollID)
 	return args.Get(0).([]Option), args.Error(1)
 }
 
-func (m *MockRepository) HasUserVoted(ctx context.Context, pollID int64, userID int64) (bool, error) {
+func (m *MockRepository) GetVoteTimeline(ctx context.Context, pollID int64, period string, firstPreferences bool) ([]TimeBucket, error) {
+	args := m.Called(ctx, pollID, period, firstPreferences)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]TimeBucket), args.Error(1)
+}
+
+func (m *MockRepository) GetCohortBreakdown(ctx context.Context, pollID int64, firstPreferences bool) ([]CohortBucket, error) {
+	args := m.Called(ctx, pollID, firstPreferences)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]CohortBucket), args.Error(1)
+}
+
+func (m *MockRepository) StreamVotes(ctx context.Context, pollID int64, fn func(Vote) error) error {
+	args := m.Called(ctx, pollID, fn)
+	if votes, ok := args.Get(0).([]Vote); ok {
+		for _, v := range votes {
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockRepository) HasUserVoted(ctx context.Context, pollID int64, voter Voter) (bool, error) {
+	args := m.Called(ctx, pollID, voter)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) List(ctx context.Context, f ListPollsFilter) ([]Poll, error) {
+	args := m.Called(ctx, f)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Poll), args.Error(1)
+}
+
+func (m *MockRepository) Count(ctx context.Context, f ListPollsFilter) (int, error) {
+	args := m.Called(ctx, f)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) UpdateStatus(ctx context.Context, id int64, status string) error {
+	args := m.Called(ctx, id, status)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ChangeVote(ctx context.Context, pollID int64, voter Voter, optionIDs []int64, receiptHash string) error {
+	args := m.Called(ctx, pollID, voter, optionIDs, receiptHash)
+	return args.Error(0)
+}
+
+func (m *MockRepository) RetractVote(ctx context.Context, pollID int64, voter Voter) error {
+	args := m.Called(ctx, pollID, voter)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetReceipt(ctx context.Context, pollID int64, receiptHash string) (*ReceiptResponse, error) {
+	args := m.Called(ctx, pollID, receiptHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ReceiptResponse), args.Error(1)
+}
+
+func (m *MockRepository) Update(ctx context.Context, p *Poll, editsBallot, replaceOptions, replaceTags bool) error {
+	args := m.Called(ctx, p, editsBallot, replaceOptions, replaceTags)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetTrending(ctx context.Context, window time.Duration, gravity float64, limit int) ([]TrendingPoll, error) {
+	args := m.Called(ctx, window, gravity, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]TrendingPoll), args.Error(1)
+}
+
+func (m *MockRepository) ListTags(ctx context.Context, prefix string, limit int) ([]Tag, error) {
+	args := m.Called(ctx, prefix, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Tag), args.Error(1)
+}
+
+func (m *MockRepository) SoftDelete(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) SetFollowUps(ctx context.Context, pollID int64, rules []FollowUpRule) error {
+	args := m.Called(ctx, pollID, rules)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetFollowUps(ctx context.Context, pollID int64) ([]FollowUpRule, error) {
+	args := m.Called(ctx, pollID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]FollowUpRule), args.Error(1)
+}
+
+func (m *MockRepository) GetBySlug(ctx context.Context, slug string) (*Poll, error) {
+	args := m.Called(ctx, slug)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Poll), args.Error(1)
+}
+
+func (m *MockRepository) IsUserActive(ctx context.Context, userID int64) (bool, error) {
+	args := m.Called(ctx, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) IsInvited(ctx context.Context, pollID, userID int64) (bool, error) {
 	args := m.Called(ctx, pollID, userID)
 	return args.Bool(0), args.Error(1)
 }
 
+func (m *MockRepository) AddInvites(ctx context.Context, pollID int64, userIDs []int64) error {
+	args := m.Called(ctx, pollID, userIDs)
+	return args.Error(0)
+}
+
+func (m *MockRepository) RemoveInvite(ctx context.Context, pollID, userID int64) error {
+	args := m.Called(ctx, pollID, userID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListInvites(ctx context.Context, pollID int64) ([]int64, error) {
+	args := m.Called(ctx, pollID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockRepository) CreateTemplate(ctx context.Context, t *PollTemplate) error {
+	args := m.Called(ctx, t)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetTemplate(ctx context.Context, id int64) (*PollTemplate, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*PollTemplate), args.Error(1)
+}
+
+func (m *MockRepository) ListTemplates(ctx context.Context, userID int64) ([]PollTemplate, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]PollTemplate), args.Error(1)
+}
+
+func (m *MockRepository) DeleteTemplate(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) SetCoverImage(ctx context.Context, pollID int64, img *Image) error {
+	args := m.Called(ctx, pollID, img)
+	return args.Error(0)
+}
+
+func (m *MockRepository) SetOptionImage(ctx context.Context, pollID, optionID int64, img *Image) error {
+	args := m.Called(ctx, pollID, optionID, img)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetQuizBallots(ctx context.Context, pollID int64) ([]QuizBallot, error) {
+	args := m.Called(ctx, pollID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]QuizBallot), args.Error(1)
+}
+
+func (m *MockRepository) CountVoters(ctx context.Context, pollID int64) (int64, error) {
+	args := m.Called(ctx, pollID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) GetFunnelStep(ctx context.Context, pollID, optionID, nextPollID int64) (int64, int64, error) {
+	args := m.Called(ctx, pollID, optionID, nextPollID)
+	return args.Get(0).(int64), args.Get(1).(int64), args.Error(2)
+}
+
 // MockDBService implements database.Service interface for testing
 type MockDBService struct {
 	mock.Mock
@@ -89,3 +291,168 @@ func (m *MockPollService) GetResults(c echo.Context) error {
 	args := m.Called(c)
 	return args.Error(0)
 }
+
+func (m *MockPollService) ListPolls(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) PublishPoll(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) ClosePoll(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) ChangeVote(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) RetractVote(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) StreamResults(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) PollRoom(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) ExportResults(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) VerifyReceipt(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) UpdatePoll(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) DeletePoll(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) SetFollowUps(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) GetFunnel(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) GetLeaderboard(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) InviteUsers(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) ListInvites(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) RemoveInvite(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) CreateInviteLink(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) ListTags(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) ListPollsByTag(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) GetTrending(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) ClonePoll(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) CreateTemplate(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) ListTemplates(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) GetTemplate(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) DeleteTemplate(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) CreatePollFromTemplate(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) UploadCoverImage(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) DeleteCoverImage(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) UploadOptionImage(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) DeleteOptionImage(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockPollService) GetImage(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+// ResolvePoll passes every request through, so route tests reach the handlers.
+func (m *MockPollService) ResolvePoll(next echo.HandlerFunc) echo.HandlerFunc {
+	return next
+}
@@ -1,6 +1,11 @@
 package poll
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+)
 
 // Poll represents a poll/question.
 type Poll struct {
@@ -9,6 +14,109 @@ type Poll struct {
 	Options   []Option  `json:"options,omitempty"`
 	UserID    int64     `json:"user_id"`
 	CreatedAt time.Time `json:"created_at"`
+	Type      string    `json:"poll_type" example:"single"`
+	// MinSelections and MaxSelections bound the number of options a voter
+	// picks in a multi poll. They are zero for other poll types.
+	MinSelections int `json:"min_selections,omitempty" example:"1"`
+	MaxSelections int `json:"max_selections,omitempty" example:"3"`
+	// AllowVoteChange lets voters change or retract their ballot while the
+	// poll is open.
+	AllowVoteChange bool `json:"allow_vote_change" example:"false"`
+	// Anonymous polls store a salted hash of each voter instead of the user,
+	// so ballots cannot be linked back to voters. Voters get a receipt token
+	// to check that their ballot was counted.
+	Anonymous bool `json:"anonymous" example:"false"`
+	// ExportVotes lets the owner export every ballot alongside the tallies.
+	// It is fixed at creation so voters know before they vote.
+	ExportVotes bool `json:"export_votes" example:"false"`
+	// Quiz polls mark correct options and score every ballot. Their results
+	// are hidden from voters until the quiz closes.
+	Quiz     bool       `json:"quiz" example:"false"`
+	Status   string     `json:"status" example:"open"`
+	OpensAt  *time.Time `json:"opens_at,omitempty"`
+	ClosesAt *time.Time `json:"closes_at,omitempty"`
+	// Visibility is public, unlisted or invite_only. Unlisted polls are only
+	// reachable through Slug, which replaces the ID in their URLs.
+	Visibility string `json:"visibility" example:"public"`
+	Slug       string `json:"slug,omitempty" example:"q3J8dUaZ0xT1kLmN"`
+	// ResultsVisibility says who may see the results before the poll closes:
+	// always, after_vote, after_close or owner_only.
+	ResultsVisibility string `json:"results_visibility" example:"always"`
+	// Tags organise polls for browsing, in alphabetical order.
+	Tags       []string `json:"tags,omitempty" example:"[\"programming\",\"go\"]"`
+	CoverImage *Image   `json:"cover_image,omitempty"`
+	TotalVotes int64    `json:"total_votes,omitempty"`
+}
+
+// Poll types.
+const (
+	// TypeSingle polls accept exactly one option per voter.
+	TypeSingle = "single"
+	// TypeMulti polls accept between MinSelections and MaxSelections options.
+	TypeMulti = "multi"
+	// TypeRanked polls accept an ordered preference list and are decided by
+	// instant-runoff.
+	TypeRanked = "ranked"
+)
+
+// Poll lifecycle statuses.
+const (
+	StatusDraft    = "draft"
+	StatusOpen     = "open"
+	StatusClosed   = "closed"
+	StatusArchived = "archived"
+)
+
+// Poll visibilities.
+const (
+	// VisibilityPublic polls are listed and reachable by ID.
+	VisibilityPublic = "public"
+	// VisibilityUnlisted polls are left out of listings and only reachable
+	// by their slug.
+	VisibilityUnlisted = "unlisted"
+	// VisibilityInviteOnly polls can only be viewed and voted on by invited
+	// users and holders of a signed invite link.
+	VisibilityInviteOnly = "invite_only"
+)
+
+// Results visibility policies. The poll owner and admins always see results.
+const (
+	// ResultsAlways shows results to everyone who can see the poll.
+	ResultsAlways = "always"
+	// ResultsAfterVote shows results to voters, and to everyone once the
+	// poll closes.
+	ResultsAfterVote = "after_vote"
+	// ResultsAfterClose shows results to everyone once the poll closes.
+	ResultsAfterClose = "after_close"
+	// ResultsOwnerOnly never shows results to anyone but the owner.
+	ResultsOwnerOnly = "owner_only"
+)
+
+// IsOpen reports whether the poll accepts votes at the given time: it must be
+// published and inside its opens_at/closes_at window.
+func (p *Poll) IsOpen(now time.Time) bool {
+	if p.Status != StatusOpen {
+		return false
+	}
+	if p.OpensAt != nil && now.Before(*p.OpensAt) {
+		return false
+	}
+	if p.ClosesAt != nil && !now.Before(*p.ClosesAt) {
+		return false
+	}
+	return true
+}
+
+// IsFinal reports whether the poll's results can no longer change at the
+// given time.
+func (p *Poll) IsFinal(now time.Time) bool {
+	switch p.Status {
+	case StatusClosed, StatusArchived:
+		return true
+	case StatusOpen:
+		return p.ClosesAt != nil && !now.Before(*p.ClosesAt)
+	}
+	return false
 }
 
 // Option represents an option/answer for a poll.
@@ -17,21 +125,102 @@ type Option struct {
 	PollID int64  `json:"poll_id"`
 	Text   string `json:"text"`
 	Votes  int64  `json:"votes,omitempty"`
+	// NextPollID is the follow-up poll shown to voters whose first choice is
+	// this option.
+	NextPollID *int64 `json:"next_poll_id,omitempty" example:"15"`
+	// Correct and Points mark the right answers of a quiz and what they are
+	// worth. They are hidden from voters until the quiz closes.
+	Correct bool   `json:"correct,omitempty" example:"true"`
+	Points  int    `json:"points,omitempty" example:"10"`
+	Image   *Image `json:"image,omitempty"`
+}
+
+// Image is an uploaded picture and the thumbnail generated from it. Key and
+// ThumbnailKey locate them in storage; clients fetch them through URL and
+// ThumbnailURL.
+type Image struct {
+	Key          string `json:"-"`
+	ThumbnailKey string `json:"-"`
+	URL          string `json:"url" example:"/api/v1/poll/images/q3J8dUaZ0xT1kLmN.png"`
+	ThumbnailURL string `json:"thumbnail_url" example:"/api/v1/poll/images/q3J8dUaZ0xT1kLmN_thumb.png"`
 }
 
 // Vote represents a vote cast by a user for a particular option in a poll.
+// Multi and ranked polls store one Vote per selected option; Rank is the
+// 1-based position of the option in the voter's ballot.
 type Vote struct {
 	ID        int64     `json:"id"`
 	PollID    int64     `json:"poll_id"`
 	OptionID  int64     `json:"option_id"`
 	UserID    int64     `json:"user_id"`
+	Rank      int       `json:"rank"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// Voter identifies who cast a ballot: the user on regular polls, or only a
+// salted hash of the user on anonymous polls.
+type Voter struct {
+	UserID int64
+	Hash   string
+}
+
+// Vote history actions recorded in poll_vote_history.
+const (
+	VoteActionChange  = "change"
+	VoteActionRetract = "retract"
+)
+
+// Ballot is one voter's selections in a poll, ordered by rank.
+type Ballot []int64
+
 // CreatePollRequest represents the request payload for creating a new poll
 type CreatePollRequest struct {
 	Question string   `json:"question" example:"What is your favorite programming language?"`
 	Options  []string `json:"options" example:"[\"Go\",\"Python\",\"JavaScript\",\"Java\"]"`
+	// PollType is single (default), multi or ranked.
+	PollType        string `json:"poll_type,omitempty" example:"single"`
+	MinSelections   int    `json:"min_selections,omitempty" example:"1"`
+	MaxSelections   int    `json:"max_selections,omitempty" example:"2"`
+	AllowVoteChange bool   `json:"allow_vote_change,omitempty" example:"true"`
+	// Anonymous cannot be changed once the poll is created.
+	Anonymous bool `json:"anonymous,omitempty" example:"false"`
+	// ExportVotes cannot be changed once the poll is created.
+	ExportVotes bool `json:"export_votes,omitempty" example:"false"`
+	// Quiz turns a single or multi poll into a quiz scored by CorrectOptions.
+	Quiz           bool            `json:"quiz,omitempty" example:"false"`
+	CorrectOptions []CorrectOption `json:"correct_options,omitempty"`
+	// Visibility is public (default), unlisted or invite_only.
+	Visibility string `json:"visibility,omitempty" example:"public"`
+	// ResultsVisibility is always (default), after_vote, after_close or owner_only.
+	ResultsVisibility string `json:"results_visibility,omitempty" example:"after_vote"`
+	// Tags are lowercased; spaces become dashes.
+	Tags []string `json:"tags,omitempty" example:"[\"programming\",\"go\"]"`
+	// Status is either "draft" or "open" (default); drafts must be published before they accept votes.
+	Status   string     `json:"status,omitempty" example:"open"`
+	OpensAt  *time.Time `json:"opens_at,omitempty" example:"2025-05-18T09:00:00Z"`
+	ClosesAt *time.Time `json:"closes_at,omitempty" example:"2025-05-25T09:00:00Z"`
+}
+
+// CorrectOption marks the option at Index in CreatePollRequest.Options as a
+// right answer worth Points, which defaults to 1.
+type CorrectOption struct {
+	Index  int `json:"index" example:"0"`
+	Points int `json:"points,omitempty" example:"10"`
+}
+
+// UpdatePollRequest represents the request payload for editing a poll.
+// Omitted fields are left unchanged. The question and options can only be
+// edited while the poll has no votes.
+type UpdatePollRequest struct {
+	Question          *string    `json:"question,omitempty" example:"What is your favorite programming language?"`
+	Options           []string   `json:"options,omitempty" example:"[\"Go\",\"Python\",\"JavaScript\",\"Java\"]"`
+	AllowVoteChange   *bool      `json:"allow_vote_change,omitempty" example:"true"`
+	OpensAt           *time.Time `json:"opens_at,omitempty" example:"2025-05-18T09:00:00Z"`
+	ClosesAt          *time.Time `json:"closes_at,omitempty" example:"2025-05-25T09:00:00Z"`
+	Visibility        *string    `json:"visibility,omitempty" example:"unlisted"`
+	ResultsVisibility *string    `json:"results_visibility,omitempty" example:"after_close"`
+	// Tags replaces every tag of the poll; an empty list removes them all.
+	Tags []string `json:"tags,omitempty" example:"[\"programming\"]"`
 }
 
 // CreatePollResponse represents the response for a successfully created poll
@@ -39,32 +228,408 @@ type CreatePollResponse struct {
 	Poll Poll `json:"poll"`
 }
 
-// VotePollRequest represents the request payload for voting on a poll
+// VotePollRequest represents the request payload for voting on a poll.
+// Single polls take option_id; multi polls take the chosen option_ids and
+// ranked polls take option_ids in order of preference.
 type VotePollRequest struct {
-	OptionID int64 `json:"option_id" example:"1"`
+	OptionID  int64   `json:"option_id,omitempty" example:"1"`
+	OptionIDs []int64 `json:"option_ids,omitempty" example:"2,1,3"`
+}
+
+// Selections returns the ballot as a list of option IDs. A single option_id
+// is accepted as a one-option ballot.
+func (r VotePollRequest) Selections() ([]int64, error) {
+	selections := r.OptionIDs
+	if len(selections) == 0 && r.OptionID != 0 {
+		selections = []int64{r.OptionID}
+	}
+	if len(selections) == 0 {
+		return nil, errs.BaseErr("option_id is required")
+	}
+	return selections, nil
 }
 
 // VotePollResponse represents the response for a successfully recorded vote
 type VotePollResponse struct {
-	Message   string `json:"message" example:"Vote recorded successfully"`
-	PollID    int64  `json:"poll_id" example:"1"`
-	OptionID  int64  `json:"option_id" example:"2"`
-	Timestamp string `json:"timestamp" example:"2025-05-18T10:30:45Z"`
+	Message   string  `json:"message" example:"Vote recorded successfully"`
+	PollID    int64   `json:"poll_id" example:"1"`
+	OptionID  int64   `json:"option_id" example:"2"`
+	OptionIDs []int64 `json:"option_ids" example:"2,1,3"`
+	Timestamp string  `json:"timestamp" example:"2025-05-18T10:30:45Z"`
+	// Receipt is issued for ballots on anonymous polls. It is shown only
+	// once and is the only way to look the ballot up again.
+	Receipt string `json:"receipt,omitempty" example:"T0m8b3Jc2lYk5rq1X0pZ4w"`
+	// NextPollID is the follow-up poll to show next, set when the ballot's
+	// first choice has a follow-up rule.
+	NextPollID *int64 `json:"next_poll_id,omitempty" example:"15"`
+}
+
+// VerifyReceiptRequest represents the request payload for checking a ballot receipt
+type VerifyReceiptRequest struct {
+	Receipt string `json:"receipt" example:"T0m8b3Jc2lYk5rq1X0pZ4w"`
+}
+
+// ReceiptResponse confirms that the ballot behind a receipt is counted.
+type ReceiptResponse struct {
+	PollID    int64     `json:"poll_id" example:"1"`
+	Counted   bool      `json:"counted" example:"true"`
+	OptionIDs []int64   `json:"option_ids" example:"2,1,3"`
+	CastAt    time.Time `json:"cast_at"`
+}
+
+// FollowUpRule sends voters whose first choice is OptionID on to NextPollID.
+type FollowUpRule struct {
+	OptionID   int64 `json:"option_id" example:"2"`
+	NextPollID int64 `json:"next_poll_id" example:"15"`
+}
+
+// SetFollowUpsRequest represents the request payload for replacing a poll's
+// follow-up rules. An empty list removes them all.
+type SetFollowUpsRequest struct {
+	Rules []FollowUpRule `json:"rules"`
+}
+
+// FollowUpsResponse lists the follow-up rules of a poll
+type FollowUpsResponse struct {
+	PollID int64          `json:"poll_id" example:"12"`
+	Rules  []FollowUpRule `json:"rules"`
+}
+
+// FunnelResponse reports how many voters follow a chain of polls. Voters
+// counts the identified voters of the first poll; anonymous ballots cannot
+// be linked across polls and are left out.
+type FunnelResponse struct {
+	PollID int64        `json:"poll_id" example:"12"`
+	Voters int64        `json:"voters" example:"200"`
+	Steps  []FunnelStep `json:"steps"`
+}
+
+// FunnelStep is one follow-up rule of a chain. Entered counts the voters of
+// FromPollID whose first choice was OptionID, Continued those of them who
+// went on to vote in NextPollID. Depth is 1 for the rules of the first poll.
+type FunnelStep struct {
+	FromPollID  int64   `json:"from_poll_id" example:"12"`
+	OptionID    int64   `json:"option_id" example:"2"`
+	NextPollID  int64   `json:"next_poll_id" example:"15"`
+	Depth       int     `json:"depth" example:"1"`
+	Entered     int64   `json:"entered" example:"120"`
+	Continued   int64   `json:"continued" example:"90"`
+	DropOff     int64   `json:"drop_off" example:"30"`
+	DropOffRate float64 `json:"drop_off_rate" example:"25"`
 }
 
 // PollResultsResponse represents the response for poll results
 type PollResultsResponse struct {
-	PollID     int64     `json:"poll_id" example:"1"`
-	Question   string    `json:"question" example:"What is your favorite programming language?"`
-	TotalVotes int64     `json:"total_votes" example:"42"`
-	CreatedAt  time.Time `json:"created_at"`
-	Options    []Option  `json:"options"`
+	PollID     int64  `json:"poll_id" example:"1"`
+	Question   string `json:"question" example:"What is your favorite programming language?"`
+	TotalVotes int64  `json:"total_votes" example:"42"`
+	// TotalVoters differs from TotalVotes for multi polls, where one voter
+	// can select several options.
+	TotalVoters int64      `json:"total_voters" example:"42"`
+	PollType    string     `json:"poll_type" example:"single"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Status      string     `json:"status" example:"closed"`
+	ClosesAt    *time.Time `json:"closes_at,omitempty"`
+	IsFinal     bool       `json:"is_final" example:"true"`
+	// Tie is set when more than one option shares the win.
+	Tie     bool           `json:"tie" example:"false"`
+	Options []OptionResult `json:"options"`
+	// Runoff is set for ranked polls with at least one ballot.
+	Runoff *RunoffResult `json:"runoff,omitempty"`
+	// Timeline is set when a time-bucket breakdown is requested.
+	Timeline []TimeBucket `json:"timeline,omitempty"`
+	// Cohorts is set when a voter cohort breakdown is requested.
+	Cohorts []CohortBucket `json:"cohorts,omitempty"`
+	// Hidden is set on quiz results that are withheld until the quiz
+	// closes; options then carry no votes.
+	Hidden bool `json:"hidden,omitempty" example:"false"`
+	// Quiz summarises the scores of a quiz once its results are shown.
+	Quiz *QuizSummary `json:"quiz,omitempty"`
+}
+
+// QuizSummary describes how participants scored on a quiz. Scores is set
+// when the per-user score view is requested.
+type QuizSummary struct {
+	CorrectOptionIDs []int64            `json:"correct_option_ids" example:"2"`
+	MaxScore         int                `json:"max_score" example:"10"`
+	Participants     int                `json:"participants" example:"42"`
+	AverageScore     float64            `json:"average_score" example:"6.2"`
+	Scores           []ParticipantScore `json:"scores,omitempty"`
+}
+
+// ParticipantScore is one participant's score on a quiz. Correct is set
+// when every selection of the ballot was a correct option.
+type ParticipantScore struct {
+	UserID     int64     `json:"user_id" example:"7"`
+	Username   string    `json:"username" example:"alice"`
+	Score      int       `json:"score" example:"10"`
+	Correct    bool      `json:"correct" example:"true"`
+	AnsweredAt time.Time `json:"answered_at"`
+}
+
+// QuizBallot is a participant's ballot on a quiz, ordered by rank.
+type QuizBallot struct {
+	UserID     int64
+	Username   string
+	OptionIDs  []int64
+	AnsweredAt time.Time
+}
+
+// LeaderboardResponse ranks the participants of a quiz by score.
+type LeaderboardResponse struct {
+	PollID       int64              `json:"poll_id" example:"1"`
+	MaxScore     int                `json:"max_score" example:"10"`
+	Participants int                `json:"participants" example:"42"`
+	Entries      []LeaderboardEntry `json:"entries"`
+}
+
+// LeaderboardEntry is a ranked participant. Equal scores share a rank and
+// are ordered by who answered first.
+type LeaderboardEntry struct {
+	Rank int `json:"rank" example:"1"`
+	ParticipantScore
+}
+
+// RunoffResult is the outcome of an instant-runoff count of a ranked poll.
+type RunoffResult struct {
+	// WinnerID is nil when the final round ends in a tie.
+	WinnerID *int64        `json:"winner_id,omitempty" example:"2"`
+	Tied     []int64       `json:"tied,omitempty"`
+	Rounds   []RunoffRound `json:"rounds"`
 }
 
-// OptionResult represents an option with its vote count and percentage
+// RunoffRound is one round of an instant-runoff count.
+type RunoffRound struct {
+	Round int `json:"round" example:"1"`
+	// Tallies holds the continuing options and their votes this round.
+	Tallies []RoundTally `json:"tallies"`
+	// Exhausted counts ballots with no continuing option left.
+	Exhausted  int64   `json:"exhausted" example:"0"`
+	Eliminated []int64 `json:"eliminated,omitempty"`
+}
+
+// RoundTally is an option's vote count in one runoff round.
+type RoundTally struct {
+	OptionID int64 `json:"option_id" example:"1"`
+	Votes    int64 `json:"votes" example:"12"`
+}
+
+// OptionResult represents an option with its vote count and percentage.
+// Percentages are of votes for single and ranked polls and of voters for
+// multi polls. Rank is 1 for the most voted option; tied options share a
+// rank. Winner marks the winning options, decided by the instant-runoff
+// count for ranked polls.
 type OptionResult struct {
 	ID         int64   `json:"id" example:"1"`
 	Text       string  `json:"text" example:"Go"`
 	Votes      int64   `json:"votes" example:"25"`
 	Percentage float64 `json:"percentage" example:"59.5"`
+	Rank       int     `json:"rank" example:"1"`
+	Winner     bool    `json:"winner" example:"true"`
+}
+
+// Results views.
+const (
+	// ViewScores adds every participant's score to quiz results.
+	ViewScores = "scores"
+)
+
+// Results breakdowns.
+const (
+	BreakdownHourly = "hourly"
+	BreakdownDaily  = "daily"
+
+	CohortAccountAge = "account_age"
+)
+
+// Account age cohorts, measured from sign-up to the time of the vote.
+const (
+	CohortUnder7Days = "under_7_days"
+	Cohort7To90Days  = "7_to_90_days"
+	CohortOver90Days = "over_90_days"
+)
+
+// VoteCount is the number of votes an option received within a breakdown bucket.
+type VoteCount struct {
+	OptionID int64 `json:"option_id" example:"1"`
+	Votes    int64 `json:"votes" example:"4"`
+}
+
+// TimeBucket is the votes cast in one hour or day, by the start of the period.
+type TimeBucket struct {
+	Start   time.Time   `json:"start"`
+	Votes   int64       `json:"votes" example:"9"`
+	Options []VoteCount `json:"options"`
+}
+
+// CohortBucket is the votes cast by one voter cohort.
+type CohortBucket struct {
+	Cohort  string      `json:"cohort" example:"under_7_days"`
+	Votes   int64       `json:"votes" example:"9"`
+	Options []VoteCount `json:"options"`
+}
+
+// Sort orders supported when listing polls.
+const (
+	SortNewest    = "newest"
+	SortMostVoted = "most_voted"
+	SortTrending  = "trending"
+)
+
+// ListPollsFilter holds the filters, search term, ordering and page window
+// used when listing polls.
+type ListPollsFilter struct {
+	UserID        int64
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Search        string
+	Tag           string
+	Status        string
+	Sort          string
+	Limit         int
+	Offset        int
+}
+
+// TrendingPoll is a poll ranked by how fast it has been collecting votes.
+// Score adds up every recent ballot, each decayed by its age; RecentVotes
+// counts the ballots inside the trending window.
+type TrendingPoll struct {
+	Poll
+	Score       float64 `json:"score" example:"3.42"`
+	RecentVotes int64   `json:"recent_votes" example:"57"`
+}
+
+// TrendingResponse is the cached trending ranking and when it was computed.
+type TrendingResponse struct {
+	Polls      []TrendingPoll `json:"polls"`
+	ComputedAt *time.Time     `json:"computed_at,omitempty"`
+}
+
+// Tag is a label polls are organised by. UsageCount is the number of
+// listed polls carrying it: published, public and not deleted.
+type Tag struct {
+	Name       string `json:"name" example:"programming"`
+	UsageCount int64  `json:"usage_count" example:"42"`
+}
+
+// RoomProtocolVersion is the version of the poll room WebSocket protocol.
+// Every message carries it in "v"; messages with another version are rejected.
+const RoomProtocolVersion = 1
+
+// Poll room message types. Clients send vote, change_vote, retract_vote,
+// get_results and ping; the server sends welcome, results, presence,
+// vote_ack, error and pong, plus a ping on idle connections that clients
+// may ignore.
+const (
+	RoomMsgVote        = "vote"
+	RoomMsgChangeVote  = "change_vote"
+	RoomMsgRetractVote = "retract_vote"
+	RoomMsgGetResults  = "get_results"
+	RoomMsgPing        = "ping"
+
+	RoomMsgWelcome  = "welcome"
+	RoomMsgResults  = "results"
+	RoomMsgPresence = "presence"
+	RoomMsgVoteAck  = "vote_ack"
+	RoomMsgError    = "error"
+	RoomMsgPong     = "pong"
+)
+
+// RoomMessage is the envelope of every poll room WebSocket message. Data is
+// a VotePollRequest for vote and change_vote, a PollResultsResponse for
+// results, a VotePollResponse for vote_ack, a RoomPresence for presence and
+// welcome, and a response.FailedResponse for error. Replies echo the ID of
+// the client message they answer.
+type RoomMessage struct {
+	V    int             `json:"v" example:"1"`
+	Type string          `json:"type" example:"vote"`
+	ID   string          `json:"id,omitempty" example:"c1"`
+	Data json.RawMessage `json:"data,omitempty" swaggertype:"object"`
+}
+
+// RoomPresence reports how many clients are connected to a poll room.
+type RoomPresence struct {
+	PollID int64 `json:"poll_id" example:"1"`
+	Online int   `json:"online" example:"12"`
+}
+
+// InviteUsersRequest lists the users to invite to an invite-only poll.
+type InviteUsersRequest struct {
+	UserIDs []int64 `json:"user_ids" example:"4,7"`
+}
+
+// InvitesResponse lists the users invited to a poll.
+type InvitesResponse struct {
+	PollID  int64   `json:"poll_id"`
+	UserIDs []int64 `json:"user_ids"`
+}
+
+// InviteLinkResponse carries a signed invite token. Passing it as the invite
+// query parameter grants access to the poll until ExpiresAt.
+type InviteLinkResponse struct {
+	PollID    int64     `json:"poll_id"`
+	Token     string    `json:"token" example:"12.1767225600.Qm9vZ2llV29vZ2ll"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PollTemplate is a saved question and option set that polls can be created
+// from. The question and options may contain placeholders such as {{week}},
+// which are filled in when a poll is created from the template.
+type PollTemplate struct {
+	ID       int64            `json:"id"`
+	UserID   int64            `json:"user_id"`
+	Name     string           `json:"name" example:"Weekly retro"`
+	Question string           `json:"question" example:"How did week {{week}} go for {{team}}?"`
+	Options  []TemplateOption `json:"options"`
+	// The settings below are copied into every poll created from the template.
+	Type              string `json:"poll_type" example:"single"`
+	MinSelections     int    `json:"min_selections,omitempty" example:"1"`
+	MaxSelections     int    `json:"max_selections,omitempty" example:"3"`
+	AllowVoteChange   bool   `json:"allow_vote_change" example:"false"`
+	Anonymous         bool   `json:"anonymous" example:"false"`
+	Quiz              bool   `json:"quiz" example:"false"`
+	Visibility        string `json:"visibility" example:"public"`
+	ResultsVisibility string `json:"results_visibility" example:"always"`
+	// Placeholders lists the placeholders used by the question and options,
+	// in order of first use.
+	Placeholders []string  `json:"placeholders" example:"[\"week\",\"team\"]"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TemplateOption is an option of a poll template. Correct and Points mark
+// the right answers of a quiz template.
+type TemplateOption struct {
+	Text    string `json:"text" example:"Great"`
+	Correct bool   `json:"correct,omitempty" example:"true"`
+	Points  int    `json:"points,omitempty" example:"10"`
+}
+
+// CreateTemplateRequest represents the request payload for saving a poll
+// template. Settings are validated as for CreatePollRequest.
+type CreateTemplateRequest struct {
+	Name     string   `json:"name" example:"Weekly retro"`
+	Question string   `json:"question" example:"How did week {{week}} go for {{team}}?"`
+	Options  []string `json:"options" example:"[\"Great\",\"Fine\",\"Rough\"]"`
+	// PollType is single (default), multi or ranked.
+	PollType          string          `json:"poll_type,omitempty" example:"single"`
+	MinSelections     int             `json:"min_selections,omitempty" example:"1"`
+	MaxSelections     int             `json:"max_selections,omitempty" example:"2"`
+	AllowVoteChange   bool            `json:"allow_vote_change,omitempty" example:"true"`
+	Anonymous         bool            `json:"anonymous,omitempty" example:"false"`
+	Quiz              bool            `json:"quiz,omitempty" example:"false"`
+	CorrectOptions    []CorrectOption `json:"correct_options,omitempty"`
+	Visibility        string          `json:"visibility,omitempty" example:"public"`
+	ResultsVisibility string          `json:"results_visibility,omitempty" example:"after_vote"`
+}
+
+// UseTemplateRequest represents the request payload for creating a poll from
+// a template. Values fill in the template's placeholders; date, week and
+// year default to the current date.
+type UseTemplateRequest struct {
+	Values map[string]string `json:"values,omitempty" example:"{\"team\":\"Platform\"}"`
+	Tags   []string          `json:"tags,omitempty" example:"[\"retro\"]"`
+	// Status is either "draft" or "open" (default).
+	Status   string     `json:"status,omitempty" example:"draft"`
+	OpensAt  *time.Time `json:"opens_at,omitempty" example:"2025-05-18T09:00:00Z"`
+	ClosesAt *time.Time `json:"closes_at,omitempty" example:"2025-05-25T09:00:00Z"`
 }
@@ -0,0 +1,215 @@
+package poll
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+)
+
+// defaultQuizPoints is what a correct option is worth when no points are given.
+const defaultQuizPoints = 1
+
+// markCorrectOptions applies the correct options of a quiz request to the
+// poll's options, which must already be populated in request order.
+func markCorrectOptions(p *Poll, correct []CorrectOption) error {
+	if !p.Quiz {
+		if len(correct) > 0 {
+			return errs.BaseErr("correct_options only apply to quiz polls")
+		}
+		return nil
+	}
+	if p.Type == TypeRanked {
+		return errs.BaseErr("quiz mode is only available for single and multi polls")
+	}
+	if p.Anonymous {
+		return errs.BaseErr("quiz polls cannot be anonymous")
+	}
+	if len(correct) == 0 {
+		return errs.BaseErr("a quiz needs at least one correct option")
+	}
+
+	for _, co := range correct {
+		if co.Index < 0 || co.Index >= len(p.Options) {
+			return errs.BaseErr(fmt.Sprintf("correct option index %d is out of range", co.Index))
+		}
+		if co.Points < 0 {
+			return errs.BaseErr("points cannot be negative")
+		}
+		opt := &p.Options[co.Index]
+		if opt.Correct {
+			return errs.BaseErr(fmt.Sprintf("correct option index %d is listed twice", co.Index))
+		}
+		opt.Correct = true
+		opt.Points = co.Points
+		if opt.Points == 0 {
+			opt.Points = defaultQuizPoints
+		}
+	}
+	if p.Type == TypeMulti && p.MinSelections > len(correct) {
+		return errs.BaseErr("min_selections cannot exceed the number of correct options")
+	}
+	return nil
+}
+
+// answersHidden reports whether the caller may not yet see a quiz's answers
+// and results: only its owner and admins can before the quiz closes.
+func answersHidden(c echo.Context, p *Poll, now time.Time) bool {
+	return p.Quiz && !p.IsFinal(now) && !canManage(c, p)
+}
+
+// hideAnswers clears the correct options and points of a quiz.
+func hideAnswers(p *Poll) {
+	options := make([]Option, len(p.Options))
+	for i, opt := range p.Options {
+		opt.Correct = false
+		opt.Points = 0
+		options[i] = opt
+	}
+	p.Options = options
+}
+
+// hideResults withholds the tallies of a quiz that is still running, keeping
+// only how many have answered.
+func hideResults(results *PollResultsResponse) {
+	for i, opt := range results.Options {
+		results.Options[i] = OptionResult{ID: opt.ID, Text: opt.Text}
+	}
+	results.Tie = false
+	results.Runoff = nil
+	results.Hidden = true
+}
+
+// scoreBallot scores a quiz ballot. A ballot earns the points of its
+// selections when every selection is correct, and nothing otherwise, so
+// picking extra options on a multi quiz does not pay off.
+func scoreBallot(p *Poll, optionIDs []int64) (score int, correct bool) {
+	if len(optionIDs) == 0 {
+		return 0, false
+	}
+	for _, id := range optionIDs {
+		opt := findOption(p, id)
+		if opt == nil || !opt.Correct {
+			return 0, false
+		}
+		score += opt.Points
+	}
+	return score, true
+}
+
+// maxScore is the best score a ballot can reach on a quiz: the most valuable
+// correct option on a single quiz, or the most valuable correct options a
+// voter may select on a multi quiz.
+func maxScore(p *Poll) int {
+	var points []int
+	for _, opt := range p.Options {
+		if opt.Correct {
+			points = append(points, opt.Points)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(points)))
+
+	picks := 1
+	if p.Type == TypeMulti {
+		picks = p.MaxSelections
+	}
+	best := 0
+	for i := 0; i < picks && i < len(points); i++ {
+		best += points[i]
+	}
+	return best
+}
+
+// scoreBallots scores every ballot of a quiz, ordered by score and then by
+// who answered first.
+func scoreBallots(p *Poll, ballots []QuizBallot) []ParticipantScore {
+	scores := make([]ParticipantScore, len(ballots))
+	for i, b := range ballots {
+		score, correct := scoreBallot(p, b.OptionIDs)
+		scores[i] = ParticipantScore{
+			UserID:     b.UserID,
+			Username:   b.Username,
+			Score:      score,
+			Correct:    correct,
+			AnsweredAt: b.AnsweredAt,
+		}
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		if !scores[i].AnsweredAt.Equal(scores[j].AnsweredAt) {
+			return scores[i].AnsweredAt.Before(scores[j].AnsweredAt)
+		}
+		return scores[i].UserID < scores[j].UserID
+	})
+	return scores
+}
+
+// quizScores loads and scores every ballot of a quiz.
+func (s *Service) quizScores(ctx context.Context, p *Poll) ([]ParticipantScore, error) {
+	ballots, err := s.Repo.GetQuizBallots(ctx, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	return scoreBallots(p, ballots), nil
+}
+
+// summarizeQuiz describes the scores of a quiz, listing each of them when
+// withScores is set.
+func summarizeQuiz(p *Poll, scores []ParticipantScore, withScores bool) *QuizSummary {
+	summary := &QuizSummary{
+		CorrectOptionIDs: []int64{},
+		MaxScore:         maxScore(p),
+		Participants:     len(scores),
+	}
+	for _, opt := range p.Options {
+		if opt.Correct {
+			summary.CorrectOptionIDs = append(summary.CorrectOptionIDs, opt.ID)
+		}
+	}
+
+	if len(scores) > 0 {
+		total := 0
+		for _, sc := range scores {
+			total += sc.Score
+		}
+		summary.AverageScore = math.Round(float64(total)*100/float64(len(scores))) / 100
+	}
+	if withScores {
+		summary.Scores = scores
+	}
+	return summary
+}
+
+// rankScores turns ordered scores into the first limit leaderboard entries.
+// Equal scores share a competition rank ("1224").
+func rankScores(scores []ParticipantScore, limit int) []LeaderboardEntry {
+	if limit > len(scores) {
+		limit = len(scores)
+	}
+	entries := make([]LeaderboardEntry, limit)
+	for i := 0; i < limit; i++ {
+		rank := i + 1
+		if i > 0 && scores[i].Score == scores[i-1].Score {
+			rank = entries[i-1].Rank
+		}
+		entries[i] = LeaderboardEntry{Rank: rank, ParticipantScore: scores[i]}
+	}
+	return entries
+}
+
+// findOption returns the poll's option with the given ID, or nil.
+func findOption(p *Poll, optionID int64) *Option {
+	for i := range p.Options {
+		if p.Options[i].ID == optionID {
+			return &p.Options[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package poll
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreBallot(t *testing.T) {
+	quiz := &Poll{Quiz: true, Type: TypeMulti, MaxSelections: 2, Options: []Option{
+		{ID: 1, Correct: true, Points: 5},
+		{ID: 2},
+		{ID: 3, Correct: true, Points: 3},
+	}}
+
+	tests := []struct {
+		name            string
+		optionIDs       []int64
+		expectedScore   int
+		expectedCorrect bool
+	}{
+		{name: "All correct", optionIDs: []int64{1, 3}, expectedScore: 8, expectedCorrect: true},
+		{name: "Partly answered", optionIDs: []int64{3}, expectedScore: 3, expectedCorrect: true},
+		{name: "A wrong pick scores nothing", optionIDs: []int64{1, 2}, expectedScore: 0, expectedCorrect: false},
+		{name: "Unknown option", optionIDs: []int64{9}, expectedScore: 0, expectedCorrect: false},
+		{name: "Empty ballot", optionIDs: nil, expectedScore: 0, expectedCorrect: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, correct := scoreBallot(quiz, tt.optionIDs)
+			assert.Equal(t, tt.expectedScore, score)
+			assert.Equal(t, tt.expectedCorrect, correct)
+		})
+	}
+}
+
+func TestMaxScore(t *testing.T) {
+	options := []Option{
+		{ID: 1, Correct: true, Points: 2},
+		{ID: 2, Correct: true, Points: 7},
+		{ID: 3, Correct: true, Points: 4},
+		{ID: 4},
+	}
+
+	assert.Equal(t, 7, maxScore(&Poll{Type: TypeSingle, Options: options}))
+	assert.Equal(t, 11, maxScore(&Poll{Type: TypeMulti, MaxSelections: 2, Options: options}))
+	assert.Equal(t, 13, maxScore(&Poll{Type: TypeMulti, MaxSelections: 4, Options: options}))
+}
+
+func TestRankScores(t *testing.T) {
+	at := time.Date(2025, 5, 18, 9, 0, 0, 0, time.UTC)
+	scores := []ParticipantScore{
+		{UserID: 1, Score: 9, AnsweredAt: at},
+		{UserID: 2, Score: 9, AnsweredAt: at.Add(time.Second)},
+		{UserID: 3, Score: 4, AnsweredAt: at},
+		{UserID: 4, Score: 0, AnsweredAt: at},
+	}
+
+	entries := rankScores(scores, 10)
+	ranks := make([]int, len(entries))
+	for i, e := range entries {
+		ranks[i] = e.Rank
+	}
+	assert.Equal(t, []int{1, 1, 3, 4}, ranks)
+	assert.Len(t, rankScores(scores, 2), 2)
+}
+
+func TestMarkCorrectOptions(t *testing.T) {
+	newQuiz := func() *Poll {
+		return &Poll{Quiz: true, Type: TypeMulti, MinSelections: 2, MaxSelections: 3,
+			Options: []Option{{Text: "a"}, {Text: "b"}, {Text: "c"}}}
+	}
+
+	p := newQuiz()
+	assert.NoError(t, markCorrectOptions(p, []CorrectOption{{Index: 0, Points: 4}, {Index: 2}}))
+	assert.Equal(t, []Option{{Text: "a", Correct: true, Points: 4}, {Text: "b"}, {Text: "c", Correct: true, Points: 1}}, p.Options)
+
+	assert.EqualError(t, markCorrectOptions(newQuiz(), []CorrectOption{{Index: 1}, {Index: 1}}),
+		"correct option index 1 is listed twice")
+	assert.EqualError(t, markCorrectOptions(newQuiz(), []CorrectOption{{Index: 1, Points: -1}}),
+		"points cannot be negative")
+	assert.EqualError(t, markCorrectOptions(newQuiz(), []CorrectOption{{Index: 1}}),
+		"min_selections cannot exceed the number of correct options")
+
+	anonymous := newQuiz()
+	anonymous.Anonymous = true
+	assert.EqualError(t, markCorrectOptions(anonymous, []CorrectOption{{Index: 0}}), "quiz polls cannot be anonymous")
+}
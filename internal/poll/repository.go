@@ -4,7 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/phsaurav/echo_prod_blueprint/internal/database"
 	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
 )
@@ -21,6 +26,43 @@ func NewRepo(db database.Service) *Repo {
 
 var _ Repository = (*Repo)(nil)
 
+// pollColumns lists the polls columns read by scanPoll, in scan order.
+const pollColumns = `p.id, p.question, p.user_id, p.created_at, p.poll_type, p.min_selections,
+	p.max_selections, p.allow_vote_change, p.anonymous, p.export_votes, p.quiz, p.status, p.opens_at, p.closes_at,
+	p.visibility, COALESCE(p.slug, ''), p.results_visibility,
+	(SELECT COALESCE(string_agg(t.name, ',' ORDER BY t.name), '')
+		FROM poll_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.poll_id = p.id),
+	COALESCE(p.cover_image, ''), COALESCE(p.cover_thumbnail, '')`
+
+// listedPolls is the condition on polls p that the public listings show:
+// live, published and public polls.
+const listedPolls = `p.deleted_at IS NULL AND p.status <> 'draft' AND p.visibility = 'public'`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPoll scans the pollColumns of a row into p, followed by any extra
+// destinations selected after them. Tags arrive comma-separated, which is
+// safe because tag names cannot contain commas.
+func scanPoll(row rowScanner, p *Poll, extra ...interface{}) error {
+	var tags, cover, coverThumbnail string
+	dest := []interface{}{
+		&p.ID, &p.Question, &p.UserID, &p.CreatedAt, &p.Type, &p.MinSelections,
+		&p.MaxSelections, &p.AllowVoteChange, &p.Anonymous, &p.ExportVotes, &p.Quiz, &p.Status, &p.OpensAt, &p.ClosesAt,
+		&p.Visibility, &p.Slug, &p.ResultsVisibility, &tags, &cover, &coverThumbnail,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	if tags != "" {
+		p.Tags = strings.Split(tags, ",")
+	}
+	p.CoverImage = newImage(cover, coverThumbnail)
+	return nil
+}
+
 // Create inserts a new poll and its options into the DB.
 func (r *Repo) Create(ctx context.Context, p *Poll) error {
 	tx, err := r.DB.BeginTx(ctx, nil)
@@ -31,73 +73,342 @@ func (r *Repo) Create(ctx context.Context, p *Poll) error {
 
 	// Insert poll
 	pollQuery := `
-			INSERT INTO polls (question, user_id, created_at)
-			VALUES ($1, $2, NOW())
+			INSERT INTO polls (question, user_id, poll_type, min_selections, max_selections,
+				allow_vote_change, anonymous, export_votes, quiz, status, opens_at, closes_at, visibility, slug,
+				results_visibility, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NULLIF($14, ''), $15, NOW())
 			RETURNING id, created_at
 	`
-	err = tx.QueryRowContext(ctx, pollQuery, p.Question, p.UserID).Scan(&p.ID, &p.CreatedAt)
+	err = tx.QueryRowContext(ctx, pollQuery, p.Question, p.UserID, p.Type, p.MinSelections, p.MaxSelections,
+		p.AllowVoteChange, p.Anonymous, p.ExportVotes, p.Quiz, p.Status, p.OpensAt, p.ClosesAt, p.Visibility, p.Slug,
+		p.ResultsVisibility).Scan(&p.ID, &p.CreatedAt)
 	if err != nil {
 			return errs.InternalServerError(err)
 	}
 
 	// Insert options
-	optionQuery := `INSERT INTO poll_options (poll_id, text) VALUES ($1, $2) RETURNING id`
+	optionQuery := `INSERT INTO poll_options (poll_id, text, correct, points) VALUES ($1, $2, $3, $4) RETURNING id`
 	for i := range p.Options {
-			err := tx.QueryRowContext(ctx, optionQuery, p.ID, p.Options[i].Text).Scan(&p.Options[i].ID)
+			opt := &p.Options[i]
+			err := tx.QueryRowContext(ctx, optionQuery, p.ID, opt.Text, opt.Correct, opt.Points).Scan(&opt.ID)
 			if err != nil {
 					return errs.InternalServerError(err)
 			}
 			p.Options[i].PollID = p.ID
 	}
 
+	if err := addTags(ctx, tx, p.ID, p.Tags); err != nil {
+			return err
+	}
+
 	if err := tx.Commit(); err != nil {
 			return errs.InternalServerError(err)
 	}
 	return nil
 }
 
+// addTags attaches tags to a poll, creating the ones that do not exist yet.
+func addTags(ctx context.Context, tx *sql.Tx, pollID int64, tags []string) error {
+	// The no-op update makes RETURNING yield the ID of existing tags too
+	tagQuery := `
+		INSERT INTO tags (name, created_at) VALUES ($1, NOW())
+		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id
+	`
+	for _, name := range tags {
+		var tagID int64
+		if err := tx.QueryRowContext(ctx, tagQuery, name).Scan(&tagID); err != nil {
+			return errs.InternalServerError(err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO poll_tags (poll_id, tag_id) VALUES ($1, $2)`, pollID, tagID); err != nil {
+			return errs.InternalServerError(err)
+		}
+	}
+	return nil
+}
+
 // GetByID fetches a poll and its options by poll ID.
 func (r *Repo) GetByID(ctx context.Context, id int64) (*Poll, error) {
-	query := `SELECT id, question, created_at FROM polls WHERE id = $1`
+	query := `SELECT ` + pollColumns + ` FROM polls p WHERE p.id = $1 AND p.deleted_at IS NULL`
 	p := new(Poll)
-	err := r.DB.QueryRowContext(ctx, query, id).Scan(&p.ID, &p.Question, &p.CreatedAt)
+	err := scanPoll(r.DB.QueryRowContext(ctx, query, id), p)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, errs.NotFound(err)
 		}
 		return nil, errs.InternalServerError(err)
 	}
+	if err := r.loadOptions(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
 
-	// Fetch options
-	optQuery := `SELECT id, poll_id, text FROM poll_options WHERE poll_id = $1`
-	rows, err := r.DB.QueryContext(ctx, optQuery, p.ID)
+// GetBySlug fetches a poll and its options by the slug of an unlisted poll.
+func (r *Repo) GetBySlug(ctx context.Context, slug string) (*Poll, error) {
+	query := `SELECT ` + pollColumns + ` FROM polls p WHERE p.slug = $1 AND p.deleted_at IS NULL`
+	p := new(Poll)
+	err := scanPoll(r.DB.QueryRowContext(ctx, query, slug), p)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errs.NotFound(err)
+		}
 		return nil, errs.InternalServerError(err)
 	}
+	if err := r.loadOptions(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// loadOptions fetches the options of p with their follow-up polls,
+// skipping deleted ones.
+func (r *Repo) loadOptions(ctx context.Context, p *Poll) error {
+	optQuery := `
+		SELECT o.id, o.poll_id, o.text, n.id, o.correct, o.points,
+			COALESCE(o.image, ''), COALESCE(o.image_thumbnail, '')
+		FROM poll_options o
+		LEFT JOIN polls n ON n.id = o.next_poll_id AND n.deleted_at IS NULL
+		WHERE o.poll_id = $1
+	`
+	rows, err := r.DB.QueryContext(ctx, optQuery, p.ID)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
 	defer rows.Close()
 
 	var opts []Option
 	for rows.Next() {
 		var opt Option
-		if err := rows.Scan(&opt.ID, &opt.PollID, &opt.Text); err != nil {
-			return nil, errs.InternalServerError(err)
+		var nextPollID sql.NullInt64
+		var image, thumbnail string
+		if err := rows.Scan(&opt.ID, &opt.PollID, &opt.Text, &nextPollID, &opt.Correct, &opt.Points,
+			&image, &thumbnail); err != nil {
+			return errs.InternalServerError(err)
 		}
+		if nextPollID.Valid {
+			opt.NextPollID = &nextPollID.Int64
+		}
+		opt.Image = newImage(image, thumbnail)
 		opts = append(opts, opt)
 	}
 	p.Options = opts
-	return p, nil
+	return nil
+}
+
+// Vote records a voter's ballot in one transaction. Each selected option is
+// stored as its own row, ranked by its position in optionIDs. receiptHash is
+// stored with anonymous ballots and is empty otherwise.
+func (r *Repo) Vote(ctx context.Context, pollID int64, voter Voter, optionIDs []int64, receiptHash string) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	if err := insertBallot(ctx, tx, pollID, voter, optionIDs, receiptHash); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// ChangeVote replaces a voter's ballot in one transaction and records the
+// previous and new selections in the vote history. An anonymous ballot gets
+// the new receiptHash, so the old receipt no longer verifies.
+func (r *Repo) ChangeVote(ctx context.Context, pollID int64, voter Voter, optionIDs []int64, receiptHash string) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	previous, err := lockBallot(ctx, tx, pollID, voter)
+	if err != nil {
+		return err
+	}
+
+	column, value := voterColumn(voter)
+	if _, err := tx.ExecContext(ctx, `DELETE FROM poll_votes WHERE poll_id = $1 AND `+column+` = $2`, pollID, value); err != nil {
+		return errs.InternalServerError(err)
+	}
+
+	if err := insertBallot(ctx, tx, pollID, voter, optionIDs, receiptHash); err != nil {
+		return err
+	}
+
+	if err := recordVoteHistory(ctx, tx, pollID, voter, VoteActionChange, previous, optionIDs); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// RetractVote deletes a voter's ballot in one transaction and records the
+// retraction in the vote history.
+func (r *Repo) RetractVote(ctx context.Context, pollID int64, voter Voter) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	previous, err := lockBallot(ctx, tx, pollID, voter)
+	if err != nil {
+		return err
+	}
+
+	column, value := voterColumn(voter)
+	if _, err := tx.ExecContext(ctx, `DELETE FROM poll_votes WHERE poll_id = $1 AND `+column+` = $2`, pollID, value); err != nil {
+		return errs.InternalServerError(err)
+	}
+
+	if err := recordVoteHistory(ctx, tx, pollID, voter, VoteActionRetract, previous, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// uniqueViolation is the Postgres error code for a violated unique constraint.
+const uniqueViolation = "23505"
+
+// insertBallot stores each selected option of a ballot as its own row,
+// ranked by its position in optionIDs.
+func insertBallot(ctx context.Context, tx *sql.Tx, pollID int64, voter Voter, optionIDs []int64, receiptHash string) error {
+	userID, voterHash := voterValues(voter)
+	var receipt interface{}
+	if receiptHash != "" {
+		receipt = receiptHash
+	}
+
+	voteQuery := `
+		INSERT INTO poll_votes (poll_id, option_id, user_id, voter_hash, receipt_hash, rank, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+	`
+	for i, optionID := range optionIDs {
+		if _, err := tx.ExecContext(ctx, voteQuery, pollID, optionID, userID, voterHash, receipt, i+1); err != nil {
+			// A concurrent ballot by the same voter was stored first
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+				return errs.Conflict(errors.New("already voted"))
+			}
+			return errs.InternalServerError(err)
+		}
+	}
+	return nil
 }
 
-// Vote records a user's vote for a specific poll option.
-func (r *Repo) Vote(ctx context.Context, pollID, optionID, userID int64) error {
-	voteQuery := `INSERT INTO poll_votes (poll_id, option_id, user_id, created_at) VALUES ($1, $2, $3, NOW())`
-	_, err := r.DB.ExecContext(ctx, voteQuery, pollID, optionID, userID)
+// voterColumn returns the poll_votes column identifying the voter and its value.
+func voterColumn(v Voter) (string, interface{}) {
+	if v.Hash != "" {
+		return "voter_hash", v.Hash
+	}
+	return "user_id", v.UserID
+}
+
+// voterValues returns the user_id and voter_hash stored for a voter; the one
+// that does not apply is NULL.
+func voterValues(v Voter) (userID, voterHash interface{}) {
+	if v.Hash != "" {
+		return nil, v.Hash
+	}
+	return v.UserID, nil
+}
+
+// lockBallot reads and row-locks a voter's current selections in rank order.
+// It returns a NotFound error if the voter has not voted.
+func lockBallot(ctx context.Context, tx *sql.Tx, pollID int64, voter Voter) ([]int64, error) {
+	column, value := voterColumn(voter)
+	query := `SELECT option_id FROM poll_votes WHERE poll_id = $1 AND ` + column + ` = $2 ORDER BY rank FOR UPDATE`
+	rows, err := tx.QueryContext(ctx, query, pollID, value)
 	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	var ballot []int64
+	for rows.Next() {
+		var optionID int64
+		if err := rows.Scan(&optionID); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		ballot = append(ballot, optionID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	if len(ballot) == 0 {
+		return nil, errs.NotFound(errors.New("you have not voted on this poll"))
+	}
+	return ballot, nil
+}
+
+// recordVoteHistory appends an audit entry for a ballot change or retraction.
+func recordVoteHistory(ctx context.Context, tx *sql.Tx, pollID int64, voter Voter, action string, previous, current []int64) error {
+	query := `
+		INSERT INTO poll_vote_history (poll_id, user_id, voter_hash, action, previous_option_ids, option_ids, created_at)
+		VALUES ($1, $2, $3, $4, $5::integer[], $6::integer[], NOW())
+	`
+	userID, voterHash := voterValues(voter)
+	if _, err := tx.ExecContext(ctx, query, pollID, userID, voterHash, action, intArray(previous), intArray(current)); err != nil {
 		return errs.InternalServerError(err)
 	}
 	return nil
 }
 
+// intArray formats IDs as a Postgres array literal such as {1,2,3}.
+func intArray(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// GetBallots fetches every voter's selections for a poll, ordered by rank.
+func (r *Repo) GetBallots(ctx context.Context, pollID int64) ([]Ballot, error) {
+	query := `
+		SELECT COALESCE(user_id::text, voter_hash) AS voter, option_id
+		FROM poll_votes
+		WHERE poll_id = $1
+		ORDER BY voter, rank
+	`
+	rows, err := r.DB.QueryContext(ctx, query, pollID)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	var ballots []Ballot
+	var lastVoter string
+	for rows.Next() {
+		var voter string
+		var optionID int64
+		if err := rows.Scan(&voter, &optionID); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		if len(ballots) == 0 || voter != lastVoter {
+			ballots = append(ballots, Ballot{})
+			lastVoter = voter
+		}
+		ballots[len(ballots)-1] = append(ballots[len(ballots)-1], optionID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	return ballots, nil
+}
+
 // GetResults fetches poll options and their vote counts for a poll.
 func (r *Repo) GetResults(ctx context.Context, pollID int64) ([]Option, error) {
 	query := `
@@ -125,16 +436,885 @@ func (r *Repo) GetResults(ctx context.Context, pollID int64) ([]Option, error) {
 	return opts, nil
 }
 
-func (r *Repo) HasUserVoted(ctx context.Context, pollID int64, userID int64) (bool, error) {
-	query := "SELECT 1 FROM poll_votes WHERE poll_id=$1 AND user_id=$2"
-	row := r.DB.QueryRowContext(ctx, query, pollID, userID)
-	var dummy int
-	err := row.Scan(&dummy)
-	if err == sql.ErrNoRows {
-		return false, nil
+// GetVoteTimeline counts a poll's votes per option in hourly or daily
+// buckets of poll_votes.created_at. period is a date_trunc unit ("hour" or
+// "day"). With firstPreferences only rank-1 selections are counted, as for
+// ranked poll results.
+func (r *Repo) GetVoteTimeline(ctx context.Context, pollID int64, period string, firstPreferences bool) ([]TimeBucket, error) {
+	query := `
+		SELECT date_trunc($2, v.created_at) AS bucket, v.option_id, COUNT(*)
+		FROM poll_votes v
+		WHERE v.poll_id = $1 AND (NOT $3 OR v.rank = 1)
+		GROUP BY bucket, v.option_id
+		ORDER BY bucket, v.option_id
+	`
+	rows, err := r.DB.QueryContext(ctx, query, pollID, period, firstPreferences)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	buckets := []TimeBucket{}
+	for rows.Next() {
+		var start time.Time
+		var count VoteCount
+		if err := rows.Scan(&start, &count.OptionID, &count.Votes); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		if n := len(buckets); n == 0 || !buckets[n-1].Start.Equal(start) {
+			buckets = append(buckets, TimeBucket{Start: start.UTC(), Options: []VoteCount{}})
+		}
+		b := &buckets[len(buckets)-1]
+		b.Votes += count.Votes
+		b.Options = append(b.Options, count)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	return buckets, nil
+}
+
+// GetCohortBreakdown counts a poll's votes per option by the voter's account
+// age at the time of the vote.
+func (r *Repo) GetCohortBreakdown(ctx context.Context, pollID int64, firstPreferences bool) ([]CohortBucket, error) {
+	query := `
+		SELECT CASE
+				WHEN v.created_at - u.created_at < INTERVAL '7 days' THEN '` + CohortUnder7Days + `'
+				WHEN v.created_at - u.created_at < INTERVAL '90 days' THEN '` + Cohort7To90Days + `'
+				ELSE '` + CohortOver90Days + `'
+			END AS cohort, v.option_id, COUNT(*)
+		FROM poll_votes v
+		JOIN users u ON u.id = v.user_id
+		WHERE v.poll_id = $1 AND (NOT $2 OR v.rank = 1)
+		GROUP BY cohort, v.option_id
+		ORDER BY v.option_id
+	`
+	rows, err := r.DB.QueryContext(ctx, query, pollID, firstPreferences)
 	if err != nil {
-		return false, err
+		return nil, errs.InternalServerError(err)
 	}
-	return true, nil
+	defer rows.Close()
+
+	byCohort := make(map[string]*CohortBucket)
+	for rows.Next() {
+		var cohort string
+		var count VoteCount
+		if err := rows.Scan(&cohort, &count.OptionID, &count.Votes); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		b, ok := byCohort[cohort]
+		if !ok {
+			b = &CohortBucket{Cohort: cohort, Options: []VoteCount{}}
+			byCohort[cohort] = b
+		}
+		b.Votes += count.Votes
+		b.Options = append(b.Options, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+
+	// Youngest accounts first
+	cohorts := []CohortBucket{}
+	for _, name := range []string{CohortUnder7Days, Cohort7To90Days, CohortOver90Days} {
+		if b, ok := byCohort[name]; ok {
+			cohorts = append(cohorts, *b)
+		}
+	}
+	return cohorts, nil
+}
+
+// StreamVotes calls fn for every vote in a poll, oldest first, reading rows
+// as they arrive instead of loading them all. An error from fn stops the scan.
+// Anonymous ballots are never streamed.
+func (r *Repo) StreamVotes(ctx context.Context, pollID int64, fn func(Vote) error) error {
+	query := `
+		SELECT id, poll_id, option_id, user_id, rank, created_at
+		FROM poll_votes
+		WHERE poll_id = $1 AND user_id IS NOT NULL
+		ORDER BY created_at, user_id, rank
+	`
+	rows, err := r.DB.QueryContext(ctx, query, pollID)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v Vote
+		if err := rows.Scan(&v.ID, &v.PollID, &v.OptionID, &v.UserID, &v.Rank, &v.CreatedAt); err != nil {
+			return errs.InternalServerError(err)
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// UpdateStatus moves a poll to a new lifecycle status. Closing a poll also
+// pulls its closes_at forward to now so the voting window ends immediately.
+func (r *Repo) UpdateStatus(ctx context.Context, id int64, status string) error {
+	query := `
+		UPDATE polls
+		SET status = $2,
+			closes_at = CASE
+				WHEN $2 = 'closed' AND (closes_at IS NULL OR closes_at > NOW()) THEN NOW()
+				ELSE closes_at
+			END
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+	res, err := r.DB.ExecContext(ctx, query, id, status)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errs.NotFound(sql.ErrNoRows)
+	}
+	return nil
+}
+
+// Update saves a poll's editable fields. When editsBallot is set, the
+// question or options are being edited, which is refused with a Conflict
+// once the poll has votes. When replaceOptions is set, the poll's options
+// are replaced by p.Options in the same transaction. When replaceTags is
+// set, the poll's tags are replaced by p.Tags.
+func (r *Repo) Update(ctx context.Context, p *Poll, editsBallot, replaceOptions, replaceTags bool) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	if editsBallot {
+		if err := lockUnvotedPoll(ctx, tx, p.ID); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		UPDATE polls
+		SET question = $2, allow_vote_change = $3, opens_at = $4, closes_at = $5,
+			visibility = $6, slug = NULLIF($7, ''), results_visibility = $8
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+	res, err := tx.ExecContext(ctx, query, p.ID, p.Question, p.AllowVoteChange, p.OpensAt, p.ClosesAt,
+		p.Visibility, p.Slug, p.ResultsVisibility)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errs.NotFound(sql.ErrNoRows)
+	}
+
+	if replaceOptions {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM poll_options WHERE poll_id = $1`, p.ID); err != nil {
+			return errs.InternalServerError(err)
+		}
+		optionQuery := `INSERT INTO poll_options (poll_id, text) VALUES ($1, $2) RETURNING id`
+		for i := range p.Options {
+			if err := tx.QueryRowContext(ctx, optionQuery, p.ID, p.Options[i].Text).Scan(&p.Options[i].ID); err != nil {
+				return errs.InternalServerError(err)
+			}
+			p.Options[i].PollID = p.ID
+		}
+	}
+
+	if replaceTags {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM poll_tags WHERE poll_id = $1`, p.ID); err != nil {
+			return errs.InternalServerError(err)
+		}
+		if err := addTags(ctx, tx, p.ID, p.Tags); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// SoftDelete hides a poll from reads while keeping its options, votes and
+// tags.
+func (r *Repo) SoftDelete(ctx context.Context, id int64) error {
+	query := `UPDATE polls SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
+	res, err := r.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errs.NotFound(sql.ErrNoRows)
+	}
+	return nil
+}
+
+// GetTrending ranks the public polls by a Hacker News style score: every
+// ballot cast within the window counts 1 / (age in hours + 2)^gravity, so
+// fresh votes weigh most. Only the first-ranked row of each ballot counts.
+func (r *Repo) GetTrending(ctx context.Context, window time.Duration, gravity float64, limit int) ([]TrendingPoll, error) {
+	query := `
+		SELECT ` + pollColumns + `, t.score, t.recent_votes
+		FROM (
+			SELECT v.poll_id,
+				SUM(1 / POWER(EXTRACT(EPOCH FROM NOW() - v.created_at) / 3600 + 2, $2::float8))::float8 AS score,
+				COUNT(*) AS recent_votes
+			FROM poll_votes v
+			WHERE v.rank = 1 AND v.created_at > NOW() - make_interval(secs => $1)
+			GROUP BY v.poll_id
+		) t
+		JOIN polls p ON p.id = t.poll_id
+		WHERE ` + listedPolls + `
+		ORDER BY t.score DESC, p.id DESC
+		LIMIT $3
+	`
+	rows, err := r.DB.QueryContext(ctx, query, window.Seconds(), gravity, limit)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	polls := []TrendingPoll{}
+	for rows.Next() {
+		var tp TrendingPoll
+		if err := scanPoll(rows, &tp.Poll, &tp.Score, &tp.RecentVotes); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		polls = append(polls, tp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	return polls, nil
+}
+
+// ListTags returns the most used tags, most used first. Only the polls the
+// public listings show count towards a tag's usage. With a prefix, only
+// tags starting with it are returned, which is what autocomplete asks for.
+func (r *Repo) ListTags(ctx context.Context, prefix string, limit int) ([]Tag, error) {
+	conds := []string{listedPolls}
+	var args []interface{}
+	if prefix != "" {
+		args = append(args, likeEscaper.Replace(prefix)+"%")
+		conds = append(conds, fmt.Sprintf("t.name LIKE $%d", len(args)))
+	}
+	args = append(args, limit)
+	query := fmt.Sprintf(`
+		SELECT t.name, COUNT(*) AS usage_count
+		FROM tags t
+		JOIN poll_tags pt ON pt.tag_id = t.id
+		JOIN polls p ON p.id = pt.poll_id
+		WHERE %s
+		GROUP BY t.name
+		ORDER BY usage_count DESC, t.name
+		LIMIT $%d
+	`, strings.Join(conds, " AND "), len(args))
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.Name, &t.UsageCount); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		tags = append(tags, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	return tags, nil
+}
+
+// likeEscaper escapes the LIKE wildcards in a search prefix.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// lockUnvotedPoll row-locks a poll within tx and returns a Conflict error if
+// it already has votes. Casting a vote key-share locks the poll row, so no
+// vote can land between the check and the end of tx.
+func lockUnvotedPoll(ctx context.Context, tx *sql.Tx, pollID int64) error {
+	var id int64
+	query := `SELECT id FROM polls WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, query, pollID).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errs.NotFound(err)
+		}
+		return errs.InternalServerError(err)
+	}
+
+	// A separate statement, so votes committed while waiting for the lock
+	// are seen
+	var hasVotes bool
+	query = `SELECT EXISTS (SELECT 1 FROM poll_votes WHERE poll_id = $1)`
+	if err := tx.QueryRowContext(ctx, query, pollID).Scan(&hasVotes); err != nil {
+		return errs.InternalServerError(err)
+	}
+	if hasVotes {
+		return errs.Conflict(errors.New("question and options cannot be edited once voting has started"))
+	}
+	return nil
+}
+
+func (r *Repo) HasUserVoted(ctx context.Context, pollID int64, voter Voter) (bool, error) {
+	column, value := voterColumn(voter)
+	query := "SELECT 1 FROM poll_votes WHERE poll_id=$1 AND " + column + "=$2"
+	row := r.DB.QueryRowContext(ctx, query, pollID, value)
+	var dummy int
+	err := row.Scan(&dummy)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetReceipt looks up the ballot stored with a receipt hash. It returns a
+// NotFound error if no counted ballot carries the receipt.
+func (r *Repo) GetReceipt(ctx context.Context, pollID int64, receiptHash string) (*ReceiptResponse, error) {
+	query := `SELECT option_id, created_at FROM poll_votes WHERE poll_id = $1 AND receipt_hash = $2 ORDER BY rank`
+	rows, err := r.DB.QueryContext(ctx, query, pollID, receiptHash)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	receipt := &ReceiptResponse{PollID: pollID, OptionIDs: []int64{}}
+	for rows.Next() {
+		var optionID int64
+		if err := rows.Scan(&optionID, &receipt.CastAt); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		receipt.OptionIDs = append(receipt.OptionIDs, optionID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	if len(receipt.OptionIDs) == 0 {
+		return nil, errs.NotFound(errors.New("receipt not found"))
+	}
+	receipt.Counted = true
+	return receipt, nil
+}
+
+// GetQuizBallots returns every participant's ballot on a quiz with their
+// username and when they answered, ordered by user.
+func (r *Repo) GetQuizBallots(ctx context.Context, pollID int64) ([]QuizBallot, error) {
+	query := `
+		SELECT v.user_id, u.username, v.option_id, v.created_at
+		FROM poll_votes v
+		JOIN users u ON u.id = v.user_id
+		WHERE v.poll_id = $1
+		ORDER BY v.user_id, v.rank
+	`
+	rows, err := r.DB.QueryContext(ctx, query, pollID)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	ballots := []QuizBallot{}
+	for rows.Next() {
+		var userID, optionID int64
+		var username string
+		var votedAt time.Time
+		if err := rows.Scan(&userID, &username, &optionID, &votedAt); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		n := len(ballots)
+		if n == 0 || ballots[n-1].UserID != userID {
+			ballots = append(ballots, QuizBallot{UserID: userID, Username: username, AnsweredAt: votedAt})
+			n++
+		}
+		b := &ballots[n-1]
+		b.OptionIDs = append(b.OptionIDs, optionID)
+		if votedAt.Before(b.AnsweredAt) {
+			b.AnsweredAt = votedAt
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	return ballots, nil
+}
+
+// SetFollowUps replaces the follow-up rules of a poll's options in one
+// transaction.
+func (r *Repo) SetFollowUps(ctx context.Context, pollID int64, rules []FollowUpRule) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE poll_options SET next_poll_id = NULL WHERE poll_id = $1`, pollID); err != nil {
+		return errs.InternalServerError(err)
+	}
+	query := `UPDATE poll_options SET next_poll_id = $1 WHERE id = $2 AND poll_id = $3`
+	for _, rule := range rules {
+		if _, err := tx.ExecContext(ctx, query, rule.NextPollID, rule.OptionID, pollID); err != nil {
+			return errs.InternalServerError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// GetFollowUps returns the follow-up rules of a poll, leaving out rules that
+// point at deleted polls.
+func (r *Repo) GetFollowUps(ctx context.Context, pollID int64) ([]FollowUpRule, error) {
+	query := `
+		SELECT o.id, o.next_poll_id
+		FROM poll_options o
+		JOIN polls n ON n.id = o.next_poll_id AND n.deleted_at IS NULL
+		WHERE o.poll_id = $1
+		ORDER BY o.id
+	`
+	rows, err := r.DB.QueryContext(ctx, query, pollID)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	rules := []FollowUpRule{}
+	for rows.Next() {
+		var rule FollowUpRule
+		if err := rows.Scan(&rule.OptionID, &rule.NextPollID); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	return rules, nil
+}
+
+// CountVoters returns the number of identified users who voted in a poll.
+func (r *Repo) CountVoters(ctx context.Context, pollID int64) (int64, error) {
+	var count int64
+	query := `SELECT COUNT(DISTINCT user_id) FROM poll_votes WHERE poll_id = $1 AND user_id IS NOT NULL`
+	if err := r.DB.QueryRowContext(ctx, query, pollID).Scan(&count); err != nil {
+		return 0, errs.InternalServerError(err)
+	}
+	return count, nil
+}
+
+// GetFunnelStep counts the identified voters of pollID whose first choice was
+// optionID (entered), and how many of them also voted in nextPollID
+// (continued).
+func (r *Repo) GetFunnelStep(ctx context.Context, pollID, optionID, nextPollID int64) (entered, continued int64, err error) {
+	query := `
+		SELECT COUNT(DISTINCT v.user_id), COUNT(DISTINCT n.user_id)
+		FROM poll_votes v
+		LEFT JOIN poll_votes n ON n.poll_id = $3 AND n.user_id = v.user_id
+		WHERE v.poll_id = $1 AND v.option_id = $2 AND v.rank = 1 AND v.user_id IS NOT NULL
+	`
+	if err := r.DB.QueryRowContext(ctx, query, pollID, optionID, nextPollID).Scan(&entered, &continued); err != nil {
+		return 0, 0, errs.InternalServerError(err)
+	}
+	return entered, continued, nil
+}
+
+// IsUserActive reports whether a user exists and has verified their email
+// address.
+func (r *Repo) IsUserActive(ctx context.Context, userID int64) (bool, error) {
+	var active bool
+	query := `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND is_active)`
+	if err := r.DB.QueryRowContext(ctx, query, userID).Scan(&active); err != nil {
+		return false, errs.InternalServerError(err)
+	}
+	return active, nil
+}
+
+// IsInvited reports whether a user was invited to a poll.
+func (r *Repo) IsInvited(ctx context.Context, pollID, userID int64) (bool, error) {
+	var invited bool
+	query := `SELECT EXISTS (SELECT 1 FROM poll_invites WHERE poll_id = $1 AND user_id = $2)`
+	if err := r.DB.QueryRowContext(ctx, query, pollID, userID).Scan(&invited); err != nil {
+		return false, errs.InternalServerError(err)
+	}
+	return invited, nil
+}
+
+// AddInvites invites users to a poll in one transaction. Users that are
+// already invited or do not exist are skipped.
+func (r *Repo) AddInvites(ctx context.Context, pollID int64, userIDs []int64) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO poll_invites (poll_id, user_id, created_at)
+		SELECT $1, id, NOW() FROM users WHERE id = $2
+		ON CONFLICT DO NOTHING
+	`
+	for _, userID := range userIDs {
+		if _, err := tx.ExecContext(ctx, query, pollID, userID); err != nil {
+			return errs.InternalServerError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// RemoveInvite withdraws a user's invite to a poll.
+func (r *Repo) RemoveInvite(ctx context.Context, pollID, userID int64) error {
+	query := `DELETE FROM poll_invites WHERE poll_id = $1 AND user_id = $2`
+	res, err := r.DB.ExecContext(ctx, query, pollID, userID)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errs.NotFound(sql.ErrNoRows)
+	}
+	return nil
+}
+
+// ListInvites returns the IDs of the users invited to a poll, in order.
+func (r *Repo) ListInvites(ctx context.Context, pollID int64) ([]int64, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT user_id FROM poll_invites WHERE poll_id = $1 ORDER BY user_id`, pollID)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	userIDs := []int64{}
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	return userIDs, nil
+}
+
+// List fetches a page of polls matching the filter, each with its options and
+// total vote count.
+func (r *Repo) List(ctx context.Context, f ListPollsFilter) ([]Poll, error) {
+	where, args := buildListFilter(f)
+
+	orderBy := "p.created_at DESC, p.id DESC"
+	switch f.Sort {
+	case SortMostVoted:
+		orderBy = "total_votes DESC, p.created_at DESC, p.id DESC"
+	case SortTrending:
+		orderBy = `(SELECT COUNT(*) FROM poll_votes rv
+			WHERE rv.poll_id = p.id AND rv.created_at > NOW() - INTERVAL '24 hours') DESC,
+			total_votes DESC, p.created_at DESC, p.id DESC`
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s,
+			(SELECT COUNT(*) FROM poll_votes v WHERE v.poll_id = p.id) AS total_votes
+		FROM polls p
+		%s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d
+	`, pollColumns, where, orderBy, len(args)+1, len(args)+2)
+	args = append(args, f.Limit, f.Offset)
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	polls := []Poll{}
+	index := make(map[int64]int)
+	for rows.Next() {
+		var p Poll
+		if err := scanPoll(rows, &p, &p.TotalVotes); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		index[p.ID] = len(polls)
+		polls = append(polls, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	if len(polls) == 0 {
+		return polls, nil
+	}
+
+	// Fetch the options of every poll on the page in one round trip
+	placeholders := make([]string, len(polls))
+	ids := make([]interface{}, len(polls))
+	for i, p := range polls {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		ids[i] = p.ID
+	}
+	optQuery := fmt.Sprintf(
+		`SELECT id, poll_id, text FROM poll_options WHERE poll_id IN (%s) ORDER BY id`,
+		strings.Join(placeholders, ", "))
+	optRows, err := r.DB.QueryContext(ctx, optQuery, ids...)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer optRows.Close()
+
+	for optRows.Next() {
+		var opt Option
+		if err := optRows.Scan(&opt.ID, &opt.PollID, &opt.Text); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		if i, ok := index[opt.PollID]; ok {
+			polls[i].Options = append(polls[i].Options, opt)
+		}
+	}
+	if err := optRows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	return polls, nil
+}
+
+// Count returns the number of polls matching the filter, ignoring its page window.
+func (r *Repo) Count(ctx context.Context, f ListPollsFilter) (int, error) {
+	where, args := buildListFilter(f)
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM polls p %s`, where)
+
+	var total int
+	if err := r.DB.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
+		return 0, errs.InternalServerError(err)
+	}
+	return total, nil
+}
+
+// buildListFilter turns a ListPollsFilter into a WHERE clause and its
+// positional arguments. Deleted polls and drafts are never listed. The search term is matched
+// against the question and the option texts with Postgres full-text search.
+func buildListFilter(f ListPollsFilter) (string, []interface{}) {
+	conds := []string{listedPolls}
+	var args []interface{}
+
+	switch f.Status {
+	case StatusOpen:
+		conds = append(conds, "p.status = 'open' AND (p.closes_at IS NULL OR p.closes_at > NOW())")
+	case StatusClosed:
+		conds = append(conds, "(p.status = 'closed' OR (p.status = 'open' AND p.closes_at <= NOW()))")
+	case StatusArchived:
+		conds = append(conds, "p.status = 'archived'")
+	}
+
+	if f.UserID != 0 {
+		args = append(args, f.UserID)
+		conds = append(conds, fmt.Sprintf("p.user_id = $%d", len(args)))
+	}
+	if f.CreatedAfter != nil {
+		args = append(args, *f.CreatedAfter)
+		conds = append(conds, fmt.Sprintf("p.created_at >= $%d", len(args)))
+	}
+	if f.CreatedBefore != nil {
+		args = append(args, *f.CreatedBefore)
+		conds = append(conds, fmt.Sprintf("p.created_at < $%d", len(args)))
+	}
+	if f.Tag != "" {
+		args = append(args, f.Tag)
+		conds = append(conds, fmt.Sprintf(`EXISTS (SELECT 1 FROM poll_tags ft JOIN tags t ON t.id = ft.tag_id
+			WHERE ft.poll_id = p.id AND t.name = $%d)`, len(args)))
+	}
+	if f.Search != "" {
+		args = append(args, f.Search)
+		n := len(args)
+		conds = append(conds, fmt.Sprintf(`(to_tsvector('english', p.question) @@ plainto_tsquery('english', $%d)
+			OR EXISTS (SELECT 1 FROM poll_options so WHERE so.poll_id = p.id
+				AND to_tsvector('english', so.text) @@ plainto_tsquery('english', $%d)))`, n, n))
+	}
+
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+// templateColumns lists the columns read by scanTemplate, in scan order.
+const templateColumns = `id, user_id, name, question, poll_type, min_selections, max_selections,
+	allow_vote_change, anonymous, quiz, visibility, results_visibility, created_at`
+
+// scanTemplate scans the templateColumns of a row into t.
+func scanTemplate(row rowScanner, t *PollTemplate) error {
+	return row.Scan(&t.ID, &t.UserID, &t.Name, &t.Question, &t.Type, &t.MinSelections, &t.MaxSelections,
+		&t.AllowVoteChange, &t.Anonymous, &t.Quiz, &t.Visibility, &t.ResultsVisibility, &t.CreatedAt)
+}
+
+// CreateTemplate inserts a poll template and its options, filling in its ID
+// and creation time.
+func (r *Repo) CreateTemplate(ctx context.Context, t *PollTemplate) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO poll_templates (user_id, name, question, poll_type, min_selections, max_selections,
+			allow_vote_change, anonymous, quiz, visibility, results_visibility, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
+		RETURNING id, created_at
+	`
+	err = tx.QueryRowContext(ctx, query, t.UserID, t.Name, t.Question, t.Type, t.MinSelections, t.MaxSelections,
+		t.AllowVoteChange, t.Anonymous, t.Quiz, t.Visibility, t.ResultsVisibility).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+
+	optionQuery := `INSERT INTO poll_template_options (template_id, position, text, correct, points) VALUES ($1, $2, $3, $4, $5)`
+	for i, opt := range t.Options {
+		if _, err := tx.ExecContext(ctx, optionQuery, t.ID, i, opt.Text, opt.Correct, opt.Points); err != nil {
+			return errs.InternalServerError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// GetTemplate fetches a poll template with its options.
+func (r *Repo) GetTemplate(ctx context.Context, id int64) (*PollTemplate, error) {
+	query := `SELECT ` + templateColumns + ` FROM poll_templates WHERE id = $1`
+	t := new(PollTemplate)
+	if err := scanTemplate(r.DB.QueryRowContext(ctx, query, id), t); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errs.NotFound(err)
+		}
+		return nil, errs.InternalServerError(err)
+	}
+
+	options, err := r.templateOptions(ctx, `template_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	t.Options = options[t.ID]
+	setPlaceholders(t)
+	return t, nil
+}
+
+// ListTemplates fetches the poll templates of a user with their options,
+// ordered by name.
+func (r *Repo) ListTemplates(ctx context.Context, userID int64) ([]PollTemplate, error) {
+	query := `SELECT ` + templateColumns + ` FROM poll_templates WHERE user_id = $1 ORDER BY name, id`
+	rows, err := r.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	templates := []PollTemplate{}
+	for rows.Next() {
+		var t PollTemplate
+		if err := scanTemplate(rows, &t); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		templates = append(templates, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	if len(templates) == 0 {
+		return templates, nil
+	}
+
+	options, err := r.templateOptions(ctx, `template_id IN (SELECT id FROM poll_templates WHERE user_id = $1)`, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range templates {
+		templates[i].Options = options[templates[i].ID]
+		setPlaceholders(&templates[i])
+	}
+	return templates, nil
+}
+
+// templateOptions fetches the options of the templates matching where, keyed
+// by template ID and in template order.
+func (r *Repo) templateOptions(ctx context.Context, where string, args ...interface{}) (map[int64][]TemplateOption, error) {
+	query := `SELECT template_id, text, correct, points FROM poll_template_options
+		WHERE ` + where + `
+		ORDER BY template_id, position`
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	options := make(map[int64][]TemplateOption)
+	for rows.Next() {
+		var templateID int64
+		var opt TemplateOption
+		if err := rows.Scan(&templateID, &opt.Text, &opt.Correct, &opt.Points); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		options[templateID] = append(options[templateID], opt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	return options, nil
+}
+
+// DeleteTemplate removes a poll template. Polls created from it are kept.
+func (r *Repo) DeleteTemplate(ctx context.Context, id int64) error {
+	res, err := r.DB.ExecContext(ctx, `DELETE FROM poll_templates WHERE id = $1`, id)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errs.NotFound(sql.ErrNoRows)
+	}
+	return nil
+}
+
+// SetCoverImage replaces the cover image of a poll; nil removes it.
+func (r *Repo) SetCoverImage(ctx context.Context, pollID int64, img *Image) error {
+	var key, thumbnail sql.NullString
+	if img != nil {
+		key = sql.NullString{String: img.Key, Valid: true}
+		thumbnail = sql.NullString{String: img.ThumbnailKey, Valid: true}
+	}
+	query := `UPDATE polls SET cover_image = $2, cover_thumbnail = $3 WHERE id = $1 AND deleted_at IS NULL`
+	res, err := r.DB.ExecContext(ctx, query, pollID, key, thumbnail)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errs.NotFound(sql.ErrNoRows)
+	}
+	return nil
+}
+
+// SetOptionImage replaces the image of a poll option; nil removes it.
+func (r *Repo) SetOptionImage(ctx context.Context, pollID, optionID int64, img *Image) error {
+	var key, thumbnail sql.NullString
+	if img != nil {
+		key = sql.NullString{String: img.Key, Valid: true}
+		thumbnail = sql.NullString{String: img.ThumbnailKey, Valid: true}
+	}
+	query := `UPDATE poll_options SET image = $3, image_thumbnail = $4 WHERE id = $2 AND poll_id = $1`
+	res, err := r.DB.ExecContext(ctx, query, pollID, optionID, key, thumbnail)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errs.NotFound(sql.ErrNoRows)
+	}
+	return nil
 }
@@ -3,10 +3,13 @@ package poll
 import (
 	"context"
 	"database/sql"
+	"net/http"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -46,8 +49,11 @@ func TestRepo_Create(t *testing.T) {
 	poll := &Poll{
 		Question: "What is your favorite color?",
 		UserID:   1,
+		Type:     TypeSingle,
+		Quiz:     true,
+		Status:   StatusOpen,
 		Options: []Option{
-			{Text: "Red"},
+			{Text: "Red", Correct: true, Points: 5},
 			{Text: "Blue"},
 		},
 	}
@@ -60,18 +66,20 @@ func TestRepo_Create(t *testing.T) {
 	pollRows := sqlmock.NewRows([]string{"id", "created_at"}).
 		AddRow(1, time.Now())
 	mock.ExpectQuery("INSERT INTO polls").
-		WithArgs(poll.Question, poll.UserID).
+		WithArgs(poll.Question, poll.UserID, poll.Type, poll.MinSelections, poll.MaxSelections,
+			poll.AllowVoteChange, poll.Anonymous, poll.ExportVotes, poll.Quiz, poll.Status, poll.OpensAt, poll.ClosesAt, poll.Visibility, poll.Slug,
+			poll.ResultsVisibility).
 		WillReturnRows(pollRows)
 
 	// 3. Options insertion
 	optionRows1 := sqlmock.NewRows([]string{"id"}).AddRow(1)
 	mock.ExpectQuery("INSERT INTO poll_options").
-		WithArgs(1, "Red").
+		WithArgs(1, "Red", true, 5).
 		WillReturnRows(optionRows1)
 
 	optionRows2 := sqlmock.NewRows([]string{"id"}).AddRow(2)
 	mock.ExpectQuery("INSERT INTO poll_options").
-		WithArgs(1, "Blue").
+		WithArgs(1, "Blue", false, 0).
 		WillReturnRows(optionRows2)
 
 	// 4. Transaction commits
@@ -107,6 +115,8 @@ func TestRepo_Create_Failure(t *testing.T) {
 	poll := &Poll{
 		Question: "What is your favorite color?",
 		UserID:   1,
+		Type:     TypeSingle,
+		Status:   StatusOpen,
 		Options: []Option{
 			{Text: "Red"},
 			{Text: "Blue"},
@@ -116,7 +126,9 @@ func TestRepo_Create_Failure(t *testing.T) {
 	// Setup expectations - transaction begins but fails
 	mock.ExpectBegin()
 	mock.ExpectQuery("INSERT INTO polls").
-		WithArgs(poll.Question, poll.UserID).
+		WithArgs(poll.Question, poll.UserID, poll.Type, poll.MinSelections, poll.MaxSelections,
+			poll.AllowVoteChange, poll.Anonymous, poll.ExportVotes, poll.Quiz, poll.Status, poll.OpensAt, poll.ClosesAt, poll.Visibility, poll.Slug,
+			poll.ResultsVisibility).
 		WillReturnError(sql.ErrConnDone)
 	mock.ExpectRollback()
 
@@ -145,17 +157,21 @@ func TestRepo_GetByID(t *testing.T) {
 
 	// Setup expectations
 	// 1. Poll query
-	pollRows := sqlmock.NewRows([]string{"id", "question", "created_at"}).
-		AddRow(1, "What is your favorite color?", now)
-	mock.ExpectQuery("SELECT id, question, created_at FROM polls").
+	closesAt := now.Add(time.Hour)
+	pollRows := sqlmock.NewRows([]string{"id", "question", "user_id", "created_at", "poll_type",
+		"min_selections", "max_selections", "allow_vote_change", "anonymous", "export_votes", "quiz", "status", "opens_at", "closes_at",
+		"visibility", "slug", "results_visibility", "tags", "cover_image", "cover_thumbnail"}).
+		AddRow(1, "What is your favorite color?", 5, now, TypeMulti, 1, 2, true, false, false, false, StatusOpen, nil, closesAt,
+			VisibilityUnlisted, "q3J8dUaZ0xT1kLmN", ResultsAfterVote, "colors,design", "cover.png", "cover_thumb.png")
+	mock.ExpectQuery("SELECT p.id, p.question, p.user_id, p.created_at").
 		WithArgs(1).
 		WillReturnRows(pollRows)
 
 	// 2. Options query
-	optionRows := sqlmock.NewRows([]string{"id", "poll_id", "text"}).
-		AddRow(1, 1, "Red").
-		AddRow(2, 1, "Blue")
-	mock.ExpectQuery("SELECT id, poll_id, text FROM poll_options").
+	optionRows := sqlmock.NewRows([]string{"id", "poll_id", "text", "next_poll_id", "correct", "points", "image", "image_thumbnail"}).
+		AddRow(1, 1, "Red", nil, true, 3, "red.jpg", "red_thumb.jpg").
+		AddRow(2, 1, "Blue", 9, false, 0, "", "")
+	mock.ExpectQuery("SELECT o.id, o.poll_id, o.text, n.id, o.correct, o.points, COALESCE\\(o.image, ''\\), COALESCE\\(o.image_thumbnail, ''\\) FROM poll_options o").
 		WithArgs(1).
 		WillReturnRows(optionRows)
 
@@ -168,10 +184,30 @@ func TestRepo_GetByID(t *testing.T) {
 	// Verify poll data
 	assert.Equal(t, int64(1), poll.ID)
 	assert.Equal(t, "What is your favorite color?", poll.Question)
+	assert.Equal(t, int64(5), poll.UserID)
 	assert.Equal(t, now, poll.CreatedAt)
+	assert.Equal(t, TypeMulti, poll.Type)
+	assert.Equal(t, 1, poll.MinSelections)
+	assert.Equal(t, 2, poll.MaxSelections)
+	assert.True(t, poll.AllowVoteChange)
+	assert.Equal(t, StatusOpen, poll.Status)
+	assert.Nil(t, poll.OpensAt)
+	assert.Equal(t, closesAt, *poll.ClosesAt)
+	assert.Equal(t, VisibilityUnlisted, poll.Visibility)
+	assert.Equal(t, "q3J8dUaZ0xT1kLmN", poll.Slug)
+	assert.Equal(t, ResultsAfterVote, poll.ResultsVisibility)
+	assert.Equal(t, []string{"colors", "design"}, poll.Tags)
+	assert.Equal(t, &Image{Key: "cover.png", ThumbnailKey: "cover_thumb.png",
+		URL: "/api/v1/poll/images/cover.png", ThumbnailURL: "/api/v1/poll/images/cover_thumb.png"}, poll.CoverImage)
 	assert.Len(t, poll.Options, 2)
 	assert.Equal(t, "Red", poll.Options[0].Text)
 	assert.Equal(t, "Blue", poll.Options[1].Text)
+	assert.Nil(t, poll.Options[0].NextPollID)
+	assert.True(t, poll.Options[0].Correct)
+	assert.Equal(t, 3, poll.Options[0].Points)
+	assert.Equal(t, int64(9), *poll.Options[1].NextPollID)
+	assert.Equal(t, "/api/v1/poll/images/red_thumb.jpg", poll.Options[0].Image.ThumbnailURL)
+	assert.Nil(t, poll.Options[1].Image)
 
 	// Verify all expectations were met
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -187,7 +223,7 @@ func TestRepo_GetByID_NotFound(t *testing.T) {
 	repo := &Repo{DB: db}
 
 	// Setup expectations - poll not found
-	mock.ExpectQuery("SELECT id, question, created_at FROM polls").
+	mock.ExpectQuery("SELECT p.id, p.question, p.user_id, p.created_at").
 		WithArgs(999).
 		WillReturnError(sql.ErrNoRows)
 
@@ -212,13 +248,101 @@ func TestRepo_Vote(t *testing.T) {
 	// Create repository
 	repo := &Repo{DB: db}
 
-	// Setup expectations
+	// Setup expectations - one row per selection, ranked in ballot order
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO poll_votes").
+		WithArgs(1, 4, 3, nil, nil, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO poll_votes").
+		WithArgs(1, 2, 3, nil, nil, 2).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	// Call function under test
+	err = repo.Vote(context.Background(), 1, Voter{UserID: 3}, []int64{4, 2}, "")
+
+	// Assert no error
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_Vote_Failure(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations - the second selection fails and the ballot is rolled back
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO poll_votes").
+		WithArgs(1, 4, 3, nil, nil, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO poll_votes").
+		WithArgs(1, 2, 3, nil, nil, 2).
+		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	// Call function under test
+	err = repo.Vote(context.Background(), 1, Voter{UserID: 3}, []int64{4, 2}, "")
+
+	// Assert error occurred
+	assert.Error(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_Vote_Concurrent(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations - a concurrent first vote by the same user was stored first
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO poll_votes").
+		WithArgs(1, 4, 3, nil, nil, 1).
+		WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: "poll_votes_poll_id_user_id_rank_key"})
+	mock.ExpectRollback()
+
+	// Call function under test
+	err = repo.Vote(context.Background(), 1, Voter{UserID: 3}, []int64{4}, "")
+
+	// Assert the duplicate ballot is a conflict
+	var serverErr *errs.ServerError
+	require.ErrorAs(t, err, &serverErr)
+	assert.Equal(t, http.StatusConflict, serverErr.Code)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_Vote_Anonymous(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations - only the voter hash and receipt hash are stored
+	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO poll_votes").
-		WithArgs(1, 2, 3).
+		WithArgs(1, 4, nil, "voterhash", "receipthash", 1).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	// Call function under test
-	err = repo.Vote(context.Background(), 1, 2, 3)
+	err = repo.Vote(context.Background(), 1, Voter{Hash: "voterhash"}, []int64{4}, "receipthash")
 
 	// Assert no error
 	assert.NoError(t, err)
@@ -227,6 +351,36 @@ func TestRepo_Vote(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestRepo_GetBallots(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	rows := sqlmock.NewRows([]string{"voter", "option_id"}).
+		AddRow("3", 2).
+		AddRow("3", 1).
+		AddRow("5", 1).
+		AddRow("9f86d081", 2)
+	mock.ExpectQuery("SELECT COALESCE\\(user_id::text, voter_hash\\) AS voter, option_id FROM poll_votes").
+		WithArgs(1).
+		WillReturnRows(rows)
+
+	// Call function under test
+	ballots, err := repo.GetBallots(context.Background(), 1)
+
+	// Assert ballots are grouped per voter in rank order
+	assert.NoError(t, err)
+	assert.Equal(t, []Ballot{{2, 1}, {1}, {2}}, ballots)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestRepo_GetResults(t *testing.T) {
 	// Create mock DB
 	db, mock, err := sqlmock.New()
@@ -257,13 +411,115 @@ func TestRepo_GetResults(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestRepo_GetVoteTimeline(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	first := time.Date(2025, 5, 18, 9, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+	rows := sqlmock.NewRows([]string{"bucket", "option_id", "count"}).
+		AddRow(first, 1, 3).
+		AddRow(first, 2, 1).
+		AddRow(second, 2, 4)
+	mock.ExpectQuery("SELECT date_trunc\\(\\$2, v.created_at\\) AS bucket").
+		WithArgs(1, "hour", false).
+		WillReturnRows(rows)
+
+	// Call function under test
+	timeline, err := repo.GetVoteTimeline(context.Background(), 1, "hour", false)
+
+	// Assert rows are grouped per bucket
+	assert.NoError(t, err)
+	assert.Equal(t, []TimeBucket{
+		{Start: first, Votes: 4, Options: []VoteCount{{OptionID: 1, Votes: 3}, {OptionID: 2, Votes: 1}}},
+		{Start: second, Votes: 4, Options: []VoteCount{{OptionID: 2, Votes: 4}}},
+	}, timeline)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetCohortBreakdown(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	rows := sqlmock.NewRows([]string{"cohort", "option_id", "count"}).
+		AddRow(CohortOver90Days, 1, 2).
+		AddRow(CohortUnder7Days, 1, 1).
+		AddRow(CohortUnder7Days, 2, 5)
+	mock.ExpectQuery("JOIN users u ON u.id = v.user_id").
+		WithArgs(1, true).
+		WillReturnRows(rows)
+
+	// Call function under test
+	cohorts, err := repo.GetCohortBreakdown(context.Background(), 1, true)
+
+	// Assert cohorts are ordered from the youngest accounts
+	assert.NoError(t, err)
+	assert.Equal(t, []CohortBucket{
+		{Cohort: CohortUnder7Days, Votes: 6, Options: []VoteCount{{OptionID: 1, Votes: 1}, {OptionID: 2, Votes: 5}}},
+		{Cohort: CohortOver90Days, Votes: 2, Options: []VoteCount{{OptionID: 1, Votes: 2}}},
+	}, cohorts)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_StreamVotes(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "poll_id", "option_id", "user_id", "rank", "created_at"}).
+		AddRow(1, 1, 2, 5, 0, now).
+		AddRow(2, 1, 1, 6, 0, now)
+	mock.ExpectQuery("SELECT id, poll_id, option_id, user_id, rank, created_at FROM poll_votes").
+		WithArgs(1).
+		WillReturnRows(rows)
+
+	// Call function under test
+	var votes []Vote
+	err = repo.StreamVotes(context.Background(), 1, func(v Vote) error {
+		votes = append(votes, v)
+		return nil
+	})
+
+	// Assert every vote was passed to the callback in order
+	assert.NoError(t, err)
+	assert.Equal(t, []Vote{
+		{ID: 1, PollID: 1, OptionID: 2, UserID: 5, CreatedAt: now},
+		{ID: 2, PollID: 1, OptionID: 1, UserID: 6, CreatedAt: now},
+	}, votes)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestRepo_HasUserVoted(t *testing.T) {
 	// Test cases
 	tests := []struct {
 		name     string
 		setup    func(mock sqlmock.Sqlmock)
 		pollID   int64
-		userID   int64
+		voter    Voter
 		expected bool
 		hasError bool
 	}{
@@ -276,7 +532,7 @@ func TestRepo_HasUserVoted(t *testing.T) {
 					WillReturnRows(rows)
 			},
 			pollID:   1,
-			userID:   2,
+			voter:    Voter{UserID: 2},
 			expected: true,
 			hasError: false,
 		},
@@ -288,10 +544,23 @@ func TestRepo_HasUserVoted(t *testing.T) {
 					WillReturnError(sql.ErrNoRows)
 			},
 			pollID:   1,
-			userID:   3,
+			voter:    Voter{UserID: 3},
 			expected: false,
 			hasError: false,
 		},
+		{
+			name: "Anonymous voter has voted",
+			setup: func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"1"}).AddRow(1)
+				mock.ExpectQuery("SELECT 1 FROM poll_votes WHERE poll_id=\\$1 AND voter_hash=\\$2").
+					WithArgs(1, "voterhash").
+					WillReturnRows(rows)
+			},
+			pollID:   1,
+			voter:    Voter{Hash: "voterhash"},
+			expected: true,
+			hasError: false,
+		},
 		{
 			name: "Database error",
 			setup: func(mock sqlmock.Sqlmock) {
@@ -300,7 +569,7 @@ func TestRepo_HasUserVoted(t *testing.T) {
 					WillReturnError(sql.ErrConnDone)
 			},
 			pollID:   1,
-			userID:   4,
+			voter:    Voter{UserID: 4},
 			expected: false,
 			hasError: true,
 		},
@@ -320,7 +589,7 @@ func TestRepo_HasUserVoted(t *testing.T) {
 			tt.setup(mock)
 
 			// Call function under test
-			hasVoted, err := repo.HasUserVoted(context.Background(), tt.pollID, tt.userID)
+			hasVoted, err := repo.HasUserVoted(context.Background(), tt.pollID, tt.voter)
 
 			// Assert results
 			if tt.hasError {
@@ -335,3 +604,1175 @@ func TestRepo_HasUserVoted(t *testing.T) {
 		})
 	}
 }
+
+func TestRepo_List(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	now := time.Now().Truncate(time.Second)
+
+	// Setup expectations
+	// 1. Page query with creator filter, search term and page window
+	pollRows := sqlmock.NewRows([]string{"id", "question", "user_id", "created_at", "poll_type",
+		"min_selections", "max_selections", "allow_vote_change", "anonymous", "export_votes", "quiz", "status", "opens_at", "closes_at",
+		"visibility", "slug", "results_visibility", "tags", "cover_image", "cover_thumbnail", "total_votes"}).
+		AddRow(2, "Best editor?", 7, now, TypeSingle, 0, 0, false, false, false, false, StatusOpen, nil, nil, VisibilityPublic, "", ResultsAlways, "editors", "", "", 4).
+		AddRow(1, "Favorite language?", 7, now.Add(-time.Hour), TypeRanked, 0, 0, false, true, false, false, StatusClosed, nil, now, VisibilityPublic, "", ResultsAfterClose, "", "", "", 9)
+	mock.ExpectQuery("SELECT p.id, p.question, p.user_id, p.created_at").
+		WithArgs(7, "go", 10, 0).
+		WillReturnRows(pollRows)
+
+	// 2. Options for every poll on the page
+	optionRows := sqlmock.NewRows([]string{"id", "poll_id", "text"}).
+		AddRow(1, 1, "Go").
+		AddRow(2, 1, "Rust").
+		AddRow(3, 2, "Vim")
+	mock.ExpectQuery("SELECT id, poll_id, text FROM poll_options WHERE poll_id IN").
+		WithArgs(2, 1).
+		WillReturnRows(optionRows)
+
+	// Call function under test
+	polls, err := repo.List(context.Background(), ListPollsFilter{
+		UserID: 7,
+		Search: "go",
+		Sort:   SortMostVoted,
+		Limit:  10,
+	})
+
+	// Assert results
+	assert.NoError(t, err)
+	assert.Len(t, polls, 2)
+	assert.Equal(t, int64(2), polls[0].ID)
+	assert.Equal(t, int64(4), polls[0].TotalVotes)
+	assert.Equal(t, []string{"editors"}, polls[0].Tags)
+	assert.Nil(t, polls[1].Tags)
+	assert.Len(t, polls[0].Options, 1)
+	assert.Len(t, polls[1].Options, 2)
+	assert.Equal(t, int64(7), polls[1].UserID)
+	assert.Equal(t, StatusClosed, polls[1].Status)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_List_Empty(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations - no option query is issued for an empty page
+	mock.ExpectQuery("SELECT p.id, p.question, p.user_id, p.created_at").
+		WithArgs(10, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "question", "user_id", "created_at", "poll_type",
+			"min_selections", "max_selections", "allow_vote_change", "anonymous", "export_votes", "quiz", "status", "opens_at", "closes_at",
+			"visibility", "slug", "results_visibility", "tags", "cover_image", "cover_thumbnail", "total_votes"}))
+
+	// Call function under test
+	polls, err := repo.List(context.Background(), ListPollsFilter{Limit: 10, Offset: 20})
+
+	// Assert an empty, non-nil slice
+	assert.NoError(t, err)
+	assert.NotNil(t, polls)
+	assert.Empty(t, polls)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_Count(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	after := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Setup expectations
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM polls p WHERE p.deleted_at IS NULL AND p.status <> 'draft' AND p.visibility = 'public' AND p.created_at >= \\$1").
+		WithArgs(after).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(42))
+
+	// Call function under test
+	total, err := repo.Count(context.Background(), ListPollsFilter{CreatedAfter: &after})
+
+	// Assert results
+	assert.NoError(t, err)
+	assert.Equal(t, 42, total)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_UpdateStatus(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectExec("UPDATE polls").
+		WithArgs(1, StatusClosed).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE polls").
+		WithArgs(999, StatusOpen).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Existing poll is updated
+	err = repo.UpdateStatus(context.Background(), 1, StatusClosed)
+	assert.NoError(t, err)
+
+	// Missing poll is reported as not found
+	err = repo.UpdateStatus(context.Background(), 999, StatusOpen)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no rows")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ChangeVote(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations - lock, replace and audit in one transaction
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT option_id FROM poll_votes WHERE poll_id = \\$1 AND user_id = \\$2 ORDER BY rank FOR UPDATE").
+		WithArgs(1, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"option_id"}).AddRow(2).AddRow(1))
+	mock.ExpectExec("DELETE FROM poll_votes").
+		WithArgs(1, 3).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO poll_votes").
+		WithArgs(1, 4, 3, nil, nil, 1).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec("INSERT INTO poll_vote_history").
+		WithArgs(1, 3, nil, VoteActionChange, "{2,1}", "{4}").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// Call function under test
+	err = repo.ChangeVote(context.Background(), 1, Voter{UserID: 3}, []int64{4}, "")
+
+	// Assert no error
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ChangeVote_NotVoted(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations - no ballot to change
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT option_id FROM poll_votes").
+		WithArgs(1, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"option_id"}))
+	mock.ExpectRollback()
+
+	// Call function under test
+	err = repo.ChangeVote(context.Background(), 1, Voter{UserID: 3}, []int64{4}, "")
+
+	// Assert not found error
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "you have not voted on this poll")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ChangeVote_Anonymous(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations - the ballot is found by voter hash and gets the new receipt
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT option_id FROM poll_votes WHERE poll_id = \\$1 AND voter_hash = \\$2 ORDER BY rank FOR UPDATE").
+		WithArgs(1, "voterhash").
+		WillReturnRows(sqlmock.NewRows([]string{"option_id"}).AddRow(2))
+	mock.ExpectExec("DELETE FROM poll_votes WHERE poll_id = \\$1 AND voter_hash = \\$2").
+		WithArgs(1, "voterhash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO poll_votes").
+		WithArgs(1, 4, nil, "voterhash", "newreceipt", 1).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec("INSERT INTO poll_vote_history").
+		WithArgs(1, nil, "voterhash", VoteActionChange, "{2}", "{4}").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// Call function under test
+	err = repo.ChangeVote(context.Background(), 1, Voter{Hash: "voterhash"}, []int64{4}, "newreceipt")
+
+	// Assert no error
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetReceipt(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	castAt := time.Now()
+	mock.ExpectQuery("SELECT option_id, created_at FROM poll_votes WHERE poll_id = \\$1 AND receipt_hash = \\$2").
+		WithArgs(1, "receipthash").
+		WillReturnRows(sqlmock.NewRows([]string{"option_id", "created_at"}).AddRow(3, castAt).AddRow(1, castAt))
+	mock.ExpectQuery("SELECT option_id, created_at FROM poll_votes").
+		WithArgs(1, "unknown").
+		WillReturnRows(sqlmock.NewRows([]string{"option_id", "created_at"}))
+
+	// Call function under test
+	receipt, err := repo.GetReceipt(context.Background(), 1, "receipthash")
+	_, missingErr := repo.GetReceipt(context.Background(), 1, "unknown")
+
+	// Assert the ballot is returned in rank order and unknown receipts are not found
+	assert.NoError(t, err)
+	assert.Equal(t, &ReceiptResponse{PollID: 1, Counted: true, OptionIDs: []int64{3, 1}, CastAt: castAt}, receipt)
+	assert.EqualError(t, missingErr, "receipt not found")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_RetractVote(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT option_id FROM poll_votes").
+		WithArgs(1, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"option_id"}).AddRow(2))
+	mock.ExpectExec("DELETE FROM poll_votes").
+		WithArgs(1, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO poll_vote_history").
+		WithArgs(1, 3, nil, VoteActionRetract, "{2}", "{}").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// Call function under test
+	err = repo.RetractVote(context.Background(), 1, Voter{UserID: 3})
+
+	// Assert no error
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_Update(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	poll := &Poll{
+		ID:       1,
+		Question: "Updated question?",
+		Options:  []Option{{Text: "Yes"}, {Text: "No"}},
+	}
+
+	// Setup expectations - the poll is locked and checked for votes first
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM polls WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").
+		WithArgs(poll.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM poll_votes WHERE poll_id = \\$1\\)").
+		WithArgs(poll.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("UPDATE polls").
+		WithArgs(poll.ID, poll.Question, false, nil, nil, "", "", "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM poll_options").
+		WithArgs(poll.ID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("INSERT INTO poll_options").
+		WithArgs(poll.ID, "Yes").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectQuery("INSERT INTO poll_options").
+		WithArgs(poll.ID, "No").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+	mock.ExpectCommit()
+
+	// Call function under test
+	err = repo.Update(context.Background(), poll, true, true, false)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), poll.Options[0].ID)
+	assert.Equal(t, int64(12), poll.Options[1].ID)
+	assert.Equal(t, int64(1), poll.Options[1].PollID)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_SoftDelete(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectExec("UPDATE polls SET deleted_at = NOW\\(\\) WHERE id = \\$1 AND deleted_at IS NULL").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE polls SET deleted_at").
+		WithArgs(999).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Existing poll is deleted
+	err = repo.SoftDelete(context.Background(), 1)
+	assert.NoError(t, err)
+
+	// Missing or already deleted poll is reported as not found
+	err = repo.SoftDelete(context.Background(), 999)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no rows")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_SetFollowUps(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE poll_options SET next_poll_id = NULL WHERE poll_id").
+		WithArgs(12).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("UPDATE poll_options SET next_poll_id = \\$1 WHERE id = \\$2 AND poll_id = \\$3").
+		WithArgs(15, 2, 12).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE poll_options SET next_poll_id = \\$1 WHERE id = \\$2 AND poll_id = \\$3").
+		WithArgs(16, 3, 12).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Call function under test
+	err = repo.SetFollowUps(context.Background(), 12, []FollowUpRule{
+		{OptionID: 2, NextPollID: 15},
+		{OptionID: 3, NextPollID: 16},
+	})
+
+	// Assert
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetFollowUps(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	rows := sqlmock.NewRows([]string{"id", "next_poll_id"}).
+		AddRow(2, 15).
+		AddRow(3, 16)
+	mock.ExpectQuery("SELECT o.id, o.next_poll_id FROM poll_options o JOIN polls n").
+		WithArgs(12).
+		WillReturnRows(rows)
+
+	// Call function under test
+	rules, err := repo.GetFollowUps(context.Background(), 12)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []FollowUpRule{{OptionID: 2, NextPollID: 15}, {OptionID: 3, NextPollID: 16}}, rules)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetFunnelStep(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectQuery("SELECT COUNT\\(DISTINCT v.user_id\\), COUNT\\(DISTINCT n.user_id\\) FROM poll_votes v").
+		WithArgs(12, 2, 15).
+		WillReturnRows(sqlmock.NewRows([]string{"entered", "continued"}).AddRow(40, 30))
+
+	// Call function under test
+	entered, continued, err := repo.GetFunnelStep(context.Background(), 12, 2, 15)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(40), entered)
+	assert.Equal(t, int64(30), continued)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetQuizBallots(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	first := time.Date(2025, 5, 18, 9, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"user_id", "username", "option_id", "created_at"}).
+		AddRow(3, "alice", 1, first).
+		AddRow(3, "alice", 2, first).
+		AddRow(5, "bob", 2, first.Add(time.Minute))
+	mock.ExpectQuery("SELECT v.user_id, u.username, v.option_id, v.created_at FROM poll_votes v JOIN users u").
+		WithArgs(1).
+		WillReturnRows(rows)
+
+	// Call function under test
+	ballots, err := repo.GetQuizBallots(context.Background(), 1)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []QuizBallot{
+		{UserID: 3, Username: "alice", OptionIDs: []int64{1, 2}, AnsweredAt: first},
+		{UserID: 5, Username: "bob", OptionIDs: []int64{2}, AnsweredAt: first.Add(time.Minute)},
+	}, ballots)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetBySlug(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	now := time.Now().Truncate(time.Second)
+	mock.ExpectQuery("FROM polls p WHERE p.slug = \\$1 AND p.deleted_at IS NULL").
+		WithArgs("q3J8dUaZ0xT1kLmN").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "question", "user_id", "created_at", "poll_type",
+			"min_selections", "max_selections", "allow_vote_change", "anonymous", "export_votes", "quiz", "status", "opens_at", "closes_at",
+			"visibility", "slug", "results_visibility", "tags", "cover_image", "cover_thumbnail"}).
+			AddRow(4, "Lunch?", 5, now, TypeSingle, 0, 0, false, false, false, false, StatusOpen, nil, nil,
+				VisibilityUnlisted, "q3J8dUaZ0xT1kLmN", ResultsAfterVote, "", "", ""))
+	mock.ExpectQuery("SELECT o.id, o.poll_id, o.text, n.id, o.correct, o.points, COALESCE\\(o.image, ''\\), COALESCE\\(o.image_thumbnail, ''\\) FROM poll_options o").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "poll_id", "text", "next_poll_id", "correct", "points", "image", "image_thumbnail"}).
+			AddRow(1, 4, "Pizza", nil, false, 0, "", ""))
+
+	// Call function under test
+	poll, err := repo.GetBySlug(context.Background(), "q3J8dUaZ0xT1kLmN")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), poll.ID)
+	assert.Equal(t, VisibilityUnlisted, poll.Visibility)
+	assert.Len(t, poll.Options, 1)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetBySlug_NotFound(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectQuery("FROM polls p WHERE p.slug = \\$1").
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+
+	// Call function under test
+	poll, err := repo.GetBySlug(context.Background(), "missing")
+
+	// Assert not found error
+	assert.Error(t, err)
+	assert.Nil(t, poll)
+	assert.Contains(t, err.Error(), "no rows")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_IsUserActive(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM users WHERE id = \\$1 AND is_active\\)").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	// Call function under test
+	active, err := repo.IsUserActive(context.Background(), 3)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_IsInvited(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM poll_invites WHERE poll_id = \\$1 AND user_id = \\$2\\)").
+		WithArgs(1, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	// Call function under test
+	invited, err := repo.IsInvited(context.Background(), 1, 4)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, invited)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_AddInvites(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations - one insert per user, skipping unknown users
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO poll_invites .* SELECT \\$1, id, NOW\\(\\) FROM users WHERE id = \\$2 ON CONFLICT DO NOTHING").
+		WithArgs(1, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO poll_invites").
+		WithArgs(1, 99).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	// Call function under test
+	err = repo.AddInvites(context.Background(), 1, []int64{4, 99})
+
+	// Assert
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_RemoveInvite(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectExec("DELETE FROM poll_invites WHERE poll_id = \\$1 AND user_id = \\$2").
+		WithArgs(1, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM poll_invites").
+		WithArgs(1, 5).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Existing invite is withdrawn
+	err = repo.RemoveInvite(context.Background(), 1, 4)
+	assert.NoError(t, err)
+
+	// Missing invite is reported as not found
+	err = repo.RemoveInvite(context.Background(), 1, 5)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no rows")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ListInvites(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectQuery("SELECT user_id FROM poll_invites WHERE poll_id = \\$1 ORDER BY user_id").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(4).AddRow(7))
+
+	// Call function under test
+	userIDs, err := repo.ListInvites(context.Background(), 1)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{4, 7}, userIDs)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_Create_WithTags(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	poll := &Poll{
+		Question: "Tabs or spaces?",
+		UserID:   1,
+		Type:     TypeSingle,
+		Status:   StatusOpen,
+		Options:  []Option{{Text: "Tabs"}, {Text: "Spaces"}},
+		Tags:     []string{"go", "style"},
+	}
+
+	// Setup expectations - tags are upserted and attached in the same transaction
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO polls").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	mock.ExpectQuery("INSERT INTO poll_options").
+		WithArgs(1, "Tabs", false, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO poll_options").
+		WithArgs(1, "Spaces", false, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("INSERT INTO tags \\(name, created_at\\) VALUES \\(\\$1, NOW\\(\\)\\) ON CONFLICT \\(name\\) DO UPDATE SET name = EXCLUDED.name RETURNING id").
+		WithArgs("go").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec("INSERT INTO poll_tags").
+		WithArgs(1, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO tags").
+		WithArgs("style").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectExec("INSERT INTO poll_tags").
+		WithArgs(1, 8).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Call function under test
+	err = repo.Create(context.Background(), poll)
+
+	// Assert
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_Update_VotesExist(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	poll := &Poll{ID: 1, Question: "Updated question?", Options: []Option{{Text: "Yes"}, {Text: "No"}}}
+
+	// Setup expectations - nothing is written once a vote exists
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM polls WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").
+		WithArgs(poll.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM poll_votes WHERE poll_id = \\$1\\)").
+		WithArgs(poll.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	// Call function under test
+	err = repo.Update(context.Background(), poll, true, true, false)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "once voting has started")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_Update_ReplacesTags(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	poll := &Poll{ID: 1, Question: "Tabs or spaces?", Tags: []string{"style"}}
+
+	// Setup expectations - old tags are detached before the new ones are added
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE polls").
+		WithArgs(poll.ID, poll.Question, false, nil, nil, "", "", "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM poll_tags").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("INSERT INTO tags").
+		WithArgs("style").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectExec("INSERT INTO poll_tags").
+		WithArgs(1, 8).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Call function under test
+	err = repo.Update(context.Background(), poll, false, false, true)
+
+	// Assert
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ListTags(t *testing.T) {
+	tests := []struct {
+		name      string
+		prefix    string
+		mockSetup func(sqlmock.Sqlmock)
+		expected  []Tag
+	}{
+		{
+			name: "Popular tags",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT t.name, COUNT\\(\\*\\) AS usage_count FROM tags t JOIN poll_tags pt ON pt.tag_id = t.id JOIN polls p ON p.id = pt.poll_id " +
+					"WHERE p.deleted_at IS NULL AND p.status <> 'draft' AND p.visibility = 'public' GROUP BY t.name ORDER BY usage_count DESC, t.name LIMIT \\$1").
+					WithArgs(20).
+					WillReturnRows(sqlmock.NewRows([]string{"name", "usage_count"}).
+						AddRow("go", 12).
+						AddRow("rust", 4))
+			},
+			expected: []Tag{{Name: "go", UsageCount: 12}, {Name: "rust", UsageCount: 4}},
+		},
+		{
+			name:   "Prefix search escapes wildcards",
+			prefix: "data_",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("AND p.visibility = 'public' AND t.name LIKE \\$1 GROUP BY t.name ORDER BY usage_count DESC, t.name LIMIT \\$2").
+					WithArgs(`data\_%`, 20).
+					WillReturnRows(sqlmock.NewRows([]string{"name", "usage_count"}))
+			},
+			expected: []Tag{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mock DB
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			// Create repository
+			repo := &Repo{DB: db}
+
+			// Setup expectations
+			tt.mockSetup(mock)
+
+			// Call function under test
+			tags, err := repo.ListTags(context.Background(), tt.prefix, 20)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, tags)
+
+			// Verify all expectations were met
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepo_Count_ByTag(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectQuery("p.visibility = 'public' AND EXISTS \\(SELECT 1 FROM poll_tags ft JOIN tags t ON t.id = ft.tag_id WHERE ft.poll_id = p.id AND t.name = \\$1\\)").
+		WithArgs("go").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	// Call function under test
+	total, err := repo.Count(context.Background(), ListPollsFilter{Tag: "go"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetTrending(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations - window in seconds, gravity and ranking size
+	now := time.Now().Truncate(time.Second)
+	mock.ExpectQuery("WHERE v.rank = 1 AND v.created_at > NOW\\(\\) - make_interval\\(secs => \\$1\\) GROUP BY v.poll_id").
+		WithArgs(float64(48*3600), 1.8, 50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "question", "user_id", "created_at", "poll_type",
+			"min_selections", "max_selections", "allow_vote_change", "anonymous", "export_votes", "quiz", "status", "opens_at", "closes_at",
+			"visibility", "slug", "results_visibility", "tags", "cover_image", "cover_thumbnail", "score", "recent_votes"}).
+			AddRow(3, "Lunch?", 5, now, TypeSingle, 0, 0, false, false, false, false, StatusOpen, nil, nil,
+				VisibilityPublic, "", ResultsAlways, "food", "", "", 2.75, 31).
+			AddRow(1, "Tabs or spaces?", 7, now, TypeSingle, 0, 0, false, false, false, false, StatusOpen, nil, nil,
+				VisibilityPublic, "", ResultsAlways, "", "", "", 0.4, 3))
+
+	// Call function under test
+	polls, err := repo.GetTrending(context.Background(), 48*time.Hour, 1.8, 50)
+
+	// Assert
+	assert.NoError(t, err)
+	require.Len(t, polls, 2)
+	assert.Equal(t, int64(3), polls[0].ID)
+	assert.Equal(t, 2.75, polls[0].Score)
+	assert.Equal(t, int64(31), polls[0].RecentVotes)
+	assert.Equal(t, []string{"food"}, polls[0].Tags)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// templateRowColumns are the columns of a poll_templates row in scan order.
+var templateRowColumns = []string{"id", "user_id", "name", "question", "poll_type", "min_selections", "max_selections",
+	"allow_vote_change", "anonymous", "quiz", "visibility", "results_visibility", "created_at"}
+
+func TestRepo_CreateTemplate(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	tmpl := &PollTemplate{UserID: 3, Name: "Retro", Question: "Week {{week}}?", Type: TypeSingle,
+		Visibility: VisibilityPublic, ResultsVisibility: ResultsAlways,
+		Options: []TemplateOption{{Text: "Great"}, {Text: "Rough"}}}
+	now := time.Now()
+
+	// Setup expectations
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO poll_templates").
+		WithArgs(3, "Retro", "Week {{week}}?", TypeSingle, 0, 0, false, false, false, VisibilityPublic, ResultsAlways).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(5, now))
+	mock.ExpectExec("INSERT INTO poll_template_options").
+		WithArgs(5, 0, "Great", false, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO poll_template_options").
+		WithArgs(5, 1, "Rough", false, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Call function under test
+	err = repo.CreateTemplate(context.Background(), tmpl)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), tmpl.ID)
+	assert.Equal(t, now, tmpl.CreatedAt)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetTemplate(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+	now := time.Now()
+
+	// Setup expectations
+	mock.ExpectQuery("FROM poll_templates WHERE id = \\$1").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(templateRowColumns).
+			AddRow(5, 3, "Retro", "How was week {{week}} for {{team}}?", TypeSingle, 0, 0, false, false, true,
+				VisibilityPublic, ResultsAlways, now))
+	mock.ExpectQuery("SELECT template_id, text, correct, points FROM poll_template_options WHERE template_id = \\$1 ORDER BY template_id, position").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"template_id", "text", "correct", "points"}).
+			AddRow(5, "Great", true, 2).
+			AddRow(5, "{{team}} struggled", false, 0))
+
+	// Call function under test
+	tmpl, err := repo.GetTemplate(context.Background(), 5)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, &PollTemplate{
+		ID: 5, UserID: 3, Name: "Retro", Question: "How was week {{week}} for {{team}}?",
+		Options: []TemplateOption{{Text: "Great", Correct: true, Points: 2}, {Text: "{{team}} struggled"}},
+		Type:    TypeSingle, Quiz: true, Visibility: VisibilityPublic, ResultsVisibility: ResultsAlways,
+		Placeholders: []string{"week", "team"}, CreatedAt: now,
+	}, tmpl)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetTemplate_NotFound(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectQuery("FROM poll_templates WHERE id = \\$1").
+		WithArgs(5).
+		WillReturnError(sql.ErrNoRows)
+
+	// Call function under test
+	tmpl, err := repo.GetTemplate(context.Background(), 5)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, tmpl)
+	assert.Contains(t, err.Error(), "sql: no rows in result set")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ListTemplates(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+	now := time.Now()
+
+	// Setup expectations
+	mock.ExpectQuery("FROM poll_templates WHERE user_id = \\$1 ORDER BY name, id").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(templateRowColumns).
+			AddRow(5, 3, "Lunch", "Lunch on {{date}}?", TypeSingle, 0, 0, false, false, false, VisibilityPublic, ResultsAlways, now).
+			AddRow(6, 3, "Retro", "Retro?", TypeSingle, 0, 0, false, false, false, VisibilityPublic, ResultsAlways, now))
+	mock.ExpectQuery("FROM poll_template_options WHERE template_id IN \\(SELECT id FROM poll_templates WHERE user_id = \\$1\\)").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"template_id", "text", "correct", "points"}).
+			AddRow(5, "Pizza", false, 0).
+			AddRow(5, "Sushi", false, 0).
+			AddRow(6, "Good", false, 0).
+			AddRow(6, "Bad", false, 0))
+
+	// Call function under test
+	templates, err := repo.ListTemplates(context.Background(), 3)
+
+	// Assert
+	assert.NoError(t, err)
+	require.Len(t, templates, 2)
+	assert.Equal(t, []TemplateOption{{Text: "Pizza"}, {Text: "Sushi"}}, templates[0].Options)
+	assert.Equal(t, []string{"date"}, templates[0].Placeholders)
+	assert.Equal(t, []TemplateOption{{Text: "Good"}, {Text: "Bad"}}, templates[1].Options)
+	assert.Equal(t, []string{}, templates[1].Placeholders)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_DeleteTemplate(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectExec("DELETE FROM poll_templates WHERE id = \\$1").
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM poll_templates WHERE id = \\$1").
+		WithArgs(6).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Call function under test
+	err = repo.DeleteTemplate(context.Background(), 5)
+	assert.NoError(t, err)
+	err = repo.DeleteTemplate(context.Background(), 6)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "sql: no rows in result set")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_SetCoverImage(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectExec("UPDATE polls SET cover_image = \\$2, cover_thumbnail = \\$3 WHERE id = \\$1 AND deleted_at IS NULL").
+		WithArgs(1, "cover.png", "cover_thumb.png").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE polls SET cover_image = \\$2, cover_thumbnail = \\$3").
+		WithArgs(1, nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Call function under test
+	err = repo.SetCoverImage(context.Background(), 1, newImage("cover.png", "cover_thumb.png"))
+	assert.NoError(t, err)
+	err = repo.SetCoverImage(context.Background(), 1, nil)
+
+	// Assert
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_SetOptionImage(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectExec("UPDATE poll_options SET image = \\$3, image_thumbnail = \\$4 WHERE id = \\$2 AND poll_id = \\$1").
+		WithArgs(1, 2, "red.jpg", "red_thumb.jpg").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE poll_options SET image = \\$3, image_thumbnail = \\$4").
+		WithArgs(1, 3, "red.jpg", "red_thumb.jpg").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Call function under test
+	err = repo.SetOptionImage(context.Background(), 1, 2, newImage("red.jpg", "red_thumb.jpg"))
+	assert.NoError(t, err)
+	err = repo.SetOptionImage(context.Background(), 1, 3, newImage("red.jpg", "red_thumb.jpg"))
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "sql: no rows in result set")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
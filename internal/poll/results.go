@@ -0,0 +1,48 @@
+package poll
+
+import "math"
+
+// rankOptions turns raw option tallies into results with a percentage of
+// base, a competition rank ("1224") by votes and the winning options. For
+// ranked polls the winners come from the runoff count; otherwise the most
+// voted options win. Options keep their original order. tie reports whether
+// more than one option won.
+func rankOptions(options []Option, base int64, runoff *RunoffResult) (results []OptionResult, tie bool) {
+	results = make([]OptionResult, len(options))
+	var top int64
+	for i, opt := range options {
+		results[i] = OptionResult{ID: opt.ID, Text: opt.Text, Votes: opt.Votes, Rank: 1}
+		if base > 0 {
+			results[i].Percentage = math.Round(float64(opt.Votes)*1000/float64(base)) / 10
+		}
+		for _, other := range options {
+			if other.Votes > opt.Votes {
+				results[i].Rank++
+			}
+		}
+		if opt.Votes > top {
+			top = opt.Votes
+		}
+	}
+
+	winners := make(map[int64]bool)
+	switch {
+	case runoff != nil && runoff.WinnerID != nil:
+		winners[*runoff.WinnerID] = true
+	case runoff != nil:
+		for _, id := range runoff.Tied {
+			winners[id] = true
+		}
+	case top > 0:
+		for _, opt := range options {
+			if opt.Votes == top {
+				winners[opt.ID] = true
+			}
+		}
+	}
+
+	for i := range results {
+		results[i].Winner = winners[results[i].ID]
+	}
+	return results, len(winners) > 1
+}
@@ -0,0 +1,64 @@
+package poll
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRankOptions(t *testing.T) {
+	t.Run("Percentages, ranks and a single winner", func(t *testing.T) {
+		options := []Option{{ID: 1, Text: "Red", Votes: 3}, {ID: 2, Text: "Blue", Votes: 5}, {ID: 3, Text: "Green", Votes: 0}}
+
+		results, tie := rankOptions(options, 8, nil)
+
+		assert.False(t, tie)
+		assert.Equal(t, []OptionResult{
+			{ID: 1, Text: "Red", Votes: 3, Percentage: 37.5, Rank: 2},
+			{ID: 2, Text: "Blue", Votes: 5, Percentage: 62.5, Rank: 1, Winner: true},
+			{ID: 3, Text: "Green", Votes: 0, Percentage: 0, Rank: 3},
+		}, results)
+	})
+
+	t.Run("Tied options share rank and the win", func(t *testing.T) {
+		options := []Option{{ID: 1, Votes: 2}, {ID: 2, Votes: 2}, {ID: 3, Votes: 1}}
+
+		results, tie := rankOptions(options, 6, nil)
+
+		assert.True(t, tie)
+		assert.Equal(t, []int{1, 1, 3}, []int{results[0].Rank, results[1].Rank, results[2].Rank})
+		assert.True(t, results[0].Winner)
+		assert.True(t, results[1].Winner)
+		assert.False(t, results[2].Winner)
+		assert.Equal(t, 33.3, results[0].Percentage)
+	})
+
+	t.Run("No votes has no winner", func(t *testing.T) {
+		results, tie := rankOptions([]Option{{ID: 1}, {ID: 2}}, 0, nil)
+
+		assert.False(t, tie)
+		for _, r := range results {
+			assert.Equal(t, 1, r.Rank)
+			assert.False(t, r.Winner)
+			assert.Zero(t, r.Percentage)
+		}
+	})
+
+	t.Run("Runoff decides the winner of ranked polls", func(t *testing.T) {
+		winner := int64(2)
+		options := []Option{{ID: 1, Votes: 3}, {ID: 2, Votes: 2}}
+
+		results, tie := rankOptions(options, 5, &RunoffResult{WinnerID: &winner})
+
+		assert.False(t, tie)
+		assert.Equal(t, 1, results[0].Rank)
+		assert.False(t, results[0].Winner)
+		assert.True(t, results[1].Winner)
+
+		// A runoff without a winner reports its tied options
+		results, tie = rankOptions(options, 5, &RunoffResult{Tied: []int64{1, 2}})
+		assert.True(t, tie)
+		assert.True(t, results[0].Winner)
+		assert.True(t, results[1].Winner)
+	})
+}
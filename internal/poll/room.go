@@ -0,0 +1,296 @@
+package poll
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/response"
+)
+
+const (
+	// roomSendBuffer is how many outgoing messages a room client may have
+	// queued before it is disconnected as a slow consumer.
+	roomSendBuffer = 32
+	// roomWriteWait bounds a single message write to a room client.
+	roomWriteWait = 10 * time.Second
+	// roomMaxMessageBytes caps the size of a client message.
+	roomMaxMessageBytes = 4 << 10
+)
+
+// roomHub tracks the WebSocket clients connected to each poll room. Presence
+// counts are per API instance; results changes reach every instance through
+// the service's Broker.
+type roomHub struct {
+	svc *Service
+
+	mu    sync.Mutex
+	rooms map[int64]*room
+}
+
+// room is the set of clients connected to one poll. A single watcher per
+// room tallies results on change and broadcasts them to every client.
+type room struct {
+	clients     map[*roomClient]struct{}
+	unsubscribe func()
+}
+
+func newRoomHub(svc *Service) *roomHub {
+	return &roomHub{svc: svc, rooms: make(map[int64]*room)}
+}
+
+// serve runs a client connection until it disconnects or is dropped.
+func (h *roomHub) serve(ctx context.Context, conn *websocket.Conn, pollID, userID int64) {
+	conn.MaxPayloadBytes = roomMaxMessageBytes
+	// The hijacked connection keeps the server's deadlines unless cleared
+	_ = conn.SetDeadline(time.Time{})
+
+	cl := newRoomClient(conn)
+	go cl.writeLoop(h.svc.Heartbeat)
+	defer cl.close()
+
+	h.join(ctx, pollID, cl)
+	defer h.leave(pollID, cl)
+
+	if results, err := h.svc.currentResults(ctx, pollID); err == nil {
+		cl.enqueue(roomMessage(RoomMsgResults, "", results))
+	}
+
+	for {
+		var msg RoomMessage
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			return
+		}
+		h.handle(ctx, pollID, userID, cl, msg)
+	}
+}
+
+// handle answers one client message.
+func (h *roomHub) handle(ctx context.Context, pollID, userID int64, cl *roomClient, msg RoomMessage) {
+	if msg.V != RoomProtocolVersion {
+		cl.enqueue(roomError(msg.ID, errs.BaseErr("unsupported protocol version")))
+		return
+	}
+
+	switch msg.Type {
+	case RoomMsgVote, RoomMsgChangeVote:
+		var req VotePollRequest
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			cl.enqueue(roomError(msg.ID, errs.BadRequest(err)))
+			return
+		}
+		selections, err := req.Selections()
+		if err != nil {
+			cl.enqueue(roomError(msg.ID, err))
+			return
+		}
+
+		var resp *VotePollResponse
+		if msg.Type == RoomMsgVote {
+			resp, err = h.svc.castVote(ctx, pollID, userID, selections)
+		} else {
+			resp, err = h.svc.replaceVote(ctx, pollID, userID, selections)
+		}
+		if err != nil {
+			cl.enqueue(roomError(msg.ID, err))
+			return
+		}
+		cl.enqueue(roomMessage(RoomMsgVoteAck, msg.ID, resp))
+
+	case RoomMsgRetractVote:
+		resp, err := h.svc.withdrawVote(ctx, pollID, userID)
+		if err != nil {
+			cl.enqueue(roomError(msg.ID, err))
+			return
+		}
+		cl.enqueue(roomMessage(RoomMsgVoteAck, msg.ID, resp))
+
+	case RoomMsgGetResults:
+		results, err := h.svc.currentResults(ctx, pollID)
+		if err != nil {
+			cl.enqueue(roomError(msg.ID, err))
+			return
+		}
+		cl.enqueue(roomMessage(RoomMsgResults, msg.ID, results))
+
+	case RoomMsgPing:
+		cl.enqueue(roomMessage(RoomMsgPong, msg.ID, nil))
+
+	default:
+		cl.enqueue(roomError(msg.ID, errs.BaseErr("unknown message type")))
+	}
+}
+
+// join adds a client to a poll room, starting the room's results watcher
+// for its first client, and announces the new presence count.
+func (h *roomHub) join(ctx context.Context, pollID int64, cl *roomClient) {
+	h.mu.Lock()
+	r, ok := h.rooms[pollID]
+	if !ok {
+		updates, unsubscribe := h.svc.Events.Subscribe(pollID)
+		r = &room{clients: make(map[*roomClient]struct{}), unsubscribe: unsubscribe}
+		h.rooms[pollID] = r
+		go h.watch(context.WithoutCancel(ctx), pollID, r, updates)
+	}
+	r.clients[cl] = struct{}{}
+	presence := RoomPresence{PollID: pollID, Online: len(r.clients)}
+	h.mu.Unlock()
+
+	cl.enqueue(roomMessage(RoomMsgWelcome, "", presence))
+	h.broadcast(pollID, roomMessage(RoomMsgPresence, "", presence))
+}
+
+// leave removes a client from a poll room, stopping the room's watcher once
+// it is empty, and announces the new presence count.
+func (h *roomHub) leave(pollID int64, cl *roomClient) {
+	h.mu.Lock()
+	r, ok := h.rooms[pollID]
+	if !ok {
+		h.mu.Unlock()
+		return
+	}
+	delete(r.clients, cl)
+	presence := RoomPresence{PollID: pollID, Online: len(r.clients)}
+	if len(r.clients) == 0 {
+		delete(h.rooms, pollID)
+		r.unsubscribe()
+	}
+	h.mu.Unlock()
+
+	h.broadcast(pollID, roomMessage(RoomMsgPresence, "", presence))
+}
+
+// watch broadcasts fresh results to a room on every change signal. When the
+// broker shuts down, every client of the room is disconnected.
+func (h *roomHub) watch(ctx context.Context, pollID int64, r *room, updates <-chan struct{}) {
+	for range updates {
+		results, err := h.svc.currentResults(ctx, pollID)
+		if err != nil {
+			continue
+		}
+		h.broadcast(pollID, roomMessage(RoomMsgResults, "", results))
+	}
+
+	h.mu.Lock()
+	if h.rooms[pollID] == r {
+		delete(h.rooms, pollID)
+	}
+	clients := make([]*roomClient, 0, len(r.clients))
+	for cl := range r.clients {
+		clients = append(clients, cl)
+	}
+	h.mu.Unlock()
+
+	for _, cl := range clients {
+		cl.close()
+	}
+}
+
+// broadcast queues a message for every client of a poll room.
+func (h *roomHub) broadcast(pollID int64, msg RoomMessage) {
+	h.mu.Lock()
+	var clients []*roomClient
+	if r, ok := h.rooms[pollID]; ok {
+		clients = make([]*roomClient, 0, len(r.clients))
+		for cl := range r.clients {
+			clients = append(clients, cl)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, cl := range clients {
+		cl.enqueue(msg)
+	}
+}
+
+// online returns the number of clients connected to a poll room.
+func (h *roomHub) online(pollID int64) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if r, ok := h.rooms[pollID]; ok {
+		return len(r.clients)
+	}
+	return 0
+}
+
+// roomClient is one WebSocket connection to a poll room. Messages are
+// written by a dedicated goroutine from a bounded queue so a slow reader
+// never blocks the room.
+type roomClient struct {
+	conn *websocket.Conn
+	send chan RoomMessage
+	done chan struct{}
+	once sync.Once
+}
+
+func newRoomClient(conn *websocket.Conn) *roomClient {
+	return &roomClient{
+		conn: conn,
+		send: make(chan RoomMessage, roomSendBuffer),
+		done: make(chan struct{}),
+	}
+}
+
+// enqueue queues a message without blocking. A client whose queue is full
+// is too slow to keep up and is disconnected; it can reconnect and resync
+// from the results snapshot sent on join.
+func (cl *roomClient) enqueue(msg RoomMessage) {
+	select {
+	case <-cl.done:
+	case cl.send <- msg:
+	default:
+		logging.Warnf("Dropping slow poll room client")
+		cl.close()
+	}
+}
+
+func (cl *roomClient) writeLoop(heartbeat time.Duration) {
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		var msg RoomMessage
+		select {
+		case <-cl.done:
+			return
+		case msg = <-cl.send:
+		case <-ticker.C:
+			// Keeps idle proxies from dropping the connection
+			msg = roomMessage(RoomMsgPing, "", nil)
+		}
+
+		_ = cl.conn.SetWriteDeadline(time.Now().Add(roomWriteWait))
+		if err := websocket.JSON.Send(cl.conn, msg); err != nil {
+			cl.close()
+			return
+		}
+	}
+}
+
+func (cl *roomClient) close() {
+	cl.once.Do(func() {
+		close(cl.done)
+		cl.conn.Close()
+	})
+}
+
+// roomMessage builds a server message with the given payload.
+func roomMessage(msgType, id string, data interface{}) RoomMessage {
+	msg := RoomMessage{V: RoomProtocolVersion, Type: msgType, ID: id}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err == nil {
+			msg.Data = raw
+		}
+	}
+	return msg
+}
+
+// roomError builds an error message carrying the same body as an HTTP error response.
+func roomError(id string, err error) RoomMessage {
+	return roomMessage(RoomMsgError, id, response.ErrorBuilder(err))
+}
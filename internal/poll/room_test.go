@@ -0,0 +1,184 @@
+package poll
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+// startRoomServer serves the poll routes over HTTP. The test auth middleware
+// treats the bearer token as the user ID.
+func startRoomServer(t *testing.T, service *Service) *httptest.Server {
+	e := echo.New()
+	auth := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token := strings.TrimPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			userID, err := strconv.ParseInt(token, 10, 64)
+			if err != nil {
+				return echo.ErrUnauthorized
+			}
+			addUserToken(c, userID)
+			return next(c)
+		}
+	}
+	RegisterRoutes(e.Group("/api/v1/poll"), service, auth, auth)
+
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func dialRoom(t *testing.T, srv *httptest.Server, pollID int64, token string) *websocket.Conn {
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/v1/poll/" + strconv.FormatInt(pollID, 10) + "/ws?token=" + token
+	conn, err := websocket.Dial(url, "", "http://localhost/")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readRoomMessage reads messages until one of the given type arrives.
+func readRoomMessage(t *testing.T, conn *websocket.Conn, msgType string) RoomMessage {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	for {
+		var msg RoomMessage
+		require.NoError(t, websocket.JSON.Receive(conn, &msg))
+		assert.Equal(t, RoomProtocolVersion, msg.V)
+		if msg.Type == msgType {
+			return msg
+		}
+	}
+}
+
+func TestService_PollRoom(t *testing.T) {
+	poll := &Poll{
+		ID:       1,
+		Question: "Live?",
+		Options:  []Option{{ID: 1, PollID: 1, Text: "Yes"}, {ID: 2, PollID: 1, Text: "No"}},
+		Type:     TypeSingle,
+		Status:   StatusOpen,
+	}
+
+	t.Run("Votes and broadcasts results and presence", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		mockRepo.On("GetByID", mock.Anything, int64(1)).Return(poll, nil)
+		mockRepo.On("GetResults", mock.Anything, int64(1)).Return([]Option{{ID: 1, Votes: 1}, {ID: 2}}, nil)
+		mockRepo.On("IsUserActive", mock.Anything, int64(7)).Return(true, nil)
+		mockRepo.On("HasUserVoted", mock.Anything, int64(1), Voter{UserID: 7}).Return(false, nil)
+		mockRepo.On("Vote", mock.Anything, int64(1), Voter{UserID: 7}, []int64{1}, "").Return(nil)
+
+		service := NewService(mockRepo)
+		srv := startRoomServer(t, service)
+
+		alice := dialRoom(t, srv, 1, "7")
+		welcome := readRoomMessage(t, alice, RoomMsgWelcome)
+		assert.JSONEq(t, `{"poll_id":1,"online":1}`, string(welcome.Data))
+		readRoomMessage(t, alice, RoomMsgResults)
+
+		bob := dialRoom(t, srv, 1, "8")
+		readRoomMessage(t, bob, RoomMsgWelcome)
+		presence := readRoomMessage(t, alice, RoomMsgPresence)
+		for string(presence.Data) != `{"poll_id":1,"online":2}` {
+			presence = readRoomMessage(t, alice, RoomMsgPresence)
+		}
+
+		// Alice votes; both clients receive fresh results
+		require.NoError(t, websocket.JSON.Send(alice, RoomMessage{V: 1, Type: RoomMsgVote, ID: "c1", Data: json.RawMessage(`{"option_id":1}`)}))
+		ack := readRoomMessage(t, alice, RoomMsgVoteAck)
+		assert.Equal(t, "c1", ack.ID)
+		assert.Contains(t, string(ack.Data), `"message":"Vote recorded successfully"`)
+
+		results := readRoomMessage(t, bob, RoomMsgResults)
+		var tally PollResultsResponse
+		require.NoError(t, json.Unmarshal(results.Data, &tally))
+		assert.Equal(t, int64(1), tally.TotalVotes)
+
+		// Bob leaves; Alice sees the presence count drop
+		bob.Close()
+		presence = readRoomMessage(t, alice, RoomMsgPresence)
+		for string(presence.Data) != `{"poll_id":1,"online":1}` {
+			presence = readRoomMessage(t, alice, RoomMsgPresence)
+		}
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Rejects unknown protocol versions", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		mockRepo.On("GetByID", mock.Anything, int64(1)).Return(poll, nil)
+		mockRepo.On("GetResults", mock.Anything, int64(1)).Return([]Option{}, nil)
+
+		srv := startRoomServer(t, NewService(mockRepo))
+		conn := dialRoom(t, srv, 1, "7")
+
+		require.NoError(t, websocket.JSON.Send(conn, RoomMessage{V: 2, Type: RoomMsgPing, ID: "c1"}))
+		msg := readRoomMessage(t, conn, RoomMsgError)
+		assert.Equal(t, "c1", msg.ID)
+		assert.Contains(t, string(msg.Data), "unsupported protocol version")
+
+		require.NoError(t, websocket.JSON.Send(conn, RoomMessage{V: 1, Type: RoomMsgPing, ID: "c2"}))
+		assert.Equal(t, "c2", readRoomMessage(t, conn, RoomMsgPong).ID)
+	})
+
+	t.Run("Closes rooms when the broker shuts down", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		mockRepo.On("GetByID", mock.Anything, int64(1)).Return(poll, nil)
+		mockRepo.On("GetResults", mock.Anything, int64(1)).Return([]Option{}, nil)
+
+		service := NewService(mockRepo)
+		srv := startRoomServer(t, service)
+		conn := dialRoom(t, srv, 1, "7")
+		readRoomMessage(t, conn, RoomMsgWelcome)
+
+		require.NoError(t, service.Events.Close())
+
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		for {
+			var msg RoomMessage
+			if err := websocket.JSON.Receive(conn, &msg); err != nil {
+				break
+			}
+		}
+		assert.Eventually(t, func() bool { return service.rooms.online(1) == 0 }, time.Second, time.Millisecond)
+	})
+}
+
+func TestRoomClient_SlowConsumer(t *testing.T) {
+	// A peer that never reads
+	srv := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		var buf []byte
+		_ = websocket.Message.Receive(conn, &buf)
+	}))
+	defer srv.Close()
+
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), "", "http://localhost/")
+	require.NoError(t, err)
+
+	// No writer is running, so the queue fills up
+	cl := newRoomClient(conn)
+	for i := 0; i < roomSendBuffer; i++ {
+		cl.enqueue(roomMessage(RoomMsgPong, "", nil))
+	}
+	select {
+	case <-cl.done:
+		t.Fatal("client closed before its queue was full")
+	default:
+	}
+
+	cl.enqueue(roomMessage(RoomMsgPong, "", nil))
+
+	select {
+	case <-cl.done:
+	default:
+		t.Fatal("slow client was not disconnected")
+	}
+}
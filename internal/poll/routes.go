@@ -2,7 +2,9 @@ package poll
 
 import (
 	"github.com/labstack/echo/v4"
+	"github.com/phsaurav/echo_prod_blueprint/config"
 	"github.com/phsaurav/echo_prod_blueprint/internal/database"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/storage"
 )
 
 type PollService interface {
@@ -10,17 +12,137 @@ type PollService interface {
 	GetPoll(c echo.Context) error
 	VotePoll(c echo.Context) error
 	GetResults(c echo.Context) error
+	StreamResults(c echo.Context) error
+	PollRoom(c echo.Context) error
+	ExportResults(c echo.Context) error
+	ListPolls(c echo.Context) error
+	PublishPoll(c echo.Context) error
+	ClosePoll(c echo.Context) error
+	ChangeVote(c echo.Context) error
+	RetractVote(c echo.Context) error
+	VerifyReceipt(c echo.Context) error
+	UpdatePoll(c echo.Context) error
+	DeletePoll(c echo.Context) error
+	SetFollowUps(c echo.Context) error
+	GetFunnel(c echo.Context) error
+	GetLeaderboard(c echo.Context) error
+	InviteUsers(c echo.Context) error
+	ListInvites(c echo.Context) error
+	RemoveInvite(c echo.Context) error
+	CreateInviteLink(c echo.Context) error
+	ListTags(c echo.Context) error
+	ListPollsByTag(c echo.Context) error
+	GetTrending(c echo.Context) error
+	ClonePoll(c echo.Context) error
+	CreateTemplate(c echo.Context) error
+	ListTemplates(c echo.Context) error
+	GetTemplate(c echo.Context) error
+	DeleteTemplate(c echo.Context) error
+	CreatePollFromTemplate(c echo.Context) error
+	UploadCoverImage(c echo.Context) error
+	DeleteCoverImage(c echo.Context) error
+	UploadOptionImage(c echo.Context) error
+	DeleteOptionImage(c echo.Context) error
+	GetImage(c echo.Context) error
+	ResolvePoll(next echo.HandlerFunc) echo.HandlerFunc
 }
 
-func Register(g *echo.Group, db database.Service, authMiddleware echo.MiddlewareFunc) {
+// Register wires the poll feature. events fans out live results; when nil,
+// an in-process broker is used. trending serves the trending ranking; when
+// nil, the ranking stays empty. files stores uploaded images; when nil,
+// image uploads are refused. optionalAuthMiddleware authenticates callers
+// of public routes that send a token, so owners and invitees are recognised.
+// The service is returned so features mounted under a poll can reuse its
+// ResolvePoll access check.
+func Register(g *echo.Group, db database.Service, cfg config.Config, events Broker, trending *TrendingRanker, files storage.Storage, authMiddleware, optionalAuthMiddleware echo.MiddlewareFunc) *Service {
 	repo := NewRepo(db)
 	service := NewService(repo)
-	RegisterRoutes(g, service, authMiddleware)
+	service.BallotSecret = []byte(cfg.Ballot.Secret)
+	service.InviteSecret = []byte(cfg.Invite.Secret)
+	if cfg.Invite.TTL > 0 {
+		service.InviteTTL = cfg.Invite.TTL
+	}
+	if cfg.Tags.MaxPerPoll > 0 {
+		service.MaxTags = cfg.Tags.MaxPerPoll
+	}
+	if events != nil {
+		service.Events = events
+	}
+	if trending != nil {
+		service.Trending = trending
+	}
+	service.Storage = files
+	if cfg.Uploads.MaxImageSize > 0 {
+		service.MaxImageSize = int64(cfg.Uploads.MaxImageSize)
+	}
+	if cfg.Uploads.ThumbnailSize > 0 {
+		service.ThumbnailSize = cfg.Uploads.ThumbnailSize
+	}
+	RegisterRoutes(g, service, authMiddleware, optionalAuthMiddleware)
+	return service
 }
 
-func RegisterRoutes(g *echo.Group, service PollService, authMiddleware echo.MiddlewareFunc) {
+// RegisterRoutes mounts the poll routes. Every route on a single poll goes
+// through service.ResolvePoll, which accepts the slug of an unlisted poll in
+// place of its ID and hides polls the caller may not see.
+func RegisterRoutes(g *echo.Group, service PollService, authMiddleware, optionalAuthMiddleware echo.MiddlewareFunc) {
+	resolve := service.ResolvePoll
 	g.POST("", service.CreatePoll, authMiddleware)
-	g.GET("/:id", service.GetPoll)
-	g.POST("/:id/vote", service.VotePoll, authMiddleware)
-	g.GET("/:id/results", service.GetResults)
+	g.GET("", service.ListPolls)
+	g.GET("/trending", service.GetTrending)
+	g.GET("/tags", service.ListTags)
+	g.GET("/tags/:tag", service.ListPollsByTag)
+	g.POST("/templates", service.CreateTemplate, authMiddleware)
+	g.GET("/templates", service.ListTemplates, authMiddleware)
+	g.GET("/templates/:template_id", service.GetTemplate, authMiddleware)
+	g.DELETE("/templates/:template_id", service.DeleteTemplate, authMiddleware)
+	g.POST("/templates/:template_id/polls", service.CreatePollFromTemplate, authMiddleware)
+	g.GET("/images/:key", service.GetImage)
+	g.GET("/:id", service.GetPoll, optionalAuthMiddleware, resolve)
+	g.PATCH("/:id", service.UpdatePoll, authMiddleware, resolve)
+	g.DELETE("/:id", service.DeletePoll, authMiddleware, resolve)
+	g.POST("/:id/vote", service.VotePoll, authMiddleware, resolve)
+	g.PUT("/:id/vote", service.ChangeVote, authMiddleware, resolve)
+	g.DELETE("/:id/vote", service.RetractVote, authMiddleware, resolve)
+	g.POST("/:id/receipt", service.VerifyReceipt, optionalAuthMiddleware, resolve)
+	g.GET("/:id/results", service.GetResults, optionalAuthMiddleware, resolve)
+	g.GET("/:id/results/stream", service.StreamResults, optionalAuthMiddleware, resolve)
+	g.GET("/:id/leaderboard", service.GetLeaderboard, optionalAuthMiddleware, resolve)
+	g.GET("/:id/export", service.ExportResults, authMiddleware, resolve)
+	g.GET("/:id/ws", service.PollRoom, tokenFromQuery, authMiddleware, resolve)
+	g.POST("/:id/publish", service.PublishPoll, authMiddleware, resolve)
+	g.POST("/:id/close", service.ClosePoll, authMiddleware, resolve)
+	g.POST("/:id/clone", service.ClonePoll, authMiddleware, resolve)
+	g.PUT("/:id/cover", service.UploadCoverImage, authMiddleware, resolve)
+	g.DELETE("/:id/cover", service.DeleteCoverImage, authMiddleware, resolve)
+	g.PUT("/:id/options/:option_id/image", service.UploadOptionImage, authMiddleware, resolve)
+	g.DELETE("/:id/options/:option_id/image", service.DeleteOptionImage, authMiddleware, resolve)
+	g.PUT("/:id/follow-ups", service.SetFollowUps, authMiddleware, resolve)
+	g.GET("/:id/funnel", service.GetFunnel, authMiddleware, resolve)
+	g.POST("/:id/invites", service.InviteUsers, authMiddleware, resolve)
+	g.GET("/:id/invites", service.ListInvites, authMiddleware, resolve)
+	g.DELETE("/:id/invites/:user_id", service.RemoveInvite, authMiddleware, resolve)
+	g.POST("/:id/invite-link", service.CreateInviteLink, authMiddleware, resolve)
+}
+
+// tokenFromQuery lets WebSocket clients, which cannot set headers from a
+// browser, pass their JWT as the token query parameter. The token is moved
+// to the Authorization header and stripped from the request URL, so the
+// access log, which records the URI once the connection closes, never sees
+// it. Proxies in front of the server log the URL before this runs and must
+// be configured not to log the query string of this route.
+func tokenFromQuery(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		query := req.URL.Query()
+		if token := query.Get("token"); token != "" {
+			if req.Header.Get(echo.HeaderAuthorization) == "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+			}
+			query.Del("token")
+			req.URL.RawQuery = query.Encode()
+			req.RequestURI = req.URL.RequestURI()
+		}
+		return next(c)
+	}
 }
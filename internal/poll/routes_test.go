@@ -1,13 +1,16 @@
 package poll
 
 import (
+	"bytes"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/phsaurav/echo_prod_blueprint/config"
 	"github.com/phsaurav/echo_prod_blueprint/testutils"
 
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -24,7 +27,7 @@ func TestRegisterRoutes(t *testing.T) {
 	authMiddleware := testutils.CreateAuthMiddleware()
 
 	// Register routes
-	RegisterRoutes(g, mockService, authMiddleware)
+	RegisterRoutes(g, mockService, authMiddleware, authMiddleware)
 
 	// Test POST /api/v1/poll
 	mockService.On("CreatePoll", mock.Anything).Return(nil)
@@ -32,24 +35,216 @@ func TestRegisterRoutes(t *testing.T) {
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 
+	// Test GET /api/v1/poll
+	mockService.On("ListPolls", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/poll", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test GET /api/v1/poll/trending
+	mockService.On("GetTrending", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/poll/trending", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test GET /api/v1/poll/tags
+	mockService.On("ListTags", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/poll/tags", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test GET /api/v1/poll/tags/:tag
+	mockService.On("ListPollsByTag", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/poll/tags/go", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test POST /api/v1/poll/templates
+	mockService.On("CreateTemplate", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/poll/templates", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test GET /api/v1/poll/templates
+	mockService.On("ListTemplates", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/poll/templates", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test GET /api/v1/poll/templates/:template_id
+	mockService.On("GetTemplate", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/poll/templates/1", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test DELETE /api/v1/poll/templates/:template_id
+	mockService.On("DeleteTemplate", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/poll/templates/1", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test POST /api/v1/poll/templates/:template_id/polls
+	mockService.On("CreatePollFromTemplate", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/poll/templates/1/polls", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test GET /api/v1/poll/images/:key
+	mockService.On("GetImage", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/poll/images/abc.png", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
 	// Test GET /api/v1/poll/:id
 	mockService.On("GetPoll", mock.Anything).Return(nil)
 	req = httptest.NewRequest(http.MethodGet, "/api/v1/poll/1", nil)
 	rec = httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 
+	// Test PATCH /api/v1/poll/:id
+	mockService.On("UpdatePoll", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPatch, "/api/v1/poll/1", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test DELETE /api/v1/poll/:id
+	mockService.On("DeletePoll", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/poll/1", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
 	// Test POST /api/v1/poll/:id/vote
 	mockService.On("VotePoll", mock.Anything).Return(nil)
 	req = httptest.NewRequest(http.MethodPost, "/api/v1/poll/1/vote", nil)
 	rec = httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 
+	// Test PUT /api/v1/poll/:id/vote
+	mockService.On("ChangeVote", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/poll/1/vote", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test DELETE /api/v1/poll/:id/vote
+	mockService.On("RetractVote", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/poll/1/vote", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test POST /api/v1/poll/:id/receipt
+	mockService.On("VerifyReceipt", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/poll/1/receipt", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
 	// Test GET /api/v1/poll/:id/results
 	mockService.On("GetResults", mock.Anything).Return(nil)
 	req = httptest.NewRequest(http.MethodGet, "/api/v1/poll/1/results", nil)
 	rec = httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 
+	// Test GET /api/v1/poll/:id/results/stream
+	mockService.On("StreamResults", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/poll/1/results/stream", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test GET /api/v1/poll/:id/leaderboard
+	mockService.On("GetLeaderboard", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/poll/1/leaderboard", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test GET /api/v1/poll/:id/ws
+	mockService.On("PollRoom", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/poll/1/ws", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test GET /api/v1/poll/:id/export
+	mockService.On("ExportResults", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/poll/1/export", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test POST /api/v1/poll/:id/publish
+	mockService.On("PublishPoll", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/poll/1/publish", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test POST /api/v1/poll/:id/close
+	mockService.On("ClosePoll", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/poll/1/close", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test POST /api/v1/poll/:id/clone
+	mockService.On("ClonePoll", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/poll/1/clone", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test PUT /api/v1/poll/:id/cover
+	mockService.On("UploadCoverImage", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/poll/1/cover", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test DELETE /api/v1/poll/:id/cover
+	mockService.On("DeleteCoverImage", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/poll/1/cover", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test PUT /api/v1/poll/:id/options/:option_id/image
+	mockService.On("UploadOptionImage", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/poll/1/options/2/image", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test DELETE /api/v1/poll/:id/options/:option_id/image
+	mockService.On("DeleteOptionImage", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/poll/1/options/2/image", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test PUT /api/v1/poll/:id/follow-ups
+	mockService.On("SetFollowUps", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/poll/1/follow-ups", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test GET /api/v1/poll/:id/funnel
+	mockService.On("GetFunnel", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/poll/1/funnel", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test POST /api/v1/poll/:id/invites
+	mockService.On("InviteUsers", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/poll/1/invites", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test GET /api/v1/poll/:id/invites
+	mockService.On("ListInvites", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/poll/1/invites", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test DELETE /api/v1/poll/:id/invites/:user_id
+	mockService.On("RemoveInvite", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/poll/1/invites/4", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test POST /api/v1/poll/:id/invite-link
+	mockService.On("CreateInviteLink", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/poll/1/invite-link", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
 	// Verify all expected methods were called
 	mockService.AssertExpectations(t)
 }
@@ -68,9 +263,48 @@ func TestRegister(t *testing.T) {
 	authMiddleware := testutils.CreateAuthMiddleware()
 
 	assert.NotPanics(t, func() {
-		Register(g, mockDB, authMiddleware)
+		Register(g, mockDB, config.Config{}, nil, nil, nil, authMiddleware, authMiddleware)
 	})
 
 	// Verify mock was called
 	mockDB.AssertExpectations(t)
 }
+
+// TestTokenFromQuery tests that the token query parameter becomes a bearer token
+func TestTokenFromQuery(t *testing.T) {
+	e := echo.New()
+	var got string
+	handler := tokenFromQuery(func(c echo.Context) error {
+		got = c.Request().Header.Get(echo.HeaderAuthorization)
+		return nil
+	})
+
+	// Token from the query
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/poll/1/ws?token=abc", nil)
+	assert.NoError(t, handler(e.NewContext(req, httptest.NewRecorder())))
+	assert.Equal(t, "Bearer abc", got)
+
+	// An existing header wins
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/poll/1/ws?token=abc", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer xyz")
+	assert.NoError(t, handler(e.NewContext(req, httptest.NewRecorder())))
+	assert.Equal(t, "Bearer xyz", got)
+}
+
+// TestTokenFromQueryNotLogged tests that the token is stripped from the logged URI
+func TestTokenFromQueryNotLogged(t *testing.T) {
+	var logs bytes.Buffer
+	e := echo.New()
+	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{Output: &logs}))
+	e.GET("/api/v1/poll/:id/ws", func(c echo.Context) error {
+		assert.Equal(t, "Bearer secret-token", c.Request().Header.Get(echo.HeaderAuthorization))
+		assert.Equal(t, "abc", c.QueryParam("invite"))
+		return c.NoContent(http.StatusOK)
+	}, tokenFromQuery)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/poll/1/ws?token=secret-token&invite=abc", nil)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.NotContains(t, logs.String(), "secret-token")
+	assert.Contains(t, logs.String(), "/api/v1/poll/1/ws?invite=abc")
+}
@@ -0,0 +1,81 @@
+package poll
+
+import "sort"
+
+// instantRunoff counts ranked ballots round by round. Each round every ballot
+// counts for its highest-ranked continuing option; an option with a strict
+// majority of the continuing ballots wins, otherwise the options with the
+// fewest votes are eliminated together. If every continuing option is tied,
+// the count stops without a winner and the tied options are reported.
+func instantRunoff(optionIDs []int64, ballots []Ballot) *RunoffResult {
+	continuing := make(map[int64]bool, len(optionIDs))
+	for _, id := range optionIDs {
+		continuing[id] = true
+	}
+
+	result := &RunoffResult{}
+	for round := 1; len(continuing) > 0; round++ {
+		tally := make(map[int64]int64, len(continuing))
+		var exhausted int64
+		for _, b := range ballots {
+			counted := false
+			for _, id := range b {
+				if continuing[id] {
+					tally[id]++
+					counted = true
+					break
+				}
+			}
+			if !counted {
+				exhausted++
+			}
+		}
+
+		rr := RunoffRound{Round: round, Exhausted: exhausted}
+		var leader int64
+		var most, fewest int64 = -1, -1
+		for _, id := range optionIDs {
+			if !continuing[id] {
+				continue
+			}
+			n := tally[id]
+			rr.Tallies = append(rr.Tallies, RoundTally{OptionID: id, Votes: n})
+			if n > most {
+				leader, most = id, n
+			}
+			if fewest < 0 || n < fewest {
+				fewest = n
+			}
+		}
+		sort.SliceStable(rr.Tallies, func(i, j int) bool {
+			return rr.Tallies[i].Votes > rr.Tallies[j].Votes
+		})
+
+		active := int64(len(ballots)) - exhausted
+		if most*2 > active || len(continuing) == 1 {
+			result.Rounds = append(result.Rounds, rr)
+			result.WinnerID = &leader
+			return result
+		}
+
+		var losers []int64
+		for _, t := range rr.Tallies {
+			if t.Votes == fewest {
+				losers = append(losers, t.OptionID)
+			}
+		}
+		if len(losers) == len(continuing) {
+			result.Rounds = append(result.Rounds, rr)
+			result.Tied = losers
+			return result
+		}
+
+		sort.Slice(losers, func(i, j int) bool { return losers[i] < losers[j] })
+		rr.Eliminated = losers
+		for _, id := range losers {
+			delete(continuing, id)
+		}
+		result.Rounds = append(result.Rounds, rr)
+	}
+	return result
+}
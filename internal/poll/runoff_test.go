@@ -0,0 +1,49 @@
+package poll
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstantRunoff(t *testing.T) {
+	t.Run("Majority in first round", func(t *testing.T) {
+		result := instantRunoff([]int64{1, 2, 3}, []Ballot{{1}, {1, 2}, {2}, {1, 3}})
+
+		require.NotNil(t, result.WinnerID)
+		assert.Equal(t, int64(1), *result.WinnerID)
+		assert.Len(t, result.Rounds, 1)
+		assert.Equal(t, RoundTally{OptionID: 1, Votes: 3}, result.Rounds[0].Tallies[0])
+	})
+
+	t.Run("Eliminated votes transfer to next preference", func(t *testing.T) {
+		// Round 1: A=2, B=2, C=1 -> C eliminated; its ballot moves to B
+		result := instantRunoff([]int64{1, 2, 3}, []Ballot{{1}, {1}, {2}, {2}, {3, 2}})
+
+		require.NotNil(t, result.WinnerID)
+		assert.Equal(t, int64(2), *result.WinnerID)
+		require.Len(t, result.Rounds, 2)
+		assert.Equal(t, []int64{3}, result.Rounds[0].Eliminated)
+		assert.Equal(t, []RoundTally{{OptionID: 2, Votes: 3}, {OptionID: 1, Votes: 2}}, result.Rounds[1].Tallies)
+	})
+
+	t.Run("Exhausted ballots leave the count", func(t *testing.T) {
+		// C is eliminated and its only ballot ranks nothing else
+		result := instantRunoff([]int64{1, 2, 3}, []Ballot{{1}, {1}, {2}, {3}})
+
+		require.NotNil(t, result.WinnerID)
+		assert.Equal(t, int64(1), *result.WinnerID)
+		require.Len(t, result.Rounds, 2)
+		assert.Equal(t, []int64{2, 3}, result.Rounds[0].Eliminated)
+		assert.Equal(t, int64(2), result.Rounds[1].Exhausted)
+	})
+
+	t.Run("Unbreakable tie", func(t *testing.T) {
+		result := instantRunoff([]int64{1, 2}, []Ballot{{1}, {2}})
+
+		assert.Nil(t, result.WinnerID)
+		assert.ElementsMatch(t, []int64{1, 2}, result.Tied)
+		assert.Len(t, result.Rounds, 1)
+	})
+}
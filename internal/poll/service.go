@@ -2,31 +2,115 @@ package poll
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"strconv"
 	"time"
 
-	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+
+	"github.com/phsaurav/echo_prod_blueprint/config"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/auth"
 	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/export"
 	"github.com/phsaurav/echo_prod_blueprint/pkg/response"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/storage"
 )
 
 type Repository interface {
 	Create(ctx context.Context, p *Poll) error
 	GetByID(ctx context.Context, id int64) (*Poll, error)
-	Vote(ctx context.Context, pollID, optionID, userID int64) error
+	GetBySlug(ctx context.Context, slug string) (*Poll, error)
+	Vote(ctx context.Context, pollID int64, voter Voter, optionIDs []int64, receiptHash string) error
+	GetBallots(ctx context.Context, pollID int64) ([]Ballot, error)
+	ChangeVote(ctx context.Context, pollID int64, voter Voter, optionIDs []int64, receiptHash string) error
+	RetractVote(ctx context.Context, pollID int64, voter Voter) error
+	GetReceipt(ctx context.Context, pollID int64, receiptHash string) (*ReceiptResponse, error)
 	GetResults(ctx context.Context, pollID int64) ([]Option, error)
-	HasUserVoted(ctx context.Context, pollID int64, userID int64) (bool, error)
+	GetVoteTimeline(ctx context.Context, pollID int64, period string, firstPreferences bool) ([]TimeBucket, error)
+	GetCohortBreakdown(ctx context.Context, pollID int64, firstPreferences bool) ([]CohortBucket, error)
+	StreamVotes(ctx context.Context, pollID int64, fn func(Vote) error) error
+	HasUserVoted(ctx context.Context, pollID int64, voter Voter) (bool, error)
+	List(ctx context.Context, f ListPollsFilter) ([]Poll, error)
+	Count(ctx context.Context, f ListPollsFilter) (int, error)
+	UpdateStatus(ctx context.Context, id int64, status string) error
+	Update(ctx context.Context, p *Poll, editsBallot, replaceOptions, replaceTags bool) error
+	SoftDelete(ctx context.Context, id int64) error
+	ListTags(ctx context.Context, prefix string, limit int) ([]Tag, error)
+	GetTrending(ctx context.Context, window time.Duration, gravity float64, limit int) ([]TrendingPoll, error)
+	SetFollowUps(ctx context.Context, pollID int64, rules []FollowUpRule) error
+	GetFollowUps(ctx context.Context, pollID int64) ([]FollowUpRule, error)
+	CountVoters(ctx context.Context, pollID int64) (int64, error)
+	GetFunnelStep(ctx context.Context, pollID, optionID, nextPollID int64) (entered, continued int64, err error)
+	GetQuizBallots(ctx context.Context, pollID int64) ([]QuizBallot, error)
+	IsUserActive(ctx context.Context, userID int64) (bool, error)
+	IsInvited(ctx context.Context, pollID, userID int64) (bool, error)
+	AddInvites(ctx context.Context, pollID int64, userIDs []int64) error
+	RemoveInvite(ctx context.Context, pollID, userID int64) error
+	ListInvites(ctx context.Context, pollID int64) ([]int64, error)
+	CreateTemplate(ctx context.Context, t *PollTemplate) error
+	GetTemplate(ctx context.Context, id int64) (*PollTemplate, error)
+	ListTemplates(ctx context.Context, userID int64) ([]PollTemplate, error)
+	DeleteTemplate(ctx context.Context, id int64) error
+	SetCoverImage(ctx context.Context, pollID int64, img *Image) error
+	SetOptionImage(ctx context.Context, pollID, optionID int64, img *Image) error
 }
 
+// maxPageSize caps the page_size accepted by list endpoints.
+const maxPageSize = 100
+
+// defaultLeaderboardSize is the number of leaderboard entries returned when
+// no limit is given.
+const defaultLeaderboardSize = 10
+
+// defaultHeartbeat is how often an idle results stream sends a keep-alive comment.
+const defaultHeartbeat = 15 * time.Second
+
 // Service implements the consumer-side PollService interface.
 type Service struct {
-	Repo Repository
+	Repo   Repository
+	Events Broker
+	// Heartbeat is the keep-alive interval of results streams and poll rooms.
+	Heartbeat time.Duration
+	// BallotSecret keys the voter hashes of anonymous polls.
+	BallotSecret []byte
+	// InviteSecret signs invite links to invite-only polls, which stay
+	// valid for InviteTTL.
+	InviteSecret []byte
+	InviteTTL    time.Duration
+	// MaxTags is the number of tags a poll can carry.
+	MaxTags int
+	// Trending serves the cached trending ranking.
+	Trending *TrendingRanker
+	// Storage keeps uploaded images; uploads are refused when it is nil.
+	// Uploads larger than MaxImageSize bytes are rejected, and thumbnails
+	// fit in ThumbnailSize pixels.
+	Storage       storage.Storage
+	MaxImageSize  int64
+	ThumbnailSize int
+
+	rooms *roomHub
 }
 
-// NewService creates a new poll service instance.
+// NewService creates a new poll service instance with an in-process event broker.
 func NewService(repo Repository) *Service {
-	return &Service{Repo: repo}
+	s := &Service{
+		Repo:      repo,
+		Events:    NewLocalBroker(),
+		Heartbeat: defaultHeartbeat,
+		InviteTTL: defaultInviteTTL,
+		MaxTags:   defaultMaxTags,
+		Trending:  NewTrendingRanker(repo, config.TrendingConfig{}),
+
+		MaxImageSize:  defaultMaxImageSize,
+		ThumbnailSize: defaultThumbnailSize,
+	}
+	s.rooms = newRoomHub(s)
+	return s
 }
 
 // CreatePoll creates a new poll with options
@@ -48,24 +132,95 @@ func (s *Service) CreatePoll(c echo.Context) error {
 		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
 	}
 
+	poll, err := s.newPoll(req, auth.UserID(c))
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	if err := s.Repo.Create(c.Request().Context(), poll); err != nil {
+		return response.ErrorBuilder(errs.InternalServerError(err)).Send(c)
+	}
+
+	return response.SuccessBuilder(CreatePollResponse{Poll: *poll}).Send(c)
+}
+
+// newPoll validates a create request and builds the poll it describes,
+// owned by userID. Omitted settings get their defaults.
+func (s *Service) newPoll(req CreatePollRequest, userID int64) (*Poll, error) {
 	if req.Question == "" {
-		return response.ErrorBuilder(errs.BaseErr("question is required")).Send(c)
+		return nil, errs.BaseErr("question is required")
 	}
 	if len(req.Options) < 2 {
-		return response.ErrorBuilder(errs.BaseErr("at least two options are required")).Send(c)
+		return nil, errs.BaseErr("at least two options are required")
+	}
+
+	switch req.PollType {
+	case "":
+		req.PollType = TypeSingle
+		fallthrough
+	case TypeSingle, TypeRanked:
+		if req.MinSelections != 0 || req.MaxSelections != 0 {
+			return nil, errs.BaseErr("min_selections and max_selections only apply to multi polls")
+		}
+	case TypeMulti:
+		if req.MinSelections == 0 {
+			req.MinSelections = 1
+		}
+		if req.MaxSelections == 0 {
+			req.MaxSelections = len(req.Options)
+		}
+		if req.MinSelections < 1 || req.MinSelections > req.MaxSelections || req.MaxSelections > len(req.Options) {
+			return nil, errs.BaseErr("selections must satisfy 1 <= min_selections <= max_selections <= number of options")
+		}
+	default:
+		return nil, errs.BaseErr("poll_type must be one of single, multi, ranked")
 	}
 
-	// Get authenticated user ID
-	user := c.Get("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := int64(claims["user_id"].(float64))
+	switch req.Status {
+	case "":
+		req.Status = StatusOpen
+	case StatusDraft, StatusOpen:
+	default:
+		return nil, errs.BaseErr("status must be draft or open")
+	}
+	if req.Visibility == "" {
+		req.Visibility = VisibilityPublic
+	}
+	if req.ResultsVisibility == "" {
+		req.ResultsVisibility = ResultsAlways
+	}
+	if err := checkResultsVisibility(req.ResultsVisibility); err != nil {
+		return nil, err
+	}
+	if req.OpensAt != nil && req.ClosesAt != nil && !req.ClosesAt.After(*req.OpensAt) {
+		return nil, errs.BaseErr("closes_at must be after opens_at")
+	}
+	if req.ClosesAt != nil && !req.ClosesAt.After(time.Now()) {
+		return nil, errs.BaseErr("closes_at must be in the future")
+	}
+	tags, err := normalizeTags(req.Tags, s.MaxTags)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create poll and options
 	poll := &Poll{
-		Question:  req.Question,
-		UserID:    userID,
-		CreatedAt: time.Now(),
-		Options:   make([]Option, len(req.Options)),
+		Question:          req.Question,
+		UserID:            userID,
+		CreatedAt:         time.Now(),
+		Type:              req.PollType,
+		MinSelections:     req.MinSelections,
+		MaxSelections:     req.MaxSelections,
+		AllowVoteChange:   req.AllowVoteChange,
+		Anonymous:         req.Anonymous,
+		ExportVotes:       req.ExportVotes,
+		Quiz:              req.Quiz,
+		Status:            req.Status,
+		ResultsVisibility: req.ResultsVisibility,
+		OpensAt:           utcTime(req.OpensAt),
+		ClosesAt:          utcTime(req.ClosesAt),
+		Tags:              tags,
+		Options:           make([]Option, len(req.Options)),
 	}
 
 	// Populate options
@@ -74,21 +229,23 @@ func (s *Service) CreatePoll(c echo.Context) error {
 			Text: text,
 		}
 	}
-
-	if err := s.Repo.Create(c.Request().Context(), poll); err != nil {
-		return response.ErrorBuilder(errs.InternalServerError(err)).Send(c)
+	if err := markCorrectOptions(poll, req.CorrectOptions); err != nil {
+		return nil, err
 	}
-
-	return response.SuccessBuilder(CreatePollResponse{Poll: *poll}).Send(c)
+	if err := setVisibility(poll, req.Visibility); err != nil {
+		return nil, err
+	}
+	return poll, nil
 }
 
 // GetPoll retrieves poll details by ID
 // @Summary Get poll information
-// @Description Get poll details including available options
+// @Description Get poll details including available options. Unlisted polls are only found by their slug, and invite-only polls only by invited users or with an invite link. The correct options of a quiz are only shown to its owner until it closes.
 // @Tags polls
 // @Accept json
 // @Produce json
-// @Param id path int true "Poll ID"
+// @Param id path string true "Poll ID, or the slug of an unlisted poll"
+// @Param invite query string false "Invite link token of an invite-only poll"
 // @Success 200 {object} Poll "Poll details with options"
 // @Failure 400 {object} response.FailedResponse "Bad request - invalid ID format"
 // @Failure 404 {object} response.FailedResponse "Not found - poll doesn't exist"
@@ -105,76 +262,303 @@ func (s *Service) GetPoll(c echo.Context) error {
 	if err != nil {
 		return response.ErrorBuilder(err).Send(c)
 	}
+	if answersHidden(c, poll, time.Now()) {
+		hideAnswers(poll)
+	}
 
 	return response.SuccessBuilder(poll).Send(c)
 }
 
 // VotePoll records a user's vote for a specific poll option
 // @Summary Vote on a poll
-// @Description Submit a vote for a specific option in a poll
+// @Description Submit a ballot: option_id for single polls, the chosen option_ids for multi polls, or option_ids in order of preference for ranked polls. Ballots on anonymous polls return a receipt that can later confirm the ballot was counted.
 // @Tags polls
 // @Accept json
 // @Produce json
-// @Param id path int true "Poll ID"
-// @Param request body VotePollRequest true "Vote details with option_id"
+// @Param id path string true "Poll ID, or the slug of an unlisted poll"
+// @Param invite query string false "Invite link token of an invite-only poll"
+// @Param request body VotePollRequest true "Vote details with option_id or option_ids"
 // @Success 200 {object} VotePollResponse "Vote successfully recorded with details"
 // @Failure 400 {object} response.FailedResponse "Bad request - invalid input or poll ID"
 // @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
-// @Failure 403 {object} response.FailedResponse "Forbidden - user has already voted"
+// @Failure 403 {object} response.FailedResponse "Forbidden - poll is not open for voting"
+// @Failure 404 {object} response.FailedResponse "Not found - poll doesn't exist"
 // @Failure 500 {object} response.FailedResponse "Internal server error"
 // @Security BearerAuth
 // @Router /api/v1/poll/{id}/vote [post]
 func (s *Service) VotePoll(c echo.Context) error {
-	idStr := c.Param("id")
-	pollID, err := strconv.ParseInt(idStr, 10, 64)
+	pollID, selections, err := readBallot(c)
 	if err != nil {
-		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+		return response.ErrorBuilder(err).Send(c)
 	}
 
-	var req VotePollRequest
-	if err := c.Bind(&req); err != nil {
-		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	resp, err := s.castVote(c.Request().Context(), pollID, auth.UserID(c), selections)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
 	}
-	if req.OptionID == 0 {
-		return response.ErrorBuilder(errs.BaseErr("option_id is required")).Send(c)
+
+	return response.SuccessBuilder(resp).Send(c)
+}
+
+// castVote records a first ballot and notifies live results subscribers.
+// Ballots on anonymous polls are returned with their receipt.
+func (s *Service) castVote(ctx context.Context, pollID, userID int64, selections []int64) (*VotePollResponse, error) {
+	poll, err := s.votablePoll(ctx, pollID, selections)
+	if err != nil {
+		return nil, err
 	}
 
-	user := c.Get("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := int64(claims["user_id"].(float64))
+	// Only verified accounts may vote, so votes cannot be stuffed with
+	// throwaway sign-ups
+	active, err := s.Repo.IsUserActive(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, errs.Forbidden(errors.New("verify your email address before voting"))
+	}
 
-	alreadyVoted, err := s.Repo.HasUserVoted(c.Request().Context(), pollID, userID)
+	voter := s.voterFor(poll, userID)
+	alreadyVoted, err := s.Repo.HasUserVoted(ctx, pollID, voter)
 	if err != nil {
-		return response.ErrorBuilder(errs.InternalServerError(err)).Send(c)
+		return nil, errs.InternalServerError(err)
 	}
 	if alreadyVoted {
-		return response.ErrorBuilder(errs.BaseErr("already voted")).Send(c)
+		return nil, errs.Conflict(errors.New("already voted"))
 	}
 
-	if err := s.Repo.Vote(c.Request().Context(), pollID, req.OptionID, userID); err != nil {
-		return response.ErrorBuilder(errs.InternalServerError(err)).Send(c)
+	receipt, receiptHash, err := ballotReceipt(poll)
+	if err != nil {
+		return nil, err
 	}
+	if err := s.Repo.Vote(ctx, pollID, voter, selections, receiptHash); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	s.publishResults(ctx, pollID)
 
 	// Return a more informative response instead of just a status code
-	resp := VotePollResponse{
-		Message:   "Vote recorded successfully",
+	return &VotePollResponse{
+		Message:    "Vote recorded successfully",
+		PollID:     pollID,
+		OptionID:   selections[0],
+		OptionIDs:  selections,
+		Timestamp:  time.Now().Format(time.RFC3339),
+		Receipt:    receipt,
+		NextPollID: nextPoll(poll, selections[0]),
+	}, nil
+}
+
+// ballotReceipt issues a receipt for a ballot on an anonymous poll. Other
+// polls get no receipt.
+func ballotReceipt(p *Poll) (token, hash string, err error) {
+	if !p.Anonymous {
+		return "", "", nil
+	}
+	return newReceipt()
+}
+
+// ChangeVote replaces the caller's ballot on a poll
+// @Summary Change a vote
+// @Description Replace the caller's existing ballot with a new one in a single transaction. Only allowed on open polls created with allow_vote_change. On anonymous polls a new receipt replaces the old one.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Param request body VotePollRequest true "New ballot with option_id or option_ids"
+// @Success 200 {object} VotePollResponse "Vote successfully changed"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid input or poll ID"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - poll is closed or does not allow vote changes"
+// @Failure 404 {object} response.FailedResponse "Not found - poll or existing vote doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/vote [put]
+func (s *Service) ChangeVote(c echo.Context) error {
+	pollID, selections, err := readBallot(c)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	resp, err := s.replaceVote(c.Request().Context(), pollID, auth.UserID(c), selections)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(resp).Send(c)
+}
+
+// replaceVote swaps the user's ballot for a new one and notifies live
+// results subscribers.
+func (s *Service) replaceVote(ctx context.Context, pollID, userID int64, selections []int64) (*VotePollResponse, error) {
+	poll, err := s.votablePoll(ctx, pollID, selections)
+	if err != nil {
+		return nil, err
+	}
+	if !poll.AllowVoteChange {
+		return nil, errs.Forbidden(errors.New("this poll does not allow changing votes"))
+	}
+
+	receipt, receiptHash, err := ballotReceipt(poll)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Repo.ChangeVote(ctx, pollID, s.voterFor(poll, userID), selections, receiptHash); err != nil {
+		return nil, err
+	}
+	s.publishResults(ctx, pollID)
+
+	return &VotePollResponse{
+		Message:    "Vote changed successfully",
+		PollID:     pollID,
+		OptionID:   selections[0],
+		OptionIDs:  selections,
+		Timestamp:  time.Now().Format(time.RFC3339),
+		Receipt:    receipt,
+		NextPollID: nextPoll(poll, selections[0]),
+	}, nil
+}
+
+// RetractVote removes the caller's ballot from a poll
+// @Summary Retract a vote
+// @Description Remove the caller's ballot so it no longer counts. Only allowed on open polls created with allow_vote_change.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Success 200 {object} VotePollResponse "Vote successfully retracted"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid poll ID"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - poll is closed or does not allow vote changes"
+// @Failure 404 {object} response.FailedResponse "Not found - poll or existing vote doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/vote [delete]
+func (s *Service) RetractVote(c echo.Context) error {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	resp, err := s.withdrawVote(c.Request().Context(), pollID, auth.UserID(c))
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(resp).Send(c)
+}
+
+// withdrawVote removes the user's ballot and notifies live results subscribers.
+func (s *Service) withdrawVote(ctx context.Context, pollID, userID int64) (*VotePollResponse, error) {
+	poll, err := s.Repo.GetByID(ctx, pollID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVotingWindow(poll, time.Now()); err != nil {
+		return nil, err
+	}
+	if !poll.AllowVoteChange {
+		return nil, errs.Forbidden(errors.New("this poll does not allow changing votes"))
+	}
+
+	if err := s.Repo.RetractVote(ctx, pollID, s.voterFor(poll, userID)); err != nil {
+		return nil, err
+	}
+	s.publishResults(ctx, pollID)
+
+	return &VotePollResponse{
+		Message:   "Vote retracted successfully",
 		PollID:    pollID,
-		OptionID:  req.OptionID,
 		Timestamp: time.Now().Format(time.RFC3339),
+	}, nil
+}
+
+// VerifyReceipt checks the receipt of an anonymous ballot
+// @Summary Verify a ballot receipt
+// @Description Confirm that the ballot behind a receipt from an anonymous poll is counted, and return its selections. Receipts stop verifying once the ballot is changed or retracted.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Param request body VerifyReceiptRequest true "Receipt returned when voting"
+// @Success 200 {object} ReceiptResponse "Ballot is counted"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid input or poll ID"
+// @Failure 404 {object} response.FailedResponse "Not found - poll or receipt doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Router /api/v1/poll/{id}/receipt [post]
+func (s *Service) VerifyReceipt(c echo.Context) error {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
 	}
 
-	return response.SuccessBuilder(resp).Send(c)
+	var req VerifyReceiptRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+	if req.Receipt == "" {
+		return response.ErrorBuilder(errs.BaseErr("receipt is required")).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	if _, err := s.Repo.GetByID(ctx, pollID); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	receipt, err := s.Repo.GetReceipt(ctx, pollID, hashReceipt(req.Receipt))
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(receipt).Send(c)
+}
+
+// readBallot parses the poll ID path parameter and the ballot in the request
+// body. A single option_id is accepted as a one-option ballot.
+func readBallot(c echo.Context) (int64, []int64, error) {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0, nil, errs.BadRequest(err)
+	}
+
+	var req VotePollRequest
+	if err := c.Bind(&req); err != nil {
+		return 0, nil, errs.BadRequest(err)
+	}
+	selections, err := req.Selections()
+	if err != nil {
+		return 0, nil, err
+	}
+	return pollID, selections, nil
+}
+
+// votablePoll loads a poll and checks that it accepts the given ballot now.
+func (s *Service) votablePoll(ctx context.Context, pollID int64, selections []int64) (*Poll, error) {
+	poll, err := s.Repo.GetByID(ctx, pollID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVotingWindow(poll, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := validateSelections(poll, selections); err != nil {
+		return nil, err
+	}
+	return poll, nil
 }
 
 // GetResults retrieves the current results of a poll
 // @Summary Get poll results
-// @Description Get the current vote counts for each option in a poll
+// @Description Get the current vote counts, percentages, ranks and winners for each option in a poll. Multi polls also report the number of voters; ranked polls report first preferences and the round-by-round instant-runoff count. Optional breakdowns add votes per hour or day and per voter cohort. The poll's results_visibility decides who may see results before it closes; the owner always can.
 // @Tags polls
 // @Accept json
 // @Produce json
-// @Param id path int true "Poll ID"
+// @Param id path string true "Poll ID, or the slug of an unlisted poll"
+// @Param invite query string false "Invite link token of an invite-only poll"
+// @Param breakdown query string false "Time-bucket breakdown" Enums(hourly, daily)
+// @Param cohort query string false "Voter cohort breakdown, unavailable for anonymous polls" Enums(account_age)
+// @Param view query string false "Add every participant's score to quiz results" Enums(scores)
 // @Success 200 {object} PollResultsResponse "Poll results with options and vote counts"
 // @Failure 400 {object} response.FailedResponse "Bad request - invalid poll ID format"
+// @Failure 403 {object} response.FailedResponse "Forbidden - results are not visible to the caller yet"
 // @Failure 404 {object} response.FailedResponse "Not found - poll doesn't exist"
 // @Failure 500 {object} response.FailedResponse "Internal server error"
 // @Router /api/v1/poll/{id}/results [get]
@@ -190,27 +574,1625 @@ func (s *Service) GetResults(c echo.Context) error {
 	if err != nil {
 		return response.ErrorBuilder(err).Send(c)
 	}
+	if err := s.checkResultsAccess(c, poll); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	var period string
+	switch c.QueryParam("breakdown") {
+	case "":
+	case BreakdownHourly:
+		period = "hour"
+	case BreakdownDaily:
+		period = "day"
+	default:
+		return response.ErrorBuilder(errs.BadRequest(errors.New("breakdown must be one of hourly, daily"))).Send(c)
+	}
+	cohort := c.QueryParam("cohort")
+	if cohort != "" && cohort != CohortAccountAge {
+		return response.ErrorBuilder(errs.BadRequest(errors.New("cohort must be account_age"))).Send(c)
+	}
+	// Cohorts are derived from who voted, which anonymous polls do not keep
+	if cohort != "" && poll.Anonymous {
+		return response.ErrorBuilder(errs.BadRequest(errors.New("cohort breakdowns are not available for anonymous polls"))).Send(c)
+	}
+	view := c.QueryParam("view")
+	if view != "" && view != ViewScores {
+		return response.ErrorBuilder(errs.BadRequest(errors.New("view must be scores"))).Send(c)
+	}
+	if view == ViewScores && !poll.Quiz {
+		return response.ErrorBuilder(errs.BadRequest(errors.New("the scores view is only available for quiz polls"))).Send(c)
+	}
+
+	hidden := answersHidden(c, poll, time.Now())
+	if hidden && (period != "" || cohort != "" || view != "") {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("quiz results are hidden until the quiz closes"))).Send(c)
+	}
 
-	// Then get the results
-	options, err := s.Repo.GetResults(c.Request().Context(), pollID)
+	results, err := s.tallyResults(c.Request().Context(), poll)
 	if err != nil {
 		return response.ErrorBuilder(err).Send(c)
 	}
-
-	// Calculate total votes
-	var totalVotes int64
-	for _, opt := range options {
-		totalVotes += opt.Votes
+	if hidden {
+		hideResults(results)
+		return response.SuccessBuilder(results).Send(c)
+	}
+	if poll.Quiz {
+		scores, err := s.quizScores(c.Request().Context(), poll)
+		if err != nil {
+			return response.ErrorBuilder(err).Send(c)
+		}
+		results.Quiz = summarizeQuiz(poll, scores, view == ViewScores)
 	}
 
-	// Create the response
-	results := PollResultsResponse{
-		PollID:     pollID,
-		Question:   poll.Question,
-		TotalVotes: totalVotes,
-		CreatedAt:  poll.CreatedAt,
-		Options:    options,
+	// Breakdowns count the same selections as the option tallies
+	firstPreferences := poll.Type == TypeRanked
+	if period != "" {
+		results.Timeline, err = s.Repo.GetVoteTimeline(c.Request().Context(), pollID, period, firstPreferences)
+		if err != nil {
+			return response.ErrorBuilder(err).Send(c)
+		}
+	}
+	if cohort != "" {
+		results.Cohorts, err = s.Repo.GetCohortBreakdown(c.Request().Context(), pollID, firstPreferences)
+		if err != nil {
+			return response.ErrorBuilder(err).Send(c)
+		}
 	}
 
 	return response.SuccessBuilder(results).Send(c)
 }
+
+// ExportResults downloads a poll's results
+// @Summary Export poll results
+// @Description Download a poll's per-option tallies followed by every vote with its timestamp, as CSV, JSON Lines or XLSX. Votes are only included for polls created with export_votes, and never for anonymous polls. Only the poll owner or an admin can export.
+// @Tags polls
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param id path int true "Poll ID"
+// @Param format query string false "Export format (default csv)" Enums(csv, jsonl, xlsx)
+// @Success 200 {file} file "Export file"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid poll ID or format"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the poll owner or an admin"
+// @Failure 404 {object} response.FailedResponse "Not found - poll doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/export [get]
+func (s *Service) ExportResults(c echo.Context) error {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	name := c.QueryParam("format")
+	if name == "" {
+		name = export.CSV
+	}
+	format, ok := export.Lookup(name)
+	if !ok {
+		return response.ErrorBuilder(errs.BadRequest(errors.New("format must be one of csv, jsonl, xlsx"))).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	poll, err := s.Repo.GetByID(ctx, pollID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if !canManage(c, poll) {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("only the poll owner or an admin can export its results"))).Send(c)
+	}
+
+	results, err := s.tallyResults(ctx, poll)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	optionText := make(map[int64]string, len(results.Options))
+	for _, opt := range results.Options {
+		optionText[opt.ID] = opt.Text
+	}
+
+	filename := fmt.Sprintf("poll-%d-results.%s", pollID, format.Extension)
+	return response.StreamBuilder(format.ContentType, filename, func(w io.Writer) error {
+		out := format.New(w)
+
+		if err := out.Table("options", "option_id", "text", "votes", "percentage", "rank", "winner"); err != nil {
+			return err
+		}
+		for _, opt := range results.Options {
+			if err := out.Row(opt.ID, opt.Text, opt.Votes, opt.Percentage, opt.Rank, opt.Winner); err != nil {
+				return err
+			}
+		}
+
+		// Individual ballots are only exported when the poll allows it, and
+		// never for anonymous polls
+		if poll.ExportVotes && !poll.Anonymous {
+			if err := out.Table("votes", "voter_id", "option_id", "option_text", "rank", "voted_at"); err != nil {
+				return err
+			}
+			err := s.Repo.StreamVotes(ctx, pollID, func(v Vote) error {
+				return out.Row(v.UserID, v.OptionID, optionText[v.OptionID], v.Rank, v.CreatedAt)
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return out.Close()
+	}).Send(c)
+}
+
+// StreamResults streams live poll results
+// @Summary Stream poll results
+// @Description Stream a poll's results as Server-Sent Events. A "results" event carrying PollResultsResponse is sent on connect and after every change; idle streams receive a heartbeat comment. Callers the poll's results_visibility does not allow to see results are refused, and the stream ends once the caller can no longer see the poll or its results.
+// @Tags polls
+// @Produce text/event-stream
+// @Param id path int true "Poll ID"
+// @Success 200 {object} PollResultsResponse "Stream of results events"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid poll ID"
+// @Failure 403 {object} response.FailedResponse "Forbidden - results are not visible to the caller yet"
+// @Failure 404 {object} response.FailedResponse "Not found - poll doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Router /api/v1/poll/{id}/results/stream [get]
+func (s *Service) StreamResults(c echo.Context) error {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	poll, err := s.Repo.GetByID(ctx, pollID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if err := s.checkResultsAccess(c, poll); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	bySlug, _ := c.Get(resolvedBySlug).(bool)
+
+	updates, unsubscribe := s.Events.Subscribe(pollID)
+	defer unsubscribe()
+
+	res := c.Response()
+	// Streams outlive the server's write timeout
+	_ = http.NewResponseController(res).SetWriteDeadline(time.Time{})
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	if err := s.sendResultsEvent(ctx, res, poll); err != nil {
+		return nil
+	}
+
+	heartbeat := time.NewTicker(s.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-updates:
+			if !ok {
+				// Server is shutting down
+				return nil
+			}
+			poll, err = s.Repo.GetByID(ctx, pollID)
+			if err != nil {
+				// Poll was deleted or the database is unreachable
+				return nil
+			}
+			// The poll may have been made private, the subscriber's invite
+			// revoked or the results hidden since the stream opened
+			if s.checkAccess(c, poll, bySlug) != nil || s.checkResultsAccess(c, poll) != nil {
+				return nil
+			}
+			if err := s.sendResultsEvent(ctx, res, poll); err != nil {
+				return nil
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
+
+// PollRoom joins a poll's live room over WebSocket
+// @Summary Join a poll room
+// @Description Upgrade to a WebSocket for voting, live results and presence on one poll. Messages are RoomMessage envelopes with protocol version "v"; browsers may pass the JWT in the token query parameter.
+// @Tags polls
+// @Param id path int true "Poll ID"
+// @Param token query string false "JWT, when the Authorization header cannot be set"
+// @Success 101 {object} RoomMessage "Switching protocols"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid poll ID"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 404 {object} response.FailedResponse "Not found - poll doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/ws [get]
+func (s *Service) PollRoom(c echo.Context) error {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	if _, err := s.Repo.GetByID(ctx, pollID); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	userID := auth.UserID(c)
+
+	// Clients authenticate with a bearer token rather than cookies, so any
+	// origin allowed by CORS may connect
+	websocket.Server{
+		Handler: func(conn *websocket.Conn) {
+			s.rooms.serve(ctx, conn, pollID, userID)
+		},
+	}.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
+// currentResults loads a poll and tallies its results.
+func (s *Service) currentResults(ctx context.Context, pollID int64) (*PollResultsResponse, error) {
+	poll, err := s.Repo.GetByID(ctx, pollID)
+	if err != nil {
+		return nil, err
+	}
+	return s.liveResults(ctx, poll, resultsPublic(poll, time.Now()))
+}
+
+// liveResults tallies the results broadcast to live subscribers, which do
+// not include the tallies of a running quiz. Tallies are also hidden unless
+// reveal is set, i.e. unless every subscriber may see them.
+func (s *Service) liveResults(ctx context.Context, poll *Poll, reveal bool) (*PollResultsResponse, error) {
+	results, err := s.tallyResults(ctx, poll)
+	if err != nil {
+		return nil, err
+	}
+	if !reveal || poll.Quiz && !poll.IsFinal(time.Now()) {
+		hideResults(results)
+	}
+	return results, nil
+}
+
+// sendResultsEvent writes the poll's current results as one SSE "results" event.
+func (s *Service) sendResultsEvent(ctx context.Context, res *echo.Response, poll *Poll) error {
+	// StreamResults checks the subscriber may see the results before every
+	// event
+	results, err := s.liveResults(ctx, poll, true)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(res, "event: results\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+	res.Flush()
+	return nil
+}
+
+// tallyResults counts a poll's votes, including the instant-runoff count
+// for ranked polls.
+func (s *Service) tallyResults(ctx context.Context, poll *Poll) (*PollResultsResponse, error) {
+	pollID := poll.ID
+	options, err := s.Repo.GetResults(ctx, pollID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Calculate total votes
+	var totalVotes int64
+	for _, opt := range options {
+		totalVotes += opt.Votes
+	}
+	totalVoters := totalVotes
+
+	var runoff *RunoffResult
+	if poll.Type == TypeMulti || poll.Type == TypeRanked {
+		ballots, err := s.Repo.GetBallots(ctx, pollID)
+		if err != nil {
+			return nil, err
+		}
+		totalVoters = int64(len(ballots))
+
+		if poll.Type == TypeRanked {
+			// Report first preferences rather than every ranked mention
+			firsts := make(map[int64]int64)
+			for _, b := range ballots {
+				firsts[b[0]]++
+			}
+			for i := range options {
+				options[i].Votes = firsts[options[i].ID]
+			}
+			totalVotes = totalVoters
+
+			if len(ballots) > 0 {
+				ids := make([]int64, len(options))
+				for i, opt := range options {
+					ids[i] = opt.ID
+				}
+				runoff = instantRunoff(ids, ballots)
+			}
+		}
+	}
+
+	// A multi poll option's share is of the voters who picked it
+	base := totalVotes
+	if poll.Type == TypeMulti {
+		base = totalVoters
+	}
+	results, tie := rankOptions(options, base, runoff)
+
+	return &PollResultsResponse{
+		PollID:      pollID,
+		Question:    poll.Question,
+		TotalVotes:  totalVotes,
+		TotalVoters: totalVoters,
+		PollType:    poll.Type,
+		CreatedAt:   poll.CreatedAt,
+		Status:      poll.Status,
+		ClosesAt:    poll.ClosesAt,
+		IsFinal:     poll.IsFinal(time.Now()),
+		Tie:         tie,
+		Options:     results,
+		Runoff:      runoff,
+	}, nil
+}
+
+// PublishPoll opens a draft poll for voting
+// @Summary Publish a poll
+// @Description Move a draft poll to open so it accepts votes within its opens_at/closes_at window. Only the poll owner can publish.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Success 200 {object} Poll "Published poll"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the poll owner"
+// @Failure 404 {object} response.FailedResponse "Not found - poll doesn't exist"
+// @Failure 409 {object} response.FailedResponse "Conflict - poll is not a draft"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/publish [post]
+func (s *Service) PublishPoll(c echo.Context) error {
+	return s.transition(c, StatusOpen, func(p *Poll) error {
+		if p.Status != StatusDraft {
+			return errs.Conflict(errors.New("only draft polls can be published"))
+		}
+		return nil
+	})
+}
+
+// ClosePoll closes a poll before its scheduled end
+// @Summary Close a poll
+// @Description Stop accepting votes immediately and make the results final. Only the poll owner can close.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Success 200 {object} Poll "Closed poll"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the poll owner"
+// @Failure 404 {object} response.FailedResponse "Not found - poll doesn't exist"
+// @Failure 409 {object} response.FailedResponse "Conflict - poll is already closed or has not opened yet"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/close [post]
+func (s *Service) ClosePoll(c echo.Context) error {
+	return s.transition(c, StatusClosed, func(p *Poll) error {
+		if p.Status == StatusClosed || p.Status == StatusArchived {
+			return errs.Conflict(errors.New("poll is already closed"))
+		}
+		// Closing sets closes_at to now, which must stay after opens_at
+		if p.OpensAt != nil && p.OpensAt.After(time.Now()) {
+			return errs.Conflict(errors.New("poll has not opened yet; edit or delete it instead"))
+		}
+		return nil
+	})
+}
+
+// transition moves the poll in the :id path parameter to a new status after
+// checking that the caller owns it and that the move is allowed.
+func (s *Service) transition(c echo.Context, status string, allowed func(*Poll) error) error {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	poll, err := s.Repo.GetByID(ctx, pollID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if !canManage(c, poll) {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("only the poll owner can change its status"))).Send(c)
+	}
+	if err := allowed(poll); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	if err := s.Repo.UpdateStatus(ctx, pollID, status); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	s.publishResults(ctx, pollID)
+
+	poll, err = s.Repo.GetByID(ctx, pollID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	return response.SuccessBuilder(poll).Send(c)
+}
+
+// UpdatePoll edits a poll
+// @Summary Update a poll
+// @Description Edit a poll's question, options, voting window, vote-change setting or visibility. Only the poll owner or an admin can edit; the question and options are locked once votes exist.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Param request body UpdatePollRequest true "Fields to change"
+// @Success 200 {object} Poll "Updated poll"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid input"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the poll owner or an admin"
+// @Failure 404 {object} response.FailedResponse "Not found - poll doesn't exist"
+// @Failure 409 {object} response.FailedResponse "Conflict - poll already has votes"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id} [patch]
+func (s *Service) UpdatePoll(c echo.Context) error {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	var req UpdatePollRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	poll, err := s.Repo.GetByID(ctx, pollID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if !canManage(c, poll) {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("only the poll owner or an admin can edit this poll"))).Send(c)
+	}
+
+	if req.Question != nil {
+		if *req.Question == "" {
+			return response.ErrorBuilder(errs.BaseErr("question is required")).Send(c)
+		}
+		poll.Question = *req.Question
+	}
+	// The images of replaced options are removed once the edit is saved
+	var replacedImages []*Image
+	if req.Options != nil {
+		if poll.Quiz {
+			return response.ErrorBuilder(errs.BaseErr("the options of a quiz cannot be edited")).Send(c)
+		}
+		if len(req.Options) < 2 {
+			return response.ErrorBuilder(errs.BaseErr("at least two options are required")).Send(c)
+		}
+		if poll.Type == TypeMulti && poll.MaxSelections > len(req.Options) {
+			return response.ErrorBuilder(errs.BaseErr("max_selections exceeds the number of options")).Send(c)
+		}
+		for _, opt := range poll.Options {
+			replacedImages = append(replacedImages, opt.Image)
+		}
+		poll.Options = make([]Option, len(req.Options))
+		for i, text := range req.Options {
+			poll.Options[i] = Option{Text: text}
+		}
+	}
+	if req.AllowVoteChange != nil {
+		poll.AllowVoteChange = *req.AllowVoteChange
+	}
+	if req.OpensAt != nil {
+		poll.OpensAt = utcTime(req.OpensAt)
+	}
+	if req.ClosesAt != nil {
+		if !req.ClosesAt.After(time.Now()) {
+			return response.ErrorBuilder(errs.BaseErr("closes_at must be in the future")).Send(c)
+		}
+		poll.ClosesAt = utcTime(req.ClosesAt)
+	}
+	if poll.OpensAt != nil && poll.ClosesAt != nil && !poll.ClosesAt.After(*poll.OpensAt) {
+		return response.ErrorBuilder(errs.BaseErr("closes_at must be after opens_at")).Send(c)
+	}
+	if req.Visibility != nil {
+		if err := setVisibility(poll, *req.Visibility); err != nil {
+			return response.ErrorBuilder(err).Send(c)
+		}
+	}
+	if req.ResultsVisibility != nil {
+		if err := checkResultsVisibility(*req.ResultsVisibility); err != nil {
+			return response.ErrorBuilder(err).Send(c)
+		}
+		poll.ResultsVisibility = *req.ResultsVisibility
+	}
+	if req.Tags != nil {
+		tags, err := normalizeTags(req.Tags, s.MaxTags)
+		if err != nil {
+			return response.ErrorBuilder(err).Send(c)
+		}
+		poll.Tags = tags
+	}
+
+	editsBallot := req.Question != nil || req.Options != nil
+	if err := s.Repo.Update(ctx, poll, editsBallot, req.Options != nil, req.Tags != nil); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	for _, img := range replacedImages {
+		s.deleteImage(ctx, img)
+	}
+	s.publishResults(ctx, pollID)
+
+	return response.SuccessBuilder(poll).Send(c)
+}
+
+// DeletePoll soft-deletes a poll
+// @Summary Delete a poll
+// @Description Hide a poll from every endpoint while keeping its votes for history. Its images are removed. Only the poll owner or an admin can delete.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Success 200 {object} map[string]string "Poll deleted"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the poll owner or an admin"
+// @Failure 404 {object} response.FailedResponse "Not found - poll doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id} [delete]
+func (s *Service) DeletePoll(c echo.Context) error {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	poll, err := s.Repo.GetByID(ctx, pollID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if !canManage(c, poll) {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("only the poll owner or an admin can delete this poll"))).Send(c)
+	}
+
+	if err := s.Repo.SoftDelete(ctx, pollID); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	s.deleteImage(ctx, poll.CoverImage)
+	for _, opt := range poll.Options {
+		s.deleteImage(ctx, opt.Image)
+	}
+	s.publishResults(ctx, pollID)
+
+	return response.SuccessBuilder(map[string]string{"message": "Poll deleted successfully"}).Send(c)
+}
+
+// ClonePoll copies a poll into a fresh draft
+// @Summary Clone a poll
+// @Description Copy a poll's question, options and settings into a new draft owned by the caller. Votes, the voting window, follow-ups and invites are not copied. Only the poll owner or an admin can clone.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Success 200 {object} CreatePollResponse "The new draft"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the poll owner or an admin"
+// @Failure 404 {object} response.FailedResponse "Not found - poll doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/clone [post]
+func (s *Service) ClonePoll(c echo.Context) error {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	poll, err := s.Repo.GetByID(ctx, pollID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if !canManage(c, poll) {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("only the poll owner or an admin can clone this poll"))).Send(c)
+	}
+
+	clone, err := s.newPoll(clonePollRequest(poll), auth.UserID(c))
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if err := s.Repo.Create(ctx, clone); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(CreatePollResponse{Poll: *clone}).Send(c)
+}
+
+// GetLeaderboard ranks the participants of a quiz
+// @Summary Get quiz leaderboard
+// @Description Rank the participants of a quiz by score; equal scores share a rank and are ordered by who answered first. Hidden from everyone but the owner until the quiz closes.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Param limit query int false "Number of entries (max 100)" default(10)
+// @Success 200 {object} LeaderboardResponse "Quiz leaderboard"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid poll ID or limit, or not a quiz"
+// @Failure 403 {object} response.FailedResponse "Forbidden - quiz has not closed yet"
+// @Failure 404 {object} response.FailedResponse "Not found - poll doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Router /api/v1/poll/{id}/leaderboard [get]
+func (s *Service) GetLeaderboard(c echo.Context) error {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	limit := defaultLeaderboardSize
+	if v := c.QueryParam("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return response.ErrorBuilder(errs.BadRequest(errors.New("limit must be a positive integer"))).Send(c)
+		}
+		if limit > maxPageSize {
+			limit = maxPageSize
+		}
+	}
+
+	ctx := c.Request().Context()
+	poll, err := s.Repo.GetByID(ctx, pollID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if !poll.Quiz {
+		return response.ErrorBuilder(errs.BadRequest(errors.New("leaderboards are only available for quiz polls"))).Send(c)
+	}
+	if err := s.checkResultsAccess(c, poll); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if answersHidden(c, poll, time.Now()) {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("the leaderboard is hidden until the quiz closes"))).Send(c)
+	}
+
+	scores, err := s.quizScores(ctx, poll)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(LeaderboardResponse{
+		PollID:       pollID,
+		MaxScore:     maxScore(poll),
+		Participants: len(scores),
+		Entries:      rankScores(scores, limit),
+	}).Send(c)
+}
+
+// SetFollowUps replaces the follow-up rules of a poll
+// @Summary Set follow-up rules
+// @Description Replace the rules that send voters on to another poll based on their first choice, building decision flows out of polls. Every next poll must be managed by the caller, and the rules must not form a cycle. An empty list removes all rules. Only the poll owner or an admin can set them.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Param request body SetFollowUpsRequest true "Follow-up rules mapping option IDs to next poll IDs"
+// @Success 200 {object} FollowUpsResponse "Rules saved"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid rules or a cycle"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the owner of the poll or of a next poll"
+// @Failure 404 {object} response.FailedResponse "Not found - poll or next poll doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/follow-ups [put]
+func (s *Service) SetFollowUps(c echo.Context) error {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	var req SetFollowUpsRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+	if req.Rules == nil {
+		req.Rules = []FollowUpRule{}
+	}
+
+	ctx := c.Request().Context()
+	poll, err := s.Repo.GetByID(ctx, pollID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if !canManage(c, poll) {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("only the poll owner or an admin can set its follow-ups"))).Send(c)
+	}
+	if err := s.checkFollowUps(c, poll, req.Rules); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	if err := s.Repo.SetFollowUps(ctx, pollID, req.Rules); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(FollowUpsResponse{PollID: pollID, Rules: req.Rules}).Send(c)
+}
+
+// GetFunnel reports drop-off along a poll's follow-up chain
+// @Summary Get follow-up funnel
+// @Description For every follow-up rule reachable from the poll, count the voters whose first choice sent them on and how many of them voted in the next poll. Anonymous ballots cannot be linked across polls and are left out. Only the poll owner or an admin can view it.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Success 200 {object} FunnelResponse "Funnel of the follow-up chain"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid poll ID"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the poll owner or an admin"
+// @Failure 404 {object} response.FailedResponse "Not found - poll doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/funnel [get]
+func (s *Service) GetFunnel(c echo.Context) error {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	poll, err := s.Repo.GetByID(ctx, pollID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if !canManage(c, poll) {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("only the poll owner or an admin can view its funnel"))).Send(c)
+	}
+
+	funnel, err := s.funnel(ctx, poll)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(funnel).Send(c)
+}
+
+// InviteUsers invites users to an invite-only poll
+// @Summary Invite users to a poll
+// @Description Let users view and vote on an invite-only poll. Users already invited or that do not exist are skipped. Only the poll owner or an admin can invite.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Param request body InviteUsersRequest true "Users to invite"
+// @Success 200 {object} InvitesResponse "Every user invited to the poll"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid input or not an invite-only poll"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the poll owner or an admin"
+// @Failure 404 {object} response.FailedResponse "Not found - poll doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/invites [post]
+func (s *Service) InviteUsers(c echo.Context) error {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	var req InviteUsersRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+	if len(req.UserIDs) == 0 {
+		return response.ErrorBuilder(errs.BaseErr("user_ids is required")).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	poll, err := s.invitablePoll(c, pollID, "only the poll owner or an admin can invite users")
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	if err := s.Repo.AddInvites(ctx, poll.ID, req.UserIDs); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	userIDs, err := s.Repo.ListInvites(ctx, poll.ID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(InvitesResponse{PollID: poll.ID, UserIDs: userIDs}).Send(c)
+}
+
+// ListInvites lists the users invited to a poll
+// @Summary List poll invites
+// @Description List the users invited to an invite-only poll. Only the poll owner or an admin can view them.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Success 200 {object} InvitesResponse "Users invited to the poll"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid poll ID"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the poll owner or an admin"
+// @Failure 404 {object} response.FailedResponse "Not found - poll doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/invites [get]
+func (s *Service) ListInvites(c echo.Context) error {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	poll, err := s.Repo.GetByID(ctx, pollID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if !canManage(c, poll) {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("only the poll owner or an admin can view its invites"))).Send(c)
+	}
+
+	userIDs, err := s.Repo.ListInvites(ctx, pollID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(InvitesResponse{PollID: pollID, UserIDs: userIDs}).Send(c)
+}
+
+// RemoveInvite withdraws a user's invite to a poll
+// @Summary Withdraw a poll invite
+// @Description Withdraw a user's invite to an invite-only poll. Ballots already cast are kept. Only the poll owner or an admin can withdraw invites.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Param user_id path int true "Invited user ID"
+// @Success 200 {object} map[string]string "Invite withdrawn"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid poll or user ID"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the poll owner or an admin"
+// @Failure 404 {object} response.FailedResponse "Not found - poll or invite doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/invites/{user_id} [delete]
+func (s *Service) RemoveInvite(c echo.Context) error {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	poll, err := s.Repo.GetByID(ctx, pollID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if !canManage(c, poll) {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("only the poll owner or an admin can withdraw invites"))).Send(c)
+	}
+
+	if err := s.Repo.RemoveInvite(ctx, pollID, userID); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(map[string]string{"message": "Invite withdrawn successfully"}).Send(c)
+}
+
+// CreateInviteLink signs an invite link to an invite-only poll
+// @Summary Create an invite link
+// @Description Sign a token that lets anyone holding it view and vote on an invite-only poll until it expires. Pass it as the invite query parameter. Only the poll owner or an admin can create links.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Success 200 {object} InviteLinkResponse "Signed invite token and its expiry"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid poll ID or not an invite-only poll"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the poll owner or an admin"
+// @Failure 404 {object} response.FailedResponse "Not found - poll doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/invite-link [post]
+func (s *Service) CreateInviteLink(c echo.Context) error {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	poll, err := s.invitablePoll(c, pollID, "only the poll owner or an admin can create invite links")
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	expiresAt := time.Now().Add(s.InviteTTL).UTC().Truncate(time.Second)
+	return response.SuccessBuilder(InviteLinkResponse{
+		PollID:    poll.ID,
+		Token:     newInviteToken(s.InviteSecret, poll.ID, expiresAt),
+		ExpiresAt: expiresAt,
+	}).Send(c)
+}
+
+// invitablePoll fetches an invite-only poll managed by the caller, failing
+// with forbidden otherwise.
+func (s *Service) invitablePoll(c echo.Context, pollID int64, forbidden string) (*Poll, error) {
+	poll, err := s.Repo.GetByID(c.Request().Context(), pollID)
+	if err != nil {
+		return nil, err
+	}
+	if !canManage(c, poll) {
+		return nil, errs.Forbidden(errors.New(forbidden))
+	}
+	if poll.Visibility != VisibilityInviteOnly {
+		return nil, errs.BaseErr("invites only apply to invite-only polls")
+	}
+	return poll, nil
+}
+
+// ListPolls lists polls with filtering, search and pagination
+// @Summary List polls
+// @Description Browse public polls page by page, optionally filtered by creator and creation date, searched by question or option text, and sorted
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param user_id query int false "Only polls created by this user"
+// @Param created_after query string false "Only polls created at or after this time (RFC3339 or YYYY-MM-DD)"
+// @Param created_before query string false "Only polls created before this time (RFC3339 or YYYY-MM-DD)"
+// @Param q query string false "Full-text search on question and option text"
+// @Param status query string false "Lifecycle status" Enums(open, closed, archived)
+// @Param sort query string false "Sort order" Enums(newest, most_voted, trending)
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Items per page (max 100)" default(10)
+// @Success 200 {array} Poll "Page of polls with pagination metadata"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid filter"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Router /api/v1/poll [get]
+func (s *Service) ListPolls(c echo.Context) error {
+	filter, err := parseListFilter(c)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	return s.sendPollPage(c, filter)
+}
+
+// GetTrending lists the trending polls
+// @Summary List trending polls
+// @Description List the public polls collecting votes fastest right now. Polls whose results are not public are left out. Every recent ballot counts less the older it is, Hacker News style. The ranking is recomputed periodically in the background, so it may lag behind the latest votes by up to a minute.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param limit query int false "Number of polls" default(20)
+// @Success 200 {object} TrendingResponse "Trending polls, best first"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid limit"
+// @Router /api/v1/poll/trending [get]
+func (s *Service) GetTrending(c echo.Context) error {
+	limit := defaultTrendingLimit
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return response.ErrorBuilder(errs.BadRequest(errors.New("limit must be a positive integer"))).Send(c)
+		}
+		limit = n
+	}
+
+	polls, computedAt := s.Trending.Top(limit)
+	resp := TrendingResponse{Polls: polls}
+	if !computedAt.IsZero() {
+		resp.ComputedAt = &computedAt
+	}
+
+	return response.SuccessBuilder(resp).Send(c)
+}
+
+// UploadCoverImage sets the cover image of a poll
+// @Summary Upload a poll cover image
+// @Description Upload a JPEG, PNG or GIF cover image for a poll as the image field of a multipart form, replacing any previous one. A thumbnail is generated. Only the poll owner or an admin can change its images.
+// @Tags polls
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Param image formData file true "Cover image"
+// @Success 200 {object} Image "Stored image"
+// @Failure 400 {object} response.FailedResponse "Bad request - missing, unsupported or oversized image"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the poll owner or an admin"
+// @Failure 404 {object} response.FailedResponse "Not found - poll doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/cover [put]
+func (s *Service) UploadCoverImage(c echo.Context) error {
+	poll, err := s.imagePoll(c)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	img, err := s.uploadImage(c)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	ctx := c.Request().Context()
+	if err := s.Repo.SetCoverImage(ctx, poll.ID, img); err != nil {
+		s.deleteImage(ctx, img)
+		return response.ErrorBuilder(err).Send(c)
+	}
+	s.deleteImage(ctx, poll.CoverImage)
+
+	return response.SuccessBuilder(img).Send(c)
+}
+
+// DeleteCoverImage removes the cover image of a poll
+// @Summary Delete a poll cover image
+// @Description Remove the cover image of a poll. Only the poll owner or an admin can change its images.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Success 200 {object} map[string]string "Cover image removed"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the poll owner or an admin"
+// @Failure 404 {object} response.FailedResponse "Not found - poll doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/cover [delete]
+func (s *Service) DeleteCoverImage(c echo.Context) error {
+	poll, err := s.imagePoll(c)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	if err := s.Repo.SetCoverImage(ctx, poll.ID, nil); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	s.deleteImage(ctx, poll.CoverImage)
+
+	return response.SuccessBuilder(map[string]string{"message": "Cover image removed successfully"}).Send(c)
+}
+
+// UploadOptionImage sets the image of a poll option
+// @Summary Upload an option image
+// @Description Upload a JPEG, PNG or GIF image for a poll option as the image field of a multipart form, replacing any previous one. A thumbnail is generated. Only the poll owner or an admin can change its images.
+// @Tags polls
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Param option_id path int true "Option ID"
+// @Param image formData file true "Option image"
+// @Success 200 {object} Image "Stored image"
+// @Failure 400 {object} response.FailedResponse "Bad request - missing, unsupported or oversized image"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the poll owner or an admin"
+// @Failure 404 {object} response.FailedResponse "Not found - poll or option doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/options/{option_id}/image [put]
+func (s *Service) UploadOptionImage(c echo.Context) error {
+	poll, opt, err := s.imageOption(c)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	img, err := s.uploadImage(c)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	ctx := c.Request().Context()
+	if err := s.Repo.SetOptionImage(ctx, poll.ID, opt.ID, img); err != nil {
+		s.deleteImage(ctx, img)
+		return response.ErrorBuilder(err).Send(c)
+	}
+	s.deleteImage(ctx, opt.Image)
+
+	return response.SuccessBuilder(img).Send(c)
+}
+
+// DeleteOptionImage removes the image of a poll option
+// @Summary Delete an option image
+// @Description Remove the image of a poll option. Only the poll owner or an admin can change its images.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Param option_id path int true "Option ID"
+// @Success 200 {object} map[string]string "Option image removed"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the poll owner or an admin"
+// @Failure 404 {object} response.FailedResponse "Not found - poll or option doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/{id}/options/{option_id}/image [delete]
+func (s *Service) DeleteOptionImage(c echo.Context) error {
+	poll, opt, err := s.imageOption(c)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	if err := s.Repo.SetOptionImage(ctx, poll.ID, opt.ID, nil); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	s.deleteImage(ctx, opt.Image)
+
+	return response.SuccessBuilder(map[string]string{"message": "Option image removed successfully"}).Send(c)
+}
+
+// GetImage serves an uploaded image
+// @Summary Get an image
+// @Description Download a poll cover or option image, or its thumbnail. Image keys are random and never reused, so responses may be cached indefinitely.
+// @Tags polls
+// @Produce image/jpeg,image/png,image/gif
+// @Param key path string true "Image key"
+// @Success 200 {file} binary "Image"
+// @Failure 404 {object} response.FailedResponse "Not found - image doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Router /api/v1/poll/images/{key} [get]
+func (s *Service) GetImage(c echo.Context) error {
+	if s.Storage == nil {
+		return response.ErrorBuilder(errs.NotFound(errors.New("image not found"))).Send(c)
+	}
+	obj, err := s.Storage.Get(c.Request().Context(), c.Param("key"))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+			return response.ErrorBuilder(errs.NotFound(errors.New("image not found"))).Send(c)
+		}
+		return response.ErrorBuilder(errs.InternalServerError(err)).Send(c)
+	}
+	defer obj.Body.Close()
+
+	h := c.Response().Header()
+	h.Set("Cache-Control", "public, max-age=31536000, immutable")
+	h.Set("X-Content-Type-Options", "nosniff")
+	if obj.Size >= 0 {
+		h.Set(echo.HeaderContentLength, strconv.FormatInt(obj.Size, 10))
+	}
+	return c.Stream(http.StatusOK, obj.ContentType, obj.Body)
+}
+
+// imagePoll fetches the poll in the :id path parameter after checking that
+// the caller may change its images.
+func (s *Service) imagePoll(c echo.Context) (*Poll, error) {
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, errs.BadRequest(err)
+	}
+	poll, err := s.Repo.GetByID(c.Request().Context(), pollID)
+	if err != nil {
+		return nil, err
+	}
+	if !canManage(c, poll) {
+		return nil, errs.Forbidden(errors.New("only the poll owner or an admin can change its images"))
+	}
+	return poll, nil
+}
+
+// imageOption fetches the poll in the :id path parameter and its option in
+// :option_id after checking that the caller may change its images.
+func (s *Service) imageOption(c echo.Context) (*Poll, *Option, error) {
+	poll, err := s.imagePoll(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	optionID, err := strconv.ParseInt(c.Param("option_id"), 10, 64)
+	if err != nil {
+		return nil, nil, errs.BadRequest(err)
+	}
+	opt := findOption(poll, optionID)
+	if opt == nil {
+		return nil, nil, errs.NotFound(errors.New("option not found"))
+	}
+	return poll, opt, nil
+}
+
+// CreateTemplate saves a poll template
+// @Summary Create a poll template
+// @Description Save a question, option set and settings to create polls from later. The question and options may contain placeholders such as {{week}} or {{team}}, filled in when a poll is created from the template.
+// @Tags poll templates
+// @Accept json
+// @Produce json
+// @Param request body CreateTemplateRequest true "Template details"
+// @Success 200 {object} PollTemplate "Saved template"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid input"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/templates [post]
+func (s *Service) CreateTemplate(c echo.Context) error {
+	var req CreateTemplateRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	t, err := s.newTemplate(req, auth.UserID(c))
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if err := s.Repo.CreateTemplate(c.Request().Context(), t); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(t).Send(c)
+}
+
+// ListTemplates lists the caller's poll templates
+// @Summary List poll templates
+// @Description List the poll templates saved by the caller, ordered by name.
+// @Tags poll templates
+// @Accept json
+// @Produce json
+// @Success 200 {array} PollTemplate "Templates"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/templates [get]
+func (s *Service) ListTemplates(c echo.Context) error {
+	templates, err := s.Repo.ListTemplates(c.Request().Context(), auth.UserID(c))
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	return response.SuccessBuilder(templates).Send(c)
+}
+
+// GetTemplate retrieves a poll template
+// @Summary Get a poll template
+// @Description Get a poll template with its options and placeholders. Only the template owner or an admin can see it.
+// @Tags poll templates
+// @Accept json
+// @Produce json
+// @Param template_id path int true "Template ID"
+// @Success 200 {object} PollTemplate "Template"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid ID format"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the template owner or an admin"
+// @Failure 404 {object} response.FailedResponse "Not found - template doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/templates/{template_id} [get]
+func (s *Service) GetTemplate(c echo.Context) error {
+	t, err := s.ownTemplate(c)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	return response.SuccessBuilder(t).Send(c)
+}
+
+// DeleteTemplate removes a poll template
+// @Summary Delete a poll template
+// @Description Delete a poll template. Polls already created from it are kept. Only the template owner or an admin can delete it.
+// @Tags poll templates
+// @Accept json
+// @Produce json
+// @Param template_id path int true "Template ID"
+// @Success 200 {object} map[string]string "Template deleted"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid ID format"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the template owner or an admin"
+// @Failure 404 {object} response.FailedResponse "Not found - template doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/templates/{template_id} [delete]
+func (s *Service) DeleteTemplate(c echo.Context) error {
+	t, err := s.ownTemplate(c)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if err := s.Repo.DeleteTemplate(c.Request().Context(), t.ID); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	return response.SuccessBuilder(map[string]string{"message": "Template deleted successfully"}).Send(c)
+}
+
+// CreatePollFromTemplate creates a poll from a template
+// @Summary Create a poll from a template
+// @Description Create a poll owned by the caller from a template, filling in its placeholders from values. The date, week and year placeholders default to the current UTC date. Only the template owner or an admin can use it.
+// @Tags poll templates
+// @Accept json
+// @Produce json
+// @Param template_id path int true "Template ID"
+// @Param request body UseTemplateRequest true "Placeholder values and poll schedule"
+// @Success 200 {object} CreatePollResponse "Created poll"
+// @Failure 400 {object} response.FailedResponse "Bad request - missing placeholder value or invalid input"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the template owner or an admin"
+// @Failure 404 {object} response.FailedResponse "Not found - template doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/poll/templates/{template_id}/polls [post]
+func (s *Service) CreatePollFromTemplate(c echo.Context) error {
+	t, err := s.ownTemplate(c)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	var req UseTemplateRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	values := builtinValues(time.Now().UTC())
+	for name, v := range req.Values {
+		values[name] = v
+	}
+	preq, err := templatePollRequest(t, values)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	preq.Tags = req.Tags
+	preq.Status = req.Status
+	preq.OpensAt = req.OpensAt
+	preq.ClosesAt = req.ClosesAt
+
+	poll, err := s.newPoll(preq, auth.UserID(c))
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if err := s.Repo.Create(c.Request().Context(), poll); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(CreatePollResponse{Poll: *poll}).Send(c)
+}
+
+// ownTemplate fetches the template in the template_id path parameter after
+// checking that the caller owns it or is an admin.
+func (s *Service) ownTemplate(c echo.Context) (*PollTemplate, error) {
+	id, err := strconv.ParseInt(c.Param("template_id"), 10, 64)
+	if err != nil {
+		return nil, errs.BadRequest(err)
+	}
+	t, err := s.Repo.GetTemplate(c.Request().Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if t.UserID != auth.UserID(c) && !auth.HasPermission(c, auth.PermissionManagePolls) {
+		return nil, errs.Forbidden(errors.New("only the template owner or an admin can use this template"))
+	}
+	return t, nil
+}
+
+// ListTags lists popular tags or autocompletes a tag
+// @Summary List tags
+// @Description List the tags used by the most polls, most used first. With prefix, only tags starting with it are listed, for autocomplete.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param prefix query string false "Only tags starting with this prefix"
+// @Param limit query int false "Number of tags (max 100)" default(20)
+// @Success 200 {array} Tag "Tags with their usage counts"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid limit"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Router /api/v1/poll/tags [get]
+func (s *Service) ListTags(c echo.Context) error {
+	limit := defaultTagsLimit
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return response.ErrorBuilder(errs.BadRequest(errors.New("limit must be a positive integer"))).Send(c)
+		}
+		limit = n
+		if limit > maxPageSize {
+			limit = maxPageSize
+		}
+	}
+
+	tags, err := s.Repo.ListTags(c.Request().Context(), normalizeTag(c.QueryParam("prefix")), limit)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(tags).Send(c)
+}
+
+// ListPollsByTag lists the polls carrying a tag
+// @Summary List polls by tag
+// @Description Browse the public polls carrying a tag page by page. Accepts the same filters, search and sort as the poll listing.
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param tag path string true "Tag name"
+// @Param q query string false "Full-text search on question and option text"
+// @Param status query string false "Lifecycle status" Enums(open, closed, archived)
+// @Param sort query string false "Sort order" Enums(newest, most_voted, trending)
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Items per page (max 100)" default(10)
+// @Success 200 {array} Poll "Page of polls with pagination metadata"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid filter"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Router /api/v1/poll/tags/{tag} [get]
+func (s *Service) ListPollsByTag(c echo.Context) error {
+	filter, err := parseListFilter(c)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+	filter.Tag = normalizeTag(c.Param("tag"))
+	if !tagPattern.MatchString(filter.Tag) {
+		return response.ErrorBuilder(errs.BadRequest(errors.New("invalid tag"))).Send(c)
+	}
+
+	return s.sendPollPage(c, filter)
+}
+
+// sendPollPage responds with the page of polls matching filter that the
+// pagination query parameters select.
+func (s *Service) sendPollPage(c echo.Context, filter ListPollsFilter) error {
+	pagination := response.ParsePagination(c.Request())
+	if pagination.Page < 1 {
+		pagination.Page = 1
+	}
+	if pagination.PageSize < 1 {
+		pagination.PageSize = 10
+	}
+	if pagination.PageSize > maxPageSize {
+		pagination.PageSize = maxPageSize
+	}
+	filter.Limit = pagination.PageSize
+	filter.Offset = (pagination.Page - 1) * pagination.PageSize
+
+	total, err := s.Repo.Count(c.Request().Context(), filter)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	polls, err := s.Repo.List(c.Request().Context(), filter)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	hideVoteCounts(c, polls, time.Now())
+
+	pagination.TotalRecords = total
+	return response.PaginatedSuccessBuilder(polls, pagination).Send(c)
+}
+
+// parseListFilter reads the listing query parameters into a ListPollsFilter.
+func parseListFilter(c echo.Context) (ListPollsFilter, error) {
+	f := ListPollsFilter{
+		Search: c.QueryParam("q"),
+		Status: c.QueryParam("status"),
+		Sort:   c.QueryParam("sort"),
+	}
+
+	switch f.Status {
+	case "", StatusOpen, StatusClosed, StatusArchived:
+	default:
+		return f, errors.New("status must be one of open, closed, archived")
+	}
+
+	switch f.Sort {
+	case "":
+		f.Sort = SortNewest
+	case SortNewest, SortMostVoted, SortTrending:
+	default:
+		return f, errors.New("sort must be one of newest, most_voted, trending")
+	}
+
+	if v := c.QueryParam("user_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return f, err
+		}
+		f.UserID = id
+	}
+	if v := c.QueryParam("created_after"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return f, err
+		}
+		f.CreatedAfter = &t
+	}
+	if v := c.QueryParam("created_before"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return f, err
+		}
+		f.CreatedBefore = &t
+	}
+	return f, nil
+}
+
+// parseTimeParam accepts either an RFC3339 timestamp or a plain date.
+func parseTimeParam(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+// publishResults tells live results subscribers that a poll changed. The
+// change is already committed, so a failure is logged rather than returned.
+func (s *Service) publishResults(ctx context.Context, pollID int64) {
+	if err := s.Events.Publish(ctx, pollID); err != nil {
+		logging.Errorf("Publishing results of poll %d: %v", pollID, err)
+	}
+}
+
+// canManage reports whether the authenticated user owns the poll or may
+// manage every poll. Anonymous callers of public routes manage nothing.
+func canManage(c echo.Context, p *Poll) bool {
+	userID, ok := auth.ViewerID(c)
+	if !ok {
+		return false
+	}
+	return p.UserID == userID || auth.HasPermission(c, auth.PermissionManagePolls)
+}
+
+// checkVotingWindow returns a Forbidden error explaining why the poll does
+// not accept votes at the given time, or nil if it does.
+func checkVotingWindow(p *Poll, now time.Time) error {
+	if p.IsOpen(now) {
+		return nil
+	}
+	switch {
+	case p.Status == StatusDraft:
+		return errs.Forbidden(errors.New("poll is not published yet"))
+	case p.Status == StatusOpen && p.OpensAt != nil && now.Before(*p.OpensAt):
+		return errs.Forbidden(errors.New("poll is not open for voting yet"))
+	default:
+		return errs.Forbidden(errors.New("poll is closed"))
+	}
+}
+
+// validateSelections checks a ballot against the poll's type: single polls
+// take exactly one option, multi polls between MinSelections and
+// MaxSelections distinct options, and ranked polls an ordering of distinct
+// options. Every selection must belong to the poll.
+func validateSelections(p *Poll, selections []int64) error {
+	seen := make(map[int64]bool, len(selections))
+	for _, id := range selections {
+		if !hasOption(p, id) {
+			return errs.BaseErr("option does not belong to this poll")
+		}
+		if seen[id] {
+			return errs.BaseErr("an option can only be selected once")
+		}
+		seen[id] = true
+	}
+
+	switch p.Type {
+	case TypeMulti:
+		if len(selections) < p.MinSelections || len(selections) > p.MaxSelections {
+			return errs.BaseErr(fmt.Sprintf("select between %d and %d options", p.MinSelections, p.MaxSelections))
+		}
+	case TypeRanked:
+		// Partial rankings are allowed; the distinct check above bounds the length
+	default:
+		if len(selections) != 1 {
+			return errs.BaseErr("exactly one option must be selected")
+		}
+	}
+	return nil
+}
+
+// hasOption reports whether optionID is one of the poll's options.
+func hasOption(p *Poll, optionID int64) bool {
+	for _, opt := range p.Options {
+		if opt.ID == optionID {
+			return true
+		}
+	}
+	return false
+}
+
+// utcTime normalizes an optional timestamp to UTC before it is stored.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
@@ -1,9 +1,12 @@
 package poll
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -12,6 +15,10 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/phsaurav/echo_prod_blueprint/pkg/auth"
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
 )
 
 func TestNewService(t *testing.T) {
@@ -39,6 +46,17 @@ func addUserToken(c echo.Context, userID int64) {
 	c.Set("user", token)
 }
 
+// addAdminToken sets up a JWT token granting an admin's poll management
+// permission in the context
+func addAdminToken(c echo.Context, userID int64) {
+	token := jwt.New(jwt.SigningMethodHS256)
+	claims := token.Claims.(jwt.MapClaims)
+	claims["user_id"] = float64(userID)
+	claims["roles"] = []interface{}{auth.RoleAdmin}
+	claims["perms"] = []interface{}{auth.PermissionManagePolls}
+	c.Set("user", token)
+}
+
 func TestService_CreatePoll(t *testing.T) {
 	// Test cases
 	tests := []struct {
@@ -75,6 +93,59 @@ func TestService_CreatePoll(t *testing.T) {
 			expectedStatus: http.StatusOK,
 			expectedBody:   `"poll":{"id":1,"question":"What is your favorite color?","options":[{"id":1,"poll_id":1,"text":"Red"},{"id":2,"poll_id":1,"text":"Blue"},{"id":3,"poll_id":1,"text":"Green"}],"user_id":1`,
 		},
+		{
+			name:   "Quiz with correct options",
+			userID: 1,
+			requestBody: `{
+                "question": "Which are Go keywords?",
+                "options": ["defer", "yield", "select"],
+                "poll_type": "multi",
+                "quiz": true,
+                "correct_options": [{"index": 0, "points": 5}, {"index": 2}]
+            }`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("Create", mock.Anything, mock.MatchedBy(func(p *Poll) bool {
+					return p.Quiz &&
+						p.Options[0].Correct && p.Options[0].Points == 5 &&
+						!p.Options[1].Correct && p.Options[1].Points == 0 &&
+						p.Options[2].Correct && p.Options[2].Points == 1
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"quiz":true`,
+		},
+		{
+			name:           "Quiz without correct options",
+			userID:         1,
+			requestBody:    `{"question": "2 + 2?", "options": ["3", "4"], "quiz": true}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"a quiz needs at least one correct option"`,
+		},
+		{
+			name:           "Ranked quiz",
+			userID:         1,
+			requestBody:    `{"question": "2 + 2?", "options": ["3", "4"], "poll_type": "ranked", "quiz": true, "correct_options": [{"index": 1}]}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"quiz mode is only available for single and multi polls"`,
+		},
+		{
+			name:           "Correct option out of range",
+			userID:         1,
+			requestBody:    `{"question": "2 + 2?", "options": ["3", "4"], "quiz": true, "correct_options": [{"index": 2}]}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"correct option index 2 is out of range"`,
+		},
+		{
+			name:           "Correct options on a regular poll",
+			userID:         1,
+			requestBody:    `{"question": "2 + 2?", "options": ["3", "4"], "correct_options": [{"index": 1}]}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"correct_options only apply to quiz polls"`,
+		},
 		{
 			name:           "Invalid request - missing question",
 			userID:         1,
@@ -91,6 +162,125 @@ func TestService_CreatePoll(t *testing.T) {
 			expectedStatus: http.StatusInternalServerError,
 			expectedBody:   `"error":"at least two options are required"`,
 		},
+		{
+			name:   "Scheduled draft poll",
+			userID: 1,
+			requestBody: `{
+                "question": "What is your favorite color?",
+                "options": ["Red", "Blue"],
+                "status": "draft",
+                "opens_at": "2099-01-01T00:00:00Z",
+                "closes_at": "2099-01-08T00:00:00Z"
+            }`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("Create", mock.Anything, mock.MatchedBy(func(p *Poll) bool {
+					return p.Status == StatusDraft && p.OpensAt != nil && p.ClosesAt != nil &&
+						p.ClosesAt.Sub(*p.OpensAt) == 7*24*time.Hour
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"status":"draft","opens_at":"2099-01-01T00:00:00Z","closes_at":"2099-01-08T00:00:00Z"`,
+		},
+		{
+			name:        "Multi poll defaults its selection bounds",
+			userID:      1,
+			requestBody: `{"question": "Pick your stack", "options": ["Go", "Rust", "Zig"], "poll_type": "multi"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("Create", mock.Anything, mock.MatchedBy(func(p *Poll) bool {
+					return p.Type == TypeMulti && p.MinSelections == 1 && p.MaxSelections == 3
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"poll_type":"multi","min_selections":1,"max_selections":3`,
+		},
+		{
+			name:           "Invalid request - multi bounds exceed options",
+			userID:         1,
+			requestBody:    `{"question": "Q?", "options": ["Red", "Blue"], "poll_type": "multi", "max_selections": 3}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"selections must satisfy 1 \u003c= min_selections \u003c= max_selections \u003c= number of options"`,
+		},
+		{
+			name:           "Invalid request - selection bounds on ranked poll",
+			userID:         1,
+			requestBody:    `{"question": "Q?", "options": ["Red", "Blue"], "poll_type": "ranked", "max_selections": 2}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"min_selections and max_selections only apply to multi polls"`,
+		},
+		{
+			name:           "Invalid request - unknown poll type",
+			userID:         1,
+			requestBody:    `{"question": "Q?", "options": ["Red", "Blue"], "poll_type": "approval"}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"poll_type must be one of single, multi, ranked"`,
+		},
+		{
+			name:           "Invalid request - unknown status",
+			userID:         1,
+			requestBody:    `{"question": "Q?", "options": ["Red", "Blue"], "status": "closed"}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"status must be draft or open"`,
+		},
+		{
+			name:           "Invalid request - window ends before it starts",
+			userID:         1,
+			requestBody:    `{"question": "Q?", "options": ["Red", "Blue"], "opens_at": "2099-01-08T00:00:00Z", "closes_at": "2099-01-01T00:00:00Z"}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"closes_at must be after opens_at"`,
+		},
+		{
+			name:        "Unlisted poll gets a slug",
+			userID:      1,
+			requestBody: `{"question": "Q?", "options": ["Red", "Blue"], "visibility": "unlisted"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("Create", mock.Anything, mock.MatchedBy(func(p *Poll) bool {
+					return p.Visibility == VisibilityUnlisted && len(p.Slug) == 16
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"visibility":"unlisted","slug":"`,
+		},
+		{
+			name:           "Invalid request - unknown results visibility",
+			userID:         1,
+			requestBody:    `{"question": "Q?", "options": ["Red", "Blue"], "results_visibility": "never"}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"results_visibility must be one of always, after_vote, after_close, owner_only"`,
+		},
+		{
+			name:        "Valid poll creation - tags are normalized",
+			userID:      1,
+			requestBody: `{"question": "Tabs or spaces?", "options": ["Tabs", "Spaces"], "tags": ["Style", "#Go", "go"]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("Create", mock.Anything, mock.MatchedBy(func(p *Poll) bool {
+					return len(p.Tags) == 2 && p.Tags[0] == "go" && p.Tags[1] == "style"
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"tags":["go","style"]`,
+		},
+		{
+			name:           "Invalid request - too many tags",
+			userID:         1,
+			requestBody:    `{"question": "Q?", "options": ["Red", "Blue"], "tags": ["a", "b", "c", "d", "e", "f"]}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"a poll can have at most 5 tags"`,
+		},
+		{
+			name:           "Invalid request - unknown visibility",
+			userID:         1,
+			requestBody:    `{"question": "Q?", "options": ["Red", "Blue"], "visibility": "secret"}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"visibility must be one of public, unlisted, invite_only"`,
+		},
 		{
 			name:   "Database error",
 			userID: 1,
@@ -171,6 +361,30 @@ func TestService_GetPoll(t *testing.T) {
 			expectedStatus: http.StatusInternalServerError,
 			expectedBody:   `"error":"strconv.ParseInt: parsing \"abc\": invalid syntax"`,
 		},
+		{
+			name:        "Running quiz hides its answers",
+			pollIDParam: "2",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(2)).Return(&Poll{ID: 2, Quiz: true, Status: StatusOpen, Options: []Option{
+					{ID: 3, PollID: 2, Text: "3"},
+					{ID: 4, PollID: 2, Text: "4", Correct: true, Points: 2},
+				}}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"options":[{"id":3,"poll_id":2,"text":"3"},{"id":4,"poll_id":2,"text":"4"}]`,
+		},
+		{
+			name:        "Closed quiz shows its answers",
+			pollIDParam: "2",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(2)).Return(&Poll{ID: 2, Quiz: true, Status: StatusClosed, Options: []Option{
+					{ID: 3, PollID: 2, Text: "3"},
+					{ID: 4, PollID: 2, Text: "4", Correct: true, Points: 2},
+				}}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":4,"poll_id":2,"text":"4","correct":true,"points":2}`,
+		},
 		{
 			name:        "Poll not found",
 			pollIDParam: "999",
@@ -212,6 +426,27 @@ func TestService_GetPoll(t *testing.T) {
 }
 
 func TestService_VotePoll(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	options := []Option{{ID: 1, PollID: 1, Text: "Red"}, {ID: 2, PollID: 1, Text: "Blue"}}
+	openPoll := &Poll{ID: 1, UserID: 9, Status: StatusOpen, Options: options}
+	draftPoll := &Poll{ID: 1, UserID: 9, Status: StatusDraft, Options: options}
+	scheduledPoll := &Poll{ID: 1, UserID: 9, Status: StatusOpen, OpensAt: &future, Options: options}
+	expiredPoll := &Poll{ID: 1, UserID: 9, Status: StatusOpen, ClosesAt: &past, Options: options}
+	closedPoll := &Poll{ID: 1, UserID: 9, Status: StatusClosed, Options: options}
+	three := append(options, Option{ID: 3, PollID: 1, Text: "Green"})
+	multiPoll := &Poll{ID: 1, UserID: 9, Type: TypeMulti, MinSelections: 2, MaxSelections: 3, Status: StatusOpen, Options: three}
+	rankedPoll := &Poll{ID: 1, UserID: 9, Type: TypeRanked, Status: StatusOpen, Options: three}
+	anonymousPoll := &Poll{ID: 1, UserID: 9, Anonymous: true, Status: StatusOpen, Options: options}
+	anonymousVoter := mock.MatchedBy(func(v Voter) bool { return v.UserID == 0 && len(v.Hash) == 64 })
+	receiptHash := mock.MatchedBy(func(h string) bool { return len(h) == 64 })
+	nextPollID := int64(15)
+	rankedFlowPoll := &Poll{ID: 1, UserID: 9, Type: TypeRanked, Status: StatusOpen, Options: []Option{
+		{ID: 1, PollID: 1, Text: "Red"},
+		{ID: 2, PollID: 1, Text: "Blue", NextPollID: &nextPollID},
+		{ID: 3, PollID: 1, Text: "Green"},
+	}}
+
 	tests := []struct {
 		name           string
 		pollIDParam    string
@@ -227,12 +462,28 @@ func TestService_VotePoll(t *testing.T) {
 			requestBody: `{"option_id": 2}`,
 			userID:      3,
 			mockSetup: func(repo *MockRepository) {
-				repo.On("HasUserVoted", mock.Anything, int64(1), int64(3)).Return(false, nil)
-				repo.On("Vote", mock.Anything, int64(1), int64(2), int64(3)).Return(nil)
+				repo.On("GetByID", mock.Anything, int64(1)).Return(openPoll, nil)
+				repo.On("IsUserActive", mock.Anything, int64(3)).Return(true, nil)
+				repo.On("HasUserVoted", mock.Anything, int64(1), Voter{UserID: 3}).Return(false, nil)
+				repo.On("Vote", mock.Anything, int64(1), Voter{UserID: 3}, []int64{2}, "").Return(nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   `"message":"Vote recorded successfully"`,
 		},
+		{
+			name:        "First choice leads to a follow-up poll",
+			pollIDParam: "1",
+			requestBody: `{"option_ids": [2, 1]}`,
+			userID:      3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(rankedFlowPoll, nil)
+				repo.On("IsUserActive", mock.Anything, int64(3)).Return(true, nil)
+				repo.On("HasUserVoted", mock.Anything, int64(1), Voter{UserID: 3}).Return(false, nil)
+				repo.On("Vote", mock.Anything, int64(1), Voter{UserID: 3}, []int64{2, 1}, "").Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"next_poll_id":15`,
+		},
 		{
 			name:           "Invalid poll ID format",
 			pollIDParam:    "abc",
@@ -257,23 +508,182 @@ func TestService_VotePoll(t *testing.T) {
 			requestBody: `{"option_id": 2}`,
 			userID:      3,
 			mockSetup: func(repo *MockRepository) {
-				repo.On("HasUserVoted", mock.Anything, int64(1), int64(3)).Return(true, nil)
+				repo.On("GetByID", mock.Anything, int64(1)).Return(openPoll, nil)
+				repo.On("IsUserActive", mock.Anything, int64(3)).Return(true, nil)
+				repo.On("HasUserVoted", mock.Anything, int64(1), Voter{UserID: 3}).Return(true, nil)
 			},
-			expectedStatus: http.StatusInternalServerError,
+			expectedStatus: http.StatusConflict,
 			expectedBody:   `"error":"already voted"`,
 		},
+		{
+			name:        "Unverified account",
+			pollIDParam: "1",
+			requestBody: `{"option_id": 2}`,
+			userID:      3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(openPoll, nil)
+				repo.On("IsUserActive", mock.Anything, int64(3)).Return(false, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"verify your email address before voting"`,
+		},
 		{
 			name:        "Database error on vote",
 			pollIDParam: "1",
 			requestBody: `{"option_id": 2}`,
 			userID:      3,
 			mockSetup: func(repo *MockRepository) {
-				repo.On("HasUserVoted", mock.Anything, int64(1), int64(3)).Return(false, nil)
-				repo.On("Vote", mock.Anything, int64(1), int64(2), int64(3)).Return(errors.New("database error"))
+				repo.On("GetByID", mock.Anything, int64(1)).Return(openPoll, nil)
+				repo.On("IsUserActive", mock.Anything, int64(3)).Return(true, nil)
+				repo.On("HasUserVoted", mock.Anything, int64(1), Voter{UserID: 3}).Return(false, nil)
+				repo.On("Vote", mock.Anything, int64(1), Voter{UserID: 3}, []int64{2}, "").Return(errors.New("database error"))
 			},
 			expectedStatus: http.StatusInternalServerError,
 			expectedBody:   `"error":"database error"`,
 		},
+		{
+			name:        "Option from another poll",
+			pollIDParam: "1",
+			requestBody: `{"option_id": 7}`,
+			userID:      3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(openPoll, nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"option does not belong to this poll"`,
+		},
+		{
+			name:        "Single poll rejects several options",
+			pollIDParam: "1",
+			requestBody: `{"option_ids": [1, 2]}`,
+			userID:      3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(openPoll, nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"exactly one option must be selected"`,
+		},
+		{
+			name:        "Multi poll vote",
+			pollIDParam: "1",
+			requestBody: `{"option_ids": [3, 1]}`,
+			userID:      3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(multiPoll, nil)
+				repo.On("IsUserActive", mock.Anything, int64(3)).Return(true, nil)
+				repo.On("HasUserVoted", mock.Anything, int64(1), Voter{UserID: 3}).Return(false, nil)
+				repo.On("Vote", mock.Anything, int64(1), Voter{UserID: 3}, []int64{3, 1}, "").Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"option_ids":[3,1]`,
+		},
+		{
+			name:        "Multi poll below minimum",
+			pollIDParam: "1",
+			requestBody: `{"option_id": 3}`,
+			userID:      3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(multiPoll, nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"select between 2 and 3 options"`,
+		},
+		{
+			name:        "Ranked poll vote",
+			pollIDParam: "1",
+			requestBody: `{"option_ids": [2, 3, 1]}`,
+			userID:      3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(rankedPoll, nil)
+				repo.On("IsUserActive", mock.Anything, int64(3)).Return(true, nil)
+				repo.On("HasUserVoted", mock.Anything, int64(1), Voter{UserID: 3}).Return(false, nil)
+				repo.On("Vote", mock.Anything, int64(1), Voter{UserID: 3}, []int64{2, 3, 1}, "").Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"option_ids":[2,3,1]`,
+		},
+		{
+			name:        "Ranked poll with duplicate option",
+			pollIDParam: "1",
+			requestBody: `{"option_ids": [2, 2]}`,
+			userID:      3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(rankedPoll, nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"an option can only be selected once"`,
+		},
+		{
+			name:        "Anonymous vote returns a receipt",
+			pollIDParam: "1",
+			requestBody: `{"option_id": 2}`,
+			userID:      3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(anonymousPoll, nil)
+				repo.On("IsUserActive", mock.Anything, int64(3)).Return(true, nil)
+				repo.On("HasUserVoted", mock.Anything, int64(1), anonymousVoter).Return(false, nil)
+				repo.On("Vote", mock.Anything, int64(1), anonymousVoter, []int64{2}, receiptHash).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"receipt":"`,
+		},
+		{
+			name:        "Anonymous voter already voted",
+			pollIDParam: "1",
+			requestBody: `{"option_id": 2}`,
+			userID:      3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(anonymousPoll, nil)
+				repo.On("IsUserActive", mock.Anything, int64(3)).Return(true, nil)
+				repo.On("HasUserVoted", mock.Anything, int64(1), anonymousVoter).Return(true, nil)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `"error":"already voted"`,
+		},
+		{
+			name:        "Draft poll",
+			pollIDParam: "1",
+			requestBody: `{"option_id": 2}`,
+			userID:      3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(draftPoll, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"poll is not published yet"`,
+		},
+		{
+			name:        "Poll not open yet",
+			pollIDParam: "1",
+			requestBody: `{"option_id": 2}`,
+			userID:      3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(scheduledPoll, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"poll is not open for voting yet"`,
+		},
+		{
+			name:        "Poll past its closing time",
+			pollIDParam: "1",
+			requestBody: `{"option_id": 2}`,
+			userID:      3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(expiredPoll, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"poll is closed"`,
+		},
+		{
+			name:        "Poll closed early",
+			pollIDParam: "1",
+			requestBody: `{"option_id": 2}`,
+			userID:      3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(closedPoll, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"poll is closed"`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -314,6 +724,7 @@ func TestService_GetResults(t *testing.T) {
 		ID:        1,
 		Question:  "What is your favorite color?",
 		CreatedAt: now,
+		Status:    StatusClosed,
 	}
 	testOptions := []Option{
 		{ID: 1, PollID: 1, Text: "Red", Votes: 3},
@@ -323,6 +734,7 @@ func TestService_GetResults(t *testing.T) {
 	tests := []struct {
 		name           string
 		pollIDParam    string
+		query          string
 		mockSetup      func(*MockRepository)
 		expectedStatus int
 		expectedBody   string
@@ -339,41 +751,129 @@ func TestService_GetResults(t *testing.T) {
 			expectedBody: `"poll_id":1,"question":"What is your favorite color?","total_votes":8`,
 		},
 		{
-			name:        "Invalid poll ID format",
-			pollIDParam: "abc",
-			mockSetup:   func(repo *MockRepository) {},
-			// Update to match actual response
-			expectedStatus: http.StatusInternalServerError, // 500 instead of 400
-			expectedBody:   `"error":"strconv.ParseInt: parsing \"abc\": invalid syntax"`,
+			name:        "Closed poll results are final",
+			pollIDParam: "1",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(testPoll, nil)
+				repo.On("GetResults", mock.Anything, int64(1)).Return(testOptions, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"status":"closed","is_final":true`,
 		},
 		{
-			name:        "Poll not found",
-			pollIDParam: "999",
+			name:        "Multi poll reports voters",
+			pollIDParam: "1",
 			mockSetup: func(repo *MockRepository) {
-				repo.On("GetByID", mock.Anything, int64(999)).Return(nil, errors.New("not found"))
+				multi := *testPoll
+				multi.Type = TypeMulti
+				repo.On("GetByID", mock.Anything, int64(1)).Return(&multi, nil)
+				repo.On("GetResults", mock.Anything, int64(1)).Return(testOptions, nil)
+				repo.On("GetBallots", mock.Anything, int64(1)).Return([]Ballot{{1, 2}, {2}, {1, 2}, {2}, {2}, {1}}, nil)
 			},
-			// Update to match actual response
-			expectedStatus: http.StatusInternalServerError, // 500 instead of 404
-			expectedBody:   `"error":"not found"`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"total_votes":8,"total_voters":6,"poll_type":"multi"`,
 		},
 		{
-			name:        "Error getting results",
+			name:        "Ranked poll reports first preferences and runoff",
+			pollIDParam: "1",
+			mockSetup: func(repo *MockRepository) {
+				ranked := *testPoll
+				ranked.Type = TypeRanked
+				rankedOptions := []Option{
+					{ID: 1, PollID: 1, Text: "Red", Votes: 3},
+					{ID: 2, PollID: 1, Text: "Blue", Votes: 3},
+					{ID: 3, PollID: 1, Text: "Green", Votes: 2},
+				}
+				repo.On("GetByID", mock.Anything, int64(1)).Return(&ranked, nil)
+				repo.On("GetResults", mock.Anything, int64(1)).Return(rankedOptions, nil)
+				repo.On("GetBallots", mock.Anything, int64(1)).Return([]Ballot{{1, 2}, {2, 1}, {2}, {3, 2}}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"runoff":{"winner_id":2,"rounds":[{"round":1,"tallies":[{"option_id":2,"votes":2},{"option_id":1,"votes":1},{"option_id":3,"votes":1}],"exhausted":0,"eliminated":[1,3]},{"round":2,"tallies":[{"option_id":2,"votes":4}],"exhausted":0}]}`,
+		},
+		{
+			name:        "Options carry percentage, rank and winner",
 			pollIDParam: "1",
 			mockSetup: func(repo *MockRepository) {
 				repo.On("GetByID", mock.Anything, int64(1)).Return(testPoll, nil)
-				repo.On("GetResults", mock.Anything, int64(1)).Return([]Option{}, errors.New("database error"))
+				repo.On("GetResults", mock.Anything, int64(1)).Return(testOptions, nil)
 			},
-			expectedStatus: http.StatusInternalServerError,
-			// Update to match actual response
-			expectedBody: `"error":"database error"`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"tie":false,"options":[{"id":1,"text":"Red","votes":3,"percentage":37.5,"rank":2,"winner":false},{"id":2,"text":"Blue","votes":5,"percentage":62.5,"rank":1,"winner":true}]`,
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Setup
-			e := echo.New()
-			req := httptest.NewRequest(http.MethodGet, "/", nil)
+		{
+			name:        "Hourly and cohort breakdowns",
+			pollIDParam: "1",
+			query:       "?breakdown=hourly&cohort=account_age",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(testPoll, nil)
+				repo.On("GetResults", mock.Anything, int64(1)).Return(testOptions, nil)
+				repo.On("GetVoteTimeline", mock.Anything, int64(1), "hour", false).Return([]TimeBucket{
+					{Start: time.Date(2025, 5, 18, 9, 0, 0, 0, time.UTC), Votes: 8, Options: []VoteCount{{OptionID: 1, Votes: 3}, {OptionID: 2, Votes: 5}}},
+				}, nil)
+				repo.On("GetCohortBreakdown", mock.Anything, int64(1), false).Return([]CohortBucket{
+					{Cohort: CohortUnder7Days, Votes: 8, Options: []VoteCount{{OptionID: 1, Votes: 3}, {OptionID: 2, Votes: 5}}},
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"timeline":[{"start":"2025-05-18T09:00:00Z","votes":8,"options":[{"option_id":1,"votes":3},{"option_id":2,"votes":5}]}],"cohorts":[{"cohort":"under_7_days","votes":8,`,
+		},
+		{
+			name:        "Cohort breakdown of an anonymous poll",
+			pollIDParam: "1",
+			query:       "?cohort=account_age",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(&Poll{ID: 1, Anonymous: true, Status: StatusOpen}, nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"cohort breakdowns are not available for anonymous polls"`,
+		},
+		{
+			name:        "Invalid breakdown",
+			pollIDParam: "1",
+			query:       "?breakdown=weekly",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(testPoll, nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"breakdown must be one of hourly, daily"`,
+		},
+		{
+			name:        "Invalid poll ID format",
+			pollIDParam: "abc",
+			mockSetup:   func(repo *MockRepository) {},
+			// Update to match actual response
+			expectedStatus: http.StatusInternalServerError, // 500 instead of 400
+			expectedBody:   `"error":"strconv.ParseInt: parsing \"abc\": invalid syntax"`,
+		},
+		{
+			name:        "Poll not found",
+			pollIDParam: "999",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(999)).Return(nil, errors.New("not found"))
+			},
+			// Update to match actual response
+			expectedStatus: http.StatusInternalServerError, // 500 instead of 404
+			expectedBody:   `"error":"not found"`,
+		},
+		{
+			name:        "Error getting results",
+			pollIDParam: "1",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(testPoll, nil)
+				repo.On("GetResults", mock.Anything, int64(1)).Return([]Option{}, errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			// Update to match actual response
+			expectedBody: `"error":"database error"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/"+tt.query, nil)
 			rec := httptest.NewRecorder()
 			c := e.NewContext(req, rec)
 			c.SetParamNames("id")
@@ -397,3 +897,2056 @@ func TestService_GetResults(t *testing.T) {
 		})
 	}
 }
+
+func TestService_GetResults_Quiz(t *testing.T) {
+	options := []Option{
+		{ID: 1, PollID: 1, Text: "3"},
+		{ID: 2, PollID: 1, Text: "4", Correct: true, Points: 10},
+	}
+	running := &Poll{ID: 1, UserID: 9, Quiz: true, Type: TypeSingle, Status: StatusOpen, Options: options}
+	closed := &Poll{ID: 1, UserID: 9, Quiz: true, Type: TypeSingle, Status: StatusClosed, Options: options}
+	tallies := func() []Option {
+		return []Option{{ID: 1, PollID: 1, Text: "3", Votes: 1}, {ID: 2, PollID: 1, Text: "4", Votes: 2}}
+	}
+	answeredAt := time.Date(2025, 5, 18, 9, 0, 0, 0, time.UTC)
+	ballots := []QuizBallot{
+		{UserID: 3, Username: "alice", OptionIDs: []int64{2}, AnsweredAt: answeredAt},
+		{UserID: 4, Username: "bob", OptionIDs: []int64{1}, AnsweredAt: answeredAt},
+		{UserID: 5, Username: "carol", OptionIDs: []int64{2}, AnsweredAt: answeredAt.Add(time.Second)},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		userID         int64
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   []string
+	}{
+		{
+			name: "Running quiz hides tallies from voters",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(running, nil)
+				repo.On("GetResults", mock.Anything, int64(1)).Return(tallies(), nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: []string{
+				`"total_votes":3`,
+				`"options":[{"id":1,"text":"3","votes":0,"percentage":0,"rank":0,"winner":false}`,
+				`"hidden":true`,
+			},
+		},
+		{
+			name:  "Running quiz hides scores from voters",
+			query: "?view=scores",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(running, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   []string{`"error":"quiz results are hidden until the quiz closes"`},
+		},
+		{
+			name:   "Owner sees a running quiz",
+			userID: 9,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(running, nil)
+				repo.On("GetResults", mock.Anything, int64(1)).Return(tallies(), nil)
+				repo.On("GetQuizBallots", mock.Anything, int64(1)).Return(ballots, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: []string{
+				`{"id":2,"text":"4","votes":2,"percentage":66.7,"rank":1,"winner":true}`,
+				`"quiz":{"correct_option_ids":[2],"max_score":10,"participants":3,"average_score":6.67}`,
+			},
+		},
+		{
+			name:  "Closed quiz with per-user scores",
+			query: "?view=scores",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(closed, nil)
+				repo.On("GetResults", mock.Anything, int64(1)).Return(tallies(), nil)
+				repo.On("GetQuizBallots", mock.Anything, int64(1)).Return(ballots, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: []string{
+				`"scores":[{"user_id":3,"username":"alice","score":10,"correct":true,"answered_at":"2025-05-18T09:00:00Z"},` +
+					`{"user_id":5,"username":"carol","score":10,"correct":true,"answered_at":"2025-05-18T09:00:01Z"},` +
+					`{"user_id":4,"username":"bob","score":0,"correct":false,"answered_at":"2025-05-18T09:00:00Z"}]`,
+			},
+		},
+		{
+			name:  "Scores view of a regular poll",
+			query: "?view=scores",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(&Poll{ID: 1, Status: StatusOpen}, nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   []string{`"error":"the scores view is only available for quiz polls"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodGet, "/"+tt.query, "")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			if tt.userID != 0 {
+				addUserToken(c, tt.userID)
+			}
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.GetResults(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			for _, body := range tt.expectedBody {
+				assert.Contains(t, rec.Body.String(), body)
+			}
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_GetResults_Visibility(t *testing.T) {
+	options := []Option{{ID: 1, PollID: 1, Text: "Red", Votes: 3}, {ID: 2, PollID: 1, Text: "Blue", Votes: 5}}
+	pollWith := func(policy, status string) *Poll {
+		return &Poll{ID: 1, UserID: 9, Type: TypeSingle, Status: status, ResultsVisibility: policy}
+	}
+
+	tests := []struct {
+		name           string
+		userID         int64
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Voter sees after_vote results",
+			userID: 4,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(pollWith(ResultsAfterVote, StatusOpen), nil)
+				repo.On("HasUserVoted", mock.Anything, int64(1), Voter{UserID: 4}).Return(true, nil)
+				repo.On("GetResults", mock.Anything, int64(1)).Return(options, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"total_votes":8`,
+		},
+		{
+			name:   "Non-voter is told to vote first",
+			userID: 5,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(pollWith(ResultsAfterVote, StatusOpen), nil)
+				repo.On("HasUserVoted", mock.Anything, int64(1), Voter{UserID: 5}).Return(false, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"results are shown once you have voted"`,
+		},
+		{
+			name: "Anonymous caller on after_vote poll",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(pollWith(ResultsAfterVote, StatusOpen), nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"sign in and vote to see the results"`,
+		},
+		{
+			name: "after_vote results are public once closed",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(pollWith(ResultsAfterVote, StatusClosed), nil)
+				repo.On("GetResults", mock.Anything, int64(1)).Return(options, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"total_votes":8`,
+		},
+		{
+			name:   "after_close hides running poll",
+			userID: 4,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(pollWith(ResultsAfterClose, StatusOpen), nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"results are hidden until the poll closes"`,
+		},
+		{
+			name:   "owner_only hides closed poll",
+			userID: 4,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(pollWith(ResultsOwnerOnly, StatusClosed), nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"results of this poll are only visible to its owner"`,
+		},
+		{
+			name:   "Owner always sees results",
+			userID: 9,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(pollWith(ResultsOwnerOnly, StatusOpen), nil)
+				repo.On("GetResults", mock.Anything, int64(1)).Return(options, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"total_votes":8`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodGet, "/", "")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			if tt.userID != 0 {
+				addUserToken(c, tt.userID)
+			}
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.GetResults(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_GetLeaderboard(t *testing.T) {
+	options := []Option{
+		{ID: 1, PollID: 1, Text: "defer", Correct: true, Points: 5},
+		{ID: 2, PollID: 1, Text: "yield"},
+		{ID: 3, PollID: 1, Text: "select", Correct: true, Points: 3},
+	}
+	closed := &Poll{ID: 1, UserID: 9, Quiz: true, Type: TypeMulti, MinSelections: 1, MaxSelections: 2, Status: StatusClosed, Options: options}
+	running := &Poll{ID: 1, UserID: 9, Quiz: true, Type: TypeMulti, MinSelections: 1, MaxSelections: 2, Status: StatusOpen, Options: options}
+	answeredAt := time.Date(2025, 5, 18, 9, 0, 0, 0, time.UTC)
+	ballots := []QuizBallot{
+		{UserID: 3, Username: "alice", OptionIDs: []int64{1}, AnsweredAt: answeredAt},
+		{UserID: 4, Username: "bob", OptionIDs: []int64{1, 3}, AnsweredAt: answeredAt.Add(time.Minute)},
+		{UserID: 5, Username: "carol", OptionIDs: []int64{1, 2}, AnsweredAt: answeredAt},
+		{UserID: 6, Username: "dave", OptionIDs: []int64{3, 1}, AnsweredAt: answeredAt.Add(2 * time.Minute)},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   []string
+	}{
+		{
+			name:  "Closed quiz",
+			query: "?limit=3",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(closed, nil)
+				repo.On("GetQuizBallots", mock.Anything, int64(1)).Return(ballots, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: []string{
+				`"poll_id":1,"max_score":8,"participants":4`,
+				`{"rank":1,"user_id":4,"username":"bob","score":8`,
+				`{"rank":1,"user_id":6,"username":"dave","score":8`,
+				`{"rank":3,"user_id":3,"username":"alice","score":5`,
+			},
+		},
+		{
+			name: "Running quiz",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(running, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   []string{`"error":"the leaderboard is hidden until the quiz closes"`},
+		},
+		{
+			name: "Regular poll",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(&Poll{ID: 1, Status: StatusClosed}, nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   []string{`"error":"leaderboards are only available for quiz polls"`},
+		},
+		{
+			name:           "Invalid limit",
+			query:          "?limit=0",
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   []string{`"error":"limit must be a positive integer"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodGet, "/"+tt.query, "")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.GetLeaderboard(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			for _, body := range tt.expectedBody {
+				assert.Contains(t, rec.Body.String(), body)
+			}
+			assert.NotContains(t, rec.Body.String(), `"username":"carol"`)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_ListPolls(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	testPolls := []Poll{
+		{ID: 2, Question: "Best editor?", UserID: 7, CreatedAt: now, TotalVotes: 4},
+		{ID: 1, Question: "Favorite language?", UserID: 7, CreatedAt: now, TotalVotes: 9},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:  "Default listing",
+			query: "",
+			mockSetup: func(repo *MockRepository) {
+				f := ListPollsFilter{Sort: SortNewest, Limit: 10, Offset: 0}
+				repo.On("Count", mock.Anything, f).Return(2, nil)
+				repo.On("List", mock.Anything, f).Return(testPolls, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"meta":{"page":1,"page_size":10,"total_pages":1,"total_records":2}`,
+		},
+		{
+			name:  "Filtered and paginated listing",
+			query: "?user_id=7&q=go&sort=most_voted&page=3&page_size=500&created_after=2025-01-01",
+			mockSetup: func(repo *MockRepository) {
+				matcher := mock.MatchedBy(func(f ListPollsFilter) bool {
+					return f.UserID == 7 && f.Search == "go" && f.Sort == SortMostVoted &&
+						f.Limit == maxPageSize && f.Offset == 2*maxPageSize &&
+						f.CreatedAfter != nil && f.CreatedAfter.Year() == 2025 && f.CreatedBefore == nil
+				})
+				repo.On("Count", mock.Anything, matcher).Return(250, nil)
+				repo.On("List", mock.Anything, matcher).Return(testPolls, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"meta":{"page":3,"page_size":100,"total_pages":3,"total_records":250}`,
+		},
+		{
+			name:  "Hidden results leave out vote totals",
+			query: "",
+			mockSetup: func(repo *MockRepository) {
+				f := ListPollsFilter{Sort: SortNewest, Limit: 10, Offset: 0}
+				repo.On("Count", mock.Anything, f).Return(1, nil)
+				repo.On("List", mock.Anything, f).Return([]Poll{
+					{ID: 3, Question: "Secret ballot?", UserID: 7, CreatedAt: now, ResultsVisibility: ResultsOwnerOnly, TotalVotes: 5},
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"results_visibility":"owner_only"}`,
+		},
+		{
+			name:           "Invalid sort",
+			query:          "?sort=random",
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"sort must be one of newest, most_voted, trending"`,
+		},
+		{
+			name:           "Invalid date",
+			query:          "?created_before=yesterday",
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `cannot parse`,
+		},
+		{
+			name:  "Database error",
+			query: "",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("Count", mock.Anything, mock.Anything).Return(0, errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"database error"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodGet, "/api/v1/poll"+tt.query, "")
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.ListPolls(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_GetTrending(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetTrending", mock.Anything, defaultTrendingWindow, defaultTrendingGravity, defaultTrendingSize).
+		Return([]TrendingPoll{
+			{Poll: Poll{ID: 3, Question: "Lunch?"}, Score: 2.5, RecentVotes: 31},
+			{Poll: Poll{ID: 1, Question: "Tabs or spaces?"}, Score: 1.2, RecentVotes: 9},
+		}, nil)
+
+	service := NewService(mockRepo)
+
+	// Before the first ranking the list is empty
+	c, rec := setupEchoContext(http.MethodGet, "/api/v1/poll/trending", "")
+	assert.NoError(t, service.GetTrending(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"data":{"polls":[]}`)
+
+	// Requests read the cached ranking
+	require.NoError(t, service.Trending.Recompute(t.Context()))
+	c, rec = setupEchoContext(http.MethodGet, "/api/v1/poll/trending?limit=1", "")
+	assert.NoError(t, service.GetTrending(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"question":"Lunch?"`)
+	assert.Contains(t, rec.Body.String(), `"score":2.5,"recent_votes":31}],"computed_at":"`)
+	assert.NotContains(t, rec.Body.String(), "Tabs or spaces?")
+
+	// Invalid limit
+	c, rec = setupEchoContext(http.MethodGet, "/api/v1/poll/trending?limit=0", "")
+	assert.NoError(t, service.GetTrending(c))
+	assert.Contains(t, rec.Body.String(), `"error":"limit must be a positive integer"`)
+
+	mockRepo.AssertNumberOfCalls(t, "GetTrending", 1)
+}
+
+func TestService_ListTags(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:  "Popular tags",
+			query: "",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("ListTags", mock.Anything, "", defaultTagsLimit).Return([]Tag{{Name: "go", UsageCount: 12}}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"data":[{"name":"go","usage_count":12}]`,
+		},
+		{
+			name:  "Autocomplete normalizes the prefix",
+			query: "?prefix=Machine%20Le&limit=500",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("ListTags", mock.Anything, "machine-le", maxPageSize).Return([]Tag{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"data":[]`,
+		},
+		{
+			name:           "Invalid limit",
+			query:          "?limit=abc",
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"limit must be a positive integer"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodGet, "/api/v1/poll/tags"+tt.query, "")
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.ListTags(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_ListPollsByTag(t *testing.T) {
+	tests := []struct {
+		name           string
+		tag            string
+		query          string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:  "Polls carrying the tag",
+			tag:   "Go",
+			query: "?sort=most_voted",
+			mockSetup: func(repo *MockRepository) {
+				f := ListPollsFilter{Tag: "go", Sort: SortMostVoted, Limit: 10}
+				repo.On("Count", mock.Anything, f).Return(1, nil)
+				repo.On("List", mock.Anything, f).Return([]Poll{{ID: 1, Question: "Tabs or spaces?", Tags: []string{"go"}}}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"tags":["go"]`,
+		},
+		{
+			name:           "Invalid tag",
+			tag:            "%25%25",
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"invalid tag"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodGet, "/api/v1/poll/tags/x"+tt.query, "")
+			c.SetParamNames("tag")
+			c.SetParamValues(tt.tag)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.ListPollsByTag(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_PublishPoll(t *testing.T) {
+	draftPoll := &Poll{ID: 1, UserID: 3, Status: StatusDraft}
+	openPoll := &Poll{ID: 1, UserID: 3, Status: StatusOpen}
+
+	tests := []struct {
+		name           string
+		userID         int64
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Owner publishes draft",
+			userID: 3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(draftPoll, nil).Once()
+				repo.On("UpdateStatus", mock.Anything, int64(1), StatusOpen).Return(nil)
+				repo.On("GetByID", mock.Anything, int64(1)).Return(openPoll, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"status":"open"`,
+		},
+		{
+			name:   "Non-owner is forbidden",
+			userID: 4,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(draftPoll, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"only the poll owner can change its status"`,
+		},
+		{
+			name:   "Already published",
+			userID: 3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(openPoll, nil)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `"error":"only draft polls can be published"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodPost, "/", "")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			addUserToken(c, tt.userID)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.PublishPoll(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_ClosePoll(t *testing.T) {
+	now := time.Now()
+	openPoll := &Poll{ID: 1, UserID: 3, Status: StatusOpen}
+	closedPoll := &Poll{ID: 1, UserID: 3, Status: StatusClosed, ClosesAt: &now}
+	opensAt := now.Add(time.Hour)
+	scheduledPoll := &Poll{ID: 1, UserID: 3, Status: StatusOpen, OpensAt: &opensAt}
+
+	tests := []struct {
+		name           string
+		userID         int64
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Owner closes poll early",
+			userID: 3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(openPoll, nil).Once()
+				repo.On("UpdateStatus", mock.Anything, int64(1), StatusClosed).Return(nil)
+				repo.On("GetByID", mock.Anything, int64(1)).Return(closedPoll, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"status":"closed"`,
+		},
+		{
+			name:   "Non-owner is forbidden",
+			userID: 4,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(openPoll, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"only the poll owner can change its status"`,
+		},
+		{
+			name:   "Already closed",
+			userID: 3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(closedPoll, nil)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `"error":"poll is already closed"`,
+		},
+		{
+			name:   "Not yet open",
+			userID: 3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(scheduledPoll, nil)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `"error":"poll has not opened yet; edit or delete it instead"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodPost, "/", "")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			addUserToken(c, tt.userID)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.ClosePoll(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_ChangeVote(t *testing.T) {
+	options := []Option{{ID: 1, PollID: 1, Text: "Red"}, {ID: 2, PollID: 1, Text: "Blue"}}
+	changeable := &Poll{ID: 1, UserID: 9, Type: TypeSingle, AllowVoteChange: true, Status: StatusOpen, Options: options}
+	locked := &Poll{ID: 1, UserID: 9, Type: TypeSingle, Status: StatusOpen, Options: options}
+	closed := &Poll{ID: 1, UserID: 9, Type: TypeSingle, AllowVoteChange: true, Status: StatusClosed, Options: options}
+
+	tests := []struct {
+		name           string
+		requestBody    string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Vote changed",
+			requestBody: `{"option_id": 1}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(changeable, nil)
+				repo.On("ChangeVote", mock.Anything, int64(1), Voter{UserID: 3}, []int64{1}, "").Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"Vote changed successfully"`,
+		},
+		{
+			name:        "Poll forbids changes",
+			requestBody: `{"option_id": 1}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(locked, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"this poll does not allow changing votes"`,
+		},
+		{
+			name:        "Poll closed",
+			requestBody: `{"option_id": 1}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(closed, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"poll is closed"`,
+		},
+		{
+			name:        "No existing vote",
+			requestBody: `{"option_id": 1}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(changeable, nil)
+				repo.On("ChangeVote", mock.Anything, int64(1), Voter{UserID: 3}, []int64{1}, "").
+					Return(errs.NotFound(errors.New("you have not voted on this poll")))
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"error":"you have not voted on this poll"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodPut, "/", tt.requestBody)
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			addUserToken(c, 3)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.ChangeVote(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_RetractVote(t *testing.T) {
+	changeable := &Poll{ID: 1, UserID: 9, AllowVoteChange: true, Status: StatusOpen}
+	locked := &Poll{ID: 1, UserID: 9, Status: StatusOpen}
+
+	tests := []struct {
+		name           string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Vote retracted",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(changeable, nil)
+				repo.On("RetractVote", mock.Anything, int64(1), Voter{UserID: 3}).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"Vote retracted successfully"`,
+		},
+		{
+			name: "Poll forbids changes",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(locked, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"this poll does not allow changing votes"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodDelete, "/", "")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			addUserToken(c, 3)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.RetractVote(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_VerifyReceipt(t *testing.T) {
+	castAt := time.Date(2025, 5, 18, 9, 30, 0, 0, time.UTC)
+	poll := &Poll{ID: 1, Anonymous: true, Status: StatusOpen}
+
+	tests := []struct {
+		name           string
+		requestBody    string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Counted ballot",
+			requestBody: `{"receipt": "T0m8b3Jc2lYk5rq1X0pZ4w"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(poll, nil)
+				repo.On("GetReceipt", mock.Anything, int64(1), hashReceipt("T0m8b3Jc2lYk5rq1X0pZ4w")).
+					Return(&ReceiptResponse{PollID: 1, Counted: true, OptionIDs: []int64{2}, CastAt: castAt}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"poll_id":1,"counted":true,"option_ids":[2],"cast_at":"2025-05-18T09:30:00Z"`,
+		},
+		{
+			name:        "Unknown receipt",
+			requestBody: `{"receipt": "forged"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(poll, nil)
+				repo.On("GetReceipt", mock.Anything, int64(1), hashReceipt("forged")).
+					Return(nil, errs.NotFound(errors.New("receipt not found")))
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"error":"receipt not found"`,
+		},
+		{
+			name:           "Missing receipt",
+			requestBody:    `{}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"receipt is required"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodPost, "/", tt.requestBody)
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.VerifyReceipt(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_UpdatePoll(t *testing.T) {
+	newPoll := func() *Poll {
+		return &Poll{
+			ID:       1,
+			Question: "Old question?",
+			Options:  []Option{{ID: 1, PollID: 1, Text: "A"}, {ID: 2, PollID: 1, Text: "B"}},
+			UserID:   3,
+			Type:     TypeSingle,
+			Status:   StatusOpen,
+		}
+	}
+
+	tests := []struct {
+		name           string
+		userID         int64
+		admin          bool
+		requestBody    string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Owner edits question and options before voting",
+			userID:      3,
+			requestBody: `{"question":"New question?","options":["X","Y","Z"]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(newPoll(), nil)
+				repo.On("Update", mock.Anything, mock.MatchedBy(func(p *Poll) bool {
+					return p.Question == "New question?" && len(p.Options) == 3
+				}), true, true, false).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"question":"New question?"`,
+		},
+		{
+			name:        "Admin extends the voting window",
+			userID:      9,
+			admin:       true,
+			requestBody: `{"closes_at":"2999-01-01T00:00:00Z"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(newPoll(), nil)
+				repo.On("Update", mock.Anything, mock.AnythingOfType("*poll.Poll"), false, false, false).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"closes_at":"2999-01-01T00:00:00Z"`,
+		},
+		{
+			name:        "Owner makes the poll invite-only",
+			userID:      3,
+			requestBody: `{"visibility":"invite_only"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(newPoll(), nil)
+				repo.On("Update", mock.Anything, mock.MatchedBy(func(p *Poll) bool {
+					return p.Visibility == VisibilityInviteOnly && p.Slug == ""
+				}), false, false, false).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"visibility":"invite_only"`,
+		},
+		{
+			name:        "Owner replaces the tags",
+			userID:      3,
+			requestBody: `{"tags":["Design"]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(newPoll(), nil)
+				repo.On("Update", mock.Anything, mock.MatchedBy(func(p *Poll) bool {
+					return len(p.Tags) == 1 && p.Tags[0] == "design"
+				}), false, false, true).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"tags":["design"]`,
+		},
+		{
+			name:        "Non-owner is forbidden",
+			userID:      4,
+			requestBody: `{"question":"Hijacked?"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(newPoll(), nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"only the poll owner or an admin can edit this poll"`,
+		},
+		{
+			name:        "Options locked once votes exist",
+			userID:      3,
+			requestBody: `{"options":["X","Y"]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(newPoll(), nil)
+				repo.On("Update", mock.Anything, mock.AnythingOfType("*poll.Poll"), true, true, false).
+					Return(errs.Conflict(errors.New("question and options cannot be edited once voting has started")))
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `"error":"question and options cannot be edited once voting has started"`,
+		},
+		{
+			name:        "Too few options",
+			userID:      3,
+			requestBody: `{"options":["X"]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(newPoll(), nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"at least two options are required"`,
+		},
+		{
+			name:        "Deleted or missing poll",
+			userID:      3,
+			requestBody: `{"question":"New question?"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(nil, errs.NotFound(errors.New("poll not found")))
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"error":"poll not found"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodPatch, "/", tt.requestBody)
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			if tt.admin {
+				addAdminToken(c, tt.userID)
+			} else {
+				addUserToken(c, tt.userID)
+			}
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.UpdatePoll(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_DeletePoll(t *testing.T) {
+	poll := &Poll{ID: 1, UserID: 3, Status: StatusOpen}
+
+	tests := []struct {
+		name           string
+		userID         int64
+		admin          bool
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Owner deletes poll",
+			userID: 3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(poll, nil)
+				repo.On("SoftDelete", mock.Anything, int64(1)).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"Poll deleted successfully"`,
+		},
+		{
+			name:   "Admin deletes poll",
+			userID: 9,
+			admin:  true,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(poll, nil)
+				repo.On("SoftDelete", mock.Anything, int64(1)).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"Poll deleted successfully"`,
+		},
+		{
+			name:   "Non-owner is forbidden",
+			userID: 4,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(poll, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"only the poll owner or an admin can delete this poll"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodDelete, "/", "")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			if tt.admin {
+				addAdminToken(c, tt.userID)
+			} else {
+				addUserToken(c, tt.userID)
+			}
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.DeletePoll(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_SetFollowUps(t *testing.T) {
+	poll := &Poll{ID: 12, UserID: 3, Status: StatusOpen, Options: []Option{
+		{ID: 1, PollID: 12, Text: "Go"},
+		{ID: 2, PollID: 12, Text: "Rust"},
+	}}
+	ownPoll := func(id int64) *Poll { return &Poll{ID: id, UserID: 3, Status: StatusOpen} }
+
+	tests := []struct {
+		name           string
+		userID         int64
+		requestBody    string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Owner chains polls",
+			userID:      3,
+			requestBody: `{"rules": [{"option_id": 1, "next_poll_id": 15}, {"option_id": 2, "next_poll_id": 16}]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(12)).Return(poll, nil)
+				repo.On("GetByID", mock.Anything, int64(15)).Return(ownPoll(15), nil)
+				repo.On("GetByID", mock.Anything, int64(16)).Return(ownPoll(16), nil)
+				repo.On("GetFollowUps", mock.Anything, int64(15)).Return([]FollowUpRule{{OptionID: 7, NextPollID: 17}}, nil)
+				repo.On("GetFollowUps", mock.Anything, int64(16)).Return([]FollowUpRule{{OptionID: 8, NextPollID: 17}}, nil)
+				repo.On("GetFollowUps", mock.Anything, int64(17)).Return([]FollowUpRule{}, nil)
+				repo.On("SetFollowUps", mock.Anything, int64(12), []FollowUpRule{
+					{OptionID: 1, NextPollID: 15},
+					{OptionID: 2, NextPollID: 16},
+				}).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"poll_id":12,"rules":[{"option_id":1,"next_poll_id":15},{"option_id":2,"next_poll_id":16}]`,
+		},
+		{
+			name:        "Empty list removes rules",
+			userID:      3,
+			requestBody: `{}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(12)).Return(poll, nil)
+				repo.On("SetFollowUps", mock.Anything, int64(12), []FollowUpRule{}).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"rules":[]`,
+		},
+		{
+			name:        "Rules leading back to the poll",
+			userID:      3,
+			requestBody: `{"rules": [{"option_id": 1, "next_poll_id": 15}]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(12)).Return(poll, nil)
+				repo.On("GetByID", mock.Anything, int64(15)).Return(ownPoll(15), nil)
+				repo.On("GetFollowUps", mock.Anything, int64(15)).Return([]FollowUpRule{{OptionID: 7, NextPollID: 16}}, nil)
+				repo.On("GetFollowUps", mock.Anything, int64(16)).Return([]FollowUpRule{{OptionID: 9, NextPollID: 12}}, nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"follow-up rules would create a cycle: 12 -\u003e 15 -\u003e 16 -\u003e 12"`,
+		},
+		{
+			name:        "Rule pointing at the poll itself",
+			userID:      3,
+			requestBody: `{"rules": [{"option_id": 1, "next_poll_id": 12}]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(12)).Return(poll, nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"follow-up rules would create a cycle: 12 -\u003e 12"`,
+		},
+		{
+			name:        "Next poll of another user",
+			userID:      3,
+			requestBody: `{"rules": [{"option_id": 1, "next_poll_id": 15}]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(12)).Return(poll, nil)
+				repo.On("GetByID", mock.Anything, int64(15)).Return(&Poll{ID: 15, UserID: 4}, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"you do not manage poll 15"`,
+		},
+		{
+			name:        "Option of another poll",
+			userID:      3,
+			requestBody: `{"rules": [{"option_id": 5, "next_poll_id": 15}]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(12)).Return(poll, nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"option 5 does not belong to this poll"`,
+		},
+		{
+			name:        "Two rules for one option",
+			userID:      3,
+			requestBody: `{"rules": [{"option_id": 1, "next_poll_id": 15}, {"option_id": 1, "next_poll_id": 16}]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(12)).Return(poll, nil)
+				repo.On("GetByID", mock.Anything, int64(15)).Return(ownPoll(15), nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"option 1 has more than one follow-up"`,
+		},
+		{
+			name:        "Non-owner is forbidden",
+			userID:      4,
+			requestBody: `{"rules": []}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(12)).Return(poll, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"only the poll owner or an admin can set its follow-ups"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodPut, "/", tt.requestBody)
+			c.SetParamNames("id")
+			c.SetParamValues("12")
+			addUserToken(c, tt.userID)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.SetFollowUps(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_GetFunnel(t *testing.T) {
+	poll := &Poll{ID: 12, UserID: 3, Status: StatusOpen}
+
+	tests := []struct {
+		name           string
+		userID         int64
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   []string
+	}{
+		{
+			name:   "Owner views funnel",
+			userID: 3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(12)).Return(poll, nil)
+				repo.On("CountVoters", mock.Anything, int64(12)).Return(int64(100), nil)
+				repo.On("GetFollowUps", mock.Anything, int64(12)).Return([]FollowUpRule{
+					{OptionID: 1, NextPollID: 15},
+					{OptionID: 2, NextPollID: 16},
+				}, nil)
+				repo.On("GetFollowUps", mock.Anything, int64(15)).Return([]FollowUpRule{{OptionID: 7, NextPollID: 16}}, nil)
+				repo.On("GetFollowUps", mock.Anything, int64(16)).Return([]FollowUpRule{}, nil)
+				repo.On("GetFunnelStep", mock.Anything, int64(12), int64(1), int64(15)).Return(int64(60), int64(45), nil)
+				repo.On("GetFunnelStep", mock.Anything, int64(12), int64(2), int64(16)).Return(int64(40), int64(40), nil)
+				repo.On("GetFunnelStep", mock.Anything, int64(15), int64(7), int64(16)).Return(int64(45), int64(0), nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: []string{
+				`"poll_id":12,"voters":100`,
+				`{"from_poll_id":12,"option_id":1,"next_poll_id":15,"depth":1,"entered":60,"continued":45,"drop_off":15,"drop_off_rate":25}`,
+				`{"from_poll_id":12,"option_id":2,"next_poll_id":16,"depth":1,"entered":40,"continued":40,"drop_off":0,"drop_off_rate":0}`,
+				`{"from_poll_id":15,"option_id":7,"next_poll_id":16,"depth":2,"entered":45,"continued":0,"drop_off":45,"drop_off_rate":100}`,
+			},
+		},
+		{
+			name:   "Non-owner is forbidden",
+			userID: 4,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(12)).Return(poll, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   []string{`"error":"only the poll owner or an admin can view its funnel"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodGet, "/", "")
+			c.SetParamNames("id")
+			c.SetParamValues("12")
+			addUserToken(c, tt.userID)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.GetFunnel(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			for _, body := range tt.expectedBody {
+				assert.Contains(t, rec.Body.String(), body)
+			}
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_ExportResults(t *testing.T) {
+	votedAt := time.Date(2025, 5, 18, 9, 30, 0, 0, time.UTC)
+	poll := &Poll{ID: 1, UserID: 3, Question: "Tabs or spaces?", Type: TypeSingle, Status: StatusClosed, ExportVotes: true}
+	options := []Option{{ID: 1, PollID: 1, Text: "Tabs", Votes: 1}, {ID: 2, PollID: 1, Text: "Spaces", Votes: 0}}
+	votes := []Vote{{ID: 1, PollID: 1, OptionID: 1, UserID: 7, CreatedAt: votedAt}}
+
+	tests := []struct {
+		name                string
+		query               string
+		userID              int64
+		mockSetup           func(*MockRepository)
+		expectedStatus      int
+		expectedDisposition string
+		expectedBody        string
+	}{
+		{
+			name:   "Owner exports CSV by default",
+			userID: 3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(poll, nil)
+				repo.On("GetResults", mock.Anything, int64(1)).Return(options, nil)
+				repo.On("StreamVotes", mock.Anything, int64(1), mock.Anything).Return(votes, nil)
+			},
+			expectedStatus:      http.StatusOK,
+			expectedDisposition: `attachment; filename="poll-1-results.csv"`,
+			expectedBody: "option_id,text,votes,percentage,rank,winner\n" +
+				"1,Tabs,1,100,1,true\n" +
+				"2,Spaces,0,0,2,false\n" +
+				"\n" +
+				"voter_id,option_id,option_text,rank,voted_at\n" +
+				"7,1,Tabs,0,2025-05-18T09:30:00Z\n",
+		},
+		{
+			name:   "Owner exports JSON Lines",
+			query:  "?format=jsonl",
+			userID: 3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(poll, nil)
+				repo.On("GetResults", mock.Anything, int64(1)).Return(options, nil)
+				repo.On("StreamVotes", mock.Anything, int64(1), mock.Anything).Return(votes, nil)
+			},
+			expectedStatus:      http.StatusOK,
+			expectedDisposition: `attachment; filename="poll-1-results.jsonl"`,
+			expectedBody:        `{"table":"votes","voter_id":7,"option_id":1,"option_text":"Tabs","rank":0,"voted_at":"2025-05-18T09:30:00Z"}`,
+		},
+		{
+			name:   "Anonymous poll exports tallies only",
+			userID: 3,
+			mockSetup: func(repo *MockRepository) {
+				anonymous := *poll
+				anonymous.Anonymous = true
+				repo.On("GetByID", mock.Anything, int64(1)).Return(&anonymous, nil)
+				repo.On("GetResults", mock.Anything, int64(1)).Return(options, nil)
+			},
+			expectedStatus:      http.StatusOK,
+			expectedDisposition: `attachment; filename="poll-1-results.csv"`,
+			expectedBody: "option_id,text,votes,percentage,rank,winner\n" +
+				"1,Tabs,1,100,1,true\n" +
+				"2,Spaces,0,0,2,false\n",
+		},
+		{
+			name:   "Poll without vote export exports tallies only",
+			userID: 3,
+			mockSetup: func(repo *MockRepository) {
+				private := *poll
+				private.ExportVotes = false
+				repo.On("GetByID", mock.Anything, int64(1)).Return(&private, nil)
+				repo.On("GetResults", mock.Anything, int64(1)).Return(options, nil)
+			},
+			expectedStatus:      http.StatusOK,
+			expectedDisposition: `attachment; filename="poll-1-results.csv"`,
+			expectedBody: "option_id,text,votes,percentage,rank,winner\n" +
+				"1,Tabs,1,100,1,true\n" +
+				"2,Spaces,0,0,2,false\n",
+		},
+		{
+			name:   "Non-owner is forbidden",
+			userID: 4,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(poll, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"only the poll owner or an admin can export its results"`,
+		},
+		{
+			name:           "Unknown format",
+			query:          "?format=pdf",
+			userID:         3,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"format must be one of csv, jsonl, xlsx"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodGet, "/"+tt.query, "")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			addUserToken(c, tt.userID)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.ExportResults(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedDisposition, rec.Header().Get(echo.HeaderContentDisposition))
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_StreamResults(t *testing.T) {
+	poll := &Poll{ID: 1, Question: "Live?", Type: TypeSingle, Status: StatusOpen}
+	options := []Option{{ID: 1, PollID: 1, Text: "Yes", Votes: 2}, {ID: 2, PollID: 1, Text: "No", Votes: 1}}
+
+	t.Run("Sends a snapshot and an event per change", func(t *testing.T) {
+		// Setup
+		c, rec := setupEchoContext(http.MethodGet, "/", "")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		mockRepo := new(MockRepository)
+		mockRepo.On("GetByID", mock.Anything, int64(1)).Return(poll, nil)
+		mockRepo.On("GetResults", mock.Anything, int64(1)).Return(options, nil)
+
+		broker := NewLocalBroker()
+		service := NewService(mockRepo)
+		service.Events = broker
+
+		// Execute
+		done := make(chan error)
+		go func() { done <- service.StreamResults(c) }()
+
+		require.Eventually(t, func() bool { return broker.Subscribers(1) == 1 }, time.Second, time.Millisecond)
+		assert.NoError(t, broker.Publish(context.Background(), 1))
+		assert.NoError(t, broker.Close())
+
+		// Assert
+		assert.NoError(t, <-done)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/event-stream", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, 2, strings.Count(rec.Body.String(), "event: results\n"))
+		assert.Contains(t, rec.Body.String(), `data: {"poll_id":1,"question":"Live?","total_votes":3`)
+
+		// Verify mocks
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Sends heartbeats until the client disconnects", func(t *testing.T) {
+		// Setup
+		ctx, cancel := context.WithCancel(context.Background())
+		c, rec := setupEchoContext(http.MethodGet, "/", "")
+		c.SetRequest(c.Request().WithContext(ctx))
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		mockRepo := new(MockRepository)
+		mockRepo.On("GetByID", mock.Anything, int64(1)).Return(poll, nil)
+		mockRepo.On("GetResults", mock.Anything, int64(1)).Return(options, nil)
+
+		service := NewService(mockRepo)
+		service.Heartbeat = 5 * time.Millisecond
+
+		// Execute
+		done := make(chan error)
+		go func() { done <- service.StreamResults(c) }()
+		time.Sleep(30 * time.Millisecond)
+		cancel()
+
+		// Assert
+		assert.NoError(t, <-done)
+		assert.Contains(t, rec.Body.String(), ": heartbeat\n\n")
+		assert.Equal(t, 0, service.Events.(*LocalBroker).Subscribers(1))
+	})
+
+	t.Run("Ends once access is lost", func(t *testing.T) {
+		inviteOnly := &Poll{ID: 1, UserID: 3, Question: "Live?", Type: TypeSingle, Status: StatusOpen, Visibility: VisibilityInviteOnly}
+		ownerOnly := *inviteOnly
+		ownerOnly.ResultsVisibility = ResultsOwnerOnly
+
+		tests := []struct {
+			name      string
+			mockSetup func(*MockRepository)
+		}{
+			{
+				name: "Results hidden",
+				mockSetup: func(repo *MockRepository) {
+					repo.On("GetByID", mock.Anything, int64(1)).Return(inviteOnly, nil).Once()
+					repo.On("GetByID", mock.Anything, int64(1)).Return(&ownerOnly, nil)
+					repo.On("IsInvited", mock.Anything, int64(1), int64(4)).Return(true, nil)
+				},
+			},
+			{
+				name: "Invite revoked",
+				mockSetup: func(repo *MockRepository) {
+					repo.On("GetByID", mock.Anything, int64(1)).Return(inviteOnly, nil)
+					repo.On("IsInvited", mock.Anything, int64(1), int64(4)).Return(false, nil)
+				},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				// Setup
+				c, rec := setupEchoContext(http.MethodGet, "/", "")
+				c.SetParamNames("id")
+				c.SetParamValues("1")
+				addUserToken(c, 4)
+
+				mockRepo := new(MockRepository)
+				tt.mockSetup(mockRepo)
+				mockRepo.On("GetResults", mock.Anything, int64(1)).Return(options, nil)
+
+				broker := NewLocalBroker()
+				service := NewService(mockRepo)
+				service.Events = broker
+
+				// Execute
+				done := make(chan error)
+				go func() { done <- service.StreamResults(c) }()
+
+				require.Eventually(t, func() bool { return broker.Subscribers(1) == 1 }, time.Second, time.Millisecond)
+				assert.NoError(t, broker.Publish(context.Background(), 1))
+
+				// Assert: the stream ends without sending the change
+				assert.NoError(t, <-done)
+				assert.Equal(t, 1, strings.Count(rec.Body.String(), "event: results\n"))
+				assert.NoError(t, broker.Close())
+			})
+		}
+	})
+
+	t.Run("Poll not found", func(t *testing.T) {
+		// Setup
+		c, rec := setupEchoContext(http.MethodGet, "/", "")
+		c.SetParamNames("id")
+		c.SetParamValues("999")
+
+		mockRepo := new(MockRepository)
+		mockRepo.On("GetByID", mock.Anything, int64(999)).Return(nil, errs.NotFound(errors.New("poll not found")))
+
+		service := NewService(mockRepo)
+
+		// Execute
+		err := service.StreamResults(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestService_InviteUsers(t *testing.T) {
+	inviteOnly := &Poll{ID: 1, UserID: 3, Visibility: VisibilityInviteOnly, Status: StatusOpen}
+
+	tests := []struct {
+		name           string
+		userID         int64
+		requestBody    string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Owner invites users",
+			userID:      3,
+			requestBody: `{"user_ids":[4,7]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(inviteOnly, nil)
+				repo.On("AddInvites", mock.Anything, int64(1), []int64{4, 7}).Return(nil)
+				repo.On("ListInvites", mock.Anything, int64(1)).Return([]int64{2, 4, 7}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"poll_id":1,"user_ids":[2,4,7]`,
+		},
+		{
+			name:        "Non-owner is forbidden",
+			userID:      4,
+			requestBody: `{"user_ids":[4]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(inviteOnly, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"only the poll owner or an admin can invite users"`,
+		},
+		{
+			name:        "Public poll",
+			userID:      3,
+			requestBody: `{"user_ids":[4]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(&Poll{ID: 1, UserID: 3, Visibility: VisibilityPublic}, nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"invites only apply to invite-only polls"`,
+		},
+		{
+			name:           "No users",
+			userID:         3,
+			requestBody:    `{"user_ids":[]}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"user_ids is required"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodPost, "/", tt.requestBody)
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			addUserToken(c, tt.userID)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.InviteUsers(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_RemoveInvite(t *testing.T) {
+	// Setup
+	c, rec := setupEchoContext(http.MethodDelete, "/", "")
+	c.SetParamNames("id", "user_id")
+	c.SetParamValues("1", "4")
+	addUserToken(c, 3)
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetByID", mock.Anything, int64(1)).Return(&Poll{ID: 1, UserID: 3, Visibility: VisibilityInviteOnly}, nil)
+	mockRepo.On("RemoveInvite", mock.Anything, int64(1), int64(4)).Return(nil)
+
+	service := NewService(mockRepo)
+
+	// Execute
+	err := service.RemoveInvite(c)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Invite withdrawn successfully")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_CreateInviteLink(t *testing.T) {
+	// Setup
+	c, rec := setupEchoContext(http.MethodPost, "/", "")
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+	addUserToken(c, 3)
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetByID", mock.Anything, int64(1)).Return(&Poll{ID: 1, UserID: 3, Visibility: VisibilityInviteOnly}, nil)
+
+	service := NewService(mockRepo)
+	service.InviteSecret = []byte("invite-secret")
+	service.InviteTTL = time.Hour
+
+	// Execute
+	err := service.CreateInviteLink(c)
+
+	// Assert the signed token opens the poll until it expires
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var resp struct {
+		Data InviteLinkResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, int64(1), resp.Data.PollID)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), resp.Data.ExpiresAt, time.Minute)
+	assert.True(t, validInviteToken(service.InviteSecret, 1, resp.Data.Token, time.Now()))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_ClonePoll(t *testing.T) {
+	closesAt := time.Now().Add(-time.Hour)
+	poll := &Poll{ID: 1, UserID: 3, Question: "Favorite language?", Type: TypeSingle, Status: StatusClosed,
+		ClosesAt: &closesAt, Visibility: VisibilityUnlisted, Slug: "old-slug", ResultsVisibility: ResultsAlways,
+		Tags: []string{"go"}, TotalVotes: 12,
+		Options: []Option{{ID: 1, PollID: 1, Text: "Go", Votes: 8}, {ID: 2, PollID: 1, Text: "Rust", Votes: 4}}}
+
+	tests := []struct {
+		name           string
+		userID         int64
+		admin          bool
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Owner clones poll into a draft without votes",
+			userID: 3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(poll, nil)
+				repo.On("Create", mock.Anything, mock.MatchedBy(func(p *Poll) bool {
+					return p.ID == 0 && p.UserID == 3 && p.Status == StatusDraft && p.ClosesAt == nil &&
+						p.Question == "Favorite language?" && len(p.Options) == 2 &&
+						p.Options[0].ID == 0 && p.Options[0].Votes == 0 && p.TotalVotes == 0 &&
+						p.Visibility == VisibilityUnlisted && p.Slug != "" && p.Slug != "old-slug" &&
+						len(p.Tags) == 1
+				})).Return(nil).Run(func(args mock.Arguments) {
+					args.Get(1).(*Poll).ID = 2
+				})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"poll":{"id":2,"question":"Favorite language?"`,
+		},
+		{
+			name:   "Admin clones poll as their own",
+			userID: 9,
+			admin:  true,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(poll, nil)
+				repo.On("Create", mock.Anything, mock.MatchedBy(func(p *Poll) bool {
+					return p.UserID == 9
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"status":"draft"`,
+		},
+		{
+			name:   "Non-owner is forbidden",
+			userID: 4,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(poll, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"only the poll owner or an admin can clone this poll"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodPost, "/", "")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			if tt.admin {
+				addAdminToken(c, tt.userID)
+			} else {
+				addUserToken(c, tt.userID)
+			}
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.ClonePoll(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_CreateTemplate(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Saves template",
+			requestBody: `{"name": "Retro", "question": "How was week {{week}} for {{team}}?", "options": ["Great", "Rough"]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("CreateTemplate", mock.Anything, mock.MatchedBy(func(tmpl *PollTemplate) bool {
+					return tmpl.UserID == 3 && tmpl.Name == "Retro" && len(tmpl.Options) == 2
+				})).Return(nil).Run(func(args mock.Arguments) {
+					args.Get(1).(*PollTemplate).ID = 5
+				})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"placeholders":["week","team"]`,
+		},
+		{
+			name:           "Invalid settings",
+			requestBody:    `{"name": "Retro", "question": "Q", "options": ["A", "B"], "visibility": "secret"}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"visibility must be one of public, unlisted, invite_only"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodPost, "/", tt.requestBody)
+			addUserToken(c, 3)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.CreateTemplate(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_GetTemplate(t *testing.T) {
+	tmpl := &PollTemplate{ID: 5, UserID: 3, Name: "Retro"}
+
+	tests := []struct {
+		name           string
+		userID         int64
+		admin          bool
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Owner gets template",
+			userID: 3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetTemplate", mock.Anything, int64(5)).Return(tmpl, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"name":"Retro"`,
+		},
+		{
+			name:   "Admin gets template",
+			userID: 9,
+			admin:  true,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetTemplate", mock.Anything, int64(5)).Return(tmpl, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"name":"Retro"`,
+		},
+		{
+			name:   "Non-owner is forbidden",
+			userID: 4,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetTemplate", mock.Anything, int64(5)).Return(tmpl, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"only the template owner or an admin can use this template"`,
+		},
+		{
+			name:   "Template not found",
+			userID: 3,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetTemplate", mock.Anything, int64(5)).Return(nil, errs.NotFound(errors.New("template not found")))
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodGet, "/", "")
+			c.SetParamNames("template_id")
+			c.SetParamValues("5")
+			if tt.admin {
+				addAdminToken(c, tt.userID)
+			} else {
+				addUserToken(c, tt.userID)
+			}
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.GetTemplate(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_CreatePollFromTemplate(t *testing.T) {
+	_, week := time.Now().UTC().ISOWeek()
+	tmpl := &PollTemplate{ID: 5, UserID: 3, Name: "Retro", Question: "How was week {{week}} for {{team}}?",
+		Options: []TemplateOption{{Text: "Great"}, {Text: "Rough"}}, Type: TypeSingle,
+		Visibility: VisibilityPublic, ResultsVisibility: ResultsAfterVote}
+
+	tests := []struct {
+		name           string
+		requestBody    string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Fills placeholders",
+			requestBody: `{"values": {"team": "Platform"}, "status": "draft", "tags": ["retro"]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetTemplate", mock.Anything, int64(5)).Return(tmpl, nil)
+				repo.On("Create", mock.Anything, mock.MatchedBy(func(p *Poll) bool {
+					return p.UserID == 3 && p.Status == StatusDraft && len(p.Options) == 2 &&
+						p.ResultsVisibility == ResultsAfterVote && len(p.Tags) == 1
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"question":"How was week ` + strconv.Itoa(week) + ` for Platform?"`,
+		},
+		{
+			name:        "Values override built-in placeholders",
+			requestBody: `{"values": {"team": "Platform", "week": "52"}}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetTemplate", mock.Anything, int64(5)).Return(tmpl, nil)
+				repo.On("Create", mock.Anything, mock.Anything).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"question":"How was week 52 for Platform?"`,
+		},
+		{
+			name:        "Missing placeholder value",
+			requestBody: `{}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetTemplate", mock.Anything, int64(5)).Return(tmpl, nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"no value for placeholder {{team}}"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodPost, "/", tt.requestBody)
+			c.SetParamNames("template_id")
+			c.SetParamValues("5")
+			addUserToken(c, 3)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.CreatePollFromTemplate(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package poll
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+)
+
+// defaultMaxTags is the number of tags a poll can carry when the service is
+// not configured otherwise.
+const defaultMaxTags = 5
+
+// maxTagLength is the longest tag name, in characters.
+const maxTagLength = 32
+
+// defaultTagsLimit is the number of tags listed when no limit is given.
+const defaultTagsLimit = 20
+
+// tagPattern matches a normalized tag: lowercase words of ASCII letters and
+// digits joined by single dashes.
+var tagPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// normalizeTag lowercases a tag, drops a leading '#' and joins its words
+// with dashes, so "#Machine Learning" and "machine-learning" are one tag.
+func normalizeTag(tag string) string {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+	return strings.ToLower(strings.Join(strings.FieldsFunc(tag, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '_' || r == '-'
+	}), "-"))
+}
+
+// normalizeTags normalizes, validates and deduplicates the tags of a poll,
+// returning them sorted as they are read back from the database.
+func normalizeTags(tags []string, max int) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	out := make([]string, 0, len(tags))
+	for _, raw := range tags {
+		tag := normalizeTag(raw)
+		if len(tag) > maxTagLength {
+			return nil, errs.BaseErr(fmt.Sprintf("tags can be at most %d characters", maxTagLength))
+		}
+		if !tagPattern.MatchString(tag) {
+			return nil, errs.BaseErr(fmt.Sprintf("invalid tag %q: tags may only contain ASCII letters, digits and dashes", raw))
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			out = append(out, tag)
+		}
+	}
+	if len(out) > max {
+		return nil, errs.BaseErr(fmt.Sprintf("a poll can have at most %d tags", max))
+	}
+	sort.Strings(out)
+	return out, nil
+}
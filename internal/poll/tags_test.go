@@ -0,0 +1,65 @@
+package poll
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name        string
+		tags        []string
+		expected    []string
+		expectedErr string
+	}{
+		{
+			name:     "Normalizes, deduplicates and sorts",
+			tags:     []string{"#Machine Learning", "go", "machine-learning", "  GO "},
+			expected: []string{"go", "machine-learning"},
+		},
+		{
+			name:     "No tags",
+			tags:     nil,
+			expected: []string{},
+		},
+		{
+			name:        "Invalid characters",
+			tags:        []string{"c++"},
+			expectedErr: `invalid tag "c++": tags may only contain ASCII letters, digits and dashes`,
+		},
+		{
+			name:        "Non-ASCII letters",
+			tags:        []string{"café"},
+			expectedErr: `invalid tag "café": tags may only contain ASCII letters, digits and dashes`,
+		},
+		{
+			name:        "Blank tag",
+			tags:        []string{"  "},
+			expectedErr: "tags may only contain ASCII letters, digits and dashes",
+		},
+		{
+			name:        "Too long",
+			tags:        []string{"a-very-long-tag-name-that-goes-on-and-on"},
+			expectedErr: "tags can be at most 32 characters",
+		},
+		{
+			name:        "Too many",
+			tags:        []string{"a", "b", "c", "d"},
+			expectedErr: "a poll can have at most 3 tags",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags, err := normalizeTags(tt.tags, 3)
+			if tt.expectedErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, tags)
+		})
+	}
+}
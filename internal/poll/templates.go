@@ -0,0 +1,197 @@
+package poll
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+)
+
+// maxTemplateNameLength is the longest template name accepted, in characters.
+const maxTemplateNameLength = 100
+
+// placeholderPattern matches a template placeholder such as {{week}} or
+// {{ team_name }}.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z][A-Za-z0-9_]*)\s*\}\}`)
+
+// templatePlaceholders lists the placeholders used in texts, in order of
+// first use.
+func templatePlaceholders(texts ...string) []string {
+	names := []string{}
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, m := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				names = append(names, m[1])
+			}
+		}
+	}
+	return names
+}
+
+// setPlaceholders fills in the placeholders of a template from its question
+// and options.
+func setPlaceholders(t *PollTemplate) {
+	texts := make([]string, 0, len(t.Options)+1)
+	texts = append(texts, t.Question)
+	for _, opt := range t.Options {
+		texts = append(texts, opt.Text)
+	}
+	t.Placeholders = templatePlaceholders(texts...)
+}
+
+// builtinValues are the placeholder values every template can use: the
+// date, ISO week number and calendar year of now.
+func builtinValues(now time.Time) map[string]string {
+	_, week := now.ISOWeek()
+	return map[string]string{
+		"date": now.Format("2006-01-02"),
+		"week": strconv.Itoa(week),
+		"year": strconv.Itoa(now.Year()),
+	}
+}
+
+// fillPlaceholders replaces the placeholders in text with their values. A
+// placeholder without a value is an error.
+func fillPlaceholders(text string, values map[string]string) (string, error) {
+	var missing string
+	filled := placeholderPattern.ReplaceAllStringFunc(text, func(m string) string {
+		name := placeholderPattern.FindStringSubmatch(m)[1]
+		v, ok := values[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return v
+	})
+	if missing != "" {
+		return "", errs.BaseErr(fmt.Sprintf("no value for placeholder {{%s}}", missing))
+	}
+	return filled, nil
+}
+
+// templatePollRequest turns a template into a create request, filling in its
+// placeholders with values.
+func templatePollRequest(t *PollTemplate, values map[string]string) (CreatePollRequest, error) {
+	question, err := fillPlaceholders(t.Question, values)
+	if err != nil {
+		return CreatePollRequest{}, err
+	}
+	req := CreatePollRequest{
+		Question:          question,
+		Options:           make([]string, len(t.Options)),
+		PollType:          t.Type,
+		MinSelections:     t.MinSelections,
+		MaxSelections:     t.MaxSelections,
+		AllowVoteChange:   t.AllowVoteChange,
+		Anonymous:         t.Anonymous,
+		Quiz:              t.Quiz,
+		Visibility:        t.Visibility,
+		ResultsVisibility: t.ResultsVisibility,
+	}
+	for i, opt := range t.Options {
+		text, err := fillPlaceholders(opt.Text, values)
+		if err != nil {
+			return CreatePollRequest{}, err
+		}
+		req.Options[i] = text
+		if opt.Correct {
+			req.CorrectOptions = append(req.CorrectOptions, CorrectOption{Index: i, Points: opt.Points})
+		}
+	}
+	return req, nil
+}
+
+// newTemplate validates a template request and builds the template it
+// describes, owned by userID. The settings are checked by building a poll
+// from the template with every placeholder filled in with its own name, so
+// a template that saves can always be used.
+func (s *Service) newTemplate(req CreateTemplateRequest, userID int64) (*PollTemplate, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errs.BaseErr("name is required")
+	}
+	if len([]rune(name)) > maxTemplateNameLength {
+		return nil, errs.BaseErr(fmt.Sprintf("name can be at most %d characters", maxTemplateNameLength))
+	}
+
+	t := &PollTemplate{
+		UserID:   userID,
+		Name:     name,
+		Question: req.Question,
+		Options:  make([]TemplateOption, len(req.Options)),
+	}
+	for i, text := range req.Options {
+		t.Options[i] = TemplateOption{Text: text}
+	}
+	setPlaceholders(t)
+
+	sample := make(map[string]string, len(t.Placeholders))
+	for _, name := range t.Placeholders {
+		sample[name] = name
+	}
+	preq := CreatePollRequest{
+		Options:           make([]string, len(req.Options)),
+		PollType:          req.PollType,
+		MinSelections:     req.MinSelections,
+		MaxSelections:     req.MaxSelections,
+		AllowVoteChange:   req.AllowVoteChange,
+		Anonymous:         req.Anonymous,
+		Quiz:              req.Quiz,
+		CorrectOptions:    req.CorrectOptions,
+		Visibility:        req.Visibility,
+		ResultsVisibility: req.ResultsVisibility,
+	}
+	preq.Question, _ = fillPlaceholders(req.Question, sample)
+	for i, text := range req.Options {
+		preq.Options[i], _ = fillPlaceholders(text, sample)
+	}
+	p, err := s.newPoll(preq, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Type = p.Type
+	t.MinSelections = p.MinSelections
+	t.MaxSelections = p.MaxSelections
+	t.AllowVoteChange = p.AllowVoteChange
+	t.Anonymous = p.Anonymous
+	t.Quiz = p.Quiz
+	t.Visibility = p.Visibility
+	t.ResultsVisibility = p.ResultsVisibility
+	for i, opt := range p.Options {
+		t.Options[i].Correct = opt.Correct
+		t.Options[i].Points = opt.Points
+	}
+	return t, nil
+}
+
+// clonePollRequest turns a poll into a request for a fresh draft with the
+// same question, options and settings. Votes, the voting window, follow-ups
+// and invites are not carried over.
+func clonePollRequest(p *Poll) CreatePollRequest {
+	req := CreatePollRequest{
+		Question:          p.Question,
+		Options:           make([]string, len(p.Options)),
+		PollType:          p.Type,
+		MinSelections:     p.MinSelections,
+		MaxSelections:     p.MaxSelections,
+		AllowVoteChange:   p.AllowVoteChange,
+		Anonymous:         p.Anonymous,
+		Quiz:              p.Quiz,
+		Visibility:        p.Visibility,
+		ResultsVisibility: p.ResultsVisibility,
+		Tags:              p.Tags,
+		Status:            StatusDraft,
+	}
+	for i, opt := range p.Options {
+		req.Options[i] = opt.Text
+		if opt.Correct {
+			req.CorrectOptions = append(req.CorrectOptions, CorrectOption{Index: i, Points: opt.Points})
+		}
+	}
+	return req
+}
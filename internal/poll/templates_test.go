@@ -0,0 +1,151 @@
+package poll
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplatePlaceholders(t *testing.T) {
+	names := templatePlaceholders("Week {{week}} retro for {{ team }}", "{{team}} did well", "{{ not a placeholder }}", "{{year}}")
+	assert.Equal(t, []string{"week", "team", "year"}, names)
+	assert.Equal(t, []string{}, templatePlaceholders("No placeholders"))
+}
+
+func TestBuiltinValues(t *testing.T) {
+	// 2024-12-30 falls in ISO week 1 of 2025
+	values := builtinValues(time.Date(2024, 12, 30, 10, 0, 0, 0, time.UTC))
+	assert.Equal(t, map[string]string{"date": "2024-12-30", "week": "1", "year": "2024"}, values)
+}
+
+func TestFillPlaceholders(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		values      map[string]string
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:     "Fills every placeholder",
+			text:     "Week {{week}} retro for {{ team }}",
+			values:   map[string]string{"week": "7", "team": "Platform"},
+			expected: "Week 7 retro for Platform",
+		},
+		{
+			name:     "Values are not expanded again",
+			text:     "{{team}}",
+			values:   map[string]string{"team": "{{week}}"},
+			expected: "{{week}}",
+		},
+		{
+			name:        "Missing value",
+			text:        "Week {{week}} retro for {{team}}",
+			values:      map[string]string{"week": "7"},
+			expectedErr: "no value for placeholder {{team}}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := fillPlaceholders(tt.text, tt.values)
+			if tt.expectedErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, text)
+		})
+	}
+}
+
+func TestNewTemplate(t *testing.T) {
+	s := NewService(new(MockRepository))
+
+	tests := []struct {
+		name        string
+		req         CreateTemplateRequest
+		expected    *PollTemplate
+		expectedErr string
+	}{
+		{
+			name: "Applies poll defaults and keeps placeholders",
+			req: CreateTemplateRequest{
+				Name:           " Weekly quiz ",
+				Question:       "Who won in week {{week}}?",
+				Options:        []string{"{{team}}", "Nobody"},
+				Quiz:           true,
+				CorrectOptions: []CorrectOption{{Index: 0, Points: 5}},
+			},
+			expected: &PollTemplate{
+				UserID:            3,
+				Name:              "Weekly quiz",
+				Question:          "Who won in week {{week}}?",
+				Options:           []TemplateOption{{Text: "{{team}}", Correct: true, Points: 5}, {Text: "Nobody"}},
+				Type:              TypeSingle,
+				Quiz:              true,
+				Visibility:        VisibilityPublic,
+				ResultsVisibility: ResultsAlways,
+				Placeholders:      []string{"week", "team"},
+			},
+		},
+		{
+			name:        "Missing name",
+			req:         CreateTemplateRequest{Question: "Q", Options: []string{"A", "B"}},
+			expectedErr: "name is required",
+		},
+		{
+			name:        "Invalid settings",
+			req:         CreateTemplateRequest{Name: "T", Question: "Q", Options: []string{"A", "B"}, PollType: "approval"},
+			expectedErr: "poll_type must be one of single, multi, ranked",
+		},
+		{
+			name:        "Too few options",
+			req:         CreateTemplateRequest{Name: "T", Question: "Q", Options: []string{"{{only}}"}},
+			expectedErr: "at least two options are required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := s.newTemplate(tt.req, 3)
+			if tt.expectedErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, tmpl)
+		})
+	}
+}
+
+func TestClonePollRequest(t *testing.T) {
+	closesAt := time.Now().Add(time.Hour)
+	p := &Poll{
+		ID: 7, UserID: 3, Question: "Best language?", Type: TypeMulti, MinSelections: 1, MaxSelections: 2,
+		Quiz: true, Status: StatusClosed, ClosesAt: &closesAt, Visibility: VisibilityUnlisted, Slug: "abc",
+		ResultsVisibility: ResultsAfterVote, Tags: []string{"go"}, TotalVotes: 40,
+		Options: []Option{
+			{ID: 1, PollID: 7, Text: "Go", Votes: 30, Correct: true, Points: 2},
+			{ID: 2, PollID: 7, Text: "Rust", Votes: 10},
+		},
+	}
+
+	req := clonePollRequest(p)
+	assert.Equal(t, CreatePollRequest{
+		Question:          "Best language?",
+		Options:           []string{"Go", "Rust"},
+		PollType:          TypeMulti,
+		MinSelections:     1,
+		MaxSelections:     2,
+		Quiz:              true,
+		CorrectOptions:    []CorrectOption{{Index: 0, Points: 2}},
+		Visibility:        VisibilityUnlisted,
+		ResultsVisibility: ResultsAfterVote,
+		Tags:              []string{"go"},
+		Status:            StatusDraft,
+	}, req)
+}
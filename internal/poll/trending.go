@@ -0,0 +1,141 @@
+package poll
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/phsaurav/echo_prod_blueprint/config"
+)
+
+// Trending defaults, used when the configuration leaves a setting unset.
+const (
+	defaultTrendingWindow  = 48 * time.Hour
+	defaultTrendingGravity = 1.8
+	defaultTrendingRefresh = time.Minute
+	defaultTrendingSize    = 100
+)
+
+// defaultTrendingLimit is the number of trending polls returned when no
+// limit is given.
+const defaultTrendingLimit = 20
+
+// TrendingRanker keeps a cached ranking of the polls gaining votes fastest.
+// A background worker recomputes it every Refresh, so requests only ever
+// read the cache.
+type TrendingRanker struct {
+	Repo    Repository
+	Window  time.Duration
+	Gravity float64
+	Refresh time.Duration
+	// Size is the number of polls kept in the ranking.
+	Size int
+
+	mu         sync.RWMutex
+	polls      []TrendingPoll
+	computedAt time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewTrendingRanker creates a ranker reading votes from repo. Call Start to
+// run its background worker.
+func NewTrendingRanker(repo Repository, cfg config.TrendingConfig) *TrendingRanker {
+	r := &TrendingRanker{
+		Repo:    repo,
+		Window:  defaultTrendingWindow,
+		Gravity: defaultTrendingGravity,
+		Refresh: defaultTrendingRefresh,
+		Size:    defaultTrendingSize,
+	}
+	if cfg.Window > 0 {
+		r.Window = cfg.Window
+	}
+	if cfg.Gravity > 0 {
+		r.Gravity = cfg.Gravity
+	}
+	if cfg.Refresh > 0 {
+		r.Refresh = cfg.Refresh
+	}
+	if cfg.Size > 0 {
+		r.Size = cfg.Size
+	}
+	return r
+}
+
+// Start launches the background worker, which ranks the polls right away
+// and then every Refresh until Close is called.
+func (r *TrendingRanker) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go r.run(ctx)
+}
+
+func (r *TrendingRanker) run(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.Refresh)
+	defer ticker.Stop()
+	for {
+		if err := r.Recompute(ctx); err != nil && ctx.Err() == nil {
+			logging.Errorf("Recomputing trending polls failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Recompute ranks the polls now and replaces the cached ranking. On failure
+// the previous ranking is kept.
+func (r *TrendingRanker) Recompute(ctx context.Context) error {
+	polls, err := r.Repo.GetTrending(ctx, r.Window, r.Gravity, r.Size)
+	if err != nil {
+		return err
+	}
+
+	// The ranking is shared by every caller, and a poll's place in it
+	// reveals its vote counts, so only polls whose results anyone may see
+	// are ranked
+	now := time.Now().UTC()
+	ranked := polls[:0]
+	for _, p := range polls {
+		if resultsPublic(&p.Poll, now) {
+			ranked = append(ranked, p)
+		}
+	}
+	polls = ranked
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.polls = polls
+	r.computedAt = now
+	return nil
+}
+
+// Top returns up to limit polls of the cached ranking and when it was
+// computed. The time is zero until the first ranking succeeds.
+func (r *TrendingRanker) Top(limit int) ([]TrendingPoll, time.Time) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if limit > len(r.polls) {
+		limit = len(r.polls)
+	}
+	polls := make([]TrendingPoll, limit)
+	copy(polls, r.polls)
+	return polls, r.computedAt
+}
+
+// Close stops the background worker.
+func (r *TrendingRanker) Close() error {
+	if r.cancel != nil {
+		r.cancel()
+		<-r.done
+	}
+	return nil
+}
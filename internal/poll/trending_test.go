@@ -0,0 +1,97 @@
+package poll
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/phsaurav/echo_prod_blueprint/config"
+)
+
+func TestNewTrendingRanker(t *testing.T) {
+	// Unset settings fall back to the defaults
+	r := NewTrendingRanker(new(MockRepository), config.TrendingConfig{Gravity: 1.5})
+	assert.Equal(t, defaultTrendingWindow, r.Window)
+	assert.Equal(t, 1.5, r.Gravity)
+	assert.Equal(t, defaultTrendingRefresh, r.Refresh)
+	assert.Equal(t, defaultTrendingSize, r.Size)
+}
+
+func TestTrendingRanker_Recompute(t *testing.T) {
+	mockRepo := new(MockRepository)
+	r := NewTrendingRanker(mockRepo, config.TrendingConfig{Window: time.Hour, Size: 3})
+
+	// Nothing is ranked before the first computation
+	polls, computedAt := r.Top(10)
+	assert.Empty(t, polls)
+	assert.True(t, computedAt.IsZero())
+
+	ranked := []TrendingPoll{
+		{Poll: Poll{ID: 3}, Score: 2.5},
+		{Poll: Poll{ID: 1}, Score: 1.2},
+		{Poll: Poll{ID: 2}, Score: 0.3},
+	}
+	mockRepo.On("GetTrending", mock.Anything, time.Hour, defaultTrendingGravity, 3).Return(ranked, nil).Once()
+	require.NoError(t, r.Recompute(t.Context()))
+
+	polls, computedAt = r.Top(2)
+	assert.Equal(t, ranked[:2], polls)
+	assert.False(t, computedAt.IsZero())
+
+	// A failed computation keeps serving the previous ranking
+	mockRepo.On("GetTrending", mock.Anything, time.Hour, defaultTrendingGravity, 3).Return(nil, errors.New("database error")).Once()
+	assert.Error(t, r.Recompute(t.Context()))
+
+	polls, _ = r.Top(10)
+	assert.Equal(t, ranked, polls)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTrendingRanker_Recompute_SkipsHiddenResults(t *testing.T) {
+	mockRepo := new(MockRepository)
+	r := NewTrendingRanker(mockRepo, config.TrendingConfig{Size: 3})
+
+	mockRepo.On("GetTrending", mock.Anything, defaultTrendingWindow, defaultTrendingGravity, 3).Return([]TrendingPoll{
+		{Poll: Poll{ID: 3, ResultsVisibility: ResultsOwnerOnly}, Score: 2.5, RecentVotes: 40},
+		{Poll: Poll{ID: 1, ResultsVisibility: ResultsAlways}, Score: 1.2, RecentVotes: 12},
+		{Poll: Poll{ID: 2, ResultsVisibility: ResultsAfterVote}, Score: 0.3, RecentVotes: 2},
+	}, nil)
+	require.NoError(t, r.Recompute(t.Context()))
+
+	polls, _ := r.Top(3)
+	assert.Equal(t, []TrendingPoll{
+		{Poll: Poll{ID: 1, ResultsVisibility: ResultsAlways}, Score: 1.2, RecentVotes: 12},
+	}, polls)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTrendingRanker_StartClose(t *testing.T) {
+	mockRepo := new(MockRepository)
+	computed := make(chan struct{}, 1)
+	mockRepo.On("GetTrending", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]TrendingPoll{{Poll: Poll{ID: 1}, Score: 1}}, nil).
+		Run(func(mock.Arguments) {
+			select {
+			case computed <- struct{}{}:
+			default:
+			}
+		})
+
+	r := NewTrendingRanker(mockRepo, config.TrendingConfig{Refresh: time.Hour})
+	r.Start()
+
+	// The worker ranks right away instead of waiting for the first tick
+	select {
+	case <-computed:
+	case <-time.After(time.Second):
+		t.Fatal("trending ranking was not computed on start")
+	}
+	assert.NoError(t, r.Close())
+
+	polls, _ := r.Top(10)
+	assert.Len(t, polls, 1)
+}
@@ -0,0 +1,229 @@
+package poll
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/phsaurav/echo_prod_blueprint/pkg/auth"
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/response"
+)
+
+// slugBytes is the amount of randomness in the slug of an unlisted poll.
+const slugBytes = 12
+
+// defaultInviteTTL is how long an invite link stays valid when the service
+// is not configured otherwise.
+const defaultInviteTTL = 7 * 24 * time.Hour
+
+// resolvedBySlug is the context key under which ResolvePoll records whether
+// the poll was reached by its slug.
+const resolvedBySlug = "poll_by_slug"
+
+// ResolvePoll middleware looks up the poll named by the id path parameter,
+// which is either its numeric ID or the slug of an unlisted poll, and checks
+// that the caller may see it. The parameter is rewritten to the numeric ID
+// so the handlers behind it need not know about slugs. Polls the caller may
+// not see are reported as not found, so their existence is not leaked.
+func (s *Service) ResolvePoll(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		ref := c.Param("id")
+
+		var poll *Poll
+		var err error
+		id, parseErr := strconv.ParseInt(ref, 10, 64)
+		bySlug := parseErr != nil
+		if bySlug {
+			poll, err = s.Repo.GetBySlug(ctx, ref)
+		} else {
+			poll, err = s.Repo.GetByID(ctx, id)
+		}
+		if err != nil {
+			return response.ErrorBuilder(err).Send(c)
+		}
+		if err := s.checkAccess(c, poll, bySlug); err != nil {
+			return response.ErrorBuilder(err).Send(c)
+		}
+		// Handlers that check access again later, such as live streams,
+		// need to know how the poll was reached
+		c.Set(resolvedBySlug, bySlug)
+
+		names, values := c.ParamNames(), c.ParamValues()
+		for i, name := range names {
+			if name == "id" && i < len(values) {
+				values[i] = strconv.FormatInt(poll.ID, 10)
+			}
+		}
+		c.SetParamValues(values...)
+		return next(c)
+	}
+}
+
+// checkAccess returns a NotFound error unless the caller may see the poll:
+// managers see every poll, unlisted polls must be reached by slug and
+// invite-only polls need an invite or a valid invite link.
+func (s *Service) checkAccess(c echo.Context, p *Poll, bySlug bool) error {
+	if canManage(c, p) {
+		return nil
+	}
+
+	switch p.Visibility {
+	case VisibilityUnlisted:
+		if bySlug {
+			return nil
+		}
+	case VisibilityInviteOnly:
+		if token := c.QueryParam("invite"); token != "" && validInviteToken(s.InviteSecret, p.ID, token, time.Now()) {
+			return nil
+		}
+		if userID, ok := auth.ViewerID(c); ok {
+			invited, err := s.Repo.IsInvited(c.Request().Context(), p.ID, userID)
+			if err != nil {
+				return err
+			}
+			if invited {
+				return nil
+			}
+		}
+	default:
+		return nil
+	}
+	return errs.NotFound(errors.New("poll not found"))
+}
+
+// setVisibility validates and applies a visibility to p, giving it a slug
+// the first time it becomes unlisted.
+func setVisibility(p *Poll, visibility string) error {
+	switch visibility {
+	case VisibilityPublic, VisibilityInviteOnly:
+	case VisibilityUnlisted:
+		if p.Slug == "" {
+			slug, err := newSlug()
+			if err != nil {
+				return err
+			}
+			p.Slug = slug
+		}
+	default:
+		return errs.BaseErr("visibility must be one of public, unlisted, invite_only")
+	}
+	p.Visibility = visibility
+	return nil
+}
+
+// checkResultsVisibility validates a results visibility policy.
+func checkResultsVisibility(policy string) error {
+	switch policy {
+	case ResultsAlways, ResultsAfterVote, ResultsAfterClose, ResultsOwnerOnly:
+		return nil
+	}
+	return errs.BaseErr("results_visibility must be one of always, after_vote, after_close, owner_only")
+}
+
+// checkResultsAccess returns a Forbidden error explaining why the caller may
+// not see the poll's results yet, or nil if they may.
+func (s *Service) checkResultsAccess(c echo.Context, p *Poll) error {
+	if canManage(c, p) {
+		return nil
+	}
+
+	switch p.ResultsVisibility {
+	case ResultsOwnerOnly:
+		return errs.Forbidden(errors.New("results of this poll are only visible to its owner"))
+	case ResultsAfterClose:
+		if !p.IsFinal(time.Now()) {
+			return errs.Forbidden(errors.New("results are hidden until the poll closes"))
+		}
+	case ResultsAfterVote:
+		if p.IsFinal(time.Now()) {
+			return nil
+		}
+		userID, ok := auth.ViewerID(c)
+		if !ok {
+			return errs.Forbidden(errors.New("sign in and vote to see the results"))
+		}
+		voted, err := s.Repo.HasUserVoted(c.Request().Context(), p.ID, s.voterFor(p, userID))
+		if err != nil {
+			return errs.InternalServerError(err)
+		}
+		if !voted {
+			return errs.Forbidden(errors.New("results are shown once you have voted"))
+		}
+	}
+	return nil
+}
+
+// resultsPublic reports whether everyone who can see the poll may also see
+// its results, which is what live broadcasts to shared rooms rely on.
+func resultsPublic(p *Poll, now time.Time) bool {
+	switch p.ResultsVisibility {
+	case ResultsOwnerOnly:
+		return false
+	case ResultsAfterVote, ResultsAfterClose:
+		return p.IsFinal(now)
+	}
+	return true
+}
+
+// hideVoteCounts clears the vote totals of listed polls whose results the
+// caller may not see. Whether the caller voted is not looked up per poll,
+// so after_vote totals stay hidden from voters too until the poll closes.
+func hideVoteCounts(c echo.Context, polls []Poll, now time.Time) {
+	for i := range polls {
+		if !canManage(c, &polls[i]) && !resultsPublic(&polls[i], now) {
+			polls[i].TotalVotes = 0
+		}
+	}
+}
+
+// newSlug generates an opaque, URL-safe slug for an unlisted poll.
+func newSlug() (string, error) {
+	b := make([]byte, slugBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", errs.InternalServerError(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// newInviteToken signs an invite link to a poll that expires at exp. The
+// token is "<poll id>.<expiry unix time>.<HMAC-SHA256 of both>", so it can
+// be checked without storing it.
+func newInviteToken(secret []byte, pollID int64, exp time.Time) string {
+	payload := fmt.Sprintf("%d.%d", pollID, exp.Unix())
+	return payload + "." + signInvite(secret, payload)
+}
+
+// validInviteToken reports whether token is an unexpired invite link to the poll.
+func validInviteToken(secret []byte, pollID int64, token string, now time.Time) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || id != pollID {
+		return false
+	}
+	exp, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || now.Unix() >= exp {
+		return false
+	}
+	want := signInvite(secret, parts[0]+"."+parts[1])
+	return hmac.Equal([]byte(parts[2]), []byte(want))
+}
+
+// signInvite returns the URL-safe HMAC-SHA256 of an invite payload.
+func signInvite(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,199 @@
+package poll
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+)
+
+func TestInviteToken(t *testing.T) {
+	secret := []byte("invite-secret")
+	now := time.Date(2025, 5, 18, 9, 0, 0, 0, time.UTC)
+	token := newInviteToken(secret, 12, now.Add(time.Hour))
+
+	assert.True(t, validInviteToken(secret, 12, token, now))
+	assert.False(t, validInviteToken(secret, 13, token, now), "other poll")
+	assert.False(t, validInviteToken(secret, 12, token, now.Add(time.Hour)), "expired")
+	assert.False(t, validInviteToken([]byte("other-secret"), 12, token, now), "other secret")
+	assert.False(t, validInviteToken(secret, 12, "12.9999999999."+signInvite(secret, "12.1"), now), "tampered expiry")
+	assert.False(t, validInviteToken(secret, 12, "garbage", now))
+}
+
+func TestSetVisibility(t *testing.T) {
+	p := &Poll{}
+	assert.NoError(t, setVisibility(p, VisibilityUnlisted))
+	assert.Len(t, p.Slug, 16)
+
+	// The slug is kept when the poll is unlisted again
+	slug := p.Slug
+	assert.NoError(t, setVisibility(p, VisibilityPublic))
+	assert.NoError(t, setVisibility(p, VisibilityUnlisted))
+	assert.Equal(t, slug, p.Slug)
+
+	assert.Error(t, setVisibility(p, "secret"))
+	assert.Equal(t, VisibilityUnlisted, p.Visibility)
+}
+
+func TestResultsPublic(t *testing.T) {
+	now := time.Now()
+	open := &Poll{Status: StatusOpen}
+	closed := &Poll{Status: StatusClosed}
+
+	for _, policy := range []string{"", ResultsAlways} {
+		open.ResultsVisibility, closed.ResultsVisibility = policy, policy
+		assert.True(t, resultsPublic(open, now), policy)
+		assert.True(t, resultsPublic(closed, now), policy)
+	}
+	for _, policy := range []string{ResultsAfterVote, ResultsAfterClose} {
+		open.ResultsVisibility, closed.ResultsVisibility = policy, policy
+		assert.False(t, resultsPublic(open, now), policy)
+		assert.True(t, resultsPublic(closed, now), policy)
+	}
+	closed.ResultsVisibility = ResultsOwnerOnly
+	assert.False(t, resultsPublic(closed, now))
+}
+
+func TestService_ResolvePoll(t *testing.T) {
+	secret := []byte("invite-secret")
+	public := &Poll{ID: 1, UserID: 9, Visibility: VisibilityPublic}
+	unlisted := &Poll{ID: 2, UserID: 9, Visibility: VisibilityUnlisted, Slug: "q3J8dUaZ0xT1kLmN"}
+	inviteOnly := &Poll{ID: 3, UserID: 9, Visibility: VisibilityInviteOnly}
+	link := newInviteToken(secret, 3, time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name           string
+		ref            string
+		query          string
+		userID         int64
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedID     string
+	}{
+		{
+			name: "Public poll by ID",
+			ref:  "1",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(public, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedID:     "1",
+		},
+		{
+			name: "Unlisted poll by slug",
+			ref:  "q3J8dUaZ0xT1kLmN",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetBySlug", mock.Anything, "q3J8dUaZ0xT1kLmN").Return(unlisted, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedID:     "2",
+		},
+		{
+			name: "Unlisted poll by ID",
+			ref:  "2",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(2)).Return(unlisted, nil)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "Owner reaches unlisted poll by ID",
+			ref:    "2",
+			userID: 9,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(2)).Return(unlisted, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedID:     "2",
+		},
+		{
+			name:   "Invited user",
+			ref:    "3",
+			userID: 4,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(3)).Return(inviteOnly, nil)
+				repo.On("IsInvited", mock.Anything, int64(3), int64(4)).Return(true, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedID:     "3",
+		},
+		{
+			name:   "Uninvited user",
+			ref:    "3",
+			userID: 5,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(3)).Return(inviteOnly, nil)
+				repo.On("IsInvited", mock.Anything, int64(3), int64(5)).Return(false, nil)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:  "Anonymous caller with invite link",
+			ref:   "3",
+			query: "?invite=" + link,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(3)).Return(inviteOnly, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedID:     "3",
+		},
+		{
+			name:  "Anonymous caller without invite",
+			ref:   "3",
+			query: "?invite=3.1.forged",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(3)).Return(inviteOnly, nil)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "Unknown slug",
+			ref:  "missing",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetBySlug", mock.Anything, "missing").Return(nil, errs.NotFound(errors.New("poll not found")))
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := setupEchoContext(http.MethodGet, "/"+tt.query, "")
+			c.SetParamNames("id")
+			c.SetParamValues(tt.ref)
+			if tt.userID != 0 {
+				addUserToken(c, tt.userID)
+			}
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+			service.InviteSecret = secret
+
+			var gotID string
+			handler := service.ResolvePoll(func(c echo.Context) error {
+				gotID = c.Param("id")
+				return c.NoContent(http.StatusOK)
+			})
+
+			// Execute
+			err := handler(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedID, gotID)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
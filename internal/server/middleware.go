@@ -1,18 +1,27 @@
 package server
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/auth"
 	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
 	"github.com/phsaurav/echo_prod_blueprint/pkg/response"
 )
 
-// JWTAuth middleware validates JWT tokens and adds user info to context
-func JWTAuth(secret string) echo.MiddlewareFunc {
+// TokenCheck vets the claims of a token whose signature and expiry are
+// valid, for example against revocations kept in the database. A returned
+// error is sent as the response.
+type TokenCheck func(c echo.Context, claims jwt.MapClaims) error
+
+// JWTAuth middleware validates JWT tokens and adds user info to context.
+// Valid tokens must also pass every check.
+func JWTAuth(secret string, checks ...TokenCheck) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			// Get token from Authorization header
@@ -46,6 +55,11 @@ func JWTAuth(secret string) echo.MiddlewareFunc {
 
 			// Check if token is valid
 			if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+				for _, check := range checks {
+					if err := check(c, claims); err != nil {
+						return response.ErrorBuilder(err).Send(c)
+					}
+				}
 				c.Set("user", token)
 				c.Set("user_id", int64(claims["user_id"].(float64)))
 				return next(c)
@@ -55,3 +69,137 @@ func JWTAuth(secret string) echo.MiddlewareFunc {
 		}
 	}
 }
+
+// OptionalJWTAuth middleware authenticates requests that carry a token and
+// lets anonymous ones through. An invalid token is still rejected.
+func OptionalJWTAuth(secret string, checks ...TokenCheck) echo.MiddlewareFunc {
+	auth := JWTAuth(secret, checks...)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		authed := auth(next)
+		return func(c echo.Context) error {
+			if c.Request().Header.Get("Authorization") == "" {
+				return next(c)
+			}
+			return authed(c)
+		}
+	}
+}
+
+// RequireRole lets through requests whose token holds any of roles, and
+// forbids the rest. It must run after JWTAuth.
+func RequireRole(roles ...string) echo.MiddlewareFunc {
+	return requireClaim(func(c echo.Context) bool {
+		for _, role := range roles {
+			if auth.HasRole(c, role) {
+				return true
+			}
+		}
+		return false
+	}, errors.New("insufficient role"))
+}
+
+// RequirePermission lets through requests whose token grants permission,
+// and forbids the rest. It must run after JWTAuth.
+func RequirePermission(permission string) echo.MiddlewareFunc {
+	return requireClaim(func(c echo.Context) bool {
+		return auth.HasPermission(c, permission)
+	}, errors.New("insufficient permissions"))
+}
+
+// requireClaim lets through authenticated requests for which held reports
+// true, and forbids the rest with denied.
+func requireClaim(held func(echo.Context) bool, denied error) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if _, ok := auth.Claims(c); !ok {
+				return response.ErrorBuilder(errs.Unauthorized(errors.New("missing authorization header"))).Send(c)
+			}
+			if !held(c) {
+				return response.ErrorBuilder(errs.Forbidden(denied)).Send(c)
+			}
+			return next(c)
+		}
+	}
+}
+
+// TokenVersions looks up the token version of users, which changing a
+// password bumps.
+type TokenVersions interface {
+	TokenVersion(ctx context.Context, userID int64) (int, error)
+}
+
+// CurrentTokenVersion rejects tokens issued before their user's password
+// last changed, and tokens of users that no longer exist. Tokens without
+// a version predate versioning and count as version 0.
+func CurrentTokenVersion(users TokenVersions) TokenCheck {
+	return func(c echo.Context, claims jwt.MapClaims) error {
+		userID, _ := claims["user_id"].(float64)
+		tokenVersion, _ := claims["tv"].(float64)
+
+		version, err := users.TokenVersion(c.Request().Context(), int64(userID))
+		if err != nil {
+			var serverErr *errs.ServerError
+			if errors.As(err, &serverErr) && serverErr.Code == http.StatusNotFound {
+				return errs.Unauthorized(errors.New("user no longer exists"))
+			}
+			return err
+		}
+		if int(tokenVersion) != version {
+			return errs.Unauthorized(errors.New("token has been revoked"))
+		}
+		return nil
+	}
+}
+
+// RevokedTokens looks up access tokens revoked by signing out.
+type RevokedTokens interface {
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// NotRevoked rejects tokens whose JWT ID is on the revocation list. Tokens
+// without an ID predate the list and are left to the other checks.
+func NotRevoked(tokens RevokedTokens) TokenCheck {
+	return func(c echo.Context, claims jwt.MapClaims) error {
+		jti, _ := claims["jti"].(string)
+		if jti == "" {
+			return nil
+		}
+
+		revoked, err := tokens.IsTokenRevoked(c.Request().Context(), jti)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return errs.Unauthorized(errors.New("token has been revoked"))
+		}
+		return nil
+	}
+}
+
+// Sessions looks up whether the sessions access tokens belong to are still
+// active.
+type Sessions interface {
+	TouchSession(ctx context.Context, userID int64, sessionID string) (bool, error)
+}
+
+// ActiveSession rejects tokens whose session was revoked, and records that
+// the session was seen. Tokens without a session predate sessions and are
+// left to the other checks.
+func ActiveSession(sessions Sessions) TokenCheck {
+	return func(c echo.Context, claims jwt.MapClaims) error {
+		sessionID, _ := claims["sid"].(string)
+		if sessionID == "" {
+			return nil
+		}
+		userID, _ := claims["user_id"].(float64)
+
+		active, err := sessions.TouchSession(c.Request().Context(), int64(userID), sessionID)
+		if err != nil {
+			return err
+		}
+		if !active {
+			return errs.Unauthorized(errors.New("session has been revoked"))
+		}
+		return nil
+	}
+}
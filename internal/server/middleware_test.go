@@ -2,12 +2,18 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
 )
 
 // TestJWTAuth tests the JWT auth middleware
@@ -101,3 +107,368 @@ func TestJWTAuth(t *testing.T) {
 		assert.Contains(t, response["error"], "missing authorization header")
 	})
 }
+
+// TestOptionalJWTAuth tests that anonymous requests pass and bad tokens do not
+func TestOptionalJWTAuth(t *testing.T) {
+	middleware := OptionalJWTAuth("test-secret")
+	e := echo.New()
+	handler := func(c echo.Context) error {
+		return c.String(http.StatusOK, "success")
+	}
+
+	// Missing token test
+	t.Run("Missing Token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := middleware(handler)(c)
+
+		// Anonymous callers reach the handler without a user
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Nil(t, c.Get("user"))
+	})
+
+	// Invalid token test
+	t.Run("Invalid Token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer invalid-token")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		middleware(handler)(c)
+
+		// A token that fails validation is still rejected
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+// signToken returns a token signed with secret carrying the given claims
+func signToken(t *testing.T, secret string, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return token
+}
+
+// TestCurrentTokenVersion tests that tokens are revoked by password changes
+func TestCurrentTokenVersion(t *testing.T) {
+	e := echo.New()
+	handler := func(c echo.Context) error {
+		return c.String(http.StatusOK, "success")
+	}
+
+	tests := []struct {
+		name           string
+		claims         jwt.MapClaims
+		mockSetup      func(*MockTokenVersions)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Current version",
+			claims: jwt.MapClaims{"user_id": 3, "tv": 2},
+			mockSetup: func(users *MockTokenVersions) {
+				users.On("TokenVersion", mock.Anything, int64(3)).Return(2, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "success",
+		},
+		{
+			name:   "Token predating versions",
+			claims: jwt.MapClaims{"user_id": 3},
+			mockSetup: func(users *MockTokenVersions) {
+				users.On("TokenVersion", mock.Anything, int64(3)).Return(0, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "success",
+		},
+		{
+			name:   "Password changed since",
+			claims: jwt.MapClaims{"user_id": 3, "tv": 1},
+			mockSetup: func(users *MockTokenVersions) {
+				users.On("TokenVersion", mock.Anything, int64(3)).Return(2, nil)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `"error":"token has been revoked"`,
+		},
+		{
+			name:   "User deleted",
+			claims: jwt.MapClaims{"user_id": 3},
+			mockSetup: func(users *MockTokenVersions) {
+				users.On("TokenVersion", mock.Anything, int64(3)).Return(0, errs.NotFound(errors.New("no rows")))
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `"error":"user no longer exists"`,
+		},
+		{
+			name:   "Database error",
+			claims: jwt.MapClaims{"user_id": 3},
+			mockSetup: func(users *MockTokenVersions) {
+				users.On("TokenVersion", mock.Anything, int64(3)).Return(0, errs.InternalServerError(errors.New("connection refused")))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"connection refused"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			users := new(MockTokenVersions)
+			tt.mockSetup(users)
+			middleware := JWTAuth("test-secret", CurrentTokenVersion(users))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+signToken(t, "test-secret", tt.claims))
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			// Execute
+			err := middleware(handler)(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			users.AssertExpectations(t)
+		})
+	}
+}
+
+// TestNotRevoked tests that tokens are revoked by signing out
+func TestNotRevoked(t *testing.T) {
+	e := echo.New()
+	handler := func(c echo.Context) error {
+		return c.String(http.StatusOK, "success")
+	}
+
+	tests := []struct {
+		name           string
+		claims         jwt.MapClaims
+		mockSetup      func(*MockRevokedTokens)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Token not revoked",
+			claims: jwt.MapClaims{"user_id": 3, "jti": "token-id"},
+			mockSetup: func(tokens *MockRevokedTokens) {
+				tokens.On("IsTokenRevoked", mock.Anything, "token-id").Return(false, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "success",
+		},
+		{
+			name:           "Token predating IDs",
+			claims:         jwt.MapClaims{"user_id": 3},
+			mockSetup:      func(tokens *MockRevokedTokens) {},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "success",
+		},
+		{
+			name:   "Signed out",
+			claims: jwt.MapClaims{"user_id": 3, "jti": "token-id"},
+			mockSetup: func(tokens *MockRevokedTokens) {
+				tokens.On("IsTokenRevoked", mock.Anything, "token-id").Return(true, nil)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `"error":"token has been revoked"`,
+		},
+		{
+			name:   "Database error",
+			claims: jwt.MapClaims{"user_id": 3, "jti": "token-id"},
+			mockSetup: func(tokens *MockRevokedTokens) {
+				tokens.On("IsTokenRevoked", mock.Anything, "token-id").Return(false, errs.InternalServerError(errors.New("connection refused")))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"connection refused"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			tokens := new(MockRevokedTokens)
+			tt.mockSetup(tokens)
+			middleware := JWTAuth("test-secret", NotRevoked(tokens))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+signToken(t, "test-secret", tt.claims))
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			// Execute
+			err := middleware(handler)(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			tokens.AssertExpectations(t)
+		})
+	}
+}
+
+// TestActiveSession tests that tokens are revoked with their session
+func TestActiveSession(t *testing.T) {
+	e := echo.New()
+	handler := func(c echo.Context) error {
+		return c.String(http.StatusOK, "success")
+	}
+
+	tests := []struct {
+		name           string
+		claims         jwt.MapClaims
+		mockSetup      func(*MockSessions)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Active session",
+			claims: jwt.MapClaims{"user_id": 3, "sid": "session-id"},
+			mockSetup: func(sessions *MockSessions) {
+				sessions.On("TouchSession", mock.Anything, int64(3), "session-id").Return(true, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "success",
+		},
+		{
+			name:           "Token predating sessions",
+			claims:         jwt.MapClaims{"user_id": 3},
+			mockSetup:      func(sessions *MockSessions) {},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "success",
+		},
+		{
+			name:   "Revoked session",
+			claims: jwt.MapClaims{"user_id": 3, "sid": "session-id"},
+			mockSetup: func(sessions *MockSessions) {
+				sessions.On("TouchSession", mock.Anything, int64(3), "session-id").Return(false, nil)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `"error":"session has been revoked"`,
+		},
+		{
+			name:   "Database error",
+			claims: jwt.MapClaims{"user_id": 3, "sid": "session-id"},
+			mockSetup: func(sessions *MockSessions) {
+				sessions.On("TouchSession", mock.Anything, int64(3), "session-id").Return(false, errs.InternalServerError(errors.New("connection refused")))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"connection refused"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			sessions := new(MockSessions)
+			tt.mockSetup(sessions)
+			middleware := JWTAuth("test-secret", ActiveSession(sessions))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+signToken(t, "test-secret", tt.claims))
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			// Execute
+			err := middleware(handler)(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			sessions.AssertExpectations(t)
+		})
+	}
+}
+
+// TestRequireRole tests that only tokens holding a role get through
+func TestRequireRole(t *testing.T) {
+	e := echo.New()
+	handler := func(c echo.Context) error {
+		return c.String(http.StatusOK, "success")
+	}
+
+	tests := []struct {
+		name           string
+		claims         jwt.MapClaims
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Holds the role", claims: jwt.MapClaims{"user_id": 3, "roles": []string{"editor", "admin"}}, expectedStatus: http.StatusOK, expectedBody: "success"},
+		{name: "Other roles only", claims: jwt.MapClaims{"user_id": 3, "roles": []string{"editor"}}, expectedStatus: http.StatusForbidden, expectedBody: `"error":"insufficient role"`},
+		{name: "Token predating roles", claims: jwt.MapClaims{"user_id": 3}, expectedStatus: http.StatusForbidden, expectedBody: `"error":"insufficient role"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			middleware := JWTAuth("test-secret")
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+signToken(t, "test-secret", tt.claims))
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			// Execute
+			err := middleware(RequireRole("admin")(handler))(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+// TestRequirePermission tests that only tokens granting a permission get
+// through
+func TestRequirePermission(t *testing.T) {
+	e := echo.New()
+	handler := func(c echo.Context) error {
+		return c.String(http.StatusOK, "success")
+	}
+
+	t.Run("Granted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+signToken(t, "test-secret", jwt.MapClaims{"user_id": 3, "perms": []string{"users:manage"}}))
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := JWTAuth("test-secret")(RequirePermission("users:manage")(handler))(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Not granted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+signToken(t, "test-secret", jwt.MapClaims{"user_id": 3, "perms": []string{"users:read"}}))
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := JWTAuth("test-secret")(RequirePermission("users:manage")(handler))(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"error":"insufficient permissions"`)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := RequirePermission("users:manage")(handler)(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
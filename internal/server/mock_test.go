@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"database/sql"
 
 	"github.com/labstack/echo/v4"
@@ -49,3 +50,33 @@ func (m *MockMiddleware) AuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc
 	args := m.Called(next)
 	return args.Get(0).(echo.HandlerFunc)
 }
+
+// MockTokenVersions implements TokenVersions for testing
+type MockTokenVersions struct {
+	mock.Mock
+}
+
+func (m *MockTokenVersions) TokenVersion(ctx context.Context, userID int64) (int, error) {
+	args := m.Called(ctx, userID)
+	return args.Int(0), args.Error(1)
+}
+
+// MockRevokedTokens implements RevokedTokens for testing
+type MockRevokedTokens struct {
+	mock.Mock
+}
+
+func (m *MockRevokedTokens) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	args := m.Called(ctx, jti)
+	return args.Bool(0), args.Error(1)
+}
+
+// MockSessions implements Sessions for testing
+type MockSessions struct {
+	mock.Mock
+}
+
+func (m *MockSessions) TouchSession(ctx context.Context, userID int64, sessionID string) (bool, error) {
+	args := m.Called(ctx, userID, sessionID)
+	return args.Bool(0), args.Error(1)
+}
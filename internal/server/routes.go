@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/phsaurav/echo_prod_blueprint/internal/comment"
 	"github.com/phsaurav/echo_prod_blueprint/internal/poll"
+	"github.com/phsaurav/echo_prod_blueprint/internal/survey"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	_ "github.com/phsaurav/echo_prod_blueprint/docs"
 	"github.com/phsaurav/echo_prod_blueprint/internal/user"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/auth"
 	"github.com/phsaurav/echo_prod_blueprint/pkg/response"
 	echoSwagger "github.com/swaggo/echo-swagger"
 )
@@ -55,12 +58,22 @@ func (s *Server) routes(route *echo.Group, version string) {
 
 // Methods to register routes for specific versions
 func (s *Server) registerV1Routes(route *echo.Group) {
-	jwtAuthMiddleware := JWTAuth(s.config.TokenConfig.Secret)
+	// Tokens are revoked when their user's password changes, when they sign
+	// out everywhere, when their session is revoked, or when that one token
+	// is signed out
+	users := user.NewRepo(s.store.db)
+	checks := []TokenCheck{CurrentTokenVersion(users), ActiveSession(users), NotRevoked(users)}
+	jwtAuthMiddleware := JWTAuth(s.config.TokenConfig.Secret, checks...)
+	optionalAuthMiddleware := OptionalJWTAuth(s.config.TokenConfig.Secret, checks...)
 	// Routes
 	userGroup := route.Group("/user")
-	user.Register(userGroup, s.store.db, s.config, jwtAuthMiddleware)
+	adminUserGroup := route.Group("/admin/users", jwtAuthMiddleware, RequirePermission(auth.PermissionManageUsers))
+	s.users = user.Register(userGroup, adminUserGroup, s.store.db, s.config, s.mailer, jwtAuthMiddleware)
 	pollGroup := route.Group("/poll")
-	poll.Register(pollGroup, s.store.db, jwtAuthMiddleware)
+	polls := poll.Register(pollGroup, s.store.db, s.config, s.events, s.trending, s.files, jwtAuthMiddleware, optionalAuthMiddleware)
+	comment.Register(pollGroup, s.store.db, jwtAuthMiddleware, optionalAuthMiddleware, polls.ResolvePoll)
+	surveyGroup := route.Group("/survey")
+	survey.Register(surveyGroup, s.store.db, jwtAuthMiddleware)
 }
 
 func (s *Server) HelloWorldHandler(c echo.Context) error {
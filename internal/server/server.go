@@ -3,8 +3,10 @@ package server
 import (
 	"context"
 	"net/http"
+	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -13,14 +15,32 @@ import (
 
 	"github.com/phsaurav/echo_prod_blueprint/config"
 	"github.com/phsaurav/echo_prod_blueprint/internal/database"
+	"github.com/phsaurav/echo_prod_blueprint/internal/poll"
+	"github.com/phsaurav/echo_prod_blueprint/internal/user"
 	"github.com/phsaurav/echo_prod_blueprint/pkg/logger"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/mail"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/storage"
 )
 
+// shutdownTimeout is how long shutdown waits for requests in flight and
+// for the work they left running in the background.
+const shutdownTimeout = 5 * time.Second
+
+// shutdownHooks tracks the hooks registered with onShutdown that are still
+// running, so the database is not closed under them.
+var shutdownHooks sync.WaitGroup
+
 type Server struct {
-	store  Store
-	config config.Config
-	log    *logger.Logger
-	e      *echo.Echo
+	store    Store
+	config   config.Config
+	log      *logger.Logger
+	e        *echo.Echo
+	events   poll.Broker
+	trending *poll.TrendingRanker
+	files    storage.Storage
+	mailer   mail.Mailer
+	// users is set once the routes are registered.
+	users *user.Service
 }
 
 func NewServer() (*http.Server, database.Service, error) {
@@ -48,10 +68,54 @@ func NewServer() (*http.Server, database.Service, error) {
 
 	store := NewStore(db)
 
+	var events poll.Broker
+	switch cfg.Events.Backend {
+	case "local":
+		events = poll.NewLocalBroker()
+	default:
+		events = poll.NewPGBroker(db.DB())
+	}
+
+	// Rank trending polls in the background so requests only read the cache
+	trending := poll.NewTrendingRanker(poll.NewRepo(db), cfg.Trending)
+	trending.Start()
+
+	var files storage.Storage
+	switch cfg.Storage.Driver {
+	case "s3":
+		s3 := cfg.Storage.S3
+		files = storage.NewS3(s3.Endpoint, s3.Region, s3.Bucket, s3.AccessKey, s3.SecretKey)
+	default:
+		files, err = storage.NewLocal(cfg.Storage.LocalDir)
+		if err != nil {
+			log.Fatalf("Error preparing upload directory: %v", err)
+			return nil, nil, err
+		}
+	}
+
+	var mailer mail.Mailer
+	switch cfg.Mail.Driver {
+	case "smtp":
+		smtp := cfg.Mail.SMTP
+		mailer = mail.NewSMTP(smtp.Host, smtp.Port, smtp.Username, smtp.Password, cfg.Mail.From)
+	case "file":
+		mailer, err = mail.NewFile(cfg.Mail.File, cfg.Mail.From)
+		if err != nil {
+			log.Fatalf("Error opening mail file: %v", err)
+			return nil, nil, err
+		}
+	default:
+		mailer = mail.NewLog(os.Stdout, cfg.Mail.From)
+	}
+
 	NewServer := &Server{
-		store:  store,
-		config: cfg,
-		log:    log,
+		store:    store,
+		config:   cfg,
+		log:      log,
+		events:   events,
+		trending: trending,
+		files:    files,
+		mailer:   mailer,
 	}
 
 	// Declare Server config
@@ -63,9 +127,54 @@ func NewServer() (*http.Server, database.Service, error) {
 		WriteTimeout: NewServer.config.WriteTimeout,
 	}
 
+	// Live results streams never go idle on their own, so end them as soon
+	// as shutdown starts instead of waiting out the shutdown timeout
+	onShutdown(app, func() {
+		if err := events.Close(); err != nil {
+			log.Errorf("Error closing poll events broker: %v", err)
+		}
+		if err := trending.Close(); err != nil {
+			log.Errorf("Error stopping trending polls worker: %v", err)
+		}
+	})
+	// Password reset emails are sent after their request has been answered
+	onShutdown(app, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := NewServer.users.Close(ctx); err != nil {
+			log.Errorf("Error waiting for password reset emails: %v", err)
+		}
+	})
+
 	return app, db, nil
 }
 
+// onShutdown registers f to run when app shuts down. GracefulShutdown waits
+// for it before closing the database.
+func onShutdown(app *http.Server, f func()) {
+	shutdownHooks.Add(1)
+	app.RegisterOnShutdown(func() {
+		defer shutdownHooks.Done()
+		f()
+	})
+}
+
+// waitShutdownHooks waits for the hooks registered with onShutdown to
+// finish, or for ctx to end.
+func waitShutdownHooks(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		shutdownHooks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func GracefulShutdown(apiServer *http.Server, db database.Service, done chan bool) {
 	// Create context that listens for the interrupt signal from the OS.
 
@@ -79,12 +188,17 @@ func GracefulShutdown(apiServer *http.Server, db database.Service, done chan boo
 	logging.Info("shutting down gracefully, press Ctrl+C again to force")
 
 	// The context is used to inform the server it has 5 seconds to finish
-	// the request it is currently handling
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// the request it is currently handling. Shutdown also runs the hooks
+	// registered in NewServer, which close live results streams and wait
+	// for emails still being sent; they are waited for too.
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 	if err := apiServer.Shutdown(ctx); err != nil {
 		logging.Errorf("Server forced to shutdown with error: %v", err)
 	}
+	if err := waitShutdownHooks(ctx); err != nil {
+		logging.Errorf("Shutdown hooks did not finish: %v", err)
+	}
 
 	// Close the database connection gracefully
 	if err := db.Close(); err != nil {
@@ -0,0 +1,114 @@
+package survey
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepository implements survey.Repository interface for testing
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) Create(ctx context.Context, s *Survey) error {
+	args := m.Called(ctx, s)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetByID(ctx context.Context, id int64) (*Survey, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Survey), args.Error(1)
+}
+
+func (m *MockRepository) HasResponded(ctx context.Context, surveyID, userID int64) (bool, error) {
+	args := m.Called(ctx, surveyID, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) SubmitResponse(ctx context.Context, resp *Response) error {
+	args := m.Called(ctx, resp)
+	return args.Error(0)
+}
+
+func (m *MockRepository) CountResponses(ctx context.Context, surveyID int64) (int64, error) {
+	args := m.Called(ctx, surveyID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) GetAnsweredCounts(ctx context.Context, surveyID int64) (map[int64]int64, error) {
+	args := m.Called(ctx, surveyID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int64]int64), args.Error(1)
+}
+
+func (m *MockRepository) GetAnswerCounts(ctx context.Context, surveyID int64) ([]AnswerCount, error) {
+	args := m.Called(ctx, surveyID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]AnswerCount), args.Error(1)
+}
+
+func (m *MockRepository) GetTextAnswers(ctx context.Context, surveyID int64, limit int) (map[int64][]string, error) {
+	args := m.Called(ctx, surveyID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int64][]string), args.Error(1)
+}
+
+// MockDBService implements database.Service interface for testing
+type MockDBService struct {
+	mock.Mock
+}
+
+func (m *MockDBService) Health() map[string]string {
+	args := m.Called()
+	return args.Get(0).(map[string]string)
+}
+
+func (m *MockDBService) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockDBService) DB() *sql.DB {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil
+	}
+	return args.Get(0).(*sql.DB)
+}
+
+// MockSurveyService implements survey.SurveyService for testing
+type MockSurveyService struct {
+	mock.Mock
+}
+
+func (m *MockSurveyService) CreateSurvey(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockSurveyService) GetSurvey(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockSurveyService) SubmitResponse(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockSurveyService) GetResults(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
@@ -0,0 +1,177 @@
+package survey
+
+import (
+	"time"
+
+	"github.com/phsaurav/echo_prod_blueprint/internal/poll"
+)
+
+// Survey groups ordered questions answered together in one response.
+type Survey struct {
+	ID          int64      `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	UserID      int64      `json:"user_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Questions   []Question `json:"questions"`
+}
+
+// Question types. Single and multi choice questions behave like the poll
+// types of the same name.
+const (
+	TypeSingle = poll.TypeSingle
+	TypeMulti  = poll.TypeMulti
+	// TypeText questions take a free-text answer.
+	TypeText = "text"
+	// TypeRating questions take a rating from RatingMin to RatingMax.
+	TypeRating = "rating"
+	// TypeNPS questions take a Net Promoter Score from NPSMin to NPSMax.
+	TypeNPS = "nps"
+)
+
+// Answer ranges of rating and NPS questions.
+const (
+	RatingMin = 1
+	RatingMax = 5
+	NPSMin    = 0
+	NPSMax    = 10
+)
+
+// Question is one question of a survey. Position is its 1-based place in
+// the survey.
+type Question struct {
+	ID       int64  `json:"id"`
+	SurveyID int64  `json:"survey_id"`
+	Position int    `json:"position" example:"1"`
+	Type     string `json:"type" example:"single"`
+	Prompt   string `json:"prompt" example:"How did you hear about us?"`
+	Required bool   `json:"required" example:"true"`
+	// MinSelections and MaxSelections bound the number of options picked in
+	// a multi question. They are zero for other question types.
+	MinSelections int      `json:"min_selections,omitempty" example:"1"`
+	MaxSelections int      `json:"max_selections,omitempty" example:"3"`
+	Options       []Option `json:"options,omitempty"`
+}
+
+// IsChoice reports whether the question is answered by picking options.
+func (q *Question) IsChoice() bool {
+	return q.Type == TypeSingle || q.Type == TypeMulti
+}
+
+// Option is a selectable answer of a single or multi choice question.
+type Option struct {
+	ID         int64  `json:"id"`
+	QuestionID int64  `json:"question_id"`
+	Text       string `json:"text"`
+}
+
+// Response is one user's submission of a whole survey.
+type Response struct {
+	ID        int64     `json:"id"`
+	SurveyID  int64     `json:"survey_id"`
+	UserID    int64     `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Answers   []Answer  `json:"answers"`
+}
+
+// Answer is the answer to one question of a response. Choice questions set
+// OptionIDs, text questions set Text and rating and NPS questions set Value.
+type Answer struct {
+	QuestionID int64   `json:"question_id" example:"1"`
+	OptionIDs  []int64 `json:"option_ids,omitempty" example:"2,3"`
+	Text       string  `json:"text,omitempty" example:"Great onboarding"`
+	Value      *int    `json:"value,omitempty" example:"9"`
+}
+
+// CreateSurveyRequest represents the request payload for creating a survey
+type CreateSurveyRequest struct {
+	Title       string                  `json:"title" example:"Customer feedback"`
+	Description string                  `json:"description,omitempty" example:"Tell us how we are doing"`
+	Questions   []CreateQuestionRequest `json:"questions"`
+}
+
+// CreateQuestionRequest describes one question of a new survey. Options are
+// required for single and multi questions only.
+type CreateQuestionRequest struct {
+	Type          string   `json:"type" example:"single"`
+	Prompt        string   `json:"prompt" example:"How did you hear about us?"`
+	Required      bool     `json:"required,omitempty" example:"true"`
+	Options       []string `json:"options,omitempty" example:"[\"Search\",\"Friend\",\"Ad\"]"`
+	MinSelections int      `json:"min_selections,omitempty" example:"1"`
+	MaxSelections int      `json:"max_selections,omitempty" example:"2"`
+}
+
+// SubmitResponseRequest represents the request payload for answering a survey.
+// Optional questions may be left out.
+type SubmitResponseRequest struct {
+	Answers []Answer `json:"answers"`
+}
+
+// SubmitResponseResponse represents the response for a successfully submitted survey response
+type SubmitResponseResponse struct {
+	Message    string `json:"message" example:"Response submitted successfully"`
+	SurveyID   int64  `json:"survey_id" example:"1"`
+	ResponseID int64  `json:"response_id" example:"12"`
+	Timestamp  string `json:"timestamp" example:"2025-05-18T10:30:45Z"`
+}
+
+// SurveyResultsResponse represents the aggregated results of a survey
+type SurveyResultsResponse struct {
+	SurveyID       int64            `json:"survey_id" example:"1"`
+	Title          string           `json:"title" example:"Customer feedback"`
+	TotalResponses int64            `json:"total_responses" example:"120"`
+	Questions      []QuestionResult `json:"questions"`
+}
+
+// QuestionResult aggregates the answers to one question. Answered counts
+// the responses that answered it. Which of the other fields are set depends
+// on the question type.
+type QuestionResult struct {
+	QuestionID int64  `json:"question_id" example:"1"`
+	Position   int    `json:"position" example:"1"`
+	Type       string `json:"type" example:"rating"`
+	Prompt     string `json:"prompt" example:"How would you rate us?"`
+	Answered   int64  `json:"answered" example:"118"`
+	// Options is set for single and multi questions. Percentages are of the
+	// responses that answered the question.
+	Options []OptionResult `json:"options,omitempty"`
+	// Distribution and Average are set for rating and NPS questions.
+	Distribution []ValueCount `json:"distribution,omitempty"`
+	Average      *float64     `json:"average,omitempty" example:"4.2"`
+	// NPS is set for NPS questions.
+	NPS *NPSResult `json:"nps,omitempty"`
+	// Texts holds the most recent answers to a text question.
+	Texts []string `json:"texts,omitempty"`
+}
+
+// OptionResult is how often an option was picked.
+type OptionResult struct {
+	ID         int64   `json:"id" example:"1"`
+	Text       string  `json:"text" example:"Search"`
+	Count      int64   `json:"count" example:"40"`
+	Percentage float64 `json:"percentage" example:"33.9"`
+}
+
+// ValueCount is how often a rating or score was given.
+type ValueCount struct {
+	Value int   `json:"value" example:"5"`
+	Count int64 `json:"count" example:"61"`
+}
+
+// NPSResult is the Net Promoter Score of an NPS question: the percentage of
+// promoters (9-10) minus the percentage of detractors (0-6).
+type NPSResult struct {
+	Score      float64 `json:"score" example:"42.5"`
+	Promoters  int64   `json:"promoters" example:"70"`
+	Passives   int64   `json:"passives" example:"30"`
+	Detractors int64   `json:"detractors" example:"18"`
+}
+
+// AnswerCount is how many times a question received an option or a value.
+// Exactly one of OptionID and Value is set.
+type AnswerCount struct {
+	QuestionID int64
+	OptionID   *int64
+	Value      *int
+	Count      int64
+}
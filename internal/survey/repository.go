@@ -0,0 +1,312 @@
+package survey
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/phsaurav/echo_prod_blueprint/internal/database"
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+)
+
+// Repo is the concrete implementation of the survey repository.
+type Repo struct {
+	DB *sql.DB
+}
+
+// NewRepo creates a new survey repository instance.
+func NewRepo(db database.Service) *Repo {
+	return &Repo{DB: db.DB()}
+}
+
+var _ Repository = (*Repo)(nil)
+
+// Create inserts a survey with its questions and their options in one
+// transaction, filling in the generated IDs.
+func (r *Repo) Create(ctx context.Context, s *Survey) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	surveyQuery := `
+		INSERT INTO surveys (title, description, user_id, created_at)
+		VALUES ($1, $2, $3, NOW())
+		RETURNING id, created_at
+	`
+	if err := tx.QueryRowContext(ctx, surveyQuery, s.Title, s.Description, s.UserID).Scan(&s.ID, &s.CreatedAt); err != nil {
+		return errs.InternalServerError(err)
+	}
+
+	questionQuery := `
+		INSERT INTO survey_questions (survey_id, position, question_type, prompt, required, min_selections, max_selections)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+	optionQuery := `INSERT INTO survey_question_options (question_id, text) VALUES ($1, $2) RETURNING id`
+	for i := range s.Questions {
+		q := &s.Questions[i]
+		q.SurveyID = s.ID
+		err := tx.QueryRowContext(ctx, questionQuery, s.ID, q.Position, q.Type, q.Prompt, q.Required,
+			q.MinSelections, q.MaxSelections).Scan(&q.ID)
+		if err != nil {
+			return errs.InternalServerError(err)
+		}
+		for j := range q.Options {
+			if err := tx.QueryRowContext(ctx, optionQuery, q.ID, q.Options[j].Text).Scan(&q.Options[j].ID); err != nil {
+				return errs.InternalServerError(err)
+			}
+			q.Options[j].QuestionID = q.ID
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// GetByID fetches a survey with its questions in order and their options.
+func (r *Repo) GetByID(ctx context.Context, id int64) (*Survey, error) {
+	s := new(Survey)
+	query := `SELECT id, title, description, user_id, created_at FROM surveys WHERE id = $1`
+	err := r.DB.QueryRowContext(ctx, query, id).Scan(&s.ID, &s.Title, &s.Description, &s.UserID, &s.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errs.NotFound(err)
+		}
+		return nil, errs.InternalServerError(err)
+	}
+
+	questionQuery := `
+		SELECT id, survey_id, position, question_type, prompt, required, min_selections, max_selections
+		FROM survey_questions
+		WHERE survey_id = $1
+		ORDER BY position
+	`
+	rows, err := r.DB.QueryContext(ctx, questionQuery, id)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	s.Questions = []Question{}
+	byID := make(map[int64]int)
+	for rows.Next() {
+		var q Question
+		if err := rows.Scan(&q.ID, &q.SurveyID, &q.Position, &q.Type, &q.Prompt, &q.Required,
+			&q.MinSelections, &q.MaxSelections); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		byID[q.ID] = len(s.Questions)
+		s.Questions = append(s.Questions, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+
+	optionQuery := `
+		SELECT o.id, o.question_id, o.text
+		FROM survey_question_options o
+		JOIN survey_questions q ON q.id = o.question_id
+		WHERE q.survey_id = $1
+		ORDER BY o.id
+	`
+	optRows, err := r.DB.QueryContext(ctx, optionQuery, id)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer optRows.Close()
+
+	for optRows.Next() {
+		var opt Option
+		if err := optRows.Scan(&opt.ID, &opt.QuestionID, &opt.Text); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		if i, ok := byID[opt.QuestionID]; ok {
+			s.Questions[i].Options = append(s.Questions[i].Options, opt)
+		}
+	}
+	if err := optRows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	return s, nil
+}
+
+// HasResponded reports whether a user has already answered a survey.
+func (r *Repo) HasResponded(ctx context.Context, surveyID, userID int64) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM survey_responses WHERE survey_id = $1 AND user_id = $2)`
+	if err := r.DB.QueryRowContext(ctx, query, surveyID, userID).Scan(&exists); err != nil {
+		return false, errs.InternalServerError(err)
+	}
+	return exists, nil
+}
+
+// SubmitResponse stores a response and all of its answers in one
+// transaction. Each selected option of a choice answer is its own row.
+func (r *Repo) SubmitResponse(ctx context.Context, resp *Response) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	// A concurrent response by the same user inserts nothing
+	responseQuery := `
+		INSERT INTO survey_responses (survey_id, user_id, created_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (survey_id, user_id) DO NOTHING
+		RETURNING id, created_at
+	`
+	if err := tx.QueryRowContext(ctx, responseQuery, resp.SurveyID, resp.UserID).Scan(&resp.ID, &resp.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errs.Conflict(errors.New("already responded"))
+		}
+		return errs.InternalServerError(err)
+	}
+
+	answerQuery := `
+		INSERT INTO survey_answers (response_id, question_id, option_id, text_value, number_value)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	for _, a := range resp.Answers {
+		switch {
+		case len(a.OptionIDs) > 0:
+			for _, optionID := range a.OptionIDs {
+				if _, err := tx.ExecContext(ctx, answerQuery, resp.ID, a.QuestionID, optionID, nil, nil); err != nil {
+					return errs.InternalServerError(err)
+				}
+			}
+		case a.Value != nil:
+			if _, err := tx.ExecContext(ctx, answerQuery, resp.ID, a.QuestionID, nil, nil, *a.Value); err != nil {
+				return errs.InternalServerError(err)
+			}
+		default:
+			if _, err := tx.ExecContext(ctx, answerQuery, resp.ID, a.QuestionID, nil, a.Text, nil); err != nil {
+				return errs.InternalServerError(err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// CountResponses returns the number of responses to a survey.
+func (r *Repo) CountResponses(ctx context.Context, surveyID int64) (int64, error) {
+	var count int64
+	query := `SELECT COUNT(*) FROM survey_responses WHERE survey_id = $1`
+	if err := r.DB.QueryRowContext(ctx, query, surveyID).Scan(&count); err != nil {
+		return 0, errs.InternalServerError(err)
+	}
+	return count, nil
+}
+
+// GetAnsweredCounts returns, per question ID, the number of responses that
+// answered the question.
+func (r *Repo) GetAnsweredCounts(ctx context.Context, surveyID int64) (map[int64]int64, error) {
+	query := `
+		SELECT a.question_id, COUNT(DISTINCT a.response_id)
+		FROM survey_answers a
+		JOIN survey_questions q ON q.id = a.question_id
+		WHERE q.survey_id = $1
+		GROUP BY a.question_id
+	`
+	rows, err := r.DB.QueryContext(ctx, query, surveyID)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]int64)
+	for rows.Next() {
+		var questionID, count int64
+		if err := rows.Scan(&questionID, &count); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		counts[questionID] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	return counts, nil
+}
+
+// GetAnswerCounts counts how often each option of a choice question was
+// picked and how often each value of a rating or NPS question was given.
+func (r *Repo) GetAnswerCounts(ctx context.Context, surveyID int64) ([]AnswerCount, error) {
+	query := `
+		SELECT a.question_id, a.option_id, a.number_value, COUNT(*)
+		FROM survey_answers a
+		JOIN survey_questions q ON q.id = a.question_id
+		WHERE q.survey_id = $1 AND a.text_value IS NULL
+		GROUP BY a.question_id, a.option_id, a.number_value
+		ORDER BY a.question_id, a.option_id, a.number_value
+	`
+	rows, err := r.DB.QueryContext(ctx, query, surveyID)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	var counts []AnswerCount
+	for rows.Next() {
+		var c AnswerCount
+		var optionID, value sql.NullInt64
+		if err := rows.Scan(&c.QuestionID, &optionID, &value, &c.Count); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		if optionID.Valid {
+			c.OptionID = &optionID.Int64
+		}
+		if value.Valid {
+			v := int(value.Int64)
+			c.Value = &v
+		}
+		counts = append(counts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	return counts, nil
+}
+
+// GetTextAnswers returns, per question ID, up to limit of the most recent
+// answers to the survey's text questions, newest first.
+func (r *Repo) GetTextAnswers(ctx context.Context, surveyID int64, limit int) (map[int64][]string, error) {
+	query := `
+		SELECT question_id, text_value
+		FROM (
+			SELECT a.question_id, a.text_value,
+				ROW_NUMBER() OVER (PARTITION BY a.question_id ORDER BY a.id DESC) AS n
+			FROM survey_answers a
+			JOIN survey_questions q ON q.id = a.question_id
+			WHERE q.survey_id = $1 AND a.text_value IS NOT NULL
+		) latest
+		WHERE n <= $2
+		ORDER BY question_id, n
+	`
+	rows, err := r.DB.QueryContext(ctx, query, surveyID, limit)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	texts := make(map[int64][]string)
+	for rows.Next() {
+		var questionID int64
+		var text string
+		if err := rows.Scan(&questionID, &text); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		texts[questionID] = append(texts[questionID], text)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	return texts, nil
+}
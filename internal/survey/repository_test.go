@@ -0,0 +1,376 @@
+package survey
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRepo(t *testing.T) {
+	// Create mock DB
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create mock database service
+	mockDB := new(MockDBService)
+	mockDB.On("DB").Return(db)
+
+	// Create repository
+	repo := NewRepo(mockDB)
+
+	// Assert
+	assert.NotNil(t, repo)
+	assert.Equal(t, db, repo.DB)
+	mockDB.AssertExpectations(t)
+}
+
+func TestRepo_Create(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	survey := &Survey{
+		Title:  "Feedback",
+		UserID: 5,
+		Questions: []Question{
+			{Position: 1, Type: TypeSingle, Prompt: "Source?", Required: true, Options: []Option{{Text: "Search"}, {Text: "Friend"}}},
+			{Position: 2, Type: TypeNPS, Prompt: "Recommend us?"},
+		},
+	}
+
+	// Setup expectations - survey, questions and options in one transaction
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO surveys").
+		WithArgs("Feedback", "", 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, now))
+	mock.ExpectQuery("INSERT INTO survey_questions").
+		WithArgs(1, 1, TypeSingle, "Source?", true, 0, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	mock.ExpectQuery("INSERT INTO survey_question_options").
+		WithArgs(10, "Search").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(100))
+	mock.ExpectQuery("INSERT INTO survey_question_options").
+		WithArgs(10, "Friend").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(101))
+	mock.ExpectQuery("INSERT INTO survey_questions").
+		WithArgs(1, 2, TypeNPS, "Recommend us?", false, 0, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectCommit()
+
+	// Call function under test
+	err = repo.Create(context.Background(), survey)
+
+	// Assert generated IDs were filled in
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), survey.ID)
+	assert.Equal(t, int64(10), survey.Questions[0].ID)
+	assert.Equal(t, int64(1), survey.Questions[1].SurveyID)
+	assert.Equal(t, Option{ID: 101, QuestionID: 10, Text: "Friend"}, survey.Questions[0].Options[1])
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetByID(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	now := time.Now()
+	mock.ExpectQuery("SELECT id, title, description, user_id, created_at FROM surveys").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "description", "user_id", "created_at"}).
+			AddRow(1, "Feedback", "", 5, now))
+	mock.ExpectQuery("FROM survey_questions WHERE survey_id = \\$1 ORDER BY position").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "survey_id", "position", "question_type", "prompt", "required",
+			"min_selections", "max_selections"}).
+			AddRow(10, 1, 1, TypeMulti, "Features?", true, 1, 2).
+			AddRow(11, 1, 2, TypeText, "Anything else?", false, 0, 0))
+	mock.ExpectQuery("FROM survey_question_options o").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text"}).
+			AddRow(100, 10, "Search").
+			AddRow(101, 10, "Export"))
+
+	// Call function under test
+	survey, err := repo.GetByID(context.Background(), 1)
+
+	// Assert questions come back in order with their options
+	assert.NoError(t, err)
+	assert.Equal(t, "Feedback", survey.Title)
+	require.Len(t, survey.Questions, 2)
+	assert.Equal(t, []Option{{ID: 100, QuestionID: 10, Text: "Search"}, {ID: 101, QuestionID: 10, Text: "Export"}}, survey.Questions[0].Options)
+	assert.Equal(t, 2, survey.Questions[0].MaxSelections)
+	assert.Empty(t, survey.Questions[1].Options)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetByID_NotFound(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectQuery("SELECT id, title, description, user_id, created_at FROM surveys").
+		WithArgs(99).
+		WillReturnError(sql.ErrNoRows)
+
+	// Call function under test
+	survey, err := repo.GetByID(context.Background(), 99)
+
+	// Assert not found error
+	assert.Error(t, err)
+	assert.Nil(t, survey)
+	assert.Contains(t, err.Error(), "no rows")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_HasResponded(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM survey_responses").
+		WithArgs(1, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	// Call function under test
+	responded, err := repo.HasResponded(context.Background(), 1, 5)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, responded)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_SubmitResponse(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	score := 9
+	resp := &Response{
+		SurveyID: 1,
+		UserID:   5,
+		Answers: []Answer{
+			{QuestionID: 10, OptionIDs: []int64{100, 101}},
+			{QuestionID: 11, Text: "Keep it up"},
+			{QuestionID: 12, Value: &score},
+		},
+	}
+
+	// Setup expectations - one row per selected option, text or value
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO survey_responses").
+		WithArgs(1, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, now))
+	mock.ExpectExec("INSERT INTO survey_answers").
+		WithArgs(7, 10, 100, nil, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO survey_answers").
+		WithArgs(7, 10, 101, nil, nil).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("INSERT INTO survey_answers").
+		WithArgs(7, 11, nil, "Keep it up", nil).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec("INSERT INTO survey_answers").
+		WithArgs(7, 12, nil, nil, 9).
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectCommit()
+
+	// Call function under test
+	err = repo.SubmitResponse(context.Background(), resp)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), resp.ID)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_SubmitResponse_Failure(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations - a failed answer rolls back the whole response
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO survey_responses").
+		WithArgs(1, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, time.Now()))
+	mock.ExpectExec("INSERT INTO survey_answers").
+		WithArgs(7, 11, nil, "Keep it up", nil).
+		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	// Call function under test
+	err = repo.SubmitResponse(context.Background(), &Response{
+		SurveyID: 1,
+		UserID:   5,
+		Answers:  []Answer{{QuestionID: 11, Text: "Keep it up"}},
+	})
+
+	// Assert error occurred
+	assert.Error(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_SubmitResponse_AlreadyResponded(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations - a concurrent response already took the slot
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO survey_responses .* ON CONFLICT \\(survey_id, user_id\\) DO NOTHING").
+		WithArgs(1, 5).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	// Call function under test
+	err = repo.SubmitResponse(context.Background(), &Response{
+		SurveyID: 1,
+		UserID:   5,
+		Answers:  []Answer{{QuestionID: 11, Text: "Keep it up"}},
+	})
+
+	// Assert conflict
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "already responded")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetAnsweredCounts(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectQuery("SELECT a.question_id, COUNT\\(DISTINCT a.response_id\\)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"question_id", "count"}).AddRow(10, 4).AddRow(11, 2))
+
+	// Call function under test
+	counts, err := repo.GetAnsweredCounts(context.Background(), 1)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, map[int64]int64{10: 4, 11: 2}, counts)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetAnswerCounts(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectQuery("SELECT a.question_id, a.option_id, a.number_value, COUNT\\(\\*\\)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"question_id", "option_id", "number_value", "count"}).
+			AddRow(10, 100, nil, 3).
+			AddRow(12, nil, 9, 2))
+
+	// Call function under test
+	counts, err := repo.GetAnswerCounts(context.Background(), 1)
+
+	// Assert option and value counts are told apart
+	assert.NoError(t, err)
+	require.Len(t, counts, 2)
+	assert.Equal(t, int64(100), *counts[0].OptionID)
+	assert.Nil(t, counts[0].Value)
+	assert.Nil(t, counts[1].OptionID)
+	assert.Equal(t, 9, *counts[1].Value)
+	assert.Equal(t, int64(2), counts[1].Count)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetTextAnswers(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectQuery("ROW_NUMBER\\(\\) OVER \\(PARTITION BY a.question_id ORDER BY a.id DESC\\)").
+		WithArgs(1, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"question_id", "text_value"}).
+			AddRow(11, "newest").
+			AddRow(11, "older"))
+
+	// Call function under test
+	texts, err := repo.GetTextAnswers(context.Background(), 1, 20)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, map[int64][]string{11: {"newest", "older"}}, texts)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
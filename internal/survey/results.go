@@ -0,0 +1,91 @@
+package survey
+
+import "math"
+
+// tallyQuestion aggregates the answers to a question. answered is the number
+// of responses that answered it, counts its option and value counts and
+// texts its most recent text answers.
+func tallyQuestion(q *Question, answered int64, counts []AnswerCount, texts []string) QuestionResult {
+	result := QuestionResult{
+		QuestionID: q.ID,
+		Position:   q.Position,
+		Type:       q.Type,
+		Prompt:     q.Prompt,
+		Answered:   answered,
+	}
+
+	switch q.Type {
+	case TypeSingle, TypeMulti:
+		picked := make(map[int64]int64, len(counts))
+		for _, c := range counts {
+			if c.OptionID != nil {
+				picked[*c.OptionID] += c.Count
+			}
+		}
+		result.Options = make([]OptionResult, len(q.Options))
+		for i, opt := range q.Options {
+			result.Options[i] = OptionResult{ID: opt.ID, Text: opt.Text, Count: picked[opt.ID]}
+			result.Options[i].Percentage = percentage(picked[opt.ID], answered)
+		}
+
+	case TypeRating, TypeNPS:
+		low, high := RatingMin, RatingMax
+		if q.Type == TypeNPS {
+			low, high = NPSMin, NPSMax
+		}
+		given := make(map[int]int64, len(counts))
+		for _, c := range counts {
+			if c.Value != nil {
+				given[*c.Value] += c.Count
+			}
+		}
+
+		var total, sum int64
+		result.Distribution = make([]ValueCount, 0, high-low+1)
+		for v := low; v <= high; v++ {
+			result.Distribution = append(result.Distribution, ValueCount{Value: v, Count: given[v]})
+			total += given[v]
+			sum += int64(v) * given[v]
+		}
+		if total > 0 {
+			avg := math.Round(float64(sum)*100/float64(total)) / 100
+			result.Average = &avg
+		}
+		if q.Type == TypeNPS {
+			result.NPS = netPromoterScore(given, total)
+		}
+
+	case TypeText:
+		result.Texts = texts
+		if result.Texts == nil {
+			result.Texts = []string{}
+		}
+	}
+	return result
+}
+
+// netPromoterScore computes the NPS from the number of times each score was
+// given: promoters score 9-10, passives 7-8 and detractors 0-6.
+func netPromoterScore(given map[int]int64, total int64) *NPSResult {
+	nps := &NPSResult{}
+	for score, count := range given {
+		switch {
+		case score >= 9:
+			nps.Promoters += count
+		case score >= 7:
+			nps.Passives += count
+		default:
+			nps.Detractors += count
+		}
+	}
+	nps.Score = percentage(nps.Promoters-nps.Detractors, total)
+	return nps
+}
+
+// percentage returns n as a percentage of base, rounded to one decimal.
+func percentage(n, base int64) float64 {
+	if base == 0 {
+		return 0
+	}
+	return math.Round(float64(n)*1000/float64(base)) / 10
+}
@@ -0,0 +1,78 @@
+package survey
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTallyQuestion_Rating(t *testing.T) {
+	value := func(v int) *int { return &v }
+	q := &Question{ID: 1, Position: 1, Type: TypeRating, Prompt: "Rate us"}
+
+	result := tallyQuestion(q, 3, []AnswerCount{
+		{QuestionID: 1, Value: value(2), Count: 1},
+		{QuestionID: 1, Value: value(5), Count: 2},
+	}, nil)
+
+	assert.Equal(t, int64(3), result.Answered)
+	require.Len(t, result.Distribution, RatingMax-RatingMin+1)
+	assert.Equal(t, ValueCount{Value: 1, Count: 0}, result.Distribution[0])
+	assert.Equal(t, ValueCount{Value: 5, Count: 2}, result.Distribution[4])
+	require.NotNil(t, result.Average)
+	assert.Equal(t, 4.0, *result.Average)
+	assert.Nil(t, result.NPS)
+}
+
+func TestTallyQuestion_NoAnswers(t *testing.T) {
+	rating := tallyQuestion(&Question{ID: 1, Type: TypeRating}, 0, nil, nil)
+	assert.Nil(t, rating.Average)
+	assert.Len(t, rating.Distribution, RatingMax-RatingMin+1)
+
+	text := tallyQuestion(&Question{ID: 2, Type: TypeText}, 0, nil, nil)
+	assert.Equal(t, []string{}, text.Texts)
+
+	choice := tallyQuestion(&Question{ID: 3, Type: TypeSingle, Options: []Option{{ID: 1, Text: "A"}, {ID: 2, Text: "B"}}}, 0, nil, nil)
+	assert.Equal(t, []OptionResult{{ID: 1, Text: "A"}, {ID: 2, Text: "B"}}, choice.Options)
+}
+
+func TestNetPromoterScore(t *testing.T) {
+	tests := []struct {
+		name     string
+		given    map[int]int64
+		total    int64
+		expected NPSResult
+	}{
+		{
+			name:     "All promoters",
+			given:    map[int]int64{9: 1, 10: 3},
+			total:    4,
+			expected: NPSResult{Score: 100, Promoters: 4},
+		},
+		{
+			name:     "All detractors",
+			given:    map[int]int64{0: 2, 6: 1},
+			total:    3,
+			expected: NPSResult{Score: -100, Detractors: 3},
+		},
+		{
+			name:     "Mixed",
+			given:    map[int]int64{10: 1, 7: 1, 8: 1},
+			total:    3,
+			expected: NPSResult{Score: 33.3, Promoters: 1, Passives: 2},
+		},
+		{
+			name:     "No answers",
+			given:    map[int]int64{},
+			total:    0,
+			expected: NPSResult{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, *netPromoterScore(tt.given, tt.total))
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package survey
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/phsaurav/echo_prod_blueprint/internal/database"
+)
+
+type SurveyService interface {
+	CreateSurvey(c echo.Context) error
+	GetSurvey(c echo.Context) error
+	SubmitResponse(c echo.Context) error
+	GetResults(c echo.Context) error
+}
+
+func Register(g *echo.Group, db database.Service, authMiddleware echo.MiddlewareFunc) {
+	repo := NewRepo(db)
+	service := NewService(repo)
+	RegisterRoutes(g, service, authMiddleware)
+}
+
+func RegisterRoutes(g *echo.Group, service SurveyService, authMiddleware echo.MiddlewareFunc) {
+	g.POST("", service.CreateSurvey, authMiddleware)
+	g.GET("/:id", service.GetSurvey)
+	g.POST("/:id/responses", service.SubmitResponse, authMiddleware)
+	g.GET("/:id/results", service.GetResults, authMiddleware)
+}
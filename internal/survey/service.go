@@ -0,0 +1,409 @@
+package survey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/phsaurav/echo_prod_blueprint/pkg/auth"
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/response"
+)
+
+type Repository interface {
+	Create(ctx context.Context, s *Survey) error
+	GetByID(ctx context.Context, id int64) (*Survey, error)
+	HasResponded(ctx context.Context, surveyID, userID int64) (bool, error)
+	SubmitResponse(ctx context.Context, resp *Response) error
+	CountResponses(ctx context.Context, surveyID int64) (int64, error)
+	GetAnsweredCounts(ctx context.Context, surveyID int64) (map[int64]int64, error)
+	GetAnswerCounts(ctx context.Context, surveyID int64) ([]AnswerCount, error)
+	GetTextAnswers(ctx context.Context, surveyID int64, limit int) (map[int64][]string, error)
+}
+
+const (
+	// maxQuestions caps the number of questions in a survey.
+	maxQuestions = 50
+	// maxTitleLength, maxPromptLength and maxOptionLength cap the survey
+	// title, question prompts and option texts, in characters.
+	maxTitleLength  = 255
+	maxPromptLength = 500
+	maxOptionLength = 255
+	// maxTextLength caps the length of a text answer, in characters.
+	maxTextLength = 2000
+	// textAnswersLimit is how many recent answers per text question the
+	// results include.
+	textAnswersLimit = 20
+)
+
+// Service implements the consumer-side SurveyService interface.
+type Service struct {
+	Repo Repository
+}
+
+// NewService creates a new survey service instance.
+func NewService(repo Repository) *Service {
+	return &Service{Repo: repo}
+}
+
+// CreateSurvey creates a survey with its questions
+// @Summary Create a new survey
+// @Description Create a survey of ordered questions. Questions can be single or multi choice, free text, a 1-5 rating or a 0-10 Net Promoter Score.
+// @Tags surveys
+// @Accept json
+// @Produce json
+// @Param request body CreateSurveyRequest true "Survey creation request"
+// @Success 200 {object} Survey "Successfully created survey"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid input"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/survey [post]
+func (s *Service) CreateSurvey(c echo.Context) error {
+	var req CreateSurveyRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	if req.Title == "" {
+		return response.ErrorBuilder(errs.BaseErr("title is required")).Send(c)
+	}
+	if utf8.RuneCountInString(req.Title) > maxTitleLength {
+		return response.ErrorBuilder(errs.BaseErr(fmt.Sprintf("title can be at most %d characters", maxTitleLength))).Send(c)
+	}
+	if len(req.Questions) == 0 {
+		return response.ErrorBuilder(errs.BaseErr("at least one question is required")).Send(c)
+	}
+	if len(req.Questions) > maxQuestions {
+		return response.ErrorBuilder(errs.BaseErr(fmt.Sprintf("a survey can have at most %d questions", maxQuestions))).Send(c)
+	}
+
+	survey := &Survey{
+		Title:       req.Title,
+		Description: req.Description,
+		UserID:      auth.UserID(c),
+		Questions:   make([]Question, len(req.Questions)),
+	}
+	for i, qr := range req.Questions {
+		q, err := newQuestion(i+1, qr)
+		if err != nil {
+			return response.ErrorBuilder(err).Send(c)
+		}
+		survey.Questions[i] = q
+	}
+
+	if err := s.Repo.Create(c.Request().Context(), survey); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(survey).Send(c)
+}
+
+// newQuestion validates a question of a new survey and builds it at the
+// given position.
+func newQuestion(position int, req CreateQuestionRequest) (Question, error) {
+	q := Question{
+		Position: position,
+		Type:     req.Type,
+		Prompt:   req.Prompt,
+		Required: req.Required,
+	}
+	if q.Prompt == "" {
+		return q, questionErr(position, "prompt is required")
+	}
+	if utf8.RuneCountInString(q.Prompt) > maxPromptLength {
+		return q, questionErr(position, fmt.Sprintf("prompt can be at most %d characters", maxPromptLength))
+	}
+
+	switch q.Type {
+	case TypeSingle, TypeMulti:
+		if len(req.Options) < 2 {
+			return q, questionErr(position, "at least two options are required")
+		}
+		for _, text := range req.Options {
+			if text == "" || utf8.RuneCountInString(text) > maxOptionLength {
+				return q, questionErr(position, fmt.Sprintf("options must be between 1 and %d characters", maxOptionLength))
+			}
+			q.Options = append(q.Options, Option{Text: text})
+		}
+	case TypeText, TypeRating, TypeNPS:
+		if len(req.Options) > 0 {
+			return q, questionErr(position, "options only apply to single and multi questions")
+		}
+	default:
+		return q, questionErr(position, "type must be one of single, multi, text, rating, nps")
+	}
+
+	if q.Type == TypeMulti {
+		q.MinSelections, q.MaxSelections = req.MinSelections, req.MaxSelections
+		if q.MinSelections == 0 {
+			q.MinSelections = 1
+		}
+		if q.MaxSelections == 0 {
+			q.MaxSelections = len(q.Options)
+		}
+		if q.MinSelections < 1 || q.MinSelections > q.MaxSelections || q.MaxSelections > len(q.Options) {
+			return q, questionErr(position, "selections must satisfy 1 <= min_selections <= max_selections <= number of options")
+		}
+	} else if req.MinSelections != 0 || req.MaxSelections != 0 {
+		return q, questionErr(position, "min_selections and max_selections only apply to multi questions")
+	}
+	return q, nil
+}
+
+// GetSurvey retrieves a survey by ID
+// @Summary Get survey information
+// @Description Get a survey with its questions in order and their options
+// @Tags surveys
+// @Accept json
+// @Produce json
+// @Param id path int true "Survey ID"
+// @Success 200 {object} Survey "Survey details with questions"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid ID format"
+// @Failure 404 {object} response.FailedResponse "Not found - survey doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Router /api/v1/survey/{id} [get]
+func (s *Service) GetSurvey(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	survey, err := s.Repo.GetByID(c.Request().Context(), id)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(survey).Send(c)
+}
+
+// SubmitResponse records the caller's answers to a survey
+// @Summary Submit a survey response
+// @Description Answer a survey in one submission. Every required question must be answered; choice questions take option_ids, text questions take text and rating and NPS questions take value. A user can respond once.
+// @Tags surveys
+// @Accept json
+// @Produce json
+// @Param id path int true "Survey ID"
+// @Param request body SubmitResponseRequest true "Answers to the survey's questions"
+// @Success 200 {object} SubmitResponseResponse "Response successfully recorded"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid answers or survey ID"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 404 {object} response.FailedResponse "Not found - survey doesn't exist"
+// @Failure 409 {object} response.FailedResponse "Conflict - already responded"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/survey/{id}/responses [post]
+func (s *Service) SubmitResponse(c echo.Context) error {
+	surveyID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	var req SubmitResponseRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	survey, err := s.Repo.GetByID(ctx, surveyID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if err := validateAnswers(survey, req.Answers); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	userID := auth.UserID(c)
+	responded, err := s.Repo.HasResponded(ctx, surveyID, userID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if responded {
+		return response.ErrorBuilder(errs.Conflict(errors.New("already responded"))).Send(c)
+	}
+
+	resp := &Response{SurveyID: surveyID, UserID: userID, Answers: req.Answers}
+	if err := s.Repo.SubmitResponse(ctx, resp); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(SubmitResponseResponse{
+		Message:    "Response submitted successfully",
+		SurveyID:   surveyID,
+		ResponseID: resp.ID,
+		Timestamp:  time.Now().Format(time.RFC3339),
+	}).Send(c)
+}
+
+// validateAnswers checks a submission against the survey: every answer must
+// be to a question of the survey, at most once, fit the question's type,
+// and every required question must be answered.
+func validateAnswers(s *Survey, answers []Answer) error {
+	questions := make(map[int64]*Question, len(s.Questions))
+	for i := range s.Questions {
+		questions[s.Questions[i].ID] = &s.Questions[i]
+	}
+
+	answered := make(map[int64]bool, len(answers))
+	for _, a := range answers {
+		q, ok := questions[a.QuestionID]
+		if !ok {
+			return errs.BaseErr("question does not belong to this survey")
+		}
+		if answered[q.ID] {
+			return questionErr(q.Position, "answered more than once")
+		}
+		answered[q.ID] = true
+		if err := validateAnswer(q, a); err != nil {
+			return err
+		}
+	}
+
+	for _, q := range s.Questions {
+		if q.Required && !answered[q.ID] {
+			return questionErr(q.Position, "an answer is required")
+		}
+	}
+	return nil
+}
+
+// validateAnswer checks that an answer fits its question's type.
+func validateAnswer(q *Question, a Answer) error {
+	if q.IsChoice() {
+		if a.Text != "" || a.Value != nil {
+			return questionErr(q.Position, "answer with option_ids")
+		}
+		seen := make(map[int64]bool, len(a.OptionIDs))
+		for _, id := range a.OptionIDs {
+			if !hasOption(q, id) {
+				return questionErr(q.Position, "option does not belong to this question")
+			}
+			if seen[id] {
+				return questionErr(q.Position, "an option can only be selected once")
+			}
+			seen[id] = true
+		}
+		if q.Type == TypeMulti {
+			if len(a.OptionIDs) < q.MinSelections || len(a.OptionIDs) > q.MaxSelections {
+				return questionErr(q.Position, fmt.Sprintf("select between %d and %d options", q.MinSelections, q.MaxSelections))
+			}
+		} else if len(a.OptionIDs) != 1 {
+			return questionErr(q.Position, "exactly one option must be selected")
+		}
+		return nil
+	}
+
+	if len(a.OptionIDs) > 0 {
+		return questionErr(q.Position, "option_ids only apply to single and multi questions")
+	}
+	switch q.Type {
+	case TypeText:
+		if a.Value != nil {
+			return questionErr(q.Position, "answer with text")
+		}
+		if a.Text == "" {
+			return questionErr(q.Position, "text is required")
+		}
+		if utf8.RuneCountInString(a.Text) > maxTextLength {
+			return questionErr(q.Position, fmt.Sprintf("text can be at most %d characters", maxTextLength))
+		}
+	case TypeRating, TypeNPS:
+		low, high := RatingMin, RatingMax
+		if q.Type == TypeNPS {
+			low, high = NPSMin, NPSMax
+		}
+		if a.Text != "" || a.Value == nil {
+			return questionErr(q.Position, "answer with value")
+		}
+		if *a.Value < low || *a.Value > high {
+			return questionErr(q.Position, fmt.Sprintf("value must be between %d and %d", low, high))
+		}
+	}
+	return nil
+}
+
+// GetResults retrieves the aggregated results of a survey
+// @Summary Get survey results
+// @Description Get per-question results: option counts and percentages for choice questions, the distribution and average of ratings and scores, the Net Promoter Score of NPS questions and the most recent text answers. Only the survey owner can view results, as text answers are returned verbatim.
+// @Tags surveys
+// @Accept json
+// @Produce json
+// @Param id path int true "Survey ID"
+// @Success 200 {object} SurveyResultsResponse "Aggregated survey results"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid survey ID format"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - authentication required"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not the survey owner"
+// @Failure 404 {object} response.FailedResponse "Not found - survey doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security BearerAuth
+// @Router /api/v1/survey/{id}/results [get]
+func (s *Service) GetResults(c echo.Context) error {
+	surveyID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	survey, err := s.Repo.GetByID(ctx, surveyID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if survey.UserID != auth.UserID(c) {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("only the survey owner can view its results"))).Send(c)
+	}
+
+	total, err := s.Repo.CountResponses(ctx, surveyID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	answered, err := s.Repo.GetAnsweredCounts(ctx, surveyID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	counts, err := s.Repo.GetAnswerCounts(ctx, surveyID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	texts, err := s.Repo.GetTextAnswers(ctx, surveyID, textAnswersLimit)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	byQuestion := make(map[int64][]AnswerCount)
+	for _, count := range counts {
+		byQuestion[count.QuestionID] = append(byQuestion[count.QuestionID], count)
+	}
+
+	results := SurveyResultsResponse{
+		SurveyID:       survey.ID,
+		Title:          survey.Title,
+		TotalResponses: total,
+		Questions:      make([]QuestionResult, len(survey.Questions)),
+	}
+	for i := range survey.Questions {
+		q := &survey.Questions[i]
+		results.Questions[i] = tallyQuestion(q, answered[q.ID], byQuestion[q.ID], texts[q.ID])
+	}
+
+	return response.SuccessBuilder(results).Send(c)
+}
+
+// questionErr builds a validation error naming the question's position.
+func questionErr(position int, msg string) error {
+	return errs.BaseErr(fmt.Sprintf("question %d: %s", position, msg))
+}
+
+// hasOption reports whether an option belongs to the question.
+func hasOption(q *Question, optionID int64) bool {
+	for _, opt := range q.Options {
+		if opt.ID == optionID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,459 @@
+package survey
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/phsaurav/echo_prod_blueprint/testutils"
+)
+
+func TestNewService(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo)
+
+	assert.NotNil(t, service)
+	assert.Equal(t, mockRepo, service.Repo)
+}
+
+// testSurvey returns a survey with one question of every type.
+func testSurvey() *Survey {
+	return &Survey{
+		ID:     1,
+		Title:  "Feedback",
+		UserID: 5,
+		Questions: []Question{
+			{ID: 10, SurveyID: 1, Position: 1, Type: TypeSingle, Prompt: "Source?", Required: true,
+				Options: []Option{{ID: 100, QuestionID: 10, Text: "Search"}, {ID: 101, QuestionID: 10, Text: "Friend"}}},
+			{ID: 11, SurveyID: 1, Position: 2, Type: TypeMulti, Prompt: "Features?", MinSelections: 1, MaxSelections: 2,
+				Options: []Option{{ID: 110, QuestionID: 11, Text: "Export"}, {ID: 111, QuestionID: 11, Text: "Live"}, {ID: 112, QuestionID: 11, Text: "API"}}},
+			{ID: 12, SurveyID: 1, Position: 3, Type: TypeText, Prompt: "Anything else?"},
+			{ID: 13, SurveyID: 1, Position: 4, Type: TypeRating, Prompt: "Rate us", Required: true},
+			{ID: 14, SurveyID: 1, Position: 5, Type: TypeNPS, Prompt: "Recommend us?"},
+		},
+	}
+}
+
+func TestService_CreateSurvey(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Valid survey",
+			requestBody: `{"title": "Feedback", "questions": [
+				{"type": "single", "prompt": "Source?", "required": true, "options": ["Search", "Friend"]},
+				{"type": "multi", "prompt": "Features?", "options": ["Export", "Live", "API"]},
+				{"type": "nps", "prompt": "Recommend us?"}
+			]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("Create", mock.Anything, mock.MatchedBy(func(s *Survey) bool {
+					return s.Title == "Feedback" && s.UserID == 5 && len(s.Questions) == 3 &&
+						s.Questions[2].Position == 3 &&
+						s.Questions[1].MinSelections == 1 && s.Questions[1].MaxSelections == 3
+				})).Return(nil).Run(func(args mock.Arguments) {
+					args.Get(1).(*Survey).ID = 1
+				})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"id":1,"title":"Feedback"`,
+		},
+		{
+			name:           "Missing title",
+			requestBody:    `{"questions": [{"type": "text", "prompt": "Thoughts?"}]}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"title is required"`,
+		},
+		{
+			name:           "No questions",
+			requestBody:    `{"title": "Feedback"}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"at least one question is required"`,
+		},
+		{
+			name:           "Unknown question type",
+			requestBody:    `{"title": "Feedback", "questions": [{"type": "text", "prompt": "Thoughts?"}, {"type": "slider", "prompt": "How much?"}]}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"question 2: type must be one of single, multi, text, rating, nps"`,
+		},
+		{
+			name:           "Choice question with one option",
+			requestBody:    `{"title": "Feedback", "questions": [{"type": "single", "prompt": "Source?", "options": ["Search"]}]}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"question 1: at least two options are required"`,
+		},
+		{
+			name:           "Title too long",
+			requestBody:    `{"title": "` + strings.Repeat("a", maxTitleLength+1) + `", "questions": [{"type": "text", "prompt": "Thoughts?"}]}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"title can be at most 255 characters"`,
+		},
+		{
+			name:           "Prompt too long",
+			requestBody:    `{"title": "Feedback", "questions": [{"type": "text", "prompt": "` + strings.Repeat("a", maxPromptLength+1) + `"}]}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"question 1: prompt can be at most 500 characters"`,
+		},
+		{
+			name:           "Empty option",
+			requestBody:    `{"title": "Feedback", "questions": [{"type": "single", "prompt": "Source?", "options": ["Search", ""]}]}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"question 1: options must be between 1 and 255 characters"`,
+		},
+		{
+			name:           "Options on a rating question",
+			requestBody:    `{"title": "Feedback", "questions": [{"type": "rating", "prompt": "Rate us", "options": ["1", "2"]}]}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"question 1: options only apply to single and multi questions"`,
+		},
+		{
+			name:           "Invalid multi selection bounds",
+			requestBody:    `{"title": "Feedback", "questions": [{"type": "multi", "prompt": "Features?", "options": ["A", "B"], "max_selections": 3}]}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"question 1: selections must satisfy`,
+		},
+		{
+			name:        "Repository error",
+			requestBody: `{"title": "Feedback", "questions": [{"type": "text", "prompt": "Thoughts?"}]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("Create", mock.Anything, mock.Anything).Return(errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"database error"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.CreateContext(http.MethodPost, "/", tt.requestBody)
+			testutils.AddUserToken(c, 5)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.CreateSurvey(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_GetSurvey(t *testing.T) {
+	tests := []struct {
+		name           string
+		idParam        string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:    "Existing survey",
+			idParam: "1",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(testSurvey(), nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"position":1,"type":"single","prompt":"Source?","required":true`,
+		},
+		{
+			name:           "Invalid ID",
+			idParam:        "abc",
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"strconv.ParseInt: parsing \"abc\": invalid syntax"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.CreateContext(http.MethodGet, "/", "")
+			c.SetParamNames("id")
+			c.SetParamValues(tt.idParam)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.GetSurvey(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_SubmitResponse(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Complete response",
+			requestBody: `{"answers": [
+				{"question_id": 10, "option_ids": [101]},
+				{"question_id": 11, "option_ids": [110, 112]},
+				{"question_id": 12, "text": "Keep it up"},
+				{"question_id": 13, "value": 5},
+				{"question_id": 14, "value": 0}
+			]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(testSurvey(), nil)
+				repo.On("HasResponded", mock.Anything, int64(1), int64(5)).Return(false, nil)
+				repo.On("SubmitResponse", mock.Anything, mock.MatchedBy(func(r *Response) bool {
+					return r.SurveyID == 1 && r.UserID == 5 && len(r.Answers) == 5
+				})).Return(nil).Run(func(args mock.Arguments) {
+					args.Get(1).(*Response).ID = 7
+				})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"Response submitted successfully","survey_id":1,"response_id":7`,
+		},
+		{
+			name:        "Optional questions left out",
+			requestBody: `{"answers": [{"question_id": 10, "option_ids": [100]}, {"question_id": 13, "value": 3}]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(testSurvey(), nil)
+				repo.On("HasResponded", mock.Anything, int64(1), int64(5)).Return(false, nil)
+				repo.On("SubmitResponse", mock.Anything, mock.Anything).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"Response submitted successfully"`,
+		},
+		{
+			name:        "Required question missing",
+			requestBody: `{"answers": [{"question_id": 10, "option_ids": [100]}]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(testSurvey(), nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"question 4: an answer is required"`,
+		},
+		{
+			name:        "Rating out of range",
+			requestBody: `{"answers": [{"question_id": 10, "option_ids": [100]}, {"question_id": 13, "value": 6}]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(testSurvey(), nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"question 4: value must be between 1 and 5"`,
+		},
+		{
+			name:        "NPS out of range",
+			requestBody: `{"answers": [{"question_id": 10, "option_ids": [100]}, {"question_id": 13, "value": 3}, {"question_id": 14, "value": 11}]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(testSurvey(), nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"question 5: value must be between 0 and 10"`,
+		},
+		{
+			name:        "Too many options on a multi question",
+			requestBody: `{"answers": [{"question_id": 10, "option_ids": [100]}, {"question_id": 11, "option_ids": [110, 111, 112]}, {"question_id": 13, "value": 3}]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(testSurvey(), nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"question 2: select between 1 and 2 options"`,
+		},
+		{
+			name:        "Option of another question",
+			requestBody: `{"answers": [{"question_id": 10, "option_ids": [110]}]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(testSurvey(), nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"question 1: option does not belong to this question"`,
+		},
+		{
+			name:        "Value on a text question",
+			requestBody: `{"answers": [{"question_id": 12, "value": 3}]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(testSurvey(), nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"question 3: answer with text"`,
+		},
+		{
+			name:        "Question answered twice",
+			requestBody: `{"answers": [{"question_id": 10, "option_ids": [100]}, {"question_id": 10, "option_ids": [101]}]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(testSurvey(), nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"question 1: answered more than once"`,
+		},
+		{
+			name:        "Unknown question",
+			requestBody: `{"answers": [{"question_id": 99, "text": "Hi"}]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(testSurvey(), nil)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"question does not belong to this survey"`,
+		},
+		{
+			name:        "Already responded",
+			requestBody: `{"answers": [{"question_id": 10, "option_ids": [100]}, {"question_id": 13, "value": 3}]}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(testSurvey(), nil)
+				repo.On("HasResponded", mock.Anything, int64(1), int64(5)).Return(true, nil)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `"error":"already responded"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.CreateContext(http.MethodPost, "/", tt.requestBody)
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			testutils.AddUserToken(c, 5)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo)
+
+			// Execute
+			err := service.SubmitResponse(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_GetResults(t *testing.T) {
+	option := func(id int64) *int64 { return &id }
+	value := func(v int) *int { return &v }
+
+	// Setup
+	c, rec := testutils.CreateContext(http.MethodGet, "/", "")
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+	testutils.AddUserToken(c, 5)
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetByID", mock.Anything, int64(1)).Return(testSurvey(), nil)
+	mockRepo.On("CountResponses", mock.Anything, int64(1)).Return(int64(4), nil)
+	mockRepo.On("GetAnsweredCounts", mock.Anything, int64(1)).Return(map[int64]int64{10: 4, 12: 1, 13: 4, 14: 4}, nil)
+	mockRepo.On("GetAnswerCounts", mock.Anything, int64(1)).Return([]AnswerCount{
+		{QuestionID: 10, OptionID: option(100), Count: 3},
+		{QuestionID: 10, OptionID: option(101), Count: 1},
+		{QuestionID: 13, Value: value(4), Count: 2},
+		{QuestionID: 13, Value: value(5), Count: 2},
+		{QuestionID: 14, Value: value(10), Count: 2},
+		{QuestionID: 14, Value: value(8), Count: 1},
+		{QuestionID: 14, Value: value(3), Count: 1},
+	}, nil)
+	mockRepo.On("GetTextAnswers", mock.Anything, int64(1), textAnswersLimit).Return(map[int64][]string{12: {"Keep it up"}}, nil)
+
+	service := NewService(mockRepo)
+
+	// Execute
+	err := service.GetResults(c)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, `"survey_id":1,"title":"Feedback","total_responses":4`)
+	assert.Contains(t, body, `"options":[{"id":100,"text":"Search","count":3,"percentage":75},{"id":101,"text":"Friend","count":1,"percentage":25}]`)
+	assert.Contains(t, body, `"texts":["Keep it up"]`)
+	assert.Contains(t, body, `"average":4.5`)
+	assert.Contains(t, body, `"nps":{"score":25,"promoters":2,"passives":1,"detractors":1}`)
+
+	// Verify mocks
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_GetResults_NotOwner(t *testing.T) {
+	// Setup
+	c, rec := testutils.CreateContext(http.MethodGet, "/", "")
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+	testutils.AddUserToken(c, 6)
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetByID", mock.Anything, int64(1)).Return(testSurvey(), nil)
+
+	service := NewService(mockRepo)
+
+	// Execute
+	err := service.GetResults(c)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"error":"only the survey owner can view its results"`)
+
+	// Verify mocks
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_GetResults_NotFound(t *testing.T) {
+	// Setup
+	c, rec := testutils.CreateContext(http.MethodGet, "/", "")
+	c.SetParamNames("id")
+	c.SetParamValues("99")
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetByID", mock.Anything, int64(99)).Return(nil, errors.New("not found"))
+
+	service := NewService(mockRepo)
+
+	// Execute
+	err := service.GetResults(c)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"error":"not found"`)
+
+	// Verify mocks
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,13 @@
+package user
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/phsaurav/echo_prod_blueprint/pkg/auth"
+)
+
+// canViewUser is the policy for reading profiles: users may read their
+// own, and anyone granted auth.PermissionReadUsers may read any.
+func canViewUser(c echo.Context, userID int64) bool {
+	return auth.UserID(c) == userID || auth.HasPermission(c, auth.PermissionReadUsers)
+}
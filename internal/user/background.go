@@ -0,0 +1,65 @@
+package user
+
+import (
+	"context"
+	"sync"
+)
+
+// background runs tasks outside the request that started them, at most
+// limit at a time, and lets shutdown wait for the ones still running.
+type background struct {
+	mu      sync.Mutex
+	closed  bool
+	slots   chan struct{}
+	pending sync.WaitGroup
+}
+
+// newBackground returns a runner of at most limit concurrent tasks.
+func newBackground(limit int) *background {
+	return &background{slots: make(chan struct{}, limit)}
+}
+
+// Go runs task in a new goroutine. It reports false, running nothing, when
+// limit tasks are already running or Close has been called.
+func (b *background) Go(task func()) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false
+	}
+	select {
+	case b.slots <- struct{}{}:
+	default:
+		return false
+	}
+
+	b.pending.Add(1)
+	go func() {
+		defer func() {
+			<-b.slots
+			b.pending.Done()
+		}()
+		task()
+	}()
+	return true
+}
+
+// Close stops accepting tasks and waits for the running ones to finish, or
+// for ctx to end, whichever comes first.
+func (b *background) Close(ctx context.Context) error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,28 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackground(t *testing.T) {
+	b := newBackground(1)
+	release := make(chan struct{})
+
+	// Tasks beyond the limit are refused
+	assert.True(t, b.Go(func() { <-release }))
+	assert.False(t, b.Go(func() {}))
+
+	// Close gives up once its context ends
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, b.Close(ctx), context.DeadlineExceeded)
+
+	// Once closed, nothing new runs, and Close returns when the task ends
+	close(release)
+	assert.NoError(t, b.Close(context.Background()))
+	assert.False(t, b.Go(func() {}))
+}
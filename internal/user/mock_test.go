@@ -3,9 +3,12 @@ package user
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/mock"
+
+	"github.com/phsaurav/echo_prod_blueprint/pkg/mail"
 )
 
 // MockRepository implements user.Repository interface for testing
@@ -44,6 +47,114 @@ func (m *MockRepository) ActivateUser(ctx context.Context, id int64) error {
 	return args.Error(0)
 }
 
+func (m *MockRepository) MarkVerificationSent(ctx context.Context, id int64, interval time.Duration) (bool, error) {
+	args := m.Called(ctx, id, interval)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) GetPassword(ctx context.Context, id int64) (string, error) {
+	args := m.Called(ctx, id)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockRepository) CreatePasswordReset(ctx context.Context, userID int64, tokenHash string, expiresAt time.Time) error {
+	args := m.Called(ctx, userID, tokenHash, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ResetPassword(ctx context.Context, tokenHash, password string) (int64, error) {
+	args := m.Called(ctx, tokenHash, password)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) CreateSession(ctx context.Context, session *Session, t *RefreshToken) error {
+	args := m.Called(ctx, session, t)
+	return args.Error(0)
+}
+
+func (m *MockRepository) RotateRefreshToken(ctx context.Context, oldHash string, next *RefreshToken) error {
+	args := m.Called(ctx, oldHash, next)
+	return args.Error(0)
+}
+
+func (m *MockRepository) RevokeTokenSession(ctx context.Context, userID int64, tokenHash string) error {
+	args := m.Called(ctx, userID, tokenHash)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListSessions(ctx context.Context, userID int64) ([]Session, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Session), args.Error(1)
+}
+
+func (m *MockRepository) RevokeSession(ctx context.Context, userID int64, sessionID string) error {
+	args := m.Called(ctx, userID, sessionID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) RevokeUserTokens(ctx context.Context, userID int64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	args := m.Called(ctx, jti, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetAccess(ctx context.Context, userID int64) ([]string, []string, error) {
+	args := m.Called(ctx, userID)
+	roles, _ := args.Get(0).([]string)
+	permissions, _ := args.Get(1).([]string)
+	return roles, permissions, args.Error(2)
+}
+
+func (m *MockRepository) ListUsers(ctx context.Context, limit, offset int) ([]User, error) {
+	args := m.Called(ctx, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]User), args.Error(1)
+}
+
+func (m *MockRepository) CountUsers(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) DeactivateUser(ctx context.Context, userID int64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ReactivateUser(ctx context.Context, userID int64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GrantRole(ctx context.Context, userID int64, role string) error {
+	args := m.Called(ctx, userID, role)
+	return args.Error(0)
+}
+
+func (m *MockRepository) RevokeRole(ctx context.Context, userID int64, role string) error {
+	args := m.Called(ctx, userID, role)
+	return args.Error(0)
+}
+
+// MockMailer implements mail.Mailer for testing
+type MockMailer struct {
+	mock.Mock
+}
+
+func (m *MockMailer) Send(ctx context.Context, msg mail.Message) error {
+	args := m.Called(ctx, msg)
+	return args.Error(0)
+}
+
 // MockDBService implements database.Service for testing
 type MockDBService struct {
 	mock.Mock
@@ -96,3 +207,78 @@ func (m *MockUserService) RegisterUser(c echo.Context) error {
 	args := m.Called(c)
 	return args.Error(0)
 }
+
+func (m *MockUserService) VerifyEmail(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockUserService) ResendVerification(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockUserService) ForgotPassword(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockUserService) ResetPassword(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockUserService) ChangePassword(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockUserService) RefreshToken(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockUserService) Logout(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockUserService) LogoutAll(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockUserService) ListSessions(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockUserService) RevokeSession(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockUserService) ListUsers(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockUserService) DeactivateUser(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockUserService) ReactivateUser(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockUserService) PromoteUser(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockUserService) DemoteUser(c echo.Context) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
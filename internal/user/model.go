@@ -9,6 +9,15 @@ type User struct {
 	Password  string    `json:"-" description:"User's password"`
 	CreatedAt time.Time `json:"created_at" example:"2023-01-01T12:00:00Z"`
 	IsActive  bool      `json:"is_active" example:"true" description:"Whether the user account is active"`
+	// TokenVersion is bumped on every password change, revoking the
+	// tokens issued before.
+	TokenVersion int `json:"-"`
+	// DeactivatedAt is set when an admin deactivates the account.
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty" example:"2023-02-01T12:00:00Z"`
+	// Roles are the roles the user holds, and Permissions what those roles
+	// grant. Both are only loaded where needed.
+	Roles       []string `json:"roles,omitempty" example:"admin"`
+	Permissions []string `json:"-"`
 }
 
 type RegisterRequest struct {
@@ -22,6 +31,75 @@ type LoginRequest struct {
 	Password string `json:"password" example:"securePassword123" binding:"required"`
 }
 
+// ResendVerificationRequest names the account to send a new verification
+// link to.
+type ResendVerificationRequest struct {
+	Email string `json:"email" example:"john@example.com" binding:"required"`
+}
+
+// ForgotPasswordRequest names the account to email a password reset link to.
+type ForgotPasswordRequest struct {
+	Email string `json:"email" example:"john@example.com" binding:"required"`
+}
+
+// ResetPasswordRequest redeems a password reset link.
+type ResetPasswordRequest struct {
+	Token    string `json:"token" example:"q3Jv0x6S1b..." binding:"required"`
+	Password string `json:"password" example:"newSecurePassword123" binding:"required"`
+}
+
+// ChangePasswordRequest changes the signed-in user's password.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" example:"securePassword123" binding:"required"`
+	NewPassword     string `json:"new_password" example:"newSecurePassword123" binding:"required"`
+}
+
 type TokenResponse struct {
-	Token string `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..." description:"JWT token for authentication"`
+	Token        string `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..." description:"JWT token for authentication"`
+	RefreshToken string `json:"refresh_token" example:"Gx3f0h6S1b..." description:"Single-use token renewing the JWT token"`
+	ExpiresIn    int64  `json:"expires_in" example:"900" description:"Seconds until the JWT token expires"`
+}
+
+// Session is one sign-in of a user, on one device. Access tokens name the
+// session they were issued for, and stop working once it is revoked.
+type Session struct {
+	ID         string    `json:"id" example:"8ynkuSBsrhyfaBs79FiZSA"`
+	UserID     int64     `json:"-"`
+	UserAgent  string    `json:"user_agent" example:"Mozilla/5.0"`
+	IPAddress  string    `json:"ip_address" example:"203.0.113.7"`
+	CreatedAt  time.Time `json:"created_at" example:"2023-01-01T12:00:00Z"`
+	LastSeenAt time.Time `json:"last_seen_at" example:"2023-01-02T08:30:00Z"`
+	ExpiresAt  time.Time `json:"expires_at" example:"2023-01-31T12:00:00Z"`
+	// Current marks the session of the token used to list sessions.
+	Current bool `json:"current" example:"true"`
+}
+
+// RefreshToken is a stored refresh token. Every use rotates it: the token
+// is marked rotated and replaced by a new one in the same session.
+type RefreshToken struct {
+	ID        int64     `json:"id" example:"1"`
+	UserID    int64     `json:"user_id" example:"1"`
+	SessionID string    `json:"-"`
+	TokenHash string    `json:"-"`
+	UserAgent string    `json:"user_agent" example:"Mozilla/5.0"`
+	IPAddress string    `json:"ip_address" example:"203.0.113.7"`
+	CreatedAt time.Time `json:"created_at" example:"2023-01-01T12:00:00Z"`
+	ExpiresAt time.Time `json:"expires_at" example:"2023-01-31T12:00:00Z"`
+}
+
+// PromoteRequest names the role to grant a user.
+type PromoteRequest struct {
+	Role string `json:"role" example:"admin" binding:"required"`
+}
+
+// RefreshRequest renews an access token.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" example:"Gx3f0h6S1b..." binding:"required"`
+}
+
+// LogoutRequest optionally names the refresh token to revoke along with
+// the access token used to sign out. Only tokens issued before sessions
+// existed need it.
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" example:"Gx3f0h6S1b..."`
 }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/phsaurav/echo_prod_blueprint/internal/database"
 	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
@@ -27,10 +29,10 @@ var _ Repository = (*Repo)(nil)
 func (r *Repo) Create(ctx context.Context, u *User) error {
 	query := `
 		INSERT INTO users (username, email, password, created_at, is_active)
-		VALUES ($1, $2, $3, NOW(), false)
+		VALUES ($1, $2, $3, NOW(), $4)
 		RETURNING id, created_at
 	`
-	err := r.DB.QueryRowContext(ctx, query, u.Username, u.Email, u.Password).Scan(&u.ID, &u.CreatedAt)
+	err := r.DB.QueryRowContext(ctx, query, u.Username, u.Email, u.Password, u.IsActive).Scan(&u.ID, &u.CreatedAt)
 	if err != nil {
 		return errs.InternalServerError(err)
 	}
@@ -40,13 +42,13 @@ func (r *Repo) Create(ctx context.Context, u *User) error {
 // GetByID retrieves a user by their ID
 func (r *Repo) GetByID(ctx context.Context, id int64) (*User, error) {
 	query := `
-		SELECT id, username, email, created_at, is_active 
+		SELECT id, username, email, created_at, is_active, token_version, deactivated_at 
 		FROM users 
 		WHERE id = $1
 	`
 	u := new(User)
 	err := r.DB.QueryRowContext(ctx, query, id).Scan(
-		&u.ID, &u.Username, &u.Email, &u.CreatedAt, &u.IsActive)
+		&u.ID, &u.Username, &u.Email, &u.CreatedAt, &u.IsActive, &u.TokenVersion, &u.DeactivatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, errs.NotFound(err)
@@ -59,13 +61,13 @@ func (r *Repo) GetByID(ctx context.Context, id int64) (*User, error) {
 // GetByEmail retrieves a user by their email address
 func (r *Repo) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-		SELECT id, username, email, password, created_at, is_active 
+		SELECT id, username, email, password, created_at, is_active, token_version, deactivated_at 
 		FROM users 
 		WHERE email = $1
 	`
 	u := new(User)
 	err := r.DB.QueryRowContext(ctx, query, email).Scan(
-		&u.ID, &u.Username, &u.Email, &u.Password, &u.CreatedAt, &u.IsActive)
+		&u.ID, &u.Username, &u.Email, &u.Password, &u.CreatedAt, &u.IsActive, &u.TokenVersion, &u.DeactivatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, errs.NotFound(err)
@@ -75,9 +77,15 @@ func (r *Repo) GetByEmail(ctx context.Context, email string) (*User, error) {
 	return u, nil
 }
 
-// UpdatePassword updates a user's password
+// UpdatePassword updates a user's password, revoking their sessions and
+// bumping their token version to revoke every access token issued before
 func (r *Repo) UpdatePassword(ctx context.Context, id int64, password string) error {
-	query := `UPDATE users SET password = $1 WHERE id = $2`
+	query := `
+		WITH revoked AS (
+			UPDATE sessions SET revoked_at = NOW() WHERE user_id = $2 AND revoked_at IS NULL
+		)
+		UPDATE users SET password = $1, token_version = token_version + 1 WHERE id = $2
+	`
 	_, err := r.DB.ExecContext(ctx, query, password, id)
 	if err != nil {
 		return errs.InternalServerError(err)
@@ -87,11 +95,584 @@ func (r *Repo) UpdatePassword(ctx context.Context, id int64, password string) er
 
 // ActivateUser sets a user's is_active flag to true
 func (r *Repo) ActivateUser(ctx context.Context, id int64) error {
-	query := `UPDATE users SET is_active = true WHERE id = $2`
-	_, err := r.DB.ExecContext(ctx, query, id)
+	query := `UPDATE users SET is_active = true WHERE id = $1`
+	res, err := r.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	if n == 0 {
+		return errs.NotFound(sql.ErrNoRows)
+	}
+	return nil
+}
+
+// MarkVerificationSent records that a verification email is being sent to
+// an inactive user, unless one was sent less than interval ago. It reports
+// whether the send may go ahead, checking and recording in one statement so
+// concurrent requests cannot both pass.
+func (r *Repo) MarkVerificationSent(ctx context.Context, id int64, interval time.Duration) (bool, error) {
+	query := `
+		UPDATE users SET verification_sent_at = NOW()
+		WHERE id = $1 AND is_active = false
+		AND (verification_sent_at IS NULL OR verification_sent_at <= NOW() - make_interval(secs => $2))
+	`
+	res, err := r.DB.ExecContext(ctx, query, id, interval.Seconds())
+	if err != nil {
+		return false, errs.InternalServerError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errs.InternalServerError(err)
+	}
+	return n > 0, nil
+}
+
+// GetPassword retrieves the password hash of a user
+func (r *Repo) GetPassword(ctx context.Context, id int64) (string, error) {
+	var password string
+	query := `SELECT password FROM users WHERE id = $1`
+	if err := r.DB.QueryRowContext(ctx, query, id).Scan(&password); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", errs.NotFound(err)
+		}
+		return "", errs.InternalServerError(err)
+	}
+	return password, nil
+}
+
+// TokenVersion retrieves the current token version of a user
+func (r *Repo) TokenVersion(ctx context.Context, id int64) (int, error) {
+	var version int
+	query := `SELECT token_version FROM users WHERE id = $1`
+	if err := r.DB.QueryRowContext(ctx, query, id).Scan(&version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, errs.NotFound(err)
+		}
+		return 0, errs.InternalServerError(err)
+	}
+	return version, nil
+}
+
+// CreatePasswordReset stores the hash of a new password reset token,
+// replacing any unused ones of the user so only the latest link works
+func (r *Repo) CreatePasswordReset(ctx context.Context, userID int64, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM password_resets WHERE user_id = $1 AND used_at IS NULL`, userID); err != nil {
+		return errs.InternalServerError(err)
+	}
+	query := `INSERT INTO password_resets (user_id, token_hash, expires_at, created_at) VALUES ($1, $2, $3, NOW())`
+	if _, err := tx.ExecContext(ctx, query, userID, tokenHash, expiresAt); err != nil {
+		return errs.InternalServerError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// ResetPassword redeems an unused, unexpired password reset token and sets
+// the password of its user in one transaction, revoking their tokens as
+// UpdatePassword does. It returns the user's ID, or a not found error when the token
+// cannot be redeemed.
+func (r *Repo) ResetPassword(ctx context.Context, tokenHash, password string) (int64, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errs.InternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	query := `
+		UPDATE password_resets SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`
+	if err := tx.QueryRowContext(ctx, query, tokenHash).Scan(&userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, errs.NotFound(errors.New("reset link is invalid or has expired"))
+		}
+		return 0, errs.InternalServerError(err)
+	}
+
+	query = `
+		WITH revoked AS (
+			UPDATE sessions SET revoked_at = NOW() WHERE user_id = $2 AND revoked_at IS NULL
+		)
+		UPDATE users SET password = $1, token_version = token_version + 1 WHERE id = $2
+	`
+	if _, err := tx.ExecContext(ctx, query, password, userID); err != nil {
+		return 0, errs.InternalServerError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errs.InternalServerError(err)
+	}
+	return userID, nil
+}
+
+// CreateSession stores a new session along with its first refresh token
+func (r *Repo) CreateSession(ctx context.Context, session *Session, t *RefreshToken) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO sessions (id, user_id, user_agent, ip_address, created_at, last_seen_at, expires_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW(), $5)
+		RETURNING created_at, last_seen_at
+	`
+	err = tx.QueryRowContext(ctx, query, session.ID, session.UserID, session.UserAgent, session.IPAddress, session.ExpiresAt).
+		Scan(&session.CreatedAt, &session.LastSeenAt)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+
+	t.SessionID = session.ID
+	if err := insertRefreshToken(ctx, tx, t); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// insertRefreshToken stores a refresh token within tx
+func insertRefreshToken(ctx context.Context, tx *sql.Tx, t *RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, session_id, token_hash, user_agent, ip_address, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), $6)
+		RETURNING id, created_at
+	`
+	err := tx.QueryRowContext(ctx, query, t.UserID, t.SessionID, t.TokenHash, t.UserAgent, t.IPAddress, t.ExpiresAt).
+		Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// RotateRefreshToken redeems the refresh token stored under oldHash and
+// stores next in its place, in the same session and for the same user. A
+// token that was already rotated is being reused, which means it leaked:
+// its whole session is revoked and the rotation refused.
+func (r *Repo) RotateRefreshToken(ctx context.Context, oldHash string, next *RefreshToken) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	var (
+		id        int64
+		expiresAt time.Time
+		rotatedAt sql.NullTime
+		revokedAt sql.NullTime
+	)
+	query := `
+		SELECT t.id, t.user_id, t.session_id, t.expires_at, t.rotated_at, s.revoked_at
+		FROM refresh_tokens t
+		JOIN sessions s ON s.id = t.session_id
+		WHERE t.token_hash = $1
+		FOR UPDATE
+	`
+	err = tx.QueryRowContext(ctx, query, oldHash).
+		Scan(&id, &next.UserID, &next.SessionID, &expiresAt, &rotatedAt, &revokedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errs.Unauthorized(errors.New("refresh token is invalid or has expired"))
+		}
+		return errs.InternalServerError(err)
+	}
+
+	if rotatedAt.Valid && !revokedAt.Valid {
+		query = `UPDATE sessions SET revoked_at = NOW() WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, query, next.SessionID); err != nil {
+			return errs.InternalServerError(err)
+		}
+		if err := tx.Commit(); err != nil {
+			return errs.InternalServerError(err)
+		}
+		return errs.Unauthorized(errors.New("refresh token was already used; the sign-in it belongs to has been revoked"))
+	}
+	if rotatedAt.Valid || revokedAt.Valid || !expiresAt.After(time.Now()) {
+		return errs.Unauthorized(errors.New("refresh token is invalid or has expired"))
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET rotated_at = NOW() WHERE id = $1`, id); err != nil {
+		return errs.InternalServerError(err)
+	}
+	if err := insertRefreshToken(ctx, tx, next); err != nil {
+		return err
+	}
+	query = `
+		UPDATE sessions SET user_agent = $2, ip_address = $3, last_seen_at = NOW(), expires_at = $4
+		WHERE id = $1
+	`
+	if _, err := tx.ExecContext(ctx, query, next.SessionID, next.UserAgent, next.IPAddress, next.ExpiresAt); err != nil {
+		return errs.InternalServerError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// RevokeTokenSession revokes the session of a user's refresh token.
+// Sessions of other users are left alone.
+func (r *Repo) RevokeTokenSession(ctx context.Context, userID int64, tokenHash string) error {
+	query := `
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE revoked_at IS NULL AND id = (
+			SELECT session_id FROM refresh_tokens WHERE token_hash = $1 AND user_id = $2
+		)
+	`
+	if _, err := r.DB.ExecContext(ctx, query, tokenHash, userID); err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// ListSessions returns the active sessions of a user, most recently seen
+// first
+func (r *Repo) ListSessions(ctx context.Context, userID int64) ([]Session, error) {
+	query := `
+		SELECT id, user_id, user_agent, ip_address, created_at, last_seen_at, expires_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_seen_at DESC
+	`
+	rows, err := r.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		sessions = append(sessions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession revokes one of a user's active sessions
+func (r *Repo) RevokeSession(ctx context.Context, userID int64, sessionID string) error {
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+	result, err := r.DB.ExecContext(ctx, query, sessionID, userID)
 	if err != nil {
 		return errs.InternalServerError(err)
 	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	if affected == 0 {
+		return errs.NotFound(errors.New("session not found"))
+	}
+	return nil
+}
+
+// sessionSeenInterval is how stale a session's last-seen time may get
+// before a request refreshes it, sparing a write on every request.
+const sessionSeenInterval = time.Minute
+
+// TouchSession reports whether a session of a user is still active, and
+// records that it was just seen.
+func (r *Repo) TouchSession(ctx context.Context, userID int64, sessionID string) (bool, error) {
+	var active bool
+	query := `
+		WITH session AS (
+			SELECT id, last_seen_at FROM sessions
+			WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+		), seen AS (
+			UPDATE sessions SET last_seen_at = NOW()
+			WHERE id IN (SELECT id FROM session WHERE last_seen_at < NOW() - make_interval(secs => $3))
+		)
+		SELECT EXISTS (SELECT 1 FROM session)
+	`
+	err := r.DB.QueryRowContext(ctx, query, sessionID, userID, sessionSeenInterval.Seconds()).Scan(&active)
+	if err != nil {
+		return false, errs.InternalServerError(err)
+	}
+	return active, nil
+}
+
+// RevokeUserTokens signs a user out everywhere: their sessions are revoked
+// and their token version bumped, revoking every access token.
+func (r *Repo) RevokeUserTokens(ctx context.Context, userID int64) error {
+	query := `
+		WITH revoked AS (
+			UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL
+		)
+		UPDATE users SET token_version = token_version + 1 WHERE id = $1
+	`
+	if _, err := r.DB.ExecContext(ctx, query, userID); err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// RevokeAccessToken adds an access token to the revocation list until it
+// expires, pruning entries whose tokens have expired on the way.
+func (r *Repo) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	query := `
+		WITH pruned AS (
+			DELETE FROM revoked_tokens WHERE expires_at < NOW()
+		)
+		INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING
+	`
+	if _, err := r.DB.ExecContext(ctx, query, jti, expiresAt); err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// IsTokenRevoked reports whether an access token is on the revocation list
+func (r *Repo) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`
+	if err := r.DB.QueryRowContext(ctx, query, jti).Scan(&revoked); err != nil {
+		return false, errs.InternalServerError(err)
+	}
+	return revoked, nil
+}
+
+// GetAccess returns the roles a user holds and the permissions they grant
+func (r *Repo) GetAccess(ctx context.Context, userID int64) (roles, permissions []string, err error) {
+	query := `
+		SELECT r.name FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY r.name
+	`
+	if roles, err = r.queryNames(ctx, query, userID); err != nil {
+		return nil, nil, err
+	}
+
+	query = `
+		SELECT DISTINCT p.name FROM user_roles ur
+		JOIN role_permissions rp ON rp.role_id = ur.role_id
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE ur.user_id = $1
+		ORDER BY p.name
+	`
+	if permissions, err = r.queryNames(ctx, query, userID); err != nil {
+		return nil, nil, err
+	}
+	return roles, permissions, nil
+}
+
+// queryNames returns the single text column of the rows of query
+func (r *Repo) queryNames(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	return names, nil
+}
+
+// ListUsers returns a page of users with their roles, oldest first
+func (r *Repo) ListUsers(ctx context.Context, limit, offset int) ([]User, error) {
+	query := `
+		SELECT u.id, u.username, u.email, u.created_at, u.is_active, u.deactivated_at,
+			COALESCE(string_agg(r.name, ',' ORDER BY r.name), '')
+		FROM users u
+		LEFT JOIN user_roles ur ON ur.user_id = u.id
+		LEFT JOIN roles r ON r.id = ur.role_id
+		GROUP BY u.id
+		ORDER BY u.id
+		LIMIT $1 OFFSET $2
+	`
+	rows, err := r.DB.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var (
+			u     User
+			roles string
+		)
+		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.CreatedAt, &u.IsActive, &u.DeactivatedAt, &roles); err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		u.Roles = []string{}
+		if roles != "" {
+			u.Roles = strings.Split(roles, ",")
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	return users, nil
+}
+
+// CountUsers returns the number of users
+func (r *Repo) CountUsers(ctx context.Context) (int, error) {
+	var count int
+	if err := r.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&count); err != nil {
+		return 0, errs.InternalServerError(err)
+	}
+	return count, nil
+}
+
+// DeactivateUser deactivates a user's account, revoking their sessions and
+// bumping their token version to sign them out everywhere.
+func (r *Repo) DeactivateUser(ctx context.Context, userID int64) error {
+	query := `
+		WITH revoked AS (
+			UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL
+		)
+		UPDATE users SET deactivated_at = COALESCE(deactivated_at, NOW()), token_version = token_version + 1
+		WHERE id = $1
+	`
+	result, err := r.DB.ExecContext(ctx, query, userID)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	if affected == 0 {
+		return errs.NotFound(errors.New("user not found"))
+	}
+	return nil
+}
+
+// ReactivateUser lifts the deactivation of a user's account. Their token
+// version is bumped, as on deactivation, so no token issued before either
+// change is accepted.
+func (r *Repo) ReactivateUser(ctx context.Context, userID int64) error {
+	query := `UPDATE users SET deactivated_at = NULL, token_version = token_version + 1 WHERE id = $1`
+	result, err := r.DB.ExecContext(ctx, query, userID)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	if affected == 0 {
+		return errs.NotFound(errors.New("user not found"))
+	}
 	return nil
 }
 
+// GrantRole grants a user a role. Their token version is bumped so their
+// access tokens are renewed with the role.
+func (r *Repo) GrantRole(ctx context.Context, userID int64, role string) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	var roleID int64
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM roles WHERE name = $1`, role).Scan(&roleID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errs.NotFound(errors.New("role not found"))
+		}
+		return errs.InternalServerError(err)
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE users SET token_version = token_version + 1 WHERE id = $1`, userID)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	if affected == 0 {
+		return errs.NotFound(errors.New("user not found"))
+	}
+
+	query := `INSERT INTO user_roles (user_id, role_id, created_at) VALUES ($1, $2, NOW()) ON CONFLICT DO NOTHING`
+	if _, err := tx.ExecContext(ctx, query, userID, roleID); err != nil {
+		return errs.InternalServerError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// RevokeRole takes a role from a user. Their token version is bumped so
+// their access tokens are renewed without the role.
+func (r *Repo) RevokeRole(ctx context.Context, userID int64, role string) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	var roleID int64
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM roles WHERE name = $1`, role).Scan(&roleID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errs.NotFound(errors.New("role not found"))
+		}
+		return errs.InternalServerError(err)
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE users SET token_version = token_version + 1 WHERE id = $1`, userID)
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	if affected == 0 {
+		return errs.NotFound(errors.New("user not found"))
+	}
+
+	query := `DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2`
+	if _, err := tx.ExecContext(ctx, query, userID, roleID); err != nil {
+		return errs.InternalServerError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
@@ -53,7 +53,7 @@ func TestRepo_Create(t *testing.T) {
 	userRows := sqlmock.NewRows([]string{"id", "created_at"}).
 		AddRow(1, now)
 	mock.ExpectQuery("INSERT INTO users").
-		WithArgs(user.Username, user.Email, user.Password).
+		WithArgs(user.Username, user.Email, user.Password, false).
 		WillReturnRows(userRows)
 
 	// Call function under test
@@ -88,7 +88,7 @@ func TestRepo_Create_Failure(t *testing.T) {
 
 	// Setup expectations - insert fails
 	mock.ExpectQuery("INSERT INTO users").
-		WithArgs(user.Username, user.Email, user.Password).
+		WithArgs(user.Username, user.Email, user.Password, false).
 		WillReturnError(sql.ErrConnDone)
 
 	// Call function under test
@@ -115,9 +115,9 @@ func TestRepo_GetByID(t *testing.T) {
 	now := time.Now().Truncate(time.Second)
 
 	// Setup expectations
-	userRows := sqlmock.NewRows([]string{"id", "username", "email", "created_at", "is_active"}).
-		AddRow(1, "testuser", "test@example.com", now, true)
-	mock.ExpectQuery("SELECT id, username, email, created_at, is_active FROM users").
+	userRows := sqlmock.NewRows([]string{"id", "username", "email", "created_at", "is_active", "token_version", "deactivated_at"}).
+		AddRow(1, "testuser", "test@example.com", now, true, 2, nil)
+	mock.ExpectQuery("SELECT id, username, email, created_at, is_active, token_version, deactivated_at FROM users").
 		WithArgs(1).
 		WillReturnRows(userRows)
 
@@ -133,6 +133,8 @@ func TestRepo_GetByID(t *testing.T) {
 	assert.Equal(t, "test@example.com", user.Email)
 	assert.Equal(t, now, user.CreatedAt)
 	assert.True(t, user.IsActive)
+	assert.Equal(t, 2, user.TokenVersion)
+	assert.Nil(t, user.DeactivatedAt)
 
 	// Verify all expectations were met
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -148,7 +150,7 @@ func TestRepo_GetByID_NotFound(t *testing.T) {
 	repo := &Repo{DB: db}
 
 	// Setup expectations - user not found
-	mock.ExpectQuery("SELECT id, username, email, created_at, is_active FROM users").
+	mock.ExpectQuery("SELECT id, username, email, created_at, is_active, token_version, deactivated_at FROM users").
 		WithArgs(999).
 		WillReturnError(sql.ErrNoRows)
 
@@ -177,9 +179,9 @@ func TestRepo_GetByEmail(t *testing.T) {
 	now := time.Now().Truncate(time.Second)
 
 	// Setup expectations
-	userRows := sqlmock.NewRows([]string{"id", "username", "email", "password", "created_at", "is_active"}).
-		AddRow(1, "testuser", "test@example.com", "hashedpassword", now, true)
-	mock.ExpectQuery("SELECT id, username, email, password, created_at, is_active FROM users").
+	userRows := sqlmock.NewRows([]string{"id", "username", "email", "password", "created_at", "is_active", "token_version", "deactivated_at"}).
+		AddRow(1, "testuser", "test@example.com", "hashedpassword", now, true, 0, now)
+	mock.ExpectQuery("SELECT id, username, email, password, created_at, is_active, token_version, deactivated_at FROM users").
 		WithArgs("test@example.com").
 		WillReturnRows(userRows)
 
@@ -196,6 +198,8 @@ func TestRepo_GetByEmail(t *testing.T) {
 	assert.Equal(t, "hashedpassword", user.Password)
 	assert.Equal(t, now, user.CreatedAt)
 	assert.True(t, user.IsActive)
+	require.NotNil(t, user.DeactivatedAt)
+	assert.Equal(t, now, *user.DeactivatedAt)
 
 	// Verify all expectations were met
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -211,7 +215,7 @@ func TestRepo_GetByEmail_NotFound(t *testing.T) {
 	repo := &Repo{DB: db}
 
 	// Setup expectations - user not found
-	mock.ExpectQuery("SELECT id, username, email, password, created_at, is_active FROM users").
+	mock.ExpectQuery("SELECT id, username, email, password, created_at, is_active, token_version, deactivated_at FROM users").
 		WithArgs("nonexistent@example.com").
 		WillReturnError(sql.ErrNoRows)
 
@@ -237,7 +241,7 @@ func TestRepo_UpdatePassword(t *testing.T) {
 	repo := &Repo{DB: db}
 
 	// Setup expectations
-	mock.ExpectExec("UPDATE users SET password").
+	mock.ExpectExec("UPDATE users SET password = \\$1, token_version = token_version \\+ 1 WHERE id = \\$2").
 		WithArgs("newhashpassword", 1).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
@@ -261,7 +265,7 @@ func TestRepo_ActivateUser(t *testing.T) {
 	repo := &Repo{DB: db}
 
 	// Setup expectations
-	mock.ExpectExec("UPDATE users SET is_active").
+	mock.ExpectExec("UPDATE users SET is_active = true WHERE id = \\$1").
 		WithArgs(1).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
@@ -274,3 +278,832 @@ func TestRepo_ActivateUser(t *testing.T) {
 	// Verify all expectations were met
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestRepo_ActivateUser_NotFound(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations - no such user
+	mock.ExpectExec("UPDATE users SET is_active").
+		WithArgs(999).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Call function under test
+	err = repo.ActivateUser(context.Background(), 999)
+
+	// Assert not found error
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "sql: no rows in result set")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_MarkVerificationSent(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected int64
+		expected bool
+	}{
+		{name: "Send allowed", affected: 1, expected: true},
+		{name: "Sent too recently or already active", affected: 0, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mock DB
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			// Create repository
+			repo := &Repo{DB: db}
+
+			// Setup expectations
+			mock.ExpectExec("UPDATE users SET verification_sent_at = NOW\\(\\)").
+				WithArgs(1, float64(60)).
+				WillReturnResult(sqlmock.NewResult(0, tt.affected))
+
+			// Call function under test
+			ok, err := repo.MarkVerificationSent(context.Background(), 1, time.Minute)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, ok)
+
+			// Verify all expectations were met
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepo_GetPassword(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectQuery("SELECT password FROM users WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow("hashedpassword"))
+
+	// Call function under test
+	password, err := repo.GetPassword(context.Background(), 1)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "hashedpassword", password)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_TokenVersion(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectQuery("SELECT token_version FROM users WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"token_version"}).AddRow(3))
+	mock.ExpectQuery("SELECT token_version FROM users WHERE id = \\$1").
+		WithArgs(999).
+		WillReturnError(sql.ErrNoRows)
+
+	// Call function under test
+	version, err := repo.TokenVersion(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, version)
+
+	_, err = repo.TokenVersion(context.Background(), 999)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "sql: no rows in result set")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_CreatePasswordReset(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+	expiresAt := time.Now().Add(time.Hour)
+
+	// Setup expectations - unused links are replaced by the new one
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM password_resets WHERE user_id = \\$1 AND used_at IS NULL").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO password_resets").
+		WithArgs(1, "hash", expiresAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// Call function under test
+	err = repo.CreatePasswordReset(context.Background(), 1, "hash", expiresAt)
+
+	// Assert no error
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ResetPassword(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE password_resets SET used_at = NOW\\(\\)").
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(4))
+	mock.ExpectExec("UPDATE users SET password = \\$1, token_version = token_version \\+ 1 WHERE id = \\$2").
+		WithArgs("newhash", 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Call function under test
+	userID, err := repo.ResetPassword(context.Background(), "hash", "newhash")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), userID)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ResetPassword_InvalidToken(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations - used, expired or unknown token
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE password_resets SET used_at = NOW\\(\\)").
+		WithArgs("hash").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	// Call function under test
+	_, err = repo.ResetPassword(context.Background(), "hash", "newhash")
+
+	// Assert the password is left alone
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "reset link is invalid or has expired")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_CreateSession(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+	now := time.Now()
+	expiresAt := now.Add(time.Hour)
+	session := &Session{ID: "session-id", UserID: 1, UserAgent: "curl", IPAddress: "192.0.2.1", ExpiresAt: expiresAt}
+	token := &RefreshToken{UserID: 1, TokenHash: "hash", UserAgent: "curl", IPAddress: "192.0.2.1", ExpiresAt: expiresAt}
+
+	// Setup expectations - the session and its first token are stored together
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO sessions").
+		WithArgs("session-id", 1, "curl", "192.0.2.1", expiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "last_seen_at"}).AddRow(now, now))
+	mock.ExpectQuery("INSERT INTO refresh_tokens").
+		WithArgs(1, "session-id", "hash", "curl", "192.0.2.1", expiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, now))
+	mock.ExpectCommit()
+
+	// Call function under test
+	err = repo.CreateSession(context.Background(), session, token)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, now, session.CreatedAt)
+	assert.Equal(t, int64(7), token.ID)
+	assert.Equal(t, "session-id", token.SessionID)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_RotateRefreshToken(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+	now := time.Now()
+	next := &RefreshToken{TokenHash: "newhash", UserAgent: "curl", IPAddress: "192.0.2.1", ExpiresAt: now.Add(time.Hour)}
+
+	// Setup expectations - the old token is marked rotated, the new one
+	// joins its session and the session is marked seen
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT t.id, t.user_id, t.session_id, t.expires_at, t.rotated_at, s.revoked_at FROM refresh_tokens t").
+		WithArgs("oldhash").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "session_id", "expires_at", "rotated_at", "revoked_at"}).
+			AddRow(3, 1, "session-id", now.Add(time.Hour), nil, nil))
+	mock.ExpectExec("UPDATE refresh_tokens SET rotated_at = NOW\\(\\) WHERE id = \\$1").
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO refresh_tokens").
+		WithArgs(1, "session-id", "newhash", "curl", "192.0.2.1", next.ExpiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(4, now))
+	mock.ExpectExec("UPDATE sessions SET user_agent = \\$2, ip_address = \\$3, last_seen_at = NOW\\(\\), expires_at = \\$4").
+		WithArgs("session-id", "curl", "192.0.2.1", next.ExpiresAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Call function under test
+	err = repo.RotateRefreshToken(context.Background(), "oldhash", next)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), next.ID)
+	assert.Equal(t, int64(1), next.UserID)
+	assert.Equal(t, "session-id", next.SessionID)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_RotateRefreshToken_Reused(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+	now := time.Now()
+
+	// Setup expectations - replaying a rotated token revokes its session
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT t.id, t.user_id, t.session_id, t.expires_at, t.rotated_at, s.revoked_at FROM refresh_tokens t").
+		WithArgs("oldhash").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "session_id", "expires_at", "rotated_at", "revoked_at"}).
+			AddRow(3, 1, "session-id", now.Add(time.Hour), now.Add(-time.Minute), nil))
+	mock.ExpectExec("UPDATE sessions SET revoked_at = NOW\\(\\) WHERE id = \\$1").
+		WithArgs("session-id").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Call function under test
+	err = repo.RotateRefreshToken(context.Background(), "oldhash", &RefreshToken{TokenHash: "newhash"})
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "refresh token was already used")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_RotateRefreshToken_Expired(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT t.id, t.user_id, t.session_id, t.expires_at, t.rotated_at, s.revoked_at FROM refresh_tokens t").
+		WithArgs("oldhash").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "session_id", "expires_at", "rotated_at", "revoked_at"}).
+			AddRow(3, 1, "session-id", time.Now().Add(-time.Minute), nil, nil))
+	mock.ExpectRollback()
+
+	// Call function under test
+	err = repo.RotateRefreshToken(context.Background(), "oldhash", &RefreshToken{TokenHash: "newhash"})
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "refresh token is invalid or has expired")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_RevokeTokenSession(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectExec("UPDATE sessions SET revoked_at = NOW\\(\\)").
+		WithArgs("hash", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Call function under test
+	err = repo.RevokeTokenSession(context.Background(), 1, "hash")
+
+	// Assert no error
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ListSessions(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+	now := time.Now()
+
+	// Setup expectations
+	rows := sqlmock.NewRows([]string{"id", "user_id", "user_agent", "ip_address", "created_at", "last_seen_at", "expires_at"}).
+		AddRow("phone", 1, "Safari", "192.0.2.2", now, now, now.Add(time.Hour)).
+		AddRow("laptop", 1, "Firefox", "192.0.2.1", now, now.Add(-time.Hour), now.Add(time.Hour))
+	mock.ExpectQuery("SELECT id, user_id, user_agent, ip_address, created_at, last_seen_at, expires_at FROM sessions").
+		WithArgs(1).
+		WillReturnRows(rows)
+
+	// Call function under test
+	sessions, err := repo.ListSessions(context.Background(), 1)
+
+	// Assert
+	assert.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "phone", sessions[0].ID)
+	assert.Equal(t, "Firefox", sessions[1].UserAgent)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_RevokeSession(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectExec("UPDATE sessions SET revoked_at = NOW\\(\\) WHERE id = \\$1 AND user_id = \\$2").
+		WithArgs("laptop", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Call function under test
+	err = repo.RevokeSession(context.Background(), 1, "laptop")
+
+	// Assert no error
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_RevokeSession_NotFound(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations - revoked, unknown or someone else's session
+	mock.ExpectExec("UPDATE sessions SET revoked_at = NOW\\(\\) WHERE id = \\$1 AND user_id = \\$2").
+		WithArgs("laptop", 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Call function under test
+	err = repo.RevokeSession(context.Background(), 1, "laptop")
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "session not found")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_TouchSession(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectQuery("UPDATE sessions SET last_seen_at = NOW\\(\\)").
+		WithArgs("session-id", 1, sessionSeenInterval.Seconds()).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	// Call function under test
+	active, err := repo.TouchSession(context.Background(), 1, "session-id")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_RevokeUserTokens(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectExec("UPDATE users SET token_version = token_version \\+ 1 WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Call function under test
+	err = repo.RevokeUserTokens(context.Background(), 1)
+
+	// Assert no error
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_RevokeAccessToken(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+	expiresAt := time.Now().Add(time.Hour)
+
+	// Setup expectations
+	mock.ExpectExec("INSERT INTO revoked_tokens \\(jti, expires_at\\) VALUES \\(\\$1, \\$2\\)").
+		WithArgs("token-id", expiresAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Call function under test
+	err = repo.RevokeAccessToken(context.Background(), "token-id", expiresAt)
+
+	// Assert no error
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_IsTokenRevoked(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM revoked_tokens WHERE jti = \\$1\\)").
+		WithArgs("token-id").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	// Call function under test
+	revoked, err := repo.IsTokenRevoked(context.Background(), "token-id")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetAccess(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectQuery("SELECT r.name FROM user_roles ur").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("admin"))
+	mock.ExpectQuery("SELECT DISTINCT p.name FROM user_roles ur").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("users:manage").AddRow("users:read"))
+
+	// Call function under test
+	roles, permissions, err := repo.GetAccess(context.Background(), 1)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admin"}, roles)
+	assert.Equal(t, []string{"users:manage", "users:read"}, permissions)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ListUsers(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+	now := time.Now().Truncate(time.Second)
+
+	// Setup expectations
+	rows := sqlmock.NewRows([]string{"id", "username", "email", "created_at", "is_active", "deactivated_at", "roles"}).
+		AddRow(1, "admin", "admin@example.com", now, true, nil, "admin").
+		AddRow(2, "jane", "jane@example.com", now, true, now, "")
+	mock.ExpectQuery("SELECT u.id, u.username, u.email, u.created_at, u.is_active, u.deactivated_at").
+		WithArgs(10, 20).
+		WillReturnRows(rows)
+
+	// Call function under test
+	users, err := repo.ListUsers(context.Background(), 10, 20)
+
+	// Assert
+	assert.NoError(t, err)
+	require.Len(t, users, 2)
+	assert.Equal(t, []string{"admin"}, users[0].Roles)
+	assert.Nil(t, users[0].DeactivatedAt)
+	assert.Equal(t, []string{}, users[1].Roles)
+	assert.NotNil(t, users[1].DeactivatedAt)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_DeactivateUser(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations - sessions are revoked along the way
+	mock.ExpectExec("UPDATE users SET deactivated_at = COALESCE\\(deactivated_at, NOW\\(\\)\\), token_version = token_version \\+ 1").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Call function under test
+	err = repo.DeactivateUser(context.Background(), 2)
+
+	// Assert no error
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_DeactivateUser_NotFound(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectExec("UPDATE users SET deactivated_at").
+		WithArgs(999).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Call function under test
+	err = repo.DeactivateUser(context.Background(), 999)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "user not found")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ReactivateUser(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectExec("UPDATE users SET deactivated_at = NULL, token_version = token_version \\+ 1 WHERE id = \\$1").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Call function under test
+	err = repo.ReactivateUser(context.Background(), 2)
+
+	// Assert no error
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ReactivateUser_NotFound(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectExec("UPDATE users SET deactivated_at = NULL").
+		WithArgs(999).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Call function under test
+	err = repo.ReactivateUser(context.Background(), 999)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "user not found")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GrantRole(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations - the token version is bumped so tokens pick up
+	// the role
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM roles WHERE name = \\$1").
+		WithArgs("admin").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("UPDATE users SET token_version = token_version \\+ 1 WHERE id = \\$1").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO user_roles").
+		WithArgs(2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Call function under test
+	err = repo.GrantRole(context.Background(), 2, "admin")
+
+	// Assert no error
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GrantRole_UnknownRole(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM roles WHERE name = \\$1").
+		WithArgs("owner").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	// Call function under test
+	err = repo.GrantRole(context.Background(), 2, "owner")
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "role not found")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_RevokeRole(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations - the token version is bumped so tokens drop the
+	// role
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM roles WHERE name = \\$1").
+		WithArgs("admin").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("UPDATE users SET token_version = token_version \\+ 1 WHERE id = \\$1").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM user_roles WHERE user_id = \\$1 AND role_id = \\$2").
+		WithArgs(2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Call function under test
+	err = repo.RevokeRole(context.Background(), 2, "admin")
+
+	// Assert no error
+	assert.NoError(t, err)
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_RevokeRole_UserNotFound(t *testing.T) {
+	// Create mock DB
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Create repository
+	repo := &Repo{DB: db}
+
+	// Setup expectations
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM roles WHERE name = \\$1").
+		WithArgs("admin").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("UPDATE users SET token_version").
+		WithArgs(999).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	// Call function under test
+	err = repo.RevokeRole(context.Background(), 999, "admin")
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "user not found")
+
+	// Verify all expectations were met
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -4,23 +4,89 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/phsaurav/echo_prod_blueprint/config"
 	"github.com/phsaurav/echo_prod_blueprint/internal/database"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/mail"
 )
 
 type UserService interface {
 	RegisterUser(c echo.Context) error
 	LoginUser(c echo.Context) error
 	GetUser(c echo.Context) error
+	VerifyEmail(c echo.Context) error
+	ResendVerification(c echo.Context) error
+	ForgotPassword(c echo.Context) error
+	ResetPassword(c echo.Context) error
+	ChangePassword(c echo.Context) error
+	RefreshToken(c echo.Context) error
+	Logout(c echo.Context) error
+	LogoutAll(c echo.Context) error
+	ListSessions(c echo.Context) error
+	RevokeSession(c echo.Context) error
 }
 
-func Register(g *echo.Group, db database.Service, cfg config.Config, authMiddleware echo.MiddlewareFunc) {
+// AdminService manages users on behalf of admins.
+type AdminService interface {
+	ListUsers(c echo.Context) error
+	DeactivateUser(c echo.Context) error
+	ReactivateUser(c echo.Context) error
+	PromoteUser(c echo.Context) error
+	DemoteUser(c echo.Context) error
+}
+
+// Register wires the user feature. mailer sends verification and password
+// reset emails; when nil, none are sent. The user management API is
+// registered under admin, which the caller must restrict to admins. The
+// service is returned so the caller can Close it on shutdown.
+func Register(g, admin *echo.Group, db database.Service, cfg config.Config, mailer mail.Mailer, authMiddleware echo.MiddlewareFunc) *Service {
 	repo := NewRepo(db)
 	service := NewService(repo, cfg.TokenConfig.Secret)
+	if cfg.TokenConfig.Exp > 0 {
+		service.JWTExpires = cfg.TokenConfig.Exp
+	}
+	if cfg.TokenConfig.RefreshExp > 0 {
+		service.RefreshExpires = cfg.TokenConfig.RefreshExp
+	}
+	service.Mailer = mailer
+	service.VerifyURL = verifyURL(cfg.APIURL)
+	service.VerifySecret = []byte(cfg.Verification.Secret)
+	if cfg.Verification.TTL > 0 {
+		service.VerifyTTL = cfg.Verification.TTL
+	}
+	if cfg.Verification.ResendInterval > 0 {
+		service.ResendInterval = cfg.Verification.ResendInterval
+	}
+	service.ResetURL = resetURL(cfg.FrontendURL)
+	if cfg.Reset.TTL > 0 {
+		service.ResetTTL = cfg.Reset.TTL
+	}
 	RegisterRoutes(g, service, authMiddleware)
+	RegisterAdminRoutes(admin, service)
+	return service
 }
 
 // RegisterRoutes registers the user routes under the provided echo.Group.
 func RegisterRoutes(g *echo.Group, service UserService, authMiddleware echo.MiddlewareFunc) {
 	g.POST("/register", service.RegisterUser)
 	g.POST("/login", service.LoginUser)
+	g.POST("/token/refresh", service.RefreshToken)
+	g.POST("/logout", service.Logout, authMiddleware)
+	g.POST("/logout/all", service.LogoutAll, authMiddleware)
+	g.GET("/sessions", service.ListSessions, authMiddleware)
+	g.DELETE("/sessions/:id", service.RevokeSession, authMiddleware)
+	g.GET("/verify", service.VerifyEmail)
+	g.POST("/verify/resend", service.ResendVerification)
+	g.POST("/password/forgot", service.ForgotPassword)
+	g.POST("/password/reset", service.ResetPassword)
+	g.POST("/password/change", service.ChangePassword, authMiddleware)
 	g.GET("/:id", service.GetUser, authMiddleware)
 }
+
+// RegisterAdminRoutes registers the user management routes under the
+// provided echo.Group, which must already require authentication and admin
+// rights.
+func RegisterAdminRoutes(g *echo.Group, service AdminService) {
+	g.GET("", service.ListUsers)
+	g.POST("/:id/deactivate", service.DeactivateUser)
+	g.POST("/:id/reactivate", service.ReactivateUser)
+	g.POST("/:id/roles", service.PromoteUser)
+	g.DELETE("/:id/roles/:role", service.DemoteUser)
+}
@@ -39,6 +39,71 @@ func TestRegisterRoutes(t *testing.T) {
 	rec = httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 
+	// Test GET /api/v1/user/verify
+	mockService.On("VerifyEmail", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/user/verify?token=abc", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test POST /api/v1/user/verify/resend
+	mockService.On("ResendVerification", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/user/verify/resend", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test POST /api/v1/user/password/forgot
+	mockService.On("ForgotPassword", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/user/password/forgot", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test POST /api/v1/user/password/reset
+	mockService.On("ResetPassword", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/user/password/reset", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test POST /api/v1/user/password/change - Add auth token
+	mockService.On("ChangePassword", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/user/password/change", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test POST /api/v1/user/token/refresh
+	mockService.On("RefreshToken", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/user/token/refresh", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test POST /api/v1/user/logout - Add auth token
+	mockService.On("Logout", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/user/logout", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test POST /api/v1/user/logout/all - Add auth token
+	mockService.On("LogoutAll", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/user/logout/all", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test GET /api/v1/user/sessions - Add auth token
+	mockService.On("ListSessions", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/user/sessions", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test DELETE /api/v1/user/sessions/:id - Add auth token
+	mockService.On("RevokeSession", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/user/sessions/abc", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
 	// Important: Set up GetUser expectation BEFORE GetProfile
 	// because of how Echo matches routes
 	mockService.On("GetUser", mock.Anything).Return(nil).Maybe()
@@ -68,6 +133,50 @@ func TestRegisterRoutes(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+// TestRegisterAdminRoutes tests that the user management routes are
+// registered correctly
+func TestRegisterAdminRoutes(t *testing.T) {
+	// Setup
+	e := echo.New()
+	g := e.Group("/api/v1/admin/users")
+
+	mockService := new(MockUserService)
+	RegisterAdminRoutes(g, mockService)
+
+	// Test GET /api/v1/admin/users
+	mockService.On("ListUsers", mock.Anything).Return(nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/users", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test POST /api/v1/admin/users/:id/deactivate
+	mockService.On("DeactivateUser", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/admin/users/2/deactivate", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test POST /api/v1/admin/users/:id/reactivate
+	mockService.On("ReactivateUser", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/admin/users/2/reactivate", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test POST /api/v1/admin/users/:id/roles
+	mockService.On("PromoteUser", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/admin/users/2/roles", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Test DELETE /api/v1/admin/users/:id/roles/:role
+	mockService.On("DemoteUser", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/admin/users/2/roles/admin", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// Verify all expectations were met
+	mockService.AssertExpectations(t)
+}
+
 // TestRegister tests the Register function
 func TestRegister(t *testing.T) {
 	// Setup
@@ -92,7 +201,7 @@ func TestRegister(t *testing.T) {
 	}
 
 	assert.NotPanics(t, func() {
-		Register(g, mockDB, cfg, authMiddleware)
+		Register(g, e.Group("/api/v1/admin/users"), mockDB, cfg, new(MockMailer), authMiddleware)
 	})
 
 	// Verify mock was called
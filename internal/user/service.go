@@ -3,12 +3,16 @@ package user
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strconv"
 	"time"
+	"unicode/utf8"
 
 	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/auth"
 	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/mail"
 	"github.com/phsaurav/echo_prod_blueprint/pkg/response"
 
 	"golang.org/x/crypto/bcrypt"
@@ -22,6 +26,24 @@ type Repository interface {
 	GetByEmail(ctx context.Context, email string) (*User, error)
 	UpdatePassword(ctx context.Context, id int64, password string) error
 	ActivateUser(ctx context.Context, id int64) error
+	MarkVerificationSent(ctx context.Context, id int64, interval time.Duration) (bool, error)
+	GetPassword(ctx context.Context, id int64) (string, error)
+	CreatePasswordReset(ctx context.Context, userID int64, tokenHash string, expiresAt time.Time) error
+	ResetPassword(ctx context.Context, tokenHash, password string) (int64, error)
+	CreateSession(ctx context.Context, session *Session, t *RefreshToken) error
+	RotateRefreshToken(ctx context.Context, oldHash string, next *RefreshToken) error
+	RevokeTokenSession(ctx context.Context, userID int64, tokenHash string) error
+	ListSessions(ctx context.Context, userID int64) ([]Session, error)
+	RevokeSession(ctx context.Context, userID int64, sessionID string) error
+	RevokeUserTokens(ctx context.Context, userID int64) error
+	RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+	GetAccess(ctx context.Context, userID int64) (roles, permissions []string, err error)
+	ListUsers(ctx context.Context, limit, offset int) ([]User, error)
+	CountUsers(ctx context.Context) (int, error)
+	DeactivateUser(ctx context.Context, userID int64) error
+	ReactivateUser(ctx context.Context, userID int64) error
+	GrantRole(ctx context.Context, userID int64, role string) error
+	RevokeRole(ctx context.Context, userID int64, role string) error
 }
 
 // Service contains business logic for user operations
@@ -29,20 +51,79 @@ type Service struct {
 	Repo       Repository
 	JWTSecret  string
 	JWTExpires time.Duration
+	// RefreshExpires is how long a refresh token stays valid. Every use
+	// replaces it with a new one valid as long again.
+	RefreshExpires time.Duration
+	// Mailer sends verification and password reset emails. When nil, none
+	// can be sent.
+	Mailer mail.Mailer
+	// VerifySecret signs email verification links, which stay valid for
+	// VerifyTTL. It has no default: until it is set, no link is sent or
+	// accepted. A user can ask for a new link once every ResendInterval.
+	VerifySecret   []byte
+	VerifyTTL      time.Duration
+	ResendInterval time.Duration
+	// VerifyURL is the address of the verify endpoint linked from emails.
+	VerifyURL string
+	// ResetTTL is how long a password reset link stays valid, and ResetURL
+	// the frontend page it opens.
+	ResetTTL time.Duration
+	ResetURL string
+
+	// resets sends password reset emails in the background, at most
+	// maxPendingResets at a time.
+	resets *background
 }
 
+// maxPendingResets bounds the password reset emails sent at once. Requests
+// beyond it are dropped with the usual reply, so a flood of them cannot
+// open unbounded connections to the mail server.
+const maxPendingResets = 16
+
 // NewService creates a new user service
 func NewService(repo Repository, jwtSecret string) *Service {
 	return &Service{
-		Repo:       repo,
-		JWTSecret:  jwtSecret,
-		JWTExpires: 24 * time.Hour, // Default expiration of 24 hours
+		Repo:           repo,
+		JWTSecret:      jwtSecret,
+		JWTExpires:     15 * time.Minute, // Short-lived; renewed with refresh tokens
+		RefreshExpires: 30 * 24 * time.Hour,
+		VerifyTTL:      24 * time.Hour,
+		ResendInterval: time.Minute,
+		VerifyURL:      verifyURL("localhost:8080"),
+		ResetTTL:       time.Hour,
+		ResetURL:       resetURL("http://localhost:5173"),
+		resets:         newBackground(maxPendingResets),
+	}
+}
+
+// Close waits for the password reset emails still being sent, until ctx
+// ends. Requests made after it is called send none.
+func (s *Service) Close(ctx context.Context) error {
+	return s.resets.Close(ctx)
+}
+
+// Passwords are at least minPasswordLength characters long, and at most
+// maxPasswordBytes bytes, the most bcrypt hashes.
+const (
+	minPasswordLength = 8
+	maxPasswordBytes  = 72
+)
+
+// checkPassword applies the rules every new password must follow, whether
+// set at registration, by a reset link or by a signed-in user.
+func checkPassword(password string) error {
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		return errs.BadRequest(fmt.Errorf("password must be at least %d characters long", minPasswordLength))
+	}
+	if len(password) > maxPasswordBytes {
+		return errs.BadRequest(fmt.Errorf("password can be at most %d bytes long", maxPasswordBytes))
 	}
+	return nil
 }
 
 // RegisterUser handles user registration
 // @Summary Register a new user
-// @Description Create a new user account with username, email, and password
+// @Description Create a new, inactive user account with username, email, and password, and email a link verifying the address
 // @Tags users
 // @Accept json
 // @Produce json
@@ -63,6 +144,9 @@ func (s *Service) RegisterUser(c echo.Context) error {
 	if req.Username == "" || req.Email == "" || req.Password == "" {
 		return response.ErrorBuilder(errs.BadRequest(errors.New("all fields required"))).Send(c)
 	}
+	if err := checkPassword(req.Password); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
 
 	// Hash the password
 	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
@@ -74,13 +158,18 @@ func (s *Service) RegisterUser(c echo.Context) error {
 		Username: req.Username,
 		Email:    req.Email,
 		Password: string(hashed),
-		IsActive: true, // Auto-activate for simplicity
+		IsActive: false, // Activated once the email address is verified
 	}
 
 	if err := s.Repo.Create(c.Request().Context(), user); err != nil {
 		return response.ErrorBuilder(errs.InternalServerError(err)).Send(c)
 	}
 
+	// The account exists either way; a failed email can be resent
+	if _, err := s.sendVerification(c.Request().Context(), user); err != nil {
+		logging.Errorf("Sending verification email to user %d: %v", user.ID, err)
+	}
+
 	// Don't return the password hash
 	user.Password = ""
 
@@ -89,14 +178,15 @@ func (s *Service) RegisterUser(c echo.Context) error {
 
 // LoginUser handles user login and returns a JWT token
 // @Summary User login
-// @Description Authenticate a user and return a JWT token
+// @Description Authenticate a user and return a short-lived JWT token with a refresh token renewing it
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param request body LoginRequest true "User login credentials"
-// @Success 200 {object} TokenResponse "Successfully authenticated with JWT token"
+// @Success 200 {object} TokenResponse "Successfully authenticated with JWT and refresh tokens"
 // @Failure 400 {object} response.FailedResponse "Bad request - invalid input"
 // @Failure 401 {object} response.FailedResponse "Unauthorized - invalid credentials"
+// @Failure 403 {object} response.FailedResponse "Forbidden - email address not verified or account deactivated"
 // @Failure 500 {object} response.FailedResponse "Internal server error"
 // @Router /api/v1/user/login [post]
 func (s *Service) LoginUser(c echo.Context) error {
@@ -117,24 +207,375 @@ func (s *Service) LoginUser(c echo.Context) error {
 		return response.ErrorBuilder(errs.BaseErr("invalid credentials")).Send(c)
 	}
 
-	// Generate JWT token
-	token, err := s.generateJWT(user)
+	if user.DeactivatedAt != nil {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("account has been deactivated"))).Send(c)
+	}
+	if !user.IsActive {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("email address not verified"))).Send(c)
+	}
+
+	// Generate JWT and refresh tokens
+	tokens, err := s.issueTokens(c, user)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(tokens).Send(c)
+}
+
+// RefreshToken renews an access token
+// @Summary Refresh tokens
+// @Description Trade a refresh token for a new JWT token and a new refresh token. Each refresh token works once; presenting a used one again revokes every token of the sign-in it came from.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body RefreshRequest true "Refresh token"
+// @Success 200 {object} TokenResponse "New JWT and refresh tokens"
+// @Failure 401 {object} response.FailedResponse "Unauthorized - refresh token invalid, expired, revoked or reused"
+// @Failure 403 {object} response.FailedResponse "Forbidden - email address not verified or account deactivated"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Router /api/v1/user/token/refresh [post]
+func (s *Service) RefreshToken(c echo.Context) error {
+	var req RefreshRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+	if req.RefreshToken == "" {
+		return response.ErrorBuilder(errs.BadRequest(errors.New("refresh_token is required"))).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	next, token, err := s.newRefreshToken(c, 0)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if err := s.Repo.RotateRefreshToken(ctx, hashOpaqueToken(req.RefreshToken), next); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	user, err := s.Repo.GetByID(ctx, next.UserID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if user.DeactivatedAt != nil {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("account has been deactivated"))).Send(c)
+	}
+	if !user.IsActive {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("email address not verified"))).Send(c)
+	}
+
+	tokens, err := s.tokenResponse(ctx, user, next.SessionID, token)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(tokens).Send(c)
+}
+
+// Logout signs the caller out
+// @Summary Log out
+// @Description Revoke the session of the JWT token used for the request, along with every token of that session. Tokens issued before sessions existed revoke the JWT token and, when given, the session of the refresh token instead. Tokens issued before JWT IDs existed cannot be revoked alone, so they sign the user out everywhere, as logout/all does
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body LogoutRequest false "Refresh token to revoke"
+// @Success 200 {object} map[string]string "Logged out"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security    BearerAuth
+// @Router /api/v1/user/logout [post]
+func (s *Service) Logout(c echo.Context) error {
+	var req LogoutRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	userID := auth.UserID(c)
+	jti, exp, ok := auth.TokenID(c)
+	if !ok {
+		// Tokens issued before JWT IDs existed cannot be revoked one by
+		// one, so signing out with one signs out everywhere
+		if err := s.Repo.RevokeUserTokens(ctx, userID); err != nil {
+			return response.ErrorBuilder(err).Send(c)
+		}
+		return response.SuccessBuilder(map[string]string{"message": "Logged out everywhere successfully"}).Send(c)
+	}
+
+	if sessionID := auth.SessionID(c); sessionID != "" {
+		if err := s.Repo.RevokeSession(ctx, userID, sessionID); err != nil {
+			return response.ErrorBuilder(err).Send(c)
+		}
+	}
+	if req.RefreshToken != "" {
+		if err := s.Repo.RevokeTokenSession(ctx, userID, hashOpaqueToken(req.RefreshToken)); err != nil {
+			return response.ErrorBuilder(err).Send(c)
+		}
+	}
+	if err := s.Repo.RevokeAccessToken(ctx, jti, exp); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(map[string]string{"message": "Logged out successfully"}).Send(c)
+}
+
+// LogoutAll signs the caller out on every device
+// @Summary Log out everywhere
+// @Description Revoke every JWT and refresh token of the signed-in user, on every device
+// @Tags users
+// @Produce json
+// @Success 200 {object} map[string]string "Logged out everywhere"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security    BearerAuth
+// @Router /api/v1/user/logout/all [post]
+func (s *Service) LogoutAll(c echo.Context) error {
+	if err := s.Repo.RevokeUserTokens(c.Request().Context(), auth.UserID(c)); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(map[string]string{"message": "Logged out everywhere successfully"}).Send(c)
+}
+
+// ListSessions lists where the caller is signed in
+// @Summary List sessions
+// @Description List the active sessions of the signed-in user, most recently seen first. The session of the token used for the request is marked current
+// @Tags users
+// @Produce json
+// @Success 200 {array} Session "Active sessions"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security    BearerAuth
+// @Router /api/v1/user/sessions [get]
+func (s *Service) ListSessions(c echo.Context) error {
+	sessions, err := s.Repo.ListSessions(c.Request().Context(), auth.UserID(c))
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	current := auth.SessionID(c)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+
+	return response.SuccessBuilder(sessions).Send(c)
+}
+
+// RevokeSession signs the caller out of one session
+// @Summary Revoke a session
+// @Description Revoke one of the signed-in user's sessions, along with every token of that session
+// @Tags users
+// @Produce json
+// @Param id path string true "Session ID"
+// @Success 200 {object} map[string]string "Session revoked"
+// @Failure 404 {object} response.FailedResponse "Not found - no such active session"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security    BearerAuth
+// @Router /api/v1/user/sessions/{id} [delete]
+func (s *Service) RevokeSession(c echo.Context) error {
+	if err := s.Repo.RevokeSession(c.Request().Context(), auth.UserID(c), c.Param("id")); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(map[string]string{"message": "Session revoked successfully"}).Send(c)
+}
+
+// VerifyEmail activates the account named by an emailed verification link
+// @Summary Verify email address
+// @Description Activate the account named by the token of an emailed verification link
+// @Tags users
+// @Produce json
+// @Param token query string true "Verification token"
+// @Success 200 {object} map[string]string "Email verified"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid or expired token"
+// @Failure 404 {object} response.FailedResponse "Not found - user doesn't exist"
+// @Router /api/v1/user/verify [get]
+func (s *Service) VerifyEmail(c echo.Context) error {
+	userID, ok := parseUserToken(s.VerifySecret, purposeVerify, c.QueryParam("token"), time.Now())
+	if !ok {
+		return response.ErrorBuilder(errs.BadRequest(errors.New("verification link is invalid or has expired"))).Send(c)
+	}
+
+	if err := s.Repo.ActivateUser(c.Request().Context(), userID); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(map[string]string{"message": "Email verified successfully"}).Send(c)
+}
+
+// ResendVerification emails a new verification link
+// @Summary Resend verification email
+// @Description Email a new verification link to an unverified account, at most once a minute. The same reply is sent whether or not the account exists or an email was sent.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body ResendVerificationRequest true "Account email address"
+// @Success 200 {object} map[string]string "Verification email sent if needed"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Router /api/v1/user/verify/resend [post]
+func (s *Service) ResendVerification(c echo.Context) error {
+	var req ResendVerificationRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+	if req.Email == "" {
+		return response.ErrorBuilder(errs.BadRequest(errors.New("email is required"))).Send(c)
+	}
+
+	// Unknown and already verified addresses, throttled resends and failed
+	// sends all get the same reply as a sent email, so the endpoint cannot
+	// be used to probe for accounts
+	reply := map[string]string{"message": "If the account exists and is not verified, a verification email has been sent"}
+	user, err := s.Repo.GetByEmail(c.Request().Context(), req.Email)
+	if err != nil || user.IsActive {
+		return response.SuccessBuilder(reply).Send(c)
+	}
+
+	if _, err := s.sendVerification(c.Request().Context(), user); err != nil {
+		logging.Errorf("Sending verification email to user %d: %v", user.ID, err)
+	}
+
+	return response.SuccessBuilder(reply).Send(c)
+}
+
+// ForgotPassword emails a password reset link
+// @Summary Request a password reset
+// @Description Email a single-use, time-limited password reset link. Requesting a new link invalidates the previous one. The email is sent in the background, so the same reply is sent whether or not the account exists.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "Account email address"
+// @Success 200 {object} map[string]string "Reset link sent if the account exists"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Router /api/v1/user/password/forgot [post]
+func (s *Service) ForgotPassword(c echo.Context) error {
+	var req ForgotPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+	if req.Email == "" {
+		return response.ErrorBuilder(errs.BadRequest(errors.New("email is required"))).Send(c)
+	}
+
+	if s.Mailer == nil {
+		return response.ErrorBuilder(errs.InternalServerError(errors.New("email is not configured"))).Send(c)
+	}
+
+	// The account is looked up and emailed in the background, so unknown
+	// addresses, known ones and failed sends get the same reply in the same
+	// time, and the endpoint cannot be used to probe for accounts
+	ctx := context.WithoutCancel(c.Request().Context())
+	started := s.resets.Go(func() {
+		if err := s.sendPasswordReset(ctx, req.Email); err != nil {
+			logging.Errorf("Sending password reset email: %v", err)
+		}
+	})
+	if !started {
+		logging.Errorf("Dropped password reset request: too many pending or shutting down")
+	}
+
+	return response.SuccessBuilder(map[string]string{"message": "If the account exists, a password reset link has been sent"}).Send(c)
+}
+
+// ResetPassword sets a new password by redeeming a reset link
+// @Summary Reset password
+// @Description Set a new password with the token of an emailed reset link. The token is used up, and every token issued to the user before is revoked.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} map[string]string "Password reset"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid input"
+// @Failure 404 {object} response.FailedResponse "Not found - reset link is invalid, used or expired"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Router /api/v1/user/password/reset [post]
+func (s *Service) ResetPassword(c echo.Context) error {
+	var req ResetPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+	if req.Token == "" || req.Password == "" {
+		return response.ErrorBuilder(errs.BadRequest(errors.New("token and password are required"))).Send(c)
+	}
+	if err := checkPassword(req.Password); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return response.ErrorBuilder(errs.InternalServerError(err)).Send(c)
+	}
+
+	if _, err := s.Repo.ResetPassword(c.Request().Context(), hashOpaqueToken(req.Token), string(hashed)); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(map[string]string{"message": "Password reset successfully"}).Send(c)
+}
+
+// ChangePassword changes the signed-in user's password
+// @Summary Change password
+// @Description Change the password of the signed-in user, who must confirm their current one. Every token issued before is revoked; fresh tokens are returned.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} TokenResponse "Password changed, with fresh JWT and refresh tokens"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid input"
+// @Failure 403 {object} response.FailedResponse "Forbidden - current password is incorrect"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security    BearerAuth
+// @Router /api/v1/user/password/change [post]
+func (s *Service) ChangePassword(c echo.Context) error {
+	var req ChangePasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		return response.ErrorBuilder(errs.BadRequest(errors.New("current_password and new_password are required"))).Send(c)
+	}
+	if err := checkPassword(req.NewPassword); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	ctx := c.Request().Context()
+	userID := auth.UserID(c)
+	current, err := s.Repo.GetPassword(ctx, userID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(current), []byte(req.CurrentPassword)); err != nil {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("current password is incorrect"))).Send(c)
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
 		return response.ErrorBuilder(errs.InternalServerError(err)).Send(c)
 	}
+	if err := s.Repo.UpdatePassword(ctx, userID, string(hashed)); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	// The caller's own tokens were revoked along with the rest
+	user, err := s.Repo.GetByID(ctx, userID)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+	tokens, err := s.issueTokens(c, user)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
 
-	return response.SuccessBuilder(map[string]string{"token": token}).Send(c)
+	return response.SuccessBuilder(tokens).Send(c)
 }
 
 // GetUser retrieves user details by ID
 // @Summary Get user profile
-// @Description Get user details by ID
+// @Description Get user details by ID. Users may read their own profile; reading others' requires the users:read permission
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
 // @Success 200 {object} User "User details"
 // @Failure 400 {object} response.FailedResponse "Bad request - invalid ID format"
+// @Failure 403 {object} response.FailedResponse "Forbidden - not allowed to read this user"
 // @Failure 404 {object} response.FailedResponse "Not found - user doesn't exist"
 // @Failure 500 {object} response.FailedResponse "Internal server error"
 // @Security    BearerAuth
@@ -145,6 +586,9 @@ func (s *Service) GetUser(c echo.Context) error {
 	if err != nil {
 		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
 	}
+	if !canViewUser(c, userID) {
+		return response.ErrorBuilder(errs.Forbidden(errors.New("not allowed to read this user"))).Send(c)
+	}
 
 	u, err := s.Repo.GetByID(c.Request().Context(), userID)
 	if err != nil {
@@ -154,15 +598,193 @@ func (s *Service) GetUser(c echo.Context) error {
 	return response.SuccessBuilder(u).Send(c)
 }
 
-// generateJWT creates a new JWT token for the user
-func (s *Service) generateJWT(user *User) (string, error) {
+// maxUsersPageSize caps the page size of the admin user listing.
+const maxUsersPageSize = 100
+
+// ListUsers lists every user for admins
+// @Summary List users
+// @Description List users with their roles, oldest first. Requires the users:manage permission
+// @Tags admin
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Users per page" default(10)
+// @Success 200 {array} User "Page of users with pagination metadata"
+// @Failure 403 {object} response.FailedResponse "Forbidden - missing permission"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security    BearerAuth
+// @Router /api/v1/admin/users [get]
+func (s *Service) ListUsers(c echo.Context) error {
+	pagination := response.ParsePagination(c.Request())
+	if pagination.Page < 1 {
+		pagination.Page = 1
+	}
+	if pagination.PageSize < 1 {
+		pagination.PageSize = 10
+	}
+	if pagination.PageSize > maxUsersPageSize {
+		pagination.PageSize = maxUsersPageSize
+	}
+
+	ctx := c.Request().Context()
+	total, err := s.Repo.CountUsers(ctx)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	users, err := s.Repo.ListUsers(ctx, pagination.PageSize, (pagination.Page-1)*pagination.PageSize)
+	if err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	pagination.TotalRecords = total
+	return response.PaginatedSuccessBuilder(users, pagination).Send(c)
+}
+
+// DeactivateUser deactivates a user's account
+// @Summary Deactivate a user
+// @Description Deactivate a user's account and sign them out everywhere. Requires the users:manage permission
+// @Tags admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string "User deactivated"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid ID or own account"
+// @Failure 403 {object} response.FailedResponse "Forbidden - missing permission"
+// @Failure 404 {object} response.FailedResponse "Not found - user doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security    BearerAuth
+// @Router /api/v1/admin/users/{id}/deactivate [post]
+func (s *Service) DeactivateUser(c echo.Context) error {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+	// An admin locking themselves out would leave no one to undo it
+	if userID == auth.UserID(c) {
+		return response.ErrorBuilder(errs.BadRequest(errors.New("you cannot deactivate your own account"))).Send(c)
+	}
+
+	if err := s.Repo.DeactivateUser(c.Request().Context(), userID); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(map[string]string{"message": "User deactivated successfully"}).Send(c)
+}
+
+// ReactivateUser reactivates a user's account
+// @Summary Reactivate a user
+// @Description Lift the deactivation of a user's account so they can sign in again. Requires the users:manage permission
+// @Tags admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string "User reactivated"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid ID"
+// @Failure 403 {object} response.FailedResponse "Forbidden - missing permission"
+// @Failure 404 {object} response.FailedResponse "Not found - user doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security    BearerAuth
+// @Router /api/v1/admin/users/{id}/reactivate [post]
+func (s *Service) ReactivateUser(c echo.Context) error {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+
+	if err := s.Repo.ReactivateUser(c.Request().Context(), userID); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(map[string]string{"message": "User reactivated successfully"}).Send(c)
+}
+
+// PromoteUser grants a user a role
+// @Summary Promote a user
+// @Description Grant a user a role. Their access tokens are renewed with it. Requires the users:manage permission
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param request body PromoteRequest true "Role to grant"
+// @Success 200 {object} map[string]string "Role granted"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid ID or missing role"
+// @Failure 403 {object} response.FailedResponse "Forbidden - missing permission"
+// @Failure 404 {object} response.FailedResponse "Not found - user or role doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security    BearerAuth
+// @Router /api/v1/admin/users/{id}/roles [post]
+func (s *Service) PromoteUser(c echo.Context) error {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+	var req PromoteRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+	if req.Role == "" {
+		return response.ErrorBuilder(errs.BadRequest(errors.New("role is required"))).Send(c)
+	}
+
+	if err := s.Repo.GrantRole(c.Request().Context(), userID, req.Role); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(map[string]string{"message": "Role granted successfully"}).Send(c)
+}
+
+// DemoteUser takes a role from a user
+// @Summary Demote a user
+// @Description Take a role from a user. Their access tokens are renewed without it. Requires the users:manage permission
+// @Tags admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Param role path string true "Role to revoke"
+// @Success 200 {object} map[string]string "Role revoked"
+// @Failure 400 {object} response.FailedResponse "Bad request - invalid ID or own admin role"
+// @Failure 403 {object} response.FailedResponse "Forbidden - missing permission"
+// @Failure 404 {object} response.FailedResponse "Not found - user or role doesn't exist"
+// @Failure 500 {object} response.FailedResponse "Internal server error"
+// @Security    BearerAuth
+// @Router /api/v1/admin/users/{id}/roles/{role} [delete]
+func (s *Service) DemoteUser(c echo.Context) error {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorBuilder(errs.BadRequest(err)).Send(c)
+	}
+	role := c.Param("role")
+	// An admin dropping their own admin role could leave no one to undo it
+	if userID == auth.UserID(c) && role == auth.RoleAdmin {
+		return response.ErrorBuilder(errs.BadRequest(errors.New("you cannot revoke your own admin role"))).Send(c)
+	}
+
+	if err := s.Repo.RevokeRole(c.Request().Context(), userID, role); err != nil {
+		return response.ErrorBuilder(err).Send(c)
+	}
+
+	return response.SuccessBuilder(map[string]string{"message": "Role revoked successfully"}).Send(c)
+}
+
+// generateJWT creates a new JWT token for the user, tied to one of their
+// sessions. Its random JWT ID lets it be revoked on its own.
+func (s *Service) generateJWT(user *User, sessionID string) (string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+
 	// Create token with claims
 	token := jwt.New(jwt.SigningMethodHS256)
 	claims := token.Claims.(jwt.MapClaims)
+	claims["jti"] = jti
+	claims["sid"] = sessionID
+	claims["iat"] = now.Unix()
 	claims["user_id"] = user.ID
 	claims["username"] = user.Username
 	claims["email"] = user.Email
-	claims["exp"] = time.Now().Add(s.JWTExpires).Unix()
+	claims["tv"] = user.TokenVersion
+	claims["roles"] = user.Roles
+	claims["perms"] = user.Permissions
+	claims["exp"] = now.Add(s.JWTExpires).Unix()
 
 	// Generate encoded token
 	tokenString, err := token.SignedString([]byte(s.JWTSecret))
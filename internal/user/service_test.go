@@ -1,15 +1,22 @@
 package user
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/auth"
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/mail"
 	"github.com/phsaurav/echo_prod_blueprint/testutils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -19,6 +26,7 @@ func TestService_RegisterUser(t *testing.T) {
 		requestBody    string
 		userID         int64
 		mockSetup      func(*MockRepository)
+		mailerSetup    func(*MockMailer)
 		expectedStatus int
 		expectedBody   string
 	}{
@@ -37,11 +45,31 @@ func TestService_RegisterUser(t *testing.T) {
 						email == "Test@Example.com" // Cover case variations
 				})).Return(nil, errors.New("not found")).Maybe() // Make it optional
 
-				repo.On("Create", mock.Anything, mock.AnythingOfType("*user.User")).Return(nil)
+				repo.On("Create", mock.Anything, mock.MatchedBy(func(u *User) bool { return !u.IsActive })).Return(nil)
+				repo.On("MarkVerificationSent", mock.Anything, int64(0), time.Minute).Return(true, nil)
+			},
+			mailerSetup: func(mailer *MockMailer) {
+				mailer.On("Send", mock.Anything, mock.MatchedBy(func(msg mail.Message) bool {
+					return msg.To == "test@example.com" &&
+						strings.Contains(msg.Body, "http://localhost:8080/api/v1/user/verify?token=")
+				})).Return(nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   `"username":"testuser"`,
 		},
+		{
+			name:        "Account is kept when the email fails",
+			requestBody: `{"username": "testuser", "email": "test@example.com", "password": "password123"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("Create", mock.Anything, mock.AnythingOfType("*user.User")).Return(nil)
+				repo.On("MarkVerificationSent", mock.Anything, int64(0), time.Minute).Return(true, nil)
+			},
+			mailerSetup: func(mailer *MockMailer) {
+				mailer.On("Send", mock.Anything, mock.Anything).Return(errors.New("connection refused"))
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"is_active":false`,
+		},
 		{
 			name:        "Email already exists",
 			requestBody: `{"username": "testuser", "email": "existing@example.com", "password": "password123"}`,
@@ -63,6 +91,13 @@ func TestService_RegisterUser(t *testing.T) {
 			expectedStatus: http.StatusInternalServerError,
 			expectedBody:   `"error":"email already exists"`,
 		},
+		{
+			name:           "Password too short",
+			requestBody:    `{"username": "testuser", "email": "test@example.com", "password": "short"}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"password must be at least 8 characters long"`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -71,12 +106,18 @@ func TestService_RegisterUser(t *testing.T) {
 
 			mockRepo := new(MockRepository)
 			tt.mockSetup(mockRepo)
+			mockMailer := new(MockMailer)
+			if tt.mailerSetup != nil {
+				tt.mailerSetup(mockMailer)
+			}
 
 			if tt.userID > 0 {
 				testutils.AddUserToken(c, tt.userID)
 			}
 
 			service := NewService(mockRepo, "test-secret")
+			service.VerifySecret = []byte("verify-secret")
+			service.Mailer = mockMailer
 
 			// Execute
 			err := service.RegisterUser(c)
@@ -85,7 +126,7 @@ func TestService_RegisterUser(t *testing.T) {
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedStatus, rec.Code)
 			assert.Contains(t, rec.Body.String(), tt.expectedBody)
-
+			mockMailer.AssertExpectations(t)
 		})
 	}
 }
@@ -114,9 +155,15 @@ func TestService_LoginUser(t *testing.T) {
 					CreatedAt: time.Now(),
 				}
 				repo.On("GetByEmail", mock.Anything, "test@example.com").Return(user, nil)
+				repo.On("CreateSession", mock.Anything, mock.MatchedBy(func(s *Session) bool {
+					return s.UserID == 1 && s.ID != "" && s.IPAddress == "192.0.2.1"
+				}), mock.MatchedBy(func(rt *RefreshToken) bool {
+					return rt.UserID == 1 && len(rt.TokenHash) == 64
+				})).Return(nil)
+				repo.On("GetAccess", mock.Anything, int64(1)).Return([]string{}, []string{}, nil)
 			},
 			expectedStatus: http.StatusOK,
-			expectedBody:   `"token"`,
+			expectedBody:   `"refresh_token"`,
 		},
 		{
 			name:        "User not found",
@@ -143,8 +190,26 @@ func TestService_LoginUser(t *testing.T) {
 				repo.On("GetByEmail", mock.Anything, "inactive@example.com").Return(user, nil)
 			},
 
-			expectedStatus: http.StatusOK,
-			expectedBody:   `"token"`, // Service returns a token
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"email address not verified"`,
+		},
+		{
+			name:        "Deactivated account",
+			requestBody: `{"email": "test@example.com", "password": "password123"}`,
+			mockSetup: func(repo *MockRepository) {
+				deactivatedAt := time.Now()
+				user := &User{
+					ID:            1,
+					Username:      "testuser",
+					Email:         "test@example.com",
+					Password:      string(hashedPassword),
+					IsActive:      true,
+					DeactivatedAt: &deactivatedAt,
+				}
+				repo.On("GetByEmail", mock.Anything, "test@example.com").Return(user, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"account has been deactivated"`,
 		},
 		{
 			name:        "Invalid credentials",
@@ -214,13 +279,16 @@ func TestService_GetUser(t *testing.T) {
 	tests := []struct {
 		name           string
 		userID         int64
+		viewerID       int64
+		permissions    []interface{}
 		mockSetup      func(*MockRepository)
 		expectedStatus int
 		expectedBody   string
 	}{
 		{
-			name:   "Valid profile retrieval",
-			userID: 1,
+			name:     "Valid profile retrieval",
+			userID:   1,
+			viewerID: 1,
 			mockSetup: func(repo *MockRepository) {
 				repo.On("GetByID", mock.Anything, int64(1)).Return(testUser, nil)
 			},
@@ -228,8 +296,29 @@ func TestService_GetUser(t *testing.T) {
 			expectedBody:   `"username":"testuser"`,
 		},
 		{
-			name:   "User not found",
-			userID: 999,
+			name:           "Another user's profile",
+			userID:         1,
+			viewerID:       2,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"not allowed to read this user"`,
+		},
+		{
+			name:        "Another user's profile with permission",
+			userID:      1,
+			viewerID:    2,
+			permissions: []interface{}{auth.PermissionReadUsers},
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByID", mock.Anything, int64(1)).Return(testUser, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"username":"testuser"`,
+		},
+		{
+			name:        "User not found",
+			userID:      999,
+			viewerID:    2,
+			permissions: []interface{}{auth.PermissionReadUsers},
 			mockSetup: func(repo *MockRepository) {
 				repo.On("GetByID", mock.Anything, int64(999)).Return(nil, errors.New("not found"))
 			},
@@ -243,6 +332,8 @@ func TestService_GetUser(t *testing.T) {
 			// Setup with URL path parameter
 			path := "/api/v1/user/" + strconv.FormatInt(tt.userID, 10)
 			c, rec := testutils.SetupEchoContext(http.MethodGet, path, "")
+			testutils.AddUserToken(c, tt.viewerID)
+			c.Get("user").(*jwt.Token).Claims.(jwt.MapClaims)["perms"] = tt.permissions
 
 			// This is critical - set the path parameter
 			c.SetParamNames("id")
@@ -266,3 +357,1053 @@ func TestService_GetUser(t *testing.T) {
 		})
 	}
 }
+
+func TestService_VerifyEmail(t *testing.T) {
+	secret := []byte("verify-secret")
+	valid := newUserToken(secret, purposeVerify, 4, time.Now().Add(time.Hour))
+	expired := newUserToken(secret, purposeVerify, 4, time.Now().Add(-time.Minute))
+
+	tests := []struct {
+		name           string
+		token          string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:  "Valid link activates the account",
+			token: valid,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("ActivateUser", mock.Anything, int64(4)).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"Email verified successfully"`,
+		},
+		{
+			name:           "Expired link",
+			token:          expired,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"verification link is invalid or has expired"`,
+		},
+		{
+			name:           "Tampered link",
+			token:          "5" + valid[1:],
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"verification link is invalid or has expired"`,
+		},
+		{
+			name:  "Deleted user",
+			token: valid,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("ActivateUser", mock.Anything, int64(4)).Return(errs.NotFound(errors.New("user not found")))
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"error":"user not found"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.SetupEchoContext(http.MethodGet, "/api/v1/user/verify?token="+tt.token, "")
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo, "test-secret")
+			service.VerifySecret = secret
+
+			// Execute
+			err := service.VerifyEmail(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_ResendVerification(t *testing.T) {
+	inactive := &User{ID: 4, Username: "jane", Email: "jane@example.com"}
+	active := &User{ID: 5, Username: "joe", Email: "joe@example.com", IsActive: true}
+
+	tests := []struct {
+		name           string
+		requestBody    string
+		mockSetup      func(*MockRepository)
+		mailerSetup    func(*MockMailer)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Inactive account gets a new link",
+			requestBody: `{"email": "jane@example.com"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByEmail", mock.Anything, "jane@example.com").Return(inactive, nil)
+				repo.On("MarkVerificationSent", mock.Anything, int64(4), time.Minute).Return(true, nil)
+			},
+			mailerSetup: func(mailer *MockMailer) {
+				mailer.On("Send", mock.Anything, mock.MatchedBy(func(msg mail.Message) bool {
+					return msg.To == "jane@example.com"
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"If the account exists and is not verified, a verification email has been sent"`,
+		},
+		{
+			name:        "Link sent too recently gets the same reply",
+			requestBody: `{"email": "jane@example.com"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByEmail", mock.Anything, "jane@example.com").Return(inactive, nil)
+				repo.On("MarkVerificationSent", mock.Anything, int64(4), time.Minute).Return(false, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"If the account exists and is not verified, a verification email has been sent"`,
+		},
+		{
+			name:        "Failed send gets the same reply",
+			requestBody: `{"email": "jane@example.com"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByEmail", mock.Anything, "jane@example.com").Return(inactive, nil)
+				repo.On("MarkVerificationSent", mock.Anything, int64(4), time.Minute).Return(true, nil)
+			},
+			mailerSetup: func(mailer *MockMailer) {
+				mailer.On("Send", mock.Anything, mock.Anything).Return(errors.New("connection refused"))
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"If the account exists and is not verified, a verification email has been sent"`,
+		},
+		{
+			name:        "Already verified account gets the same reply",
+			requestBody: `{"email": "joe@example.com"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByEmail", mock.Anything, "joe@example.com").Return(active, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"If the account exists and is not verified, a verification email has been sent"`,
+		},
+		{
+			name:        "Unknown email gets the same reply",
+			requestBody: `{"email": "nobody@example.com"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByEmail", mock.Anything, "nobody@example.com").Return(nil, errs.NotFound(errors.New("not found")))
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"If the account exists and is not verified, a verification email has been sent"`,
+		},
+		{
+			name:           "Missing email",
+			requestBody:    `{}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"email is required"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.SetupEchoContext(http.MethodPost, "/api/v1/user/verify/resend", tt.requestBody)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+			mockMailer := new(MockMailer)
+			if tt.mailerSetup != nil {
+				tt.mailerSetup(mockMailer)
+			}
+
+			service := NewService(mockRepo, "test-secret")
+			service.VerifySecret = []byte("verify-secret")
+			service.Mailer = mockMailer
+
+			// Execute
+			err := service.ResendVerification(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+			mockMailer.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_ForgotPassword(t *testing.T) {
+	jane := &User{ID: 4, Username: "jane", Email: "jane@example.com", IsActive: true}
+
+	tests := []struct {
+		name           string
+		requestBody    string
+		mockSetup      func(*MockRepository)
+		mailerSetup    func(*MockMailer)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Reset link is emailed and only its hash stored",
+			requestBody: `{"email": "jane@example.com"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByEmail", mock.Anything, "jane@example.com").Return(jane, nil)
+				repo.On("CreatePasswordReset", mock.Anything, int64(4), mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(nil)
+			},
+			mailerSetup: func(mailer *MockMailer) {
+				mailer.On("Send", mock.Anything, mock.MatchedBy(func(msg mail.Message) bool {
+					return msg.To == "jane@example.com" &&
+						strings.Contains(msg.Body, "http://localhost:5173/reset-password?token=")
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"If the account exists, a password reset link has been sent"`,
+		},
+		{
+			name:        "Unknown email gets the same reply",
+			requestBody: `{"email": "nobody@example.com"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByEmail", mock.Anything, "nobody@example.com").Return(nil, errs.NotFound(errors.New("not found")))
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"If the account exists, a password reset link has been sent"`,
+		},
+		{
+			name:        "Failed send gets the same reply",
+			requestBody: `{"email": "jane@example.com"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetByEmail", mock.Anything, "jane@example.com").Return(jane, nil)
+				repo.On("CreatePasswordReset", mock.Anything, int64(4), mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(nil)
+			},
+			mailerSetup: func(mailer *MockMailer) {
+				mailer.On("Send", mock.Anything, mock.Anything).Return(errors.New("connection refused"))
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"If the account exists, a password reset link has been sent"`,
+		},
+		{
+			name:           "Missing email",
+			requestBody:    `{}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"email is required"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.SetupEchoContext(http.MethodPost, "/api/v1/user/password/forgot", tt.requestBody)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+			mockMailer := new(MockMailer)
+			if tt.mailerSetup != nil {
+				tt.mailerSetup(mockMailer)
+			}
+
+			service := NewService(mockRepo, "test-secret")
+			service.Mailer = mockMailer
+
+			// Execute
+			err := service.ForgotPassword(c)
+			require.NoError(t, service.Close(context.Background()))
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+			mockMailer.AssertExpectations(t)
+		})
+	}
+
+	t.Run("Emailed token matches the stored hash", func(t *testing.T) {
+		c, _ := testutils.SetupEchoContext(http.MethodPost, "/api/v1/user/password/forgot", `{"email": "jane@example.com"}`)
+
+		var storedHash string
+		mockRepo := new(MockRepository)
+		mockRepo.On("GetByEmail", mock.Anything, "jane@example.com").Return(jane, nil)
+		mockRepo.On("CreatePasswordReset", mock.Anything, int64(4), mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).
+			Run(func(args mock.Arguments) { storedHash = args.String(2) }).Return(nil)
+		var body string
+		mockMailer := new(MockMailer)
+		mockMailer.On("Send", mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { body = args.Get(1).(mail.Message).Body }).Return(nil)
+
+		service := NewService(mockRepo, "test-secret")
+		service.Mailer = mockMailer
+		require.NoError(t, service.ForgotPassword(c))
+		require.NoError(t, service.Close(context.Background()))
+
+		token := strings.Fields(body[strings.Index(body, "?token=")+len("?token="):])[0]
+		assert.Equal(t, hashOpaqueToken(token), storedHash)
+		assert.NotContains(t, body, storedHash)
+	})
+}
+
+func TestService_CloseWaitsForResetEmails(t *testing.T) {
+	c, rec := testutils.SetupEchoContext(http.MethodPost, "/api/v1/user/password/forgot", `{"email": "jane@example.com"}`)
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetByEmail", mock.Anything, "jane@example.com").Return(&User{ID: 4, Email: "jane@example.com"}, nil)
+	mockRepo.On("CreatePasswordReset", mock.Anything, int64(4), mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(nil)
+	sending, release := make(chan struct{}), make(chan struct{})
+	mockMailer := new(MockMailer)
+	mockMailer.On("Send", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { close(sending); <-release }).Return(nil)
+
+	service := NewService(mockRepo, "test-secret")
+	service.Mailer = mockMailer
+
+	// The reply does not wait for the email
+	require.NoError(t, service.ForgotPassword(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	<-sending
+
+	// Shutdown waits for it
+	closed := make(chan error, 1)
+	go func() { closed <- service.Close(context.Background()) }()
+	select {
+	case <-closed:
+		t.Fatal("Close returned while an email was still being sent")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	assert.NoError(t, <-closed)
+	mockMailer.AssertExpectations(t)
+}
+
+func TestService_ResetPassword(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Valid token sets the password",
+			requestBody: `{"token": "abc", "password": "newpassword"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("ResetPassword", mock.Anything, hashOpaqueToken("abc"), mock.MatchedBy(func(hash string) bool {
+					return bcrypt.CompareHashAndPassword([]byte(hash), []byte("newpassword")) == nil
+				})).Return(int64(4), nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"Password reset successfully"`,
+		},
+		{
+			name:        "Used or expired token",
+			requestBody: `{"token": "abc", "password": "newpassword"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("ResetPassword", mock.Anything, hashOpaqueToken("abc"), mock.AnythingOfType("string")).
+					Return(int64(0), errs.NotFound(errors.New("reset link is invalid or has expired")))
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"error":"reset link is invalid or has expired"`,
+		},
+		{
+			name:           "Missing password",
+			requestBody:    `{"token": "abc"}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"token and password are required"`,
+		},
+		{
+			name:           "Password too short",
+			requestBody:    `{"token": "abc", "password": "short"}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"password must be at least 8 characters long"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.SetupEchoContext(http.MethodPost, "/api/v1/user/password/reset", tt.requestBody)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo, "test-secret")
+
+			// Execute
+			err := service.ResetPassword(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_ChangePassword(t *testing.T) {
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+
+	tests := []struct {
+		name           string
+		requestBody    string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Password changed with a fresh token",
+			requestBody: `{"current_password": "password123", "new_password": "newpassword"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPassword", mock.Anything, int64(1)).Return(string(hashedPassword), nil)
+				repo.On("UpdatePassword", mock.Anything, int64(1), mock.MatchedBy(func(hash string) bool {
+					return bcrypt.CompareHashAndPassword([]byte(hash), []byte("newpassword")) == nil
+				})).Return(nil)
+				repo.On("GetByID", mock.Anything, int64(1)).Return(&User{ID: 1, Username: "testuser", TokenVersion: 3}, nil)
+				repo.On("CreateSession", mock.Anything, mock.AnythingOfType("*user.Session"), mock.AnythingOfType("*user.RefreshToken")).Return(nil)
+				repo.On("GetAccess", mock.Anything, int64(1)).Return([]string{}, []string{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"refresh_token"`,
+		},
+		{
+			name:        "Wrong current password",
+			requestBody: `{"current_password": "wrong", "new_password": "newpassword"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GetPassword", mock.Anything, int64(1)).Return(string(hashedPassword), nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"error":"current password is incorrect"`,
+		},
+		{
+			name:           "Missing new password",
+			requestBody:    `{"current_password": "password123"}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"current_password and new_password are required"`,
+		},
+		{
+			name:           "New password too long",
+			requestBody:    `{"current_password": "password123", "new_password": "` + strings.Repeat("x", 73) + `"}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"password can be at most 72 bytes long"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.SetupEchoContext(http.MethodPost, "/api/v1/user/password/change", tt.requestBody)
+			testutils.AddUserToken(c, 1)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo, "test-secret")
+
+			// Execute
+			err := service.ChangePassword(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_RefreshToken(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Token rotated",
+			requestBody: `{"refresh_token": "abc"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("RotateRefreshToken", mock.Anything, hashOpaqueToken("abc"), mock.AnythingOfType("*user.RefreshToken")).
+					Run(func(args mock.Arguments) {
+						next := args.Get(2).(*RefreshToken)
+						next.UserID = 1
+						next.SessionID = "session-id"
+					}).Return(nil)
+				repo.On("GetByID", mock.Anything, int64(1)).Return(&User{ID: 1, Username: "testuser", IsActive: true}, nil)
+				repo.On("GetAccess", mock.Anything, int64(1)).Return([]string{"admin"}, []string{"users:manage"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"refresh_token"`,
+		},
+		{
+			name:        "Reused token",
+			requestBody: `{"refresh_token": "abc"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("RotateRefreshToken", mock.Anything, hashOpaqueToken("abc"), mock.AnythingOfType("*user.RefreshToken")).
+					Return(errs.Unauthorized(errors.New("refresh token was already used; the sign-in it belongs to has been revoked")))
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `"error":"refresh token was already used; the sign-in it belongs to has been revoked"`,
+		},
+		{
+			name:           "Missing token",
+			requestBody:    `{}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"refresh_token is required"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.SetupEchoContext(http.MethodPost, "/api/v1/user/token/refresh", tt.requestBody)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo, "test-secret")
+
+			// Execute
+			err := service.RefreshToken(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_Logout(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name           string
+		requestBody    string
+		jti            string
+		sid            string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Session revoked",
+			requestBody: `{}`,
+			jti:         "token-id",
+			sid:         "session-id",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("RevokeSession", mock.Anything, int64(1), "session-id").Return(nil)
+				repo.On("RevokeAccessToken", mock.Anything, "token-id", exp).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"Logged out successfully"`,
+		},
+		{
+			name:        "Access and refresh tokens revoked",
+			requestBody: `{"refresh_token": "abc"}`,
+			jti:         "token-id",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("RevokeTokenSession", mock.Anything, int64(1), hashOpaqueToken("abc")).Return(nil)
+				repo.On("RevokeAccessToken", mock.Anything, "token-id", exp).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"Logged out successfully"`,
+		},
+		{
+			name:        "Access token only",
+			requestBody: `{}`,
+			jti:         "token-id",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("RevokeAccessToken", mock.Anything, "token-id", exp).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"Logged out successfully"`,
+		},
+		{
+			name:        "Token without an ID signs out everywhere",
+			requestBody: `{"refresh_token": "abc"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("RevokeUserTokens", mock.Anything, int64(1)).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"Logged out everywhere successfully"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.SetupEchoContext(http.MethodPost, "/api/v1/user/logout", tt.requestBody)
+			testutils.AddUserToken(c, 1)
+			if tt.jti != "" {
+				claims := c.Get("user").(*jwt.Token).Claims.(jwt.MapClaims)
+				claims["jti"] = tt.jti
+				claims["exp"] = float64(exp.Unix())
+			}
+			if tt.sid != "" {
+				c.Get("user").(*jwt.Token).Claims.(jwt.MapClaims)["sid"] = tt.sid
+			}
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo, "test-secret")
+
+			// Execute
+			err := service.Logout(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_LogoutAll(t *testing.T) {
+	c, rec := testutils.SetupEchoContext(http.MethodPost, "/api/v1/user/logout/all", "")
+	testutils.AddUserToken(c, 1)
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("RevokeUserTokens", mock.Anything, int64(1)).Return(nil)
+
+	service := NewService(mockRepo, "test-secret")
+
+	err := service.LogoutAll(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"message":"Logged out everywhere successfully"`)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_ListSessions(t *testing.T) {
+	c, rec := testutils.SetupEchoContext(http.MethodGet, "/api/v1/user/sessions", "")
+	testutils.AddUserToken(c, 1)
+	c.Get("user").(*jwt.Token).Claims.(jwt.MapClaims)["sid"] = "phone"
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("ListSessions", mock.Anything, int64(1)).Return([]Session{
+		{ID: "laptop", UserID: 1, UserAgent: "Firefox", IPAddress: "192.0.2.1"},
+		{ID: "phone", UserID: 1, UserAgent: "Safari", IPAddress: "192.0.2.2"},
+	}, nil)
+
+	service := NewService(mockRepo, "test-secret")
+
+	err := service.ListSessions(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"id":"laptop","user_agent":"Firefox"`)
+	assert.Contains(t, rec.Body.String(), `"current":true`)
+	assert.Equal(t, 1, strings.Count(rec.Body.String(), `"current":true`))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_RevokeSession(t *testing.T) {
+	tests := []struct {
+		name           string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Session revoked",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("RevokeSession", mock.Anything, int64(1), "laptop").Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"Session revoked successfully"`,
+		},
+		{
+			name: "Unknown or someone else's session",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("RevokeSession", mock.Anything, int64(1), "laptop").Return(errs.NotFound(errors.New("session not found")))
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"error":"session not found"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.SetupEchoContext(http.MethodDelete, "/api/v1/user/sessions/laptop", "")
+			c.SetParamNames("id")
+			c.SetParamValues("laptop")
+			testutils.AddUserToken(c, 1)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo, "test-secret")
+
+			// Execute
+			err := service.RevokeSession(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_ListUsers(t *testing.T) {
+	c, rec := testutils.SetupEchoContext(http.MethodGet, "/api/v1/admin/users?page=2&page_size=1", "")
+	testutils.AddUserToken(c, 1)
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("CountUsers", mock.Anything).Return(3, nil)
+	mockRepo.On("ListUsers", mock.Anything, 1, 1).Return([]User{
+		{ID: 2, Username: "jane", Email: "jane@example.com", IsActive: true, Roles: []string{"admin"}},
+	}, nil)
+
+	service := NewService(mockRepo, "test-secret")
+
+	err := service.ListUsers(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"roles":["admin"]`)
+	assert.Contains(t, rec.Body.String(), `"total_records":3`)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_DeactivateUser(t *testing.T) {
+	tests := []struct {
+		name           string
+		userID         string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "User deactivated",
+			userID: "2",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("DeactivateUser", mock.Anything, int64(2)).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"User deactivated successfully"`,
+		},
+		{
+			name:   "User not found",
+			userID: "999",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("DeactivateUser", mock.Anything, int64(999)).Return(errs.NotFound(errors.New("user not found")))
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"error":"user not found"`,
+		},
+		{
+			name:           "Own account",
+			userID:         "1",
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"you cannot deactivate your own account"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.SetupEchoContext(http.MethodPost, "/api/v1/admin/users/"+tt.userID+"/deactivate", "")
+			c.SetParamNames("id")
+			c.SetParamValues(tt.userID)
+			testutils.AddUserToken(c, 1)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo, "test-secret")
+
+			// Execute
+			err := service.DeactivateUser(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_PromoteUser(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Role granted",
+			requestBody: `{"role": "admin"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GrantRole", mock.Anything, int64(2), "admin").Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"Role granted successfully"`,
+		},
+		{
+			name:        "Unknown role",
+			requestBody: `{"role": "owner"}`,
+			mockSetup: func(repo *MockRepository) {
+				repo.On("GrantRole", mock.Anything, int64(2), "owner").Return(errs.NotFound(errors.New("role not found")))
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"error":"role not found"`,
+		},
+		{
+			name:           "Missing role",
+			requestBody:    `{}`,
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"role is required"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.SetupEchoContext(http.MethodPost, "/api/v1/admin/users/2/roles", tt.requestBody)
+			c.SetParamNames("id")
+			c.SetParamValues("2")
+			testutils.AddUserToken(c, 1)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo, "test-secret")
+
+			// Execute
+			err := service.PromoteUser(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_ReactivateUser(t *testing.T) {
+	tests := []struct {
+		name           string
+		userID         string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "User reactivated",
+			userID: "2",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("ReactivateUser", mock.Anything, int64(2)).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"User reactivated successfully"`,
+		},
+		{
+			name:   "User not found",
+			userID: "999",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("ReactivateUser", mock.Anything, int64(999)).Return(errs.NotFound(errors.New("user not found")))
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"error":"user not found"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.SetupEchoContext(http.MethodPost, "/api/v1/admin/users/"+tt.userID+"/reactivate", "")
+			c.SetParamNames("id")
+			c.SetParamValues(tt.userID)
+			testutils.AddUserToken(c, 1)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo, "test-secret")
+
+			// Execute
+			err := service.ReactivateUser(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_DemoteUser(t *testing.T) {
+	tests := []struct {
+		name           string
+		userID         string
+		role           string
+		mockSetup      func(*MockRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Role revoked",
+			userID: "2",
+			role:   "admin",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("RevokeRole", mock.Anything, int64(2), "admin").Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"message":"Role revoked successfully"`,
+		},
+		{
+			name:   "Unknown role",
+			userID: "2",
+			role:   "owner",
+			mockSetup: func(repo *MockRepository) {
+				repo.On("RevokeRole", mock.Anything, int64(2), "owner").Return(errs.NotFound(errors.New("role not found")))
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"error":"role not found"`,
+		},
+		{
+			name:           "Own admin role",
+			userID:         "1",
+			role:           "admin",
+			mockSetup:      func(repo *MockRepository) {},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"you cannot revoke your own admin role"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, rec := testutils.SetupEchoContext(http.MethodDelete, "/api/v1/admin/users/"+tt.userID+"/roles/"+tt.role, "")
+			c.SetParamNames("id", "role")
+			c.SetParamValues(tt.userID, tt.role)
+			testutils.AddUserToken(c, 1)
+
+			mockRepo := new(MockRepository)
+			tt.mockSetup(mockRepo)
+
+			service := NewService(mockRepo, "test-secret")
+
+			// Execute
+			err := service.DemoteUser(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_GenerateJWT(t *testing.T) {
+	service := NewService(new(MockRepository), "test-secret")
+
+	user := &User{ID: 1, Username: "testuser", Email: "test@example.com", TokenVersion: 3, Roles: []string{"admin"}, Permissions: []string{"users:read", "users:manage"}}
+	tokenString, err := service.generateJWT(user, "session-id")
+	require.NoError(t, err)
+
+	token, err := jwt.Parse(tokenString, func(*jwt.Token) (interface{}, error) { return []byte("test-secret"), nil })
+	require.NoError(t, err)
+	claims := token.Claims.(jwt.MapClaims)
+	assert.Equal(t, float64(1), claims["user_id"])
+	assert.Equal(t, float64(3), claims["tv"])
+	assert.NotEmpty(t, claims["jti"])
+	assert.Equal(t, "session-id", claims["sid"])
+	assert.Equal(t, []interface{}{"admin"}, claims["roles"])
+	assert.Equal(t, []interface{}{"users:read", "users:manage"}, claims["perms"])
+}
+
+func TestService_NewRefreshToken(t *testing.T) {
+	tests := []struct {
+		name              string
+		userAgent         string
+		forwardedFor      string
+		expectedUserAgent string
+		expectedIP        string
+	}{
+		{
+			name:              "Client is recorded",
+			userAgent:         "Firefox",
+			forwardedFor:      "2001:db8::1",
+			expectedUserAgent: "Firefox",
+			expectedIP:        "2001:db8::1",
+		},
+		{
+			name:              "Long user agent is cut between characters",
+			userAgent:         "a" + strings.Repeat("é", maxUserAgentLength),
+			forwardedFor:      "192.0.2.1",
+			expectedUserAgent: "a" + strings.Repeat("é", maxUserAgentLength-1),
+			expectedIP:        "192.0.2.1",
+		},
+		{
+			name:              "Forged address is dropped",
+			userAgent:         "curl",
+			forwardedFor:      strings.Repeat("x", 100),
+			expectedUserAgent: "curl",
+			expectedIP:        "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			c, _ := testutils.SetupEchoContext(http.MethodPost, "/api/v1/user/login", "")
+			c.Request().Header.Set("User-Agent", tt.userAgent)
+			c.Request().Header.Set("X-Forwarded-For", tt.forwardedFor)
+
+			service := NewService(new(MockRepository), "test-secret")
+
+			// Execute
+			refresh, _, err := service.newRefreshToken(c, 1)
+
+			// Assert
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedUserAgent, refresh.UserAgent)
+			assert.Equal(t, tt.expectedIP, refresh.IPAddress)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/netip"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	errs "github.com/phsaurav/echo_prod_blueprint/pkg/error"
+)
+
+const (
+	// opaqueTokenBytes is the entropy of password reset and refresh tokens.
+	opaqueTokenBytes = 32
+	// tokenIDBytes is the entropy of JWT IDs and session IDs.
+	tokenIDBytes = 16
+	// maxUserAgentLength is the longest user agent kept with a refresh token.
+	maxUserAgentLength = 255
+)
+
+// newOpaqueToken returns a random token and the hash it is stored under.
+// Only the user ever sees the token itself.
+func newOpaqueToken() (token, hash string, err error) {
+	b := make([]byte, opaqueTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", errs.InternalServerError(err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashOpaqueToken(token), nil
+}
+
+// hashOpaqueToken returns the hex SHA-256 of an opaque token. Tokens are
+// random, so a fast unsalted hash is enough to keep stored ones useless.
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newTokenID returns a random, URL-safe identifier.
+func newTokenID() (string, error) {
+	b := make([]byte, tokenIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", errs.InternalServerError(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// newRefreshToken returns a refresh token for the client making the
+// request, and the token to hand it. The session is left for the caller to
+// set: a new one on sign-in, the session of the replaced token on rotation.
+func (s *Service) newRefreshToken(c echo.Context, userID int64) (*RefreshToken, string, error) {
+	token, hash, err := newOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	return &RefreshToken{
+		UserID:    userID,
+		TokenHash: hash,
+		UserAgent: clientUserAgent(c),
+		IPAddress: clientIP(c),
+		ExpiresAt: time.Now().Add(s.RefreshExpires),
+	}, token, nil
+}
+
+// clientUserAgent returns the user agent of the request as valid UTF-8, cut
+// to maxUserAgentLength characters.
+func clientUserAgent(c echo.Context) string {
+	userAgent := strings.ToValidUTF8(c.Request().UserAgent(), "")
+	if utf8.RuneCountInString(userAgent) > maxUserAgentLength {
+		userAgent = string([]rune(userAgent)[:maxUserAgentLength])
+	}
+	return userAgent
+}
+
+// clientIP returns the address of the client making the request, or "" when
+// it is not a valid IP address. Forwarding headers are set by the client
+// unless a proxy overwrites them, so they cannot be trusted to hold one.
+func clientIP(c echo.Context) string {
+	addr, err := netip.ParseAddr(c.RealIP())
+	if err != nil {
+		return ""
+	}
+	return addr.WithZone("").String()
+}
+
+// issueTokens signs a user in on a new session and returns their access
+// and refresh tokens.
+func (s *Service) issueTokens(c echo.Context, user *User) (*TokenResponse, error) {
+	refresh, token, err := s.newRefreshToken(c, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	sessionID, err := newTokenID()
+	if err != nil {
+		return nil, err
+	}
+	session := &Session{
+		ID:        sessionID,
+		UserID:    user.ID,
+		UserAgent: refresh.UserAgent,
+		IPAddress: refresh.IPAddress,
+		ExpiresAt: refresh.ExpiresAt,
+	}
+	if err := s.Repo.CreateSession(c.Request().Context(), session, refresh); err != nil {
+		return nil, err
+	}
+	return s.tokenResponse(c.Request().Context(), user, session.ID, token)
+}
+
+// tokenResponse pairs a new access token for user, tied to a session and
+// carrying their current roles, with a refresh token.
+func (s *Service) tokenResponse(ctx context.Context, user *User, sessionID, refreshToken string) (*TokenResponse, error) {
+	var err error
+	if user.Roles, user.Permissions, err = s.Repo.GetAccess(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	access, err := s.generateJWT(user, sessionID)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	return &TokenResponse{
+		Token:        access,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.JWTExpires / time.Second),
+	}, nil
+}
@@ -0,0 +1,138 @@
+package user
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/phsaurav/echo_prod_blueprint/pkg/logger"
+	"github.com/phsaurav/echo_prod_blueprint/pkg/mail"
+)
+
+var logging = logger.NewLogger()
+
+// Purposes of the signed tokens mailed to users. Each purpose is mixed into
+// the signature, so a token issued for one cannot be used for another.
+const purposeVerify = "verify"
+
+// newUserToken signs a token for purpose naming a user and expiring at exp.
+// The token is "<user id>.<expiry unix time>.<HMAC-SHA256 of both>", so it
+// can be checked without storing it.
+func newUserToken(secret []byte, purpose string, userID int64, exp time.Time) string {
+	payload := fmt.Sprintf("%d.%d", userID, exp.Unix())
+	return payload + "." + signUserToken(secret, purpose, payload)
+}
+
+// parseUserToken returns the user named by an unexpired token for purpose.
+func parseUserToken(secret []byte, purpose, token string, now time.Time) (int64, bool) {
+	if len(secret) == 0 {
+		return 0, false
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	exp, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || now.Unix() >= exp {
+		return 0, false
+	}
+	want := signUserToken(secret, purpose, parts[0]+"."+parts[1])
+	if !hmac.Equal([]byte(parts[2]), []byte(want)) {
+		return 0, false
+	}
+	return id, true
+}
+
+// signUserToken returns the URL-safe HMAC-SHA256 of a token payload.
+func signUserToken(secret []byte, purpose, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose + ":" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// sendVerification emails an inactive user a link activating their
+// account. It reports false, sending nothing, when the user was sent one
+// less than ResendInterval ago or is already active.
+func (s *Service) sendVerification(ctx context.Context, u *User) (bool, error) {
+	if s.Mailer == nil {
+		return false, errors.New("email is not configured")
+	}
+	if len(s.VerifySecret) == 0 {
+		return false, errors.New("verification secret is not configured")
+	}
+	ok, err := s.Repo.MarkVerificationSent(ctx, u.ID, s.ResendInterval)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	exp := time.Now().Add(s.VerifyTTL)
+	link := s.VerifyURL + "?token=" + url.QueryEscape(newUserToken(s.VerifySecret, purposeVerify, u.ID, exp))
+	err = s.Mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Confirm your email address to finish setting up your account:\n\n"+
+			"%s\n\n"+
+			"The link is valid until %s. If you did not sign up, you can ignore this email.\n",
+			u.Username, link, exp.UTC().Format("2 Jan 2006 15:04 MST")),
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// sendPasswordReset emails the account registered under email a link
+// resetting its password, replacing any link sent before. Unknown addresses
+// are sent nothing.
+func (s *Service) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := s.Repo.GetByEmail(ctx, email)
+	if err != nil {
+		// Lookup failures other than unknown addresses are logged by errs
+		return nil
+	}
+
+	token, hash, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+	exp := time.Now().Add(s.ResetTTL)
+	if err := s.Repo.CreatePasswordReset(ctx, user.ID, hash, exp); err != nil {
+		return err
+	}
+
+	return s.Mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Someone asked to reset the password of your account. Choose a new password here:\n\n"+
+			"%s\n\n"+
+			"The link can be used once, until %s. If you did not ask for it, you can ignore this email.\n",
+			user.Username, s.ResetURL+"?token="+token, exp.UTC().Format("2 Jan 2006 15:04 MST")),
+	})
+}
+
+// verifyURL returns the address of the verify endpoint on the API at
+// apiURL, which may omit its scheme.
+func verifyURL(apiURL string) string {
+	if !strings.Contains(apiURL, "://") {
+		apiURL = "http://" + apiURL
+	}
+	return strings.TrimRight(apiURL, "/") + "/api/v1/user/verify"
+}
+
+// resetURL returns the frontend page that redeems reset links.
+func resetURL(frontendURL string) string {
+	return strings.TrimRight(frontendURL, "/") + "/reset-password"
+}
@@ -0,0 +1,45 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserToken(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1700000000, 0)
+	token := newUserToken(secret, purposeVerify, 42, now.Add(time.Hour))
+
+	id, ok := parseUserToken(secret, purposeVerify, token, now)
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), id)
+
+	tests := []struct {
+		name    string
+		secret  []byte
+		purpose string
+		token   string
+		now     time.Time
+	}{
+		{name: "Expired", secret: secret, purpose: purposeVerify, token: token, now: now.Add(time.Hour)},
+		{name: "Other secret", secret: []byte("other"), purpose: purposeVerify, token: token, now: now},
+		{name: "Other purpose", secret: secret, purpose: "reset", token: token, now: now},
+		{name: "Other user", secret: secret, purpose: purposeVerify, token: "43" + token[2:], now: now},
+		{name: "Malformed", secret: secret, purpose: purposeVerify, token: "42.abc", now: now},
+		{name: "No secret", secret: nil, purpose: purposeVerify, token: newUserToken(nil, purposeVerify, 42, now.Add(time.Hour)), now: now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok := parseUserToken(tt.secret, tt.purpose, tt.token, tt.now)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestVerifyURL(t *testing.T) {
+	assert.Equal(t, "http://localhost:8080/api/v1/user/verify", verifyURL("localhost:8080"))
+	assert.Equal(t, "https://api.example.com/api/v1/user/verify", verifyURL("https://api.example.com/"))
+}
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Full-text search over poll questions and option texts
+CREATE INDEX IF NOT EXISTS idx_polls_question_fts ON polls USING GIN (to_tsvector('english', question));
+CREATE INDEX IF NOT EXISTS idx_poll_options_text_fts ON poll_options USING GIN (to_tsvector('english', text));
+
+-- Listing filters and sort orders
+CREATE INDEX IF NOT EXISTS idx_polls_user_id ON polls(user_id);
+CREATE INDEX IF NOT EXISTS idx_polls_created_at ON polls(created_at);
+CREATE INDEX IF NOT EXISTS idx_poll_votes_poll_id_created_at ON poll_votes(poll_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_poll_votes_poll_id_created_at;
+DROP INDEX IF EXISTS idx_polls_created_at;
+DROP INDEX IF EXISTS idx_polls_user_id;
+DROP INDEX IF EXISTS idx_poll_options_text_fts;
+DROP INDEX IF EXISTS idx_polls_question_fts;
+
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Poll lifecycle: drafts, scheduled opening and closing
+ALTER TABLE polls
+  ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'open'
+    CHECK (status IN ('draft', 'open', 'closed', 'archived')),
+  ADD COLUMN opens_at TIMESTAMP NULL,
+  ADD COLUMN closes_at TIMESTAMP NULL,
+  ADD CONSTRAINT polls_window_check CHECK (opens_at IS NULL OR closes_at IS NULL OR closes_at > opens_at);
+
+CREATE INDEX IF NOT EXISTS idx_polls_status ON polls(status);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_polls_status;
+ALTER TABLE polls
+  DROP CONSTRAINT IF EXISTS polls_window_check,
+  DROP COLUMN IF EXISTS closes_at,
+  DROP COLUMN IF EXISTS opens_at,
+  DROP COLUMN IF EXISTS status;
+
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Single, multiple-choice and ranked-choice polls
+ALTER TABLE polls
+  ADD COLUMN poll_type VARCHAR(10) NOT NULL DEFAULT 'single'
+    CHECK (poll_type IN ('single', 'multi', 'ranked')),
+  ADD COLUMN min_selections INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN max_selections INTEGER NOT NULL DEFAULT 0;
+
+-- A ballot is now one row per selected option, ordered by rank
+ALTER TABLE poll_votes DROP CONSTRAINT IF EXISTS poll_votes_poll_id_user_id_key;
+ALTER TABLE poll_votes
+  ADD COLUMN rank INTEGER NOT NULL DEFAULT 1 CHECK (rank > 0),
+  ADD CONSTRAINT poll_votes_poll_user_option_key UNIQUE (poll_id, user_id, option_id),
+  ADD CONSTRAINT poll_votes_poll_user_rank_key UNIQUE (poll_id, user_id, rank);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DELETE FROM poll_votes WHERE rank > 1;
+ALTER TABLE poll_votes
+  DROP CONSTRAINT IF EXISTS poll_votes_poll_user_rank_key,
+  DROP CONSTRAINT IF EXISTS poll_votes_poll_user_option_key,
+  DROP COLUMN IF EXISTS rank,
+  ADD CONSTRAINT poll_votes_poll_id_user_id_key UNIQUE (poll_id, user_id);
+ALTER TABLE polls
+  DROP COLUMN IF EXISTS max_selections,
+  DROP COLUMN IF EXISTS min_selections,
+  DROP COLUMN IF EXISTS poll_type;
+
+-- +goose StatementEnd
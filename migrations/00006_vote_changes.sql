@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Per-poll setting allowing voters to change or retract their ballot
+ALTER TABLE polls ADD COLUMN allow_vote_change BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Audit trail of ballot changes and retractions
+CREATE TABLE IF NOT EXISTS poll_vote_history (
+  id SERIAL PRIMARY KEY,
+  poll_id INTEGER NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+  user_id INTEGER NOT NULL REFERENCES users(id),
+  action VARCHAR(10) NOT NULL CHECK (action IN ('change', 'retract')),
+  previous_option_ids INTEGER[] NOT NULL,
+  option_ids INTEGER[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_poll_vote_history_poll_user ON poll_vote_history(poll_id, user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS poll_vote_history;
+ALTER TABLE polls DROP COLUMN IF EXISTS allow_vote_change;
+
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Soft delete keeps options and votes for history
+ALTER TABLE polls ADD COLUMN deleted_at TIMESTAMP NULL;
+
+CREATE INDEX IF NOT EXISTS idx_polls_not_deleted ON polls(created_at) WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_polls_not_deleted;
+ALTER TABLE polls DROP COLUMN IF EXISTS deleted_at;
+
+-- +goose StatementEnd
@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Anonymous polls store a salted voter hash instead of the user on each ballot
+ALTER TABLE polls ADD COLUMN anonymous BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE poll_votes
+  ALTER COLUMN user_id DROP NOT NULL,
+  ADD COLUMN voter_hash VARCHAR(64) NULL,
+  ADD COLUMN receipt_hash VARCHAR(64) NULL,
+  ADD CONSTRAINT poll_votes_voter_check CHECK ((user_id IS NULL) <> (voter_hash IS NULL)),
+  ADD CONSTRAINT poll_votes_poll_voter_option_key UNIQUE (poll_id, voter_hash, option_id),
+  ADD CONSTRAINT poll_votes_poll_voter_rank_key UNIQUE (poll_id, voter_hash, rank);
+
+CREATE INDEX IF NOT EXISTS idx_poll_votes_receipt_hash ON poll_votes(poll_id, receipt_hash) WHERE receipt_hash IS NOT NULL;
+
+ALTER TABLE poll_vote_history
+  ALTER COLUMN user_id DROP NOT NULL,
+  ADD COLUMN voter_hash VARCHAR(64) NULL,
+  ADD CONSTRAINT poll_vote_history_voter_check CHECK ((user_id IS NULL) <> (voter_hash IS NULL));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DELETE FROM poll_vote_history WHERE user_id IS NULL;
+ALTER TABLE poll_vote_history
+  DROP CONSTRAINT IF EXISTS poll_vote_history_voter_check,
+  DROP COLUMN IF EXISTS voter_hash,
+  ALTER COLUMN user_id SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_poll_votes_receipt_hash;
+DELETE FROM poll_votes WHERE user_id IS NULL;
+ALTER TABLE poll_votes
+  DROP CONSTRAINT IF EXISTS poll_votes_poll_voter_rank_key,
+  DROP CONSTRAINT IF EXISTS poll_votes_poll_voter_option_key,
+  DROP CONSTRAINT IF EXISTS poll_votes_voter_check,
+  DROP COLUMN IF EXISTS receipt_hash,
+  DROP COLUMN IF EXISTS voter_hash,
+  ALTER COLUMN user_id SET NOT NULL;
+
+ALTER TABLE polls DROP COLUMN IF EXISTS anonymous;
+
+-- +goose StatementEnd
@@ -0,0 +1,66 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Surveys group ordered questions answered together
+CREATE TABLE IF NOT EXISTS surveys (
+  id SERIAL PRIMARY KEY,
+  title VARCHAR(255) NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  user_id INTEGER NOT NULL REFERENCES users(id),
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS survey_questions (
+  id SERIAL PRIMARY KEY,
+  survey_id INTEGER NOT NULL REFERENCES surveys(id) ON DELETE CASCADE,
+  position INTEGER NOT NULL CHECK (position > 0),
+  question_type VARCHAR(10) NOT NULL CHECK (question_type IN ('single', 'multi', 'text', 'rating', 'nps')),
+  prompt VARCHAR(500) NOT NULL,
+  required BOOLEAN NOT NULL DEFAULT FALSE,
+  min_selections INTEGER NOT NULL DEFAULT 0,
+  max_selections INTEGER NOT NULL DEFAULT 0,
+  UNIQUE (survey_id, position)
+);
+
+CREATE TABLE IF NOT EXISTS survey_question_options (
+  id SERIAL PRIMARY KEY,
+  question_id INTEGER NOT NULL REFERENCES survey_questions(id) ON DELETE CASCADE,
+  text VARCHAR(255) NOT NULL
+);
+
+-- One response per user and survey, with one answer row per selected
+-- option, text or value
+CREATE TABLE IF NOT EXISTS survey_responses (
+  id SERIAL PRIMARY KEY,
+  survey_id INTEGER NOT NULL REFERENCES surveys(id) ON DELETE CASCADE,
+  user_id INTEGER NOT NULL REFERENCES users(id),
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (survey_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS survey_answers (
+  id SERIAL PRIMARY KEY,
+  response_id INTEGER NOT NULL REFERENCES survey_responses(id) ON DELETE CASCADE,
+  question_id INTEGER NOT NULL REFERENCES survey_questions(id) ON DELETE CASCADE,
+  option_id INTEGER NULL REFERENCES survey_question_options(id) ON DELETE CASCADE,
+  text_value TEXT NULL,
+  number_value INTEGER NULL,
+  CHECK (num_nonnulls(option_id, text_value, number_value) = 1)
+);
+
+CREATE INDEX IF NOT EXISTS idx_survey_questions_survey_id ON survey_questions(survey_id);
+CREATE INDEX IF NOT EXISTS idx_survey_question_options_question_id ON survey_question_options(question_id);
+CREATE INDEX IF NOT EXISTS idx_survey_answers_question_id ON survey_answers(question_id);
+CREATE INDEX IF NOT EXISTS idx_survey_answers_response_id ON survey_answers(response_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS survey_answers;
+DROP TABLE IF EXISTS survey_responses;
+DROP TABLE IF EXISTS survey_question_options;
+DROP TABLE IF EXISTS survey_questions;
+DROP TABLE IF EXISTS surveys;
+
+-- +goose StatementEnd
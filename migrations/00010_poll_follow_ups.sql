@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- A follow-up rule sends voters whose first choice is an option to another poll
+ALTER TABLE poll_options
+  ADD COLUMN next_poll_id INTEGER NULL REFERENCES polls(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_poll_options_next_poll_id ON poll_options(next_poll_id) WHERE next_poll_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_poll_options_next_poll_id;
+ALTER TABLE poll_options DROP COLUMN IF EXISTS next_poll_id;
+
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Quiz polls mark their correct options and score each ballot
+ALTER TABLE polls ADD COLUMN quiz BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE poll_options
+  ADD COLUMN correct BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN points INTEGER NOT NULL DEFAULT 0 CHECK (points >= 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE poll_options
+  DROP COLUMN IF EXISTS points,
+  DROP COLUMN IF EXISTS correct;
+
+ALTER TABLE polls DROP COLUMN IF EXISTS quiz;
+
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Unlisted polls are reached by an opaque slug, invite-only polls by invitees
+ALTER TABLE polls
+  ADD COLUMN visibility VARCHAR(20) NOT NULL DEFAULT 'public'
+    CHECK (visibility IN ('public', 'unlisted', 'invite_only')),
+  ADD COLUMN slug VARCHAR(32) NULL UNIQUE;
+
+-- Users invited to an invite-only poll
+CREATE TABLE IF NOT EXISTS poll_invites (
+  poll_id INTEGER NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (poll_id, user_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS poll_invites;
+
+ALTER TABLE polls
+  DROP COLUMN IF EXISTS slug,
+  DROP COLUMN IF EXISTS visibility;
+
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Who may see a poll's tallies before it closes
+ALTER TABLE polls
+  ADD COLUMN results_visibility VARCHAR(20) NOT NULL DEFAULT 'always'
+    CHECK (results_visibility IN ('always', 'after_vote', 'after_close', 'owner_only'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE polls DROP COLUMN IF EXISTS results_visibility;
+
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Owners can turn off discussion on their polls
+ALTER TABLE polls ADD COLUMN comments_enabled BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- Threaded comments on polls; replies point at their parent comment
+CREATE TABLE IF NOT EXISTS poll_comments (
+  id SERIAL PRIMARY KEY,
+  poll_id INTEGER NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+  parent_id INTEGER NULL REFERENCES poll_comments(id) ON DELETE CASCADE,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  body TEXT NOT NULL,
+  pinned BOOLEAN NOT NULL DEFAULT FALSE,
+  hidden BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  edited_at TIMESTAMP NULL,
+  deleted_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_poll_comments_thread ON poll_comments(poll_id, parent_id, id);
+CREATE INDEX IF NOT EXISTS idx_poll_comments_parent ON poll_comments(parent_id) WHERE parent_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS poll_comments;
+
+ALTER TABLE polls DROP COLUMN IF EXISTS comments_enabled;
+
+-- +goose StatementEnd
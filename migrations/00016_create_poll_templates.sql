@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Saved question and option sets that polls are created from; the question
+-- and option texts may contain {{placeholders}}
+CREATE TABLE IF NOT EXISTS poll_templates (
+  id SERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name VARCHAR(100) NOT NULL,
+  question TEXT NOT NULL,
+  poll_type VARCHAR(10) NOT NULL DEFAULT 'single',
+  min_selections INTEGER NOT NULL DEFAULT 0,
+  max_selections INTEGER NOT NULL DEFAULT 0,
+  allow_vote_change BOOLEAN NOT NULL DEFAULT FALSE,
+  anonymous BOOLEAN NOT NULL DEFAULT FALSE,
+  quiz BOOLEAN NOT NULL DEFAULT FALSE,
+  visibility VARCHAR(20) NOT NULL DEFAULT 'public',
+  results_visibility VARCHAR(20) NOT NULL DEFAULT 'always',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_poll_templates_user_id ON poll_templates(user_id);
+
+CREATE TABLE IF NOT EXISTS poll_template_options (
+  template_id INTEGER NOT NULL REFERENCES poll_templates(id) ON DELETE CASCADE,
+  position INTEGER NOT NULL,
+  text TEXT NOT NULL,
+  correct BOOLEAN NOT NULL DEFAULT FALSE,
+  points INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (template_id, position)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS poll_template_options;
+
+DROP TABLE IF EXISTS poll_templates;
+
+-- +goose StatementEnd
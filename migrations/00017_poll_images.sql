@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Storage keys of an optional cover image per poll and image per option,
+-- each with its generated thumbnail
+ALTER TABLE polls
+  ADD COLUMN cover_image VARCHAR(255) NULL,
+  ADD COLUMN cover_thumbnail VARCHAR(255) NULL;
+
+ALTER TABLE poll_options
+  ADD COLUMN image VARCHAR(255) NULL,
+  ADD COLUMN image_thumbnail VARCHAR(255) NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE poll_options
+  DROP COLUMN IF EXISTS image_thumbnail,
+  DROP COLUMN IF EXISTS image;
+
+ALTER TABLE polls
+  DROP COLUMN IF EXISTS cover_thumbnail,
+  DROP COLUMN IF EXISTS cover_image;
+
+-- +goose StatementEnd
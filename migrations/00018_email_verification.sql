@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- When the last verification email was sent, to throttle resends
+ALTER TABLE users
+  ADD COLUMN verification_sent_at TIMESTAMP NULL;
+
+-- Accounts created before verification existed were meant to be active,
+-- but were stored inactive; keep them able to sign in
+UPDATE users SET is_active = true;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE users
+  DROP COLUMN IF EXISTS verification_sent_at;
+
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Bumped on every password change; tokens carrying an older version are
+-- rejected
+ALTER TABLE users
+  ADD COLUMN token_version INTEGER NOT NULL DEFAULT 0;
+
+-- Single-use password reset tokens, stored as SHA-256 hashes so a leaked
+-- table cannot be used to reset passwords
+CREATE TABLE IF NOT EXISTS password_resets (
+  id SERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_hash CHAR(64) NOT NULL UNIQUE,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_resets_user_id ON password_resets(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS password_resets;
+
+ALTER TABLE users
+  DROP COLUMN IF EXISTS token_version;
+
+-- +goose StatementEnd
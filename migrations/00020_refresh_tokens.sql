@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Opaque refresh tokens, stored as SHA-256 hashes. Every use rotates a
+-- token: it is marked rotated and replaced by a new one in the same family,
+-- so a rotated token coming back reveals theft and revokes its family.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+  id SERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  family_id VARCHAR(32) NOT NULL,
+  token_hash CHAR(64) NOT NULL UNIQUE,
+  user_agent VARCHAR(255) NOT NULL DEFAULT '',
+  ip_address VARCHAR(45) NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  expires_at TIMESTAMP NOT NULL,
+  rotated_at TIMESTAMP NULL,
+  revoked_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+
+-- Access tokens revoked before they expire, by JWT ID. Rows are useless
+-- once the token expires and are pruned as new ones are added.
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+  jti VARCHAR(32) PRIMARY KEY,
+  expires_at TIMESTAMP NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS revoked_tokens;
+DROP TABLE IF EXISTS refresh_tokens;
+
+-- +goose StatementEnd
@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- One row per sign-in, shown to users so they can see and revoke where
+-- they are signed in. Access tokens name their session, and the refresh
+-- tokens rotated from one sign-in all belong to it, so revoking a session
+-- revokes every token of that sign-in.
+CREATE TABLE IF NOT EXISTS sessions (
+  id VARCHAR(32) PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  user_agent VARCHAR(255) NOT NULL DEFAULT '',
+  ip_address VARCHAR(45) NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  last_seen_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  expires_at TIMESTAMP NOT NULL,
+  revoked_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+
+-- Refresh token families become sessions, described by their latest token
+INSERT INTO sessions (id, user_id, user_agent, ip_address, created_at, last_seen_at, expires_at, revoked_at)
+SELECT DISTINCT ON (family_id)
+  family_id, user_id, user_agent, ip_address,
+  MIN(created_at) OVER (PARTITION BY family_id), created_at, expires_at, revoked_at
+FROM refresh_tokens
+ORDER BY family_id, created_at DESC;
+
+ALTER TABLE refresh_tokens RENAME COLUMN family_id TO session_id;
+ALTER INDEX idx_refresh_tokens_family_id RENAME TO idx_refresh_tokens_session_id;
+ALTER TABLE refresh_tokens
+  ADD CONSTRAINT refresh_tokens_session_id_fkey FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE,
+  DROP COLUMN revoked_at;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE refresh_tokens
+  DROP CONSTRAINT IF EXISTS refresh_tokens_session_id_fkey,
+  ADD COLUMN revoked_at TIMESTAMP NULL;
+UPDATE refresh_tokens t SET revoked_at = s.revoked_at
+FROM sessions s WHERE s.id = t.session_id;
+ALTER INDEX idx_refresh_tokens_session_id RENAME TO idx_refresh_tokens_family_id;
+ALTER TABLE refresh_tokens RENAME COLUMN session_id TO family_id;
+
+DROP TABLE IF EXISTS sessions;
+
+-- +goose StatementEnd
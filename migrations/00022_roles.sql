@@ -0,0 +1,67 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Roles group permissions, and users hold roles. Access tokens carry the
+-- roles of their user and the permissions those grant; granting a role
+-- bumps the user's token version so new tokens pick it up.
+CREATE TABLE IF NOT EXISTS roles (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(50) NOT NULL UNIQUE,
+  description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(100) NOT NULL UNIQUE,
+  description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+  role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+  permission_id INTEGER NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+  PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (user_id, role_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON user_roles(role_id);
+
+-- Set when an admin deactivates an account. Unlike is_active, which tracks
+-- email verification, verifying again does not clear it.
+ALTER TABLE users
+  ADD COLUMN deactivated_at TIMESTAMP NULL;
+
+INSERT INTO roles (name, description) VALUES
+  ('admin', 'Manages users');
+
+INSERT INTO permissions (name, description) VALUES
+  ('users:read', 'Read the profile of any user'),
+  ('users:manage', 'List, deactivate and promote users');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r CROSS JOIN permissions p
+WHERE r.name = 'admin';
+
+-- The first admin is granted by hand, e.g.:
+--   INSERT INTO user_roles (user_id, role_id)
+--   SELECT u.id, r.id FROM users u, roles r
+--   WHERE u.email = 'admin@example.com' AND r.name = 'admin';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE users
+  DROP COLUMN IF EXISTS deactivated_at;
+
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
+
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Per-poll setting allowing the owner to export individual ballots, set
+-- when the poll is created so voters know before they vote
+ALTER TABLE polls ADD COLUMN export_votes BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE polls DROP COLUMN IF EXISTS export_votes;
+
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Admins manage the polls and moderate the comments of every user through
+-- permissions rather than their role name
+INSERT INTO permissions (name, description) VALUES
+  ('polls:manage', 'Edit, close and delete the polls and templates of any user'),
+  ('comments:moderate', 'Pin, hide and delete the comments on any poll');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r CROSS JOIN permissions p
+WHERE r.name = 'admin' AND p.name IN ('polls:manage', 'comments:moderate');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DELETE FROM permissions WHERE name IN ('polls:manage', 'comments:moderate');
+
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Tag usage is counted when tags are listed, over the same public polls the
+-- poll listing shows, so drafts and polls that are not public stay out of it
+ALTER TABLE tags DROP COLUMN IF EXISTS usage_count;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE tags ADD COLUMN usage_count INTEGER NOT NULL DEFAULT 0;
+UPDATE tags t SET usage_count = (
+  SELECT COUNT(*) FROM poll_tags pt JOIN polls p ON p.id = pt.poll_id
+  WHERE pt.tag_id = t.id AND p.deleted_at IS NULL
+);
+
+-- +goose StatementEnd
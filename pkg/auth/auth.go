@@ -0,0 +1,115 @@
+// Package auth reads the claims of the access token that the JWT middleware
+// stores in the echo context under "user", and names the roles and
+// permissions those claims carry.
+//
+// Usage:
+//
+//	userID := auth.UserID(c)
+//	if viewer, ok := auth.ViewerID(c); ok {
+//		// signed in on a route where signing in is optional
+//	}
+//	if auth.HasPermission(c, auth.PermissionManagePolls) {
+//		// may manage polls of other users
+//	}
+package auth
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+)
+
+// Roles and permissions seeded by the migrations. Access tokens carry the
+// roles of their user in the "roles" claim and the permissions those grant
+// in the "perms" claim.
+const (
+	RoleAdmin = "admin"
+	// PermissionReadUsers allows reading the profile of any user.
+	PermissionReadUsers = "users:read"
+	// PermissionManageUsers allows listing, deactivating and promoting
+	// users.
+	PermissionManageUsers = "users:manage"
+	// PermissionManagePolls allows editing, closing and deleting the polls
+	// and templates of any user.
+	PermissionManagePolls = "polls:manage"
+	// PermissionModerateComments allows pinning, hiding and deleting the
+	// comments on any poll.
+	PermissionModerateComments = "comments:moderate"
+)
+
+// Claims returns the claims of the authenticated user's token, if the
+// request carries one.
+func Claims(c echo.Context) (jwt.MapClaims, bool) {
+	token, ok := c.Get("user").(*jwt.Token)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	return claims, ok
+}
+
+// UserID returns the ID of the authenticated user. It must only be called
+// behind the JWT middleware.
+func UserID(c echo.Context) int64 {
+	claims, _ := Claims(c)
+	return int64(claims["user_id"].(float64))
+}
+
+// ViewerID returns the authenticated user on routes where signing in is
+// optional.
+func ViewerID(c echo.Context) (int64, bool) {
+	claims, ok := Claims(c)
+	if !ok {
+		return 0, false
+	}
+	userID, ok := claims["user_id"].(float64)
+	return int64(userID), ok
+}
+
+// TokenID returns the JWT ID and expiry of the authenticated user's token,
+// if it has them.
+func TokenID(c echo.Context) (string, time.Time, bool) {
+	claims, _ := Claims(c)
+	jti, _ := claims["jti"].(string)
+	exp, ok := claims["exp"].(float64)
+	if jti == "" || !ok {
+		return "", time.Time{}, false
+	}
+	return jti, time.Unix(int64(exp), 0), true
+}
+
+// SessionID returns the session of the authenticated user's token, if it
+// names one.
+func SessionID(c echo.Context) string {
+	claims, _ := Claims(c)
+	sid, _ := claims["sid"].(string)
+	return sid
+}
+
+// HasRole reports whether the authenticated user's token carries role.
+func HasRole(c echo.Context, role string) bool {
+	return hasClaim(c, "roles", role)
+}
+
+// HasPermission reports whether the authenticated user's token grants
+// permission. Prefer it over HasRole, so that what a role allows is
+// decided by the permissions granted to it.
+func HasPermission(c echo.Context, permission string) bool {
+	return hasClaim(c, "perms", permission)
+}
+
+// hasClaim reports whether the array claim name lists value.
+func hasClaim(c echo.Context, name, value string) bool {
+	claims, ok := Claims(c)
+	if !ok {
+		return false
+	}
+	held, _ := claims[name].([]interface{})
+	for _, h := range held {
+		if h == value {
+			return true
+		}
+	}
+	return false
+}
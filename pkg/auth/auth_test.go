@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// newContext returns a context carrying a token with claims, or no token
+// when claims is nil.
+func newContext(claims jwt.MapClaims) echo.Context {
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	if claims != nil {
+		c.Set("user", jwt.NewWithClaims(jwt.SigningMethodHS256, claims))
+	}
+	return c
+}
+
+// Test reading the user of a token
+func TestUserID(t *testing.T) {
+	c := newContext(jwt.MapClaims{"user_id": float64(7)})
+
+	assert.Equal(t, int64(7), UserID(c))
+	userID, ok := ViewerID(c)
+	assert.True(t, ok)
+	assert.Equal(t, int64(7), userID)
+
+	// Anonymous callers of optional-auth routes have no viewer
+	_, ok = ViewerID(newContext(nil))
+	assert.False(t, ok)
+}
+
+// Test reading the token and session IDs
+func TestTokenAndSessionID(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	c := newContext(jwt.MapClaims{"user_id": float64(7), "jti": "abc", "sid": "s1", "exp": float64(exp.Unix())})
+
+	jti, expiresAt, ok := TokenID(c)
+	assert.True(t, ok)
+	assert.Equal(t, "abc", jti)
+	assert.True(t, expiresAt.Equal(exp))
+	assert.Equal(t, "s1", SessionID(c))
+
+	// Tokens issued before IDs were added have neither
+	c = newContext(jwt.MapClaims{"user_id": float64(7)})
+	_, _, ok = TokenID(c)
+	assert.False(t, ok)
+	assert.Empty(t, SessionID(c))
+}
+
+// Test role and permission checks
+func TestHasRoleAndPermission(t *testing.T) {
+	c := newContext(jwt.MapClaims{
+		"user_id": float64(7),
+		"roles":   []interface{}{RoleAdmin},
+		"perms":   []interface{}{PermissionManagePolls},
+	})
+
+	assert.True(t, HasRole(c, RoleAdmin))
+	assert.True(t, HasPermission(c, PermissionManagePolls))
+	assert.False(t, HasPermission(c, PermissionModerateComments))
+
+	// Missing tokens and claims grant nothing
+	assert.False(t, HasRole(newContext(nil), RoleAdmin))
+	assert.False(t, HasPermission(newContext(jwt.MapClaims{"user_id": float64(7)}), PermissionManagePolls))
+}
@@ -133,6 +133,18 @@ func Conflict(err error) error {
 	return serverErr
 }
 
+func TooManyRequests(err error) error {
+	serverErr := &ServerError{
+		Code: http.StatusTooManyRequests,
+		Msg:  "too_many_requests",
+		Err:  err,
+	}
+
+	serverErr.Log()
+
+	return serverErr
+}
+
 func GatewayTimeout(err error) error {
 	serverErr := &ServerError{
 		Code: http.StatusGatewayTimeout,
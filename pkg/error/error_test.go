@@ -78,6 +78,12 @@ func TestSpecificErrorTypes(t *testing.T) {
 			expectCode: http.StatusConflict,
 			expectMsg:  "Conflict",
 		},
+		{
+			name:       "TooManyRequests",
+			errFunc:    TooManyRequests,
+			expectCode: http.StatusTooManyRequests,
+			expectMsg:  "too_many_requests",
+		},
 		{
 			name:       "GatewayTimeout",
 			errFunc:    GatewayTimeout,
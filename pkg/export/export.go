@@ -0,0 +1,205 @@
+// Package export encodes tabular data as CSV, JSON Lines or XLSX while it
+// is being produced, so large exports never have to fit in memory.
+//
+// Usage:
+//
+//	format, ok := export.Lookup("csv")
+//	w := format.New(out)
+//	w.Table("options", "id", "text", "votes")
+//	w.Row(1, "Go", 42)
+//	w.Close()
+//
+// A document may hold several tables. CSV separates them with a blank line
+// and repeats the header, JSON Lines tags every object with its table name,
+// and XLSX writes one worksheet per table.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported format names.
+const (
+	CSV   = "csv"
+	JSONL = "jsonl"
+	XLSX  = "xlsx"
+)
+
+// ErrNoTable is returned when a row is written before any table is started.
+var ErrNoTable = errors.New("export: row written before table")
+
+// Writer encodes tables of rows in one export format.
+type Writer interface {
+	// Table starts a new table; rows written after it belong to the table.
+	Table(name string, columns ...string) error
+	// Row writes one row of the current table, one value per column.
+	Row(values ...interface{}) error
+	// Close flushes buffered output and finishes the document.
+	Close() error
+}
+
+// Format describes an export format.
+type Format struct {
+	Name        string
+	ContentType string
+	Extension   string
+	New         func(w io.Writer) Writer
+}
+
+var formats = map[string]Format{
+	CSV:   {Name: CSV, ContentType: "text/csv; charset=utf-8", Extension: "csv", New: NewCSV},
+	JSONL: {Name: JSONL, ContentType: "application/x-ndjson", Extension: "jsonl", New: NewJSONL},
+	XLSX:  {Name: XLSX, ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Extension: "xlsx", New: NewXLSX},
+}
+
+// Lookup returns the format with the given name.
+func Lookup(name string) (Format, bool) {
+	f, ok := formats[name]
+	return f, ok
+}
+
+// formatValue renders a cell value as text.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	tables int
+	record []string
+}
+
+// NewCSV returns a Writer producing CSV.
+func NewCSV(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Table(name string, columns ...string) error {
+	if c.tables > 0 {
+		// Blank line between tables
+		if err := c.w.Write(nil); err != nil {
+			return err
+		}
+	}
+	c.tables++
+	return c.w.Write(columns)
+}
+
+func (c *csvWriter) Row(values ...interface{}) error {
+	if c.tables == 0 {
+		return ErrNoTable
+	}
+	c.record = c.record[:0]
+	for _, v := range values {
+		cell := formatValue(v)
+		if _, ok := v.(string); ok {
+			cell = escapeFormula(cell)
+		}
+		c.record = append(c.record, cell)
+	}
+	return c.w.Write(c.record)
+}
+
+// escapeFormula prefixes text that spreadsheets would evaluate as a formula
+// with a quote, so user-supplied cells open as plain text.
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlWriter struct {
+	w       *bufio.Writer
+	table   []byte
+	columns [][]byte
+}
+
+// NewJSONL returns a Writer producing JSON Lines: one object per row, keyed
+// by column name, with the table name under "table".
+func NewJSONL(w io.Writer) Writer {
+	return &jsonlWriter{w: bufio.NewWriter(w)}
+}
+
+func (j *jsonlWriter) Table(name string, columns ...string) error {
+	table, err := json.Marshal(name)
+	if err != nil {
+		return err
+	}
+	j.table = table
+	j.columns = j.columns[:0]
+	for _, col := range columns {
+		key, err := json.Marshal(col)
+		if err != nil {
+			return err
+		}
+		j.columns = append(j.columns, key)
+	}
+	return nil
+}
+
+func (j *jsonlWriter) Row(values ...interface{}) error {
+	if j.table == nil {
+		return ErrNoTable
+	}
+	// Objects are written field by field to keep the column order
+	j.w.WriteString(`{"table":`)
+	j.w.Write(j.table)
+	for i, v := range values {
+		if i >= len(j.columns) {
+			break
+		}
+		if t, ok := v.(time.Time); ok {
+			v = t.UTC()
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		j.w.WriteByte(',')
+		j.w.Write(j.columns[i])
+		j.w.WriteByte(':')
+		j.w.Write(value)
+	}
+	_, err := j.w.WriteString("}\n")
+	return err
+}
+
+func (j *jsonlWriter) Close() error {
+	return j.w.Flush()
+}
@@ -0,0 +1,122 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var votedAt = time.Date(2025, 5, 18, 9, 30, 0, 0, time.UTC)
+
+func writeSample(t *testing.T, w Writer) {
+	require.NoError(t, w.Table("options", "id", "text", "percentage", "winner"))
+	require.NoError(t, w.Row(int64(1), `Go, "the language"`, 62.5, true))
+	require.NoError(t, w.Table("votes", "voter_id", "voted_at"))
+	require.NoError(t, w.Row(int64(7), votedAt))
+	require.NoError(t, w.Close())
+}
+
+func TestLookup(t *testing.T) {
+	for _, name := range []string{CSV, JSONL, XLSX} {
+		f, ok := Lookup(name)
+		assert.True(t, ok)
+		assert.Equal(t, name, f.Extension)
+		assert.NotEmpty(t, f.ContentType)
+	}
+
+	_, ok := Lookup("pdf")
+	assert.False(t, ok)
+}
+
+func TestCSV(t *testing.T) {
+	var buf bytes.Buffer
+	writeSample(t, NewCSV(&buf))
+
+	assert.Equal(t, "id,text,percentage,winner\n"+
+		"1,\"Go, \"\"the language\"\"\",62.5,true\n"+
+		"\n"+
+		"voter_id,voted_at\n"+
+		"7,2025-05-18T09:30:00Z\n", buf.String())
+}
+
+func TestCSV_EscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCSV(&buf)
+	require.NoError(t, w.Table("options", "id", "text"))
+	require.NoError(t, w.Row(int64(-1), `=HYPERLINK("http://example.com")`))
+	require.NoError(t, w.Row(int64(2), "@SUM(A1)"))
+	require.NoError(t, w.Row(int64(3), "-2+3"))
+	require.NoError(t, w.Row(int64(4), "Plain"))
+	require.NoError(t, w.Close())
+
+	// Only text cells are escaped; numbers keep their sign
+	assert.Equal(t, "id,text\n"+
+		"-1,\"'=HYPERLINK(\"\"http://example.com\"\")\"\n"+
+		"2,'@SUM(A1)\n"+
+		"3,'-2+3\n"+
+		"4,Plain\n", buf.String())
+}
+
+func TestJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	writeSample(t, NewJSONL(&buf))
+
+	assert.Equal(t, `{"table":"options","id":1,"text":"Go, \"the language\"","percentage":62.5,"winner":true}`+"\n"+
+		`{"table":"votes","voter_id":7,"voted_at":"2025-05-18T09:30:00Z"}`+"\n", buf.String())
+}
+
+func TestXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	writeSample(t, NewXLSX(&buf))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	parts := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		body, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		parts[f.Name] = string(body)
+	}
+
+	assert.Contains(t, parts["[Content_Types].xml"], `PartName="/xl/worksheets/sheet2.xml"`)
+	assert.Contains(t, parts["xl/workbook.xml"], `<sheet name="options" sheetId="1" r:id="rId1"/><sheet name="votes" sheetId="2" r:id="rId2"/>`)
+	assert.Contains(t, parts["xl/_rels/workbook.xml.rels"], `Target="worksheets/sheet2.xml"`)
+	assert.Contains(t, parts["_rels/.rels"], `Target="xl/workbook.xml"`)
+
+	sheet1 := parts["xl/worksheets/sheet1.xml"]
+	assert.Contains(t, sheet1, `<c r="A1" t="inlineStr"><is><t xml:space="preserve">id</t></is></c>`)
+	assert.Contains(t, sheet1, `<c r="A2"><v>1</v></c>`)
+	assert.Contains(t, sheet1, `<t xml:space="preserve">Go, &#34;the language&#34;</t>`)
+	assert.Contains(t, sheet1, `<c r="C2"><v>62.5</v></c><c r="D2" t="b"><v>1</v></c>`)
+	assert.Contains(t, parts["xl/worksheets/sheet2.xml"], `2025-05-18T09:30:00Z`)
+}
+
+func TestRowBeforeTable(t *testing.T) {
+	var buf bytes.Buffer
+	for _, w := range []Writer{NewCSV(&buf), NewJSONL(&buf), NewXLSX(&buf)} {
+		assert.ErrorIs(t, w.Row(1), ErrNoTable)
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	assert.Equal(t, "A", columnName(0))
+	assert.Equal(t, "Z", columnName(25))
+	assert.Equal(t, "AA", columnName(26))
+	assert.Equal(t, "AZ", columnName(51))
+	assert.Equal(t, "BA", columnName(52))
+}
+
+func TestSheetName(t *testing.T) {
+	assert.Equal(t, "a_b_c", sheetName("a/b:c", 1))
+	assert.Equal(t, "Sheet3", sheetName("", 3))
+	assert.Len(t, sheetName("a very long table name that exceeds the limit", 1), 31)
+}
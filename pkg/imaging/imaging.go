@@ -0,0 +1,74 @@
+// Package imaging scales images down to thumbnails using only the standard
+// library.
+//
+// Usage:
+//
+//	img, _, err := image.Decode(r)
+//	thumb := imaging.Thumbnail(img, 256)
+//	err = png.Encode(w, thumb)
+package imaging
+
+import (
+	"image"
+	"image/color"
+)
+
+// Thumbnail scales src down so that neither side exceeds size pixels,
+// keeping its aspect ratio. Every thumbnail pixel is the average of the
+// source pixels it covers, which keeps downscaled photos smooth. Images that
+// already fit are copied at their own size.
+func Thumbnail(src image.Image, size int) *image.NRGBA {
+	b := src.Bounds()
+	w, h := Fit(b.Dx(), b.Dy(), size)
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	if w == 0 || h == 0 {
+		return dst
+	}
+
+	for y := 0; y < h; y++ {
+		y0, y1 := span(y, h, b.Dy())
+		for x := 0; x < w; x++ {
+			x0, x1 := span(x, w, b.Dx())
+
+			// Average in premultiplied alpha, so transparent pixels do not
+			// darken their neighbours.
+			var r, g, bl, a uint64
+			for sy := b.Min.Y + y0; sy < b.Min.Y+y1; sy++ {
+				for sx := b.Min.X + x0; sx < b.Min.X+x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					bl += uint64(pb)
+					a += uint64(pa)
+				}
+			}
+			n := uint64((y1 - y0) * (x1 - x0))
+			c := color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)}
+			dst.Set(x, y, c)
+		}
+	}
+	return dst
+}
+
+// Fit returns the size of a w by h image scaled down to fit in a size by
+// size square, keeping its aspect ratio. Sides never shrink below one pixel.
+func Fit(w, h, size int) (int, int) {
+	if w <= size && h <= size {
+		return w, h
+	}
+	if w >= h {
+		return size, max(1, h*size/w)
+	}
+	return max(1, w*size/h), size
+}
+
+// span returns the source range [lo, hi) covered by destination index i
+// when n source pixels are squeezed into m.
+func span(i, m, n int) (int, int) {
+	lo := i * n / m
+	hi := (i + 1) * n / m
+	if hi == lo {
+		hi = lo + 1
+	}
+	return lo, hi
+}
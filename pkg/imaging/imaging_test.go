@@ -0,0 +1,51 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		w, h, size int
+		expW, expH int
+	}{
+		{w: 100, h: 50, size: 256, expW: 100, expH: 50},
+		{w: 1024, h: 768, size: 256, expW: 256, expH: 192},
+		{w: 768, h: 1024, size: 256, expW: 192, expH: 256},
+		{w: 5000, h: 10, size: 256, expW: 256, expH: 1},
+	}
+	for _, tt := range tests {
+		w, h := Fit(tt.w, tt.h, tt.size)
+		assert.Equal(t, tt.expW, w)
+		assert.Equal(t, tt.expH, h)
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	// A 4x2 image: left half red, right half transparent, with an offset
+	// origin as returned by SubImage
+	src := image.NewNRGBA(image.Rect(10, 10, 14, 12))
+	for y := 10; y < 12; y++ {
+		for x := 10; x < 12; x++ {
+			src.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+
+	thumb := Thumbnail(src, 2)
+	assert.Equal(t, image.Rect(0, 0, 2, 1), thumb.Bounds())
+	assert.Equal(t, color.NRGBA{R: 255, A: 255}, thumb.NRGBAAt(0, 0))
+	assert.Equal(t, color.NRGBA{}, thumb.NRGBAAt(1, 0))
+
+	// Half-transparent blends keep their colour
+	src = image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, color.NRGBA{B: 255, A: 255})
+	thumb = Thumbnail(src, 1)
+	assert.Equal(t, color.NRGBA{B: 255, A: 127}, thumb.NRGBAAt(0, 0))
+
+	// Small images keep their size
+	assert.Equal(t, image.Rect(0, 0, 2, 1), Thumbnail(src, 256).Bounds())
+}
@@ -0,0 +1,52 @@
+package mail
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Log writes emails to a writer instead of sending them, so links in them
+// can be followed during local development.
+type Log struct {
+	// From is the sender address of every email.
+	From string
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewLog returns a mailer writing every email to w.
+func NewLog(w io.Writer, from string) *Log {
+	return &Log{From: from, w: w}
+}
+
+// NewFile returns a mailer appending every email to the file at path,
+// creating it if needed.
+func NewFile(path, from string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return NewLog(f, from), nil
+}
+
+var _ Mailer = (*Log)(nil)
+
+// Send writes msg followed by a separator line.
+func (l *Log) Send(ctx context.Context, msg Message) error {
+	data, err := msg.format(l.From, time.Now())
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(data); err != nil {
+		return err
+	}
+	_, err = io.WriteString(l.w, "\r\n----------------------------------------\r\n")
+	return err
+}
@@ -0,0 +1,84 @@
+// Package mail sends plain-text emails through an SMTP server, or writes
+// them to a log for local development.
+//
+// Usage:
+//
+//	mailer := mail.NewSMTP("smtp.example.com", 587, "user", "pass", "no-reply@example.com")
+//	err := mailer.Send(ctx, mail.Message{
+//		To:      "jane@example.com",
+//		Subject: "Welcome",
+//		Body:    "Thanks for signing up.",
+//	})
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Mailer sends emails.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Message is a plain-text email to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// ErrInvalidMessage is returned for messages that cannot be sent safely,
+// such as headers containing line breaks.
+var ErrInvalidMessage = errors.New("mail: invalid message")
+
+// format renders the message as an RFC 5322 email from the given sender.
+// The subject is encoded for non-ASCII text and the body is
+// quoted-printable, so any UTF-8 text survives transport.
+func (m Message) format(from string, now time.Time) ([]byte, error) {
+	if err := checkAddress(from); err != nil {
+		return nil, err
+	}
+	if err := checkAddress(m.To); err != nil {
+		return nil, err
+	}
+	if strings.ContainsAny(m.Subject, "\r\n") {
+		return nil, fmt.Errorf("%w: subject contains a line break", ErrInvalidMessage)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", m.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	buf.WriteString("\r\n")
+
+	w := quotedprintable.NewWriter(&buf)
+	body := strings.ReplaceAll(strings.ReplaceAll(m.Body, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := w.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// checkAddress rejects anything but a single bare email address.
+func checkAddress(addr string) error {
+	parsed, err := mail.ParseAddress(addr)
+	if err != nil || parsed.Address != addr {
+		return fmt.Errorf("%w: bad address %q", ErrInvalidMessage, addr)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessage_Format(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	msg := Message{
+		To:      "jane@example.com",
+		Subject: "Vérifiez votre adresse",
+		Body:    "Hello Jane,\nOpen https://example.com/verify?token=abc.def to continue.\n",
+	}
+
+	data, err := msg.format("no-reply@example.com", now)
+	require.NoError(t, err)
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, "no-reply@example.com", parsed.Header.Get("From"))
+	assert.Equal(t, "jane@example.com", parsed.Header.Get("To"))
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Vérifiez votre adresse", subject)
+	date, err := parsed.Header.Date()
+	require.NoError(t, err)
+	assert.True(t, now.Equal(date))
+
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello Jane,\r\nOpen https://example.com/verify?token=abc.def to continue.\r\n", string(body))
+}
+
+func TestMessage_FormatRejectsInjection(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		msg  Message
+	}{
+		{name: "Recipient with extra header", from: "a@example.com", msg: Message{To: "b@example.com\r\nBcc: c@example.com"}},
+		{name: "Recipient with display name", from: "a@example.com", msg: Message{To: "Bob <b@example.com>"}},
+		{name: "Subject with line break", from: "a@example.com", msg: Message{To: "b@example.com", Subject: "Hi\r\nBcc: c@example.com"}},
+		{name: "Bad sender", from: "not an address", msg: Message{To: "b@example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.msg.format(tt.from, time.Now())
+			assert.ErrorIs(t, err, ErrInvalidMessage)
+		})
+	}
+}
+
+func TestLog(t *testing.T) {
+	var buf bytes.Buffer
+	mailer := NewLog(&buf, "no-reply@example.com")
+
+	require.NoError(t, mailer.Send(context.Background(), Message{To: "jane@example.com", Subject: "One", Body: "first"}))
+	require.NoError(t, mailer.Send(context.Background(), Message{To: "joe@example.com", Subject: "Two", Body: "second"}))
+
+	out := buf.String()
+	assert.Contains(t, out, "To: jane@example.com")
+	assert.Contains(t, out, "To: joe@example.com")
+	assert.Equal(t, 2, strings.Count(out, "----------------------------------------"))
+
+	err := mailer.Send(context.Background(), Message{To: "bad\naddress"})
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+}
+
+func TestNewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.log")
+
+	mailer, err := NewFile(path, "no-reply@example.com")
+	require.NoError(t, err)
+	require.NoError(t, mailer.Send(context.Background(), Message{To: "jane@example.com", Subject: "One", Body: "first"}))
+
+	// Reopening appends instead of truncating
+	mailer, err = NewFile(path, "no-reply@example.com")
+	require.NoError(t, err)
+	require.NoError(t, mailer.Send(context.Background(), Message{To: "joe@example.com", Subject: "Two", Body: "second"}))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "To: jane@example.com")
+	assert.Contains(t, string(data), "To: joe@example.com")
+}
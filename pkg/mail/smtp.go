@@ -0,0 +1,98 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// DefaultTimeout bounds the exchange with the SMTP server when neither the
+// mailer nor the caller's context sets a shorter limit.
+const DefaultTimeout = 30 * time.Second
+
+// SMTP sends emails through an SMTP server. The connection is upgraded
+// with STARTTLS whenever the server offers it, and authenticates with
+// PLAIN auth when a username is set.
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// From is the sender address of every email.
+	From string
+	// Timeout bounds each send, so a hung server cannot hang the request
+	// sending the email. DefaultTimeout is used when it is not positive.
+	Timeout time.Duration
+}
+
+// NewSMTP returns a mailer sending through the SMTP server at host:port.
+func NewSMTP(host string, port int, username, password, from string) *SMTP {
+	return &SMTP{Host: host, Port: port, Username: username, Password: password, From: from, Timeout: DefaultTimeout}
+}
+
+var _ Mailer = (*SMTP)(nil)
+
+// Send delivers msg over a new connection. The context and the mailer's
+// timeout, whichever ends first, bound the whole exchange with the server.
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	data, err := msg.format(s.From, time.Now())
+	if err != nil {
+		return err
+	}
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(s.Host, strconv.Itoa(s.Port)))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(s.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
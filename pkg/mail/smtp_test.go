@@ -0,0 +1,117 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSMTP accepts one connection and records the envelope and data of the
+// email sent over it. It offers no extensions, so no TLS or auth is tried.
+func fakeSMTP(t *testing.T) (host string, port int, received chan []string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	received = make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		var lines []string
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch {
+			case strings.HasPrefix(line, "EHLO"), strings.HasPrefix(line, "HELO"):
+				reply("250 fake")
+			case strings.HasPrefix(line, "MAIL FROM:"), strings.HasPrefix(line, "RCPT TO:"):
+				lines = append(lines, line)
+				reply("250 OK")
+			case line == "DATA":
+				reply("354 go ahead")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					data = strings.TrimRight(data, "\r\n")
+					if data == "." {
+						break
+					}
+					lines = append(lines, data)
+				}
+				reply("250 queued")
+			case line == "QUIT":
+				reply("221 bye")
+				received <- lines
+				return
+			default:
+				reply("502 unsupported")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, received
+}
+
+func TestSMTP_Send(t *testing.T) {
+	host, port, received := fakeSMTP(t)
+	mailer := NewSMTP(host, port, "", "", "no-reply@example.com")
+
+	err := mailer.Send(context.Background(), Message{To: "jane@example.com", Subject: "Welcome", Body: "Hi Jane"})
+	require.NoError(t, err)
+
+	lines := <-received
+	assert.Equal(t, "MAIL FROM:<no-reply@example.com>", lines[0])
+	assert.Equal(t, "RCPT TO:<jane@example.com>", lines[1])
+	assert.Contains(t, lines, "Subject: Welcome")
+	assert.Contains(t, lines, "Hi Jane")
+}
+
+func TestSMTP_SendInvalidMessage(t *testing.T) {
+	// The message is checked before connecting, so nothing listens here
+	mailer := NewSMTP("127.0.0.1", 1, "", "", "no-reply@example.com")
+
+	err := mailer.Send(context.Background(), Message{To: "jane@example.com\r\nBcc: joe@example.com"})
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+}
+
+func TestSMTP_SendTimeout(t *testing.T) {
+	// The server accepts the connection but never greets
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		t.Cleanup(func() { conn.Close() })
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	mailer := NewSMTP(addr.IP.String(), addr.Port, "", "", "no-reply@example.com")
+	mailer.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	err = mailer.Send(context.Background(), Message{To: "jane@example.com", Subject: "Welcome", Body: "Hi Jane"})
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
@@ -25,6 +25,11 @@
 //         Page: 2,          // Overrides default page 1.
 //     }
 //     return response.PaginatedSuccessBuilder(items, pagination).Send(c)
+//
+// // For streamed downloads:
+//     return response.StreamBuilder("text/csv", "report.csv", func(w io.Writer) error {
+//         return writeRows(w)
+//     }).Send(c)
 // This package integrates with OpenTelemetry for tracing and provides consistent
 // error handling across your API endpoints. It supports various response types
 // including success, error, and custom responses with optional metadata.
@@ -35,7 +40,10 @@
 package response
 
 import (
+	"bufio"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 
@@ -241,3 +249,55 @@ func (c SuccessResponse) Send(ctx echo.Context) error {
 	trace.SpanFromContext(ctx.Request().Context()).SetStatus(codes.Ok, http.StatusText(c.StatusCode))
 	return ctx.JSON(c.StatusCode, c)
 }
+
+// StreamResponse is a success response whose body is written incrementally
+// by Write, such as a file download too large to buffer in memory.
+type StreamResponse struct {
+	StatusCode  int
+	ContentType string
+	// Filename, when set, makes the body a download with that name.
+	Filename string
+	Write    func(w io.Writer) error
+}
+
+// StreamBuilder constructs a StreamResponse that streams the body produced by write.
+func StreamBuilder(contentType, filename string, write func(w io.Writer) error) StreamResponse {
+	return StreamResponse{
+		StatusCode:  http.StatusOK,
+		ContentType: contentType,
+		Filename:    filename,
+		Write:       write,
+	}
+}
+
+// Send writes the headers and streams the body using the provided Echo context.
+// Once the body has started the status can no longer change, so a failure
+// while writing aborts the connection and the client sees a truncated
+// transfer rather than a silently incomplete file.
+func (s StreamResponse) Send(ctx echo.Context) error {
+	log := logger.NewLogger()
+	log.Infof("Sending stream response: StatusCode=%d, ContentType=%s, Filename=%s",
+		s.StatusCode, s.ContentType, s.Filename)
+
+	res := ctx.Response()
+	res.Header().Set(echo.HeaderContentType, s.ContentType)
+	if s.Filename != "" {
+		res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", s.Filename))
+	}
+	res.WriteHeader(s.StatusCode)
+
+	span := trace.SpanFromContext(ctx.Request().Context())
+	bw := bufio.NewWriterSize(res, 32<<10)
+	err := s.Write(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		log.Errorf("Aborting stream response: %v", err)
+		span.SetStatus(codes.Error, err.Error())
+		panic(http.ErrAbortHandler)
+	}
+
+	span.SetStatus(codes.Ok, http.StatusText(s.StatusCode))
+	return nil
+}
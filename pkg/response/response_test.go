@@ -2,6 +2,7 @@ package response
 
 import (
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -128,3 +129,35 @@ func TestParsePagination(t *testing.T) {
 	assert.Equal(t, 1, pagination.Page)
 	assert.Equal(t, 10, pagination.PageSize)
 }
+
+func TestStreamResponse_Send(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := StreamBuilder("text/csv", "report.csv", func(w io.Writer) error {
+		_, err := io.WriteString(w, "a,b\n1,2\n")
+		return err
+	}).Send(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, `attachment; filename="report.csv"`, rec.Header().Get(echo.HeaderContentDisposition))
+	assert.Equal(t, "a,b\n1,2\n", rec.Body.String())
+}
+
+func TestStreamResponse_SendAbortsOnError(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	resp := StreamBuilder("text/csv", "", func(w io.Writer) error {
+		return errors.New("database went away")
+	})
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() { _ = resp.Send(c) })
+	assert.Empty(t, rec.Header().Get(echo.HeaderContentDisposition))
+}
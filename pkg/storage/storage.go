@@ -0,0 +1,61 @@
+// Package storage keeps uploaded files as blobs under string keys, on the
+// local filesystem or in an S3-compatible object store.
+//
+// Usage:
+//
+//	store, err := storage.NewLocal("./uploads")
+//	err = store.Put(ctx, "cover.png", r, "image/png")
+//	obj, err := store.Get(ctx, "cover.png")
+//	defer obj.Body.Close()
+//	err = store.Delete(ctx, "cover.png")
+//
+// Keys are made of letters, digits, dots, dashes and underscores, optionally
+// separated by slashes, so they map safely onto both file paths and object
+// names.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ErrNotFound is returned by Get for keys that hold no blob.
+var ErrNotFound = errors.New("storage: blob not found")
+
+// ErrInvalidKey is returned for keys that no Storage accepts.
+var ErrInvalidKey = errors.New("storage: invalid key")
+
+// Storage stores blobs under keys. Putting a key that exists replaces its
+// blob; deleting a key that does not exist is not an error.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	Get(ctx context.Context, key string) (*Object, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Object is a stored blob. The caller must close Body.
+type Object struct {
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64
+}
+
+// keyPattern matches the keys every Storage accepts.
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+(/[A-Za-z0-9._-]+)*$`)
+
+// checkKey rejects keys that could escape the storage root or need escaping.
+func checkKey(key string) error {
+	if !keyPattern.MatchString(key) {
+		return fmt.Errorf("%w %q", ErrInvalidKey, key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "." || part == ".." {
+			return fmt.Errorf("%w %q", ErrInvalidKey, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckKey(t *testing.T) {
+	for _, key := range []string{"cover.png", "polls/12/a_b-c.jpg"} {
+		assert.NoError(t, checkKey(key), key)
+	}
+	for _, key := range []string{"", "../secret", "a/../../b", "/abs", "a//b", "a b.png", "a/./b", "trailing/"} {
+		assert.ErrorIs(t, checkKey(key), ErrInvalidKey, key)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "uploads")
+	store, err := NewLocal(dir)
+	require.NoError(t, err)
+
+	// Put creates nested directories and replaces existing blobs
+	require.NoError(t, store.Put(ctx, "polls/cover.png", strings.NewReader("first"), "image/png"))
+	require.NoError(t, store.Put(ctx, "polls/cover.png", strings.NewReader("second"), "image/png"))
+
+	obj, err := store.Get(ctx, "polls/cover.png")
+	require.NoError(t, err)
+	data, err := io.ReadAll(obj.Body)
+	require.NoError(t, err)
+	require.NoError(t, obj.Body.Close())
+	assert.Equal(t, "second", string(data))
+	assert.Equal(t, "image/png", obj.ContentType)
+	assert.Equal(t, int64(6), obj.Size)
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(filepath.Join(dir, "polls"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	require.NoError(t, store.Delete(ctx, "polls/cover.png"))
+	_, err = store.Get(ctx, "polls/cover.png")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Deleting a missing blob is not an error
+	assert.NoError(t, store.Delete(ctx, "polls/cover.png"))
+
+	assert.Error(t, store.Put(ctx, "../escape.png", strings.NewReader("x"), "image/png"))
+}